@@ -32,4 +32,7 @@ type ProxiedRequestMetric struct {
 	ResponseBackendRoute        string
 	CacheHit                    bool
 	PartOfBatch                 bool
+	Retries                     int64
+	TimedOut                    bool
+	MissingStateFallback        bool
 }
@@ -0,0 +1,6 @@
+-- add retries column, the number of times the request was retried against another backend
+-- after the original backend failed to respond. metrics up until now were never retried.
+ALTER TABLE
+  IF EXISTS proxied_request_metrics
+ADD
+  retries integer NOT NULL DEFAULT 0;
@@ -0,0 +1,7 @@
+-- add timed_out column, whether the backend failed to respond before the timeout
+-- of the request and the proxy responded with a timeout error instead.
+-- metrics up until now never timed out.
+ALTER TABLE
+  IF EXISTS proxied_request_metrics
+ADD
+  timed_out boolean NOT NULL DEFAULT false;
@@ -0,0 +1,7 @@
+-- add missing_state_fallback column, whether the pruning backend the request was routed to
+-- was missing the state for the request and the request was re-issued to the default backend.
+-- metrics up until now never fell back.
+ALTER TABLE
+  IF EXISTS proxied_request_metrics
+ADD
+  missing_state_fallback boolean NOT NULL DEFAULT false;
@@ -26,6 +26,9 @@ type ProxiedRequestMetric struct {
 	ResponseBackendRoute        string
 	CacheHit                    bool
 	PartOfBatch                 bool
+	Retries                     int64
+	TimedOut                    bool
+	MissingStateFallback        bool
 }
 
 func (prm *ProxiedRequestMetric) ToProxiedRequestMetric() *database.ProxiedRequestMetric {
@@ -44,6 +47,9 @@ func (prm *ProxiedRequestMetric) ToProxiedRequestMetric() *database.ProxiedReque
 		ResponseBackendRoute:        prm.ResponseBackendRoute,
 		CacheHit:                    prm.CacheHit,
 		PartOfBatch:                 prm.PartOfBatch,
+		Retries:                     prm.Retries,
+		TimedOut:                    prm.TimedOut,
+		MissingStateFallback:        prm.MissingStateFallback,
 	}
 }
 
@@ -63,5 +69,8 @@ func convertProxiedRequestMetric(metric *database.ProxiedRequestMetric) *Proxied
 		ResponseBackendRoute:        metric.ResponseBackendRoute,
 		CacheHit:                    metric.CacheHit,
 		PartOfBatch:                 metric.PartOfBatch,
+		Retries:                     metric.Retries,
+		TimedOut:                    metric.TimedOut,
+		MissingStateFallback:        metric.MissingStateFallback,
 	}
 }
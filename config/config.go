@@ -6,88 +6,218 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
-	"os"
 	"strconv"
 	"strings"
 	"time"
 )
 
 type Config struct {
-	ProxyServicePort                              string
-	LogLevel                                      string
-	ProxyBackendHostURLMapRaw                     string
-	ProxyBackendHostURLMapParsed                  map[string]url.URL
-	EnableHeightBasedRouting                      bool
-	ProxyPruningBackendHostURLMapRaw              string
-	ProxyPruningBackendHostURLMap                 map[string]url.URL
-	EnableShardedRouting                          bool
-	ProxyShardBackendHostURLMapRaw                string
-	ProxyShardBackendHostURLMap                   map[string]IntervalURLMap
-	ProxyMaximumBatchSize                         int
-	EvmQueryServiceURL                            string
-	DatabaseName                                  string
-	DatabaseEndpointURL                           string
-	DatabaseUserName                              string
-	DatabasePassword                              string
-	DatabaseReadTimeoutSeconds                    int64
-	DatabaseWriteTimeoutSeconds                   int64
-	DatabaseSSLEnabled                            bool
-	DatabaseQueryLoggingEnabled                   bool
-	DatabaseMaxIdleConnections                    int64
-	DatabaseConnectionMaxIdleSeconds              int64
-	DatabaseMaxOpenConnections                    int64
-	RunDatabaseMigrations                         bool
-	HTTPReadTimeoutSeconds                        int64
-	HTTPWriteTimeoutSeconds                       int64
-	MetricCompactionRoutineInterval               time.Duration
-	MetricCollectionEnabled                       bool
-	MetricPartitioningRoutineInterval             time.Duration
-	MetricPartitioningRoutineDelayFirstRun        time.Duration
-	MetricPartitioningPrefillPeriodDays           int
-	MetricPruningEnabled                          bool
-	MetricPruningRoutineInterval                  time.Duration
-	MetricPruningRoutineDelayFirstRun             time.Duration
-	MetricPruningMaxRequestMetricsHistoryDays     int
-	MetricDatabaseEnabled                         bool
-	CacheEnabled                                  bool
-	RedisEndpointURL                              string
-	RedisPassword                                 string
-	CacheMethodHasBlockNumberParamTTL             time.Duration
-	CacheMethodHasBlockHashParamTTL               time.Duration
-	CacheStaticMethodTTL                          time.Duration
-	CacheMethodHasTxHashParamTTL                  time.Duration
-	CachePrefix                                   string
-	WhitelistedHeaders                            []string
-	DefaultAccessControlAllowOriginValue          string
-	HostnameToAccessControlAllowOriginValueMapRaw string
-	HostnameToAccessControlAllowOriginValueMap    map[string]string
+	ProxyServicePort                               string
+	LogLevel                                       string
+	ProxyBackendHostURLMapRaw                      string
+	ProxyBackendHostURLMapParsed                   map[string][]url.URL
+	ProxyBackendHostURLWeightMap                   map[string]BackendWeights
+	ProxyBackendPoolStrategy                       string
+	EnableHeightBasedRouting                       bool
+	ProxyPruningBackendHostURLMapRaw               string
+	ProxyPruningBackendHostURLMap                  map[string][]url.URL
+	ProxyPruningBackendHostURLWeightMap            map[string]BackendWeights
+	ProxyPruningBackendRetentionWindowMapRaw       string
+	ProxyPruningBackendRetentionWindowMap          map[string]uint64
+	EnableShardedRouting                           bool
+	ProxyShardBackendHostURLMapRaw                 string
+	ProxyShardBackendHostURLMap                    map[string]IntervalURLMap
+	ProxyShardDefaultFallbackEnabled               bool
+	EnableMethodRouting                            bool
+	ProxyMethodRoutingBackendHostURLMapRaw         string
+	ProxyMethodRoutingBackendHostURLMap            map[string]MethodRoutingRules
+	ProxyMaximumBatchSize                          int
+	ProxyBackendHealthCheckEnabled                 bool
+	ProxyBackendHealthCheckInterval                time.Duration
+	ProxyBackendHealthCheckTimeout                 time.Duration
+	ProxyBackendHealthCheckMethod                  string
+	ProxyBackendHealthCheckFailureThreshold        int
+	ProxyBackendTransportMapRaw                    string
+	ProxyBackendTransportMap                       map[string]BackendTransportConfig
+	ProxyBackendTimeoutEnabled                     bool
+	ProxyBackendTimeoutMapRaw                      string
+	ProxyBackendTimeoutMap                         map[string]MethodTimeoutRules
+	ProxyQuorumReadEnabled                         bool
+	ProxyQuorumReadMapRaw                          string
+	ProxyQuorumReadMap                             map[string]MethodQuorumRules
+	ProxyBackendCircuitBreakerEnabled              bool
+	ProxyBackendCircuitBreakerFailureThreshold     int
+	ProxyBackendCircuitBreakerCooldown             time.Duration
+	ProxyBackendCircuitBreakerHalfOpenRequests     int
+	ProxyBackendCircuitBreakerSlowRequestThreshold time.Duration
+	ProxyBackendRetryEnabled                       bool
+	ProxyBackendRetryMaxRetries                    int
+	ProxyBackendRetryBackoff                       time.Duration
+	ProxyBackendHedgingEnabled                     bool
+	ProxyBackendHedgingPercentile                  int
+	ProxyBackendHedgingDefaultDelay                time.Duration
+	ProxyBackendHedgingMinDelay                    time.Duration
+	ProxyMissingStateFallbackEnabled               bool
+	ProxyMissingStateErrorPatterns                 []string
+	ProxyBackendHeadTrackingEnabled                bool
+	ProxyBackendHeadTrackingInterval               time.Duration
+	ProxyBackendHeadTrackingTimeout                time.Duration
+	ProxyBackendHeadTrackingMaxLagBlocks           int
+	ProxyStickyFilterRoutingEnabled                bool
+	ProxyStickyFilterRoutingTTL                    time.Duration
+	ProxyConfigFile                                string
+	ProxyRoutingConfigReloadInterval               time.Duration
+	ProxyAdminAPIEnabled                           bool
+	ProxyAdminAPIPort                              string
+	ProxyAdminAPIToken                             string
+	ProxyAdminAPISharedStateEnabled                bool
+	ProxyAdminAPISharedStateSyncInterval           time.Duration
+	ProxyWebsocketEnabled                          bool
+	ProxyWebsocketSharedSubscriptionsEnabled       bool
+	ProxyShadowEnabled                             bool
+	ProxyShadowBackendHostURLMapRaw                string
+	ProxyShadowBackendHostURLMap                   map[string][]url.URL
+	ProxyShadowSampleRate                          float64
+	ProxyShadowTimeout                             time.Duration
+	ProxyShadowMaxInFlight                         int
+	EvmQueryServiceURL                             string
+	DatabaseName                                   string
+	DatabaseEndpointURL                            string
+	DatabaseUserName                               string
+	DatabasePassword                               string
+	DatabaseReadTimeoutSeconds                     int64
+	DatabaseWriteTimeoutSeconds                    int64
+	DatabaseSSLEnabled                             bool
+	DatabaseQueryLoggingEnabled                    bool
+	DatabaseMaxIdleConnections                     int64
+	DatabaseConnectionMaxIdleSeconds               int64
+	DatabaseMaxOpenConnections                     int64
+	RunDatabaseMigrations                          bool
+	HTTPReadTimeoutSeconds                         int64
+	HTTPWriteTimeoutSeconds                        int64
+	MetricCompactionRoutineInterval                time.Duration
+	MetricCollectionEnabled                        bool
+	MetricPartitioningRoutineInterval              time.Duration
+	MetricPartitioningRoutineDelayFirstRun         time.Duration
+	MetricPartitioningPrefillPeriodDays            int
+	MetricPruningEnabled                           bool
+	MetricPruningRoutineInterval                   time.Duration
+	MetricPruningRoutineDelayFirstRun              time.Duration
+	MetricPruningMaxRequestMetricsHistoryDays      int
+	MetricDatabaseEnabled                          bool
+	CacheEnabled                                   bool
+	RedisEndpointURL                               string
+	RedisPassword                                  string
+	CacheMethodHasBlockNumberParamTTL              time.Duration
+	CacheMethodHasBlockHashParamTTL                time.Duration
+	CacheStaticMethodTTL                           time.Duration
+	CacheMethodHasTxHashParamTTL                   time.Duration
+	CachePrefix                                    string
+	WhitelistedHeaders                             []string
+	DefaultAccessControlAllowOriginValue           string
+	HostnameToAccessControlAllowOriginValueMapRaw  string
+	HostnameToAccessControlAllowOriginValueMap     map[string]string
 }
 
 const (
-	LOG_LEVEL_ENVIRONMENT_KEY                          = "LOG_LEVEL"
-	DEFAULT_LOG_LEVEL                                  = "INFO"
-	PROXY_BACKEND_HOST_URL_MAP_ENVIRONMENT_KEY         = "PROXY_BACKEND_HOST_URL_MAP"
-	PROXY_HEIGHT_BASED_ROUTING_ENABLED_KEY             = "PROXY_HEIGHT_BASED_ROUTING_ENABLED"
-	PROXY_PRUNING_BACKEND_HOST_URL_MAP_ENVIRONMENT_KEY = "PROXY_PRUNING_BACKEND_HOST_URL_MAP"
-	PROXY_SHARDED_ROUTING_ENABLED_ENVIRONMENT_KEY      = "PROXY_SHARDED_ROUTING_ENABLED"
-	PROXY_SHARD_BACKEND_HOST_URL_MAP_ENVIRONMENT_KEY   = "PROXY_SHARD_BACKEND_HOST_URL_MAP"
-	PROXY_MAXIMUM_BATCH_SIZE_ENVIRONMENT_KEY           = "PROXY_MAXIMUM_REQ_BATCH_SIZE"
-	DEFAULT_PROXY_MAXIMUM_BATCH_SIZE                   = 500
-	PROXY_SERVICE_PORT_ENVIRONMENT_KEY                 = "PROXY_SERVICE_PORT"
-	DATABASE_NAME_ENVIRONMENT_KEY                      = "DATABASE_NAME"
-	DATABASE_ENDPOINT_URL_ENVIRONMENT_KEY              = "DATABASE_ENDPOINT_URL"
-	DATABASE_USERNAME_ENVIRONMENT_KEY                  = "DATABASE_USERNAME"
-	DATABASE_PASSWORD_ENVIRONMENT_KEY                  = "DATABASE_PASSWORD"
-	DATABASE_SSL_ENABLED_ENVIRONMENT_KEY               = "DATABASE_SSL_ENABLED"
-	DATABASE_QUERY_LOGGING_ENABLED_ENVIRONMENT_KEY     = "DATABASE_QUERY_LOGGING_ENABLED"
-	RUN_DATABASE_MIGRATIONS_ENVIRONMENT_KEY            = "RUN_DATABASE_MIGRATIONS"
-	DEFAULT_HTTP_READ_TIMEOUT                          = 30
-	DEFAULT_HTTP_WRITE_TIMEOUT                         = 60
-	HTTP_READ_TIMEOUT_ENVIRONMENT_KEY                  = "HTTP_READ_TIMEOUT_SECONDS"
-	HTTP_WRITE_TIMEOUT_ENVIRONMENT_KEY                 = "HTTP_WRITE_TIMEOUT_SECONDS"
-	METRIC_COMPACTION_ROUTINE_INTERVAL_ENVIRONMENT_KEY = "METRIC_COMPACTION_ROUTINE_INTERVAL_SECONDS"
-	METRIC_COLLECTION_ENABLED_ENVIRONMENT_KEY          = "METRIC_COLLECTION_ENABLED"
-	DEFAULT_METRIC_COLLECTION_ENABLED                  = true
+	LOG_LEVEL_ENVIRONMENT_KEY                                               = "LOG_LEVEL"
+	DEFAULT_LOG_LEVEL                                                       = "INFO"
+	PROXY_BACKEND_HOST_URL_MAP_ENVIRONMENT_KEY                              = "PROXY_BACKEND_HOST_URL_MAP"
+	PROXY_BACKEND_POOL_STRATEGY_ENVIRONMENT_KEY                             = "PROXY_BACKEND_POOL_STRATEGY"
+	DEFAULT_PROXY_BACKEND_POOL_STRATEGY                                     = BackendPoolStrategyRoundRobin
+	PROXY_HEIGHT_BASED_ROUTING_ENABLED_KEY                                  = "PROXY_HEIGHT_BASED_ROUTING_ENABLED"
+	PROXY_PRUNING_BACKEND_HOST_URL_MAP_ENVIRONMENT_KEY                      = "PROXY_PRUNING_BACKEND_HOST_URL_MAP"
+	PROXY_PRUNING_BACKEND_RETENTION_WINDOW_MAP_ENVIRONMENT_KEY              = "PROXY_PRUNING_BACKEND_RETENTION_WINDOW_MAP"
+	PROXY_SHARDED_ROUTING_ENABLED_ENVIRONMENT_KEY                           = "PROXY_SHARDED_ROUTING_ENABLED"
+	PROXY_SHARD_BACKEND_HOST_URL_MAP_ENVIRONMENT_KEY                        = "PROXY_SHARD_BACKEND_HOST_URL_MAP"
+	PROXY_SHARD_DEFAULT_FALLBACK_ENABLED_ENVIRONMENT_KEY                    = "PROXY_SHARD_DEFAULT_FALLBACK_ENABLED"
+	PROXY_METHOD_ROUTING_ENABLED_ENVIRONMENT_KEY                            = "PROXY_METHOD_ROUTING_ENABLED"
+	PROXY_METHOD_ROUTING_BACKEND_HOST_URL_MAP_ENVIRONMENT_KEY               = "PROXY_METHOD_ROUTING_BACKEND_HOST_URL_MAP"
+	PROXY_MAXIMUM_BATCH_SIZE_ENVIRONMENT_KEY                                = "PROXY_MAXIMUM_REQ_BATCH_SIZE"
+	DEFAULT_PROXY_MAXIMUM_BATCH_SIZE                                        = 500
+	PROXY_BACKEND_HEALTHCHECK_ENABLED_ENVIRONMENT_KEY                       = "PROXY_BACKEND_HEALTHCHECK_ENABLED"
+	PROXY_BACKEND_HEALTHCHECK_INTERVAL_SECONDS_ENVIRONMENT_KEY              = "PROXY_BACKEND_HEALTHCHECK_INTERVAL_SECONDS"
+	DEFAULT_PROXY_BACKEND_HEALTHCHECK_INTERVAL_SECONDS                      = 10
+	PROXY_BACKEND_HEALTHCHECK_TIMEOUT_SECONDS_ENVIRONMENT_KEY               = "PROXY_BACKEND_HEALTHCHECK_TIMEOUT_SECONDS"
+	DEFAULT_PROXY_BACKEND_HEALTHCHECK_TIMEOUT_SECONDS                       = 5
+	PROXY_BACKEND_HEALTHCHECK_METHOD_ENVIRONMENT_KEY                        = "PROXY_BACKEND_HEALTHCHECK_METHOD"
+	DEFAULT_PROXY_BACKEND_HEALTHCHECK_METHOD                                = "eth_blockNumber"
+	PROXY_BACKEND_HEALTHCHECK_FAILURE_THRESHOLD_ENVIRONMENT_KEY             = "PROXY_BACKEND_HEALTHCHECK_FAILURE_THRESHOLD"
+	DEFAULT_PROXY_BACKEND_HEALTHCHECK_FAILURE_THRESHOLD                     = 3
+	PROXY_BACKEND_TRANSPORT_MAP_ENVIRONMENT_KEY                             = "PROXY_BACKEND_TRANSPORT_MAP"
+	PROXY_BACKEND_TIMEOUT_ENABLED_ENVIRONMENT_KEY                           = "PROXY_BACKEND_TIMEOUT_ENABLED"
+	PROXY_BACKEND_TIMEOUT_MAP_ENVIRONMENT_KEY                               = "PROXY_BACKEND_TIMEOUT_MAP"
+	PROXY_QUORUM_READ_ENABLED_ENVIRONMENT_KEY                               = "PROXY_QUORUM_READ_ENABLED"
+	PROXY_QUORUM_READ_MAP_ENVIRONMENT_KEY                                   = "PROXY_QUORUM_READ_MAP"
+	PROXY_BACKEND_CIRCUIT_BREAKER_ENABLED_ENVIRONMENT_KEY                   = "PROXY_BACKEND_CIRCUIT_BREAKER_ENABLED"
+	PROXY_BACKEND_CIRCUIT_BREAKER_FAILURE_THRESHOLD_ENVIRONMENT_KEY         = "PROXY_BACKEND_CIRCUIT_BREAKER_FAILURE_THRESHOLD"
+	DEFAULT_PROXY_BACKEND_CIRCUIT_BREAKER_FAILURE_THRESHOLD                 = 5
+	PROXY_BACKEND_CIRCUIT_BREAKER_COOLDOWN_SECONDS_ENVIRONMENT_KEY          = "PROXY_BACKEND_CIRCUIT_BREAKER_COOLDOWN_SECONDS"
+	DEFAULT_PROXY_BACKEND_CIRCUIT_BREAKER_COOLDOWN_SECONDS                  = 30
+	PROXY_BACKEND_CIRCUIT_BREAKER_HALF_OPEN_REQUESTS_ENVIRONMENT_KEY        = "PROXY_BACKEND_CIRCUIT_BREAKER_HALF_OPEN_REQUESTS"
+	DEFAULT_PROXY_BACKEND_CIRCUIT_BREAKER_HALF_OPEN_REQUESTS                = 3
+	PROXY_BACKEND_CIRCUIT_BREAKER_SLOW_REQUEST_THRESHOLD_MS_ENVIRONMENT_KEY = "PROXY_BACKEND_CIRCUIT_BREAKER_SLOW_REQUEST_THRESHOLD_MILLISECONDS"
+	DEFAULT_PROXY_BACKEND_CIRCUIT_BREAKER_SLOW_REQUEST_THRESHOLD_MS         = 0
+	PROXY_BACKEND_RETRY_ENABLED_ENVIRONMENT_KEY                             = "PROXY_BACKEND_RETRY_ENABLED"
+	PROXY_BACKEND_RETRY_MAX_RETRIES_ENVIRONMENT_KEY                         = "PROXY_BACKEND_RETRY_MAX_RETRIES"
+	DEFAULT_PROXY_BACKEND_RETRY_MAX_RETRIES                                 = 2
+	PROXY_BACKEND_RETRY_BACKOFF_MILLISECONDS_ENVIRONMENT_KEY                = "PROXY_BACKEND_RETRY_BACKOFF_MILLISECONDS"
+	DEFAULT_PROXY_BACKEND_RETRY_BACKOFF_MILLISECONDS                        = 50
+	PROXY_BACKEND_HEDGING_ENABLED_ENVIRONMENT_KEY                           = "PROXY_BACKEND_HEDGING_ENABLED"
+	PROXY_BACKEND_HEDGING_PERCENTILE_ENVIRONMENT_KEY                        = "PROXY_BACKEND_HEDGING_PERCENTILE"
+	DEFAULT_PROXY_BACKEND_HEDGING_PERCENTILE                                = 95
+	PROXY_BACKEND_HEDGING_DEFAULT_DELAY_MILLISECONDS_ENVIRONMENT_KEY        = "PROXY_BACKEND_HEDGING_DEFAULT_DELAY_MILLISECONDS"
+	DEFAULT_PROXY_BACKEND_HEDGING_DEFAULT_DELAY_MILLISECONDS                = 500
+	PROXY_BACKEND_HEDGING_MIN_DELAY_MILLISECONDS_ENVIRONMENT_KEY            = "PROXY_BACKEND_HEDGING_MIN_DELAY_MILLISECONDS"
+	DEFAULT_PROXY_BACKEND_HEDGING_MIN_DELAY_MILLISECONDS                    = 20
+	PROXY_MISSING_STATE_FALLBACK_ENABLED_ENVIRONMENT_KEY                    = "PROXY_MISSING_STATE_FALLBACK_ENABLED"
+	PROXY_MISSING_STATE_ERROR_PATTERNS_ENVIRONMENT_KEY                      = "PROXY_MISSING_STATE_ERROR_PATTERNS"
+	DEFAULT_PROXY_MISSING_STATE_ERROR_PATTERNS                              = "missing trie node,header not found"
+	PROXY_BACKEND_HEAD_TRACKING_ENABLED_ENVIRONMENT_KEY                     = "PROXY_BACKEND_HEAD_TRACKING_ENABLED"
+	PROXY_BACKEND_HEAD_TRACKING_INTERVAL_SECONDS_ENVIRONMENT_KEY            = "PROXY_BACKEND_HEAD_TRACKING_INTERVAL_SECONDS"
+	DEFAULT_PROXY_BACKEND_HEAD_TRACKING_INTERVAL_SECONDS                    = 5
+	PROXY_BACKEND_HEAD_TRACKING_TIMEOUT_SECONDS_ENVIRONMENT_KEY             = "PROXY_BACKEND_HEAD_TRACKING_TIMEOUT_SECONDS"
+	DEFAULT_PROXY_BACKEND_HEAD_TRACKING_TIMEOUT_SECONDS                     = 5
+	PROXY_BACKEND_HEAD_TRACKING_MAX_LAG_BLOCKS_ENVIRONMENT_KEY              = "PROXY_BACKEND_HEAD_TRACKING_MAX_LAG_BLOCKS"
+	DEFAULT_PROXY_BACKEND_HEAD_TRACKING_MAX_LAG_BLOCKS                      = 10
+	PROXY_STICKY_FILTER_ROUTING_ENABLED_ENVIRONMENT_KEY                     = "PROXY_STICKY_FILTER_ROUTING_ENABLED"
+	PROXY_STICKY_FILTER_ROUTING_TTL_SECONDS_ENVIRONMENT_KEY                 = "PROXY_STICKY_FILTER_ROUTING_TTL_SECONDS"
+	DEFAULT_PROXY_STICKY_FILTER_ROUTING_TTL_SECONDS                         = 300
+	PROXY_CONFIG_FILE_ENVIRONMENT_KEY                                       = "PROXY_CONFIG_FILE"
+	PROXY_ROUTING_CONFIG_RELOAD_INTERVAL_SECONDS_ENVIRONMENT_KEY            = "PROXY_ROUTING_CONFIG_RELOAD_INTERVAL_SECONDS"
+	DEFAULT_PROXY_ROUTING_CONFIG_RELOAD_INTERVAL_SECONDS                    = 10
+	PROXY_ADMIN_API_ENABLED_ENVIRONMENT_KEY                                 = "PROXY_ADMIN_API_ENABLED"
+	PROXY_ADMIN_API_PORT_ENVIRONMENT_KEY                                    = "PROXY_ADMIN_API_PORT"
+	DEFAULT_PROXY_ADMIN_API_PORT                                            = "7780"
+	PROXY_ADMIN_API_TOKEN_ENVIRONMENT_KEY                                   = "PROXY_ADMIN_API_TOKEN"
+	PROXY_ADMIN_API_SHARED_STATE_ENABLED_ENVIRONMENT_KEY                    = "PROXY_ADMIN_API_SHARED_STATE_ENABLED"
+	PROXY_ADMIN_API_SHARED_STATE_SYNC_INTERVAL_SECONDS_ENVIRONMENT_KEY      = "PROXY_ADMIN_API_SHARED_STATE_SYNC_INTERVAL_SECONDS"
+	DEFAULT_PROXY_ADMIN_API_SHARED_STATE_SYNC_INTERVAL_SECONDS              = 5
+	PROXY_WEBSOCKET_ENABLED_ENVIRONMENT_KEY                                 = "PROXY_WEBSOCKET_ENABLED"
+	PROXY_WEBSOCKET_SHARED_SUBSCRIPTIONS_ENABLED_ENVIRONMENT_KEY            = "PROXY_WEBSOCKET_SHARED_SUBSCRIPTIONS_ENABLED"
+	PROXY_SHADOW_ENABLED_ENVIRONMENT_KEY                                    = "PROXY_SHADOW_ENABLED"
+	PROXY_SHADOW_BACKEND_HOST_URL_MAP_ENVIRONMENT_KEY                       = "PROXY_SHADOW_BACKEND_HOST_URL_MAP"
+	PROXY_SHADOW_SAMPLE_RATE_ENVIRONMENT_KEY                                = "PROXY_SHADOW_SAMPLE_RATE"
+	DEFAULT_PROXY_SHADOW_SAMPLE_RATE                                        = 0.01
+	PROXY_SHADOW_TIMEOUT_SECONDS_ENVIRONMENT_KEY                            = "PROXY_SHADOW_TIMEOUT_SECONDS"
+	DEFAULT_PROXY_SHADOW_TIMEOUT_SECONDS                                    = 10
+	PROXY_SHADOW_MAX_IN_FLIGHT_ENVIRONMENT_KEY                              = "PROXY_SHADOW_MAX_IN_FLIGHT"
+	DEFAULT_PROXY_SHADOW_MAX_IN_FLIGHT                                      = 100
+	PROXY_SERVICE_PORT_ENVIRONMENT_KEY                                      = "PROXY_SERVICE_PORT"
+	DATABASE_NAME_ENVIRONMENT_KEY                                           = "DATABASE_NAME"
+	DATABASE_ENDPOINT_URL_ENVIRONMENT_KEY                                   = "DATABASE_ENDPOINT_URL"
+	DATABASE_USERNAME_ENVIRONMENT_KEY                                       = "DATABASE_USERNAME"
+	DATABASE_PASSWORD_ENVIRONMENT_KEY                                       = "DATABASE_PASSWORD"
+	DATABASE_SSL_ENABLED_ENVIRONMENT_KEY                                    = "DATABASE_SSL_ENABLED"
+	DATABASE_QUERY_LOGGING_ENABLED_ENVIRONMENT_KEY                          = "DATABASE_QUERY_LOGGING_ENABLED"
+	RUN_DATABASE_MIGRATIONS_ENVIRONMENT_KEY                                 = "RUN_DATABASE_MIGRATIONS"
+	DEFAULT_HTTP_READ_TIMEOUT                                               = 30
+	DEFAULT_HTTP_WRITE_TIMEOUT                                              = 60
+	HTTP_READ_TIMEOUT_ENVIRONMENT_KEY                                       = "HTTP_READ_TIMEOUT_SECONDS"
+	HTTP_WRITE_TIMEOUT_ENVIRONMENT_KEY                                      = "HTTP_WRITE_TIMEOUT_SECONDS"
+	METRIC_COMPACTION_ROUTINE_INTERVAL_ENVIRONMENT_KEY                      = "METRIC_COMPACTION_ROUTINE_INTERVAL_SECONDS"
+	METRIC_COLLECTION_ENABLED_ENVIRONMENT_KEY                               = "METRIC_COLLECTION_ENABLED"
+	DEFAULT_METRIC_COLLECTION_ENABLED                                       = true
 	// 60 seconds / minute * 60 minutes = 1 hour
 	DEFAULT_METRIC_COMPACTION_ROUTINE_INTERVAL_SECONDS           = 3600
 	METRIC_PARTITIONING_ROUTINE_INTERVAL_SECONDS_ENVIRONMENT_KEY = "METRIC_PARTITIONING_ROUTINE_INTERVAL_SECONDS"
@@ -133,53 +263,46 @@ const (
 	HOSTNAME_TO_ACCESS_CONTROL_ALLOW_ORIGIN_VALUE_MAP_ENVIRONMENT_KEY = "HOSTNAME_TO_ACCESS_CONTROL_ALLOW_ORIGIN_VALUE_MAP"
 )
 
+// Strategies for choosing which member of a backend pool
+// a request for a host is proxied to
+const (
+	// cycle through the members of the pool in order
+	BackendPoolStrategyRoundRobin = "round-robin"
+	// choose the member with the fewest requests currently in flight
+	BackendPoolStrategyLeastOutstandingRequests = "least-outstanding-requests"
+	// choose two members at random and use the one with fewer requests in flight
+	BackendPoolStrategyRandomTwoChoices = "random-two-choices"
+)
+
 var (
 	ErrEmptyHostMap                  = errors.New("backend host url map is empty")
 	ErrEmptyHostnameToHeaderValueMap = errors.New("hostname to header value map is empty")
+	ErrEmptyRetentionWindowMap       = errors.New("host to retention window map is empty")
 )
 
 // EnvOrDefault fetches an environment variable value, or if not set returns the fallback value
 func EnvOrDefault(key string, fallback string) string {
-	if val, ok := os.LookupEnv(key); ok {
-		return val
-	}
-	return fallback
+	return processEnvironment().orDefault(key, fallback)
 }
 
 // EnvOrDefaultBool fetches a boolean environment variable value, or if not set returns the fallback value
 func EnvOrDefaultBool(key string, fallback bool) bool {
-	if val, ok := os.LookupEnv(key); ok {
-		val, err := strconv.ParseBool(val)
-		if err != nil {
-			return fallback
-		}
-		return val
-	}
-	return fallback
+	return processEnvironment().orDefaultBool(key, fallback)
 }
 
 // EnvOrDefaultInt64 fetches an int64 environment variable value, or if not set returns the fallback value
 func EnvOrDefaultInt64(key string, fallback int64) int64 {
-	if val, ok := os.LookupEnv(key); ok {
-		val, err := strconv.ParseInt(val, 0, 64)
-		if err != nil {
-			return fallback
-		}
-		return val
-	}
-	return fallback
+	return processEnvironment().orDefaultInt64(key, fallback)
 }
 
 // EnvOrDefaultInt fetches an int environment variable value, or if not set returns the fallback value
 func EnvOrDefaultInt(key string, fallback int) int {
-	if val, ok := os.LookupEnv(key); ok {
-		val, err := strconv.Atoi(val)
-		if err != nil {
-			return fallback
-		}
-		return val
-	}
-	return fallback
+	return processEnvironment().orDefaultInt(key, fallback)
+}
+
+// EnvOrDefaultFloat64 fetches a float64 environment variable value, or if not set returns the fallback value
+func EnvOrDefaultFloat64(key string, fallback float64) float64 {
+	return processEnvironment().orDefaultFloat64(key, fallback)
 }
 
 // seperator for a single entry mapping the <host to proxy for> to
@@ -189,18 +312,42 @@ const PROXY_BACKEND_HOST_URL_MAP_ENTRY_DELIMITER = ","
 // seperator for
 const PROXY_BACKEND_HOST_URL_MAP_SUB_COMPONENT_DELIMITER = ">"
 
+// seperator for the members of a pool of equivalent backend servers
+// for a single host
+const PROXY_BACKEND_HOST_URL_MAP_POOL_DELIMITER = "+"
+
+// seperator for the optional weight of a member of a pool
+// e.g. http://kava-canary:8545*5
+const PROXY_BACKEND_HOST_URL_MAP_WEIGHT_DELIMITER = "*"
+
 // ParseRawProxyBackendHostURLMap attempts to parse mappings
-// of hostname to proxy for and the backend servers to proxy
+// of hostname to proxy for and the pool of backend servers to proxy
 // the request to, returning the mapping and error (if any).
-func ParseRawProxyBackendHostURLMap(raw string) (map[string]url.URL, error) {
-	hostURLMap := map[string]url.URL{}
+func ParseRawProxyBackendHostURLMap(raw string) (map[string][]url.URL, error) {
+	hostURLMap, _, err := parseRawProxyBackendHostPoolMap(raw)
+	return hostURLMap, err
+}
+
+// ParseRawProxyBackendHostURLWeightMap attempts to parse the weights of the members
+// of the pools of a backend host url map, returning the weights of the pools
+// of hosts with at least one weighted member and error (if any).
+func ParseRawProxyBackendHostURLWeightMap(raw string) (map[string]BackendWeights, error) {
+	_, hostWeightMap, err := parseRawProxyBackendHostPoolMap(raw)
+	return hostWeightMap, err
+}
+
+// parseRawProxyBackendHostPoolMap attempts to parse mappings of hostname to the pool of backend servers
+// and the weights of the members of the pool, returning the mappings and error (if any).
+func parseRawProxyBackendHostPoolMap(raw string) (map[string][]url.URL, map[string]BackendWeights, error) {
+	hostURLMap := map[string][]url.URL{}
+	hostWeightMap := map[string]BackendWeights{}
 	var combinedErr error
 
 	entries := strings.Split(raw, PROXY_BACKEND_HOST_URL_MAP_ENTRY_DELIMITER)
 
 	if raw == "" || len(entries) < 1 {
 		extraErr := fmt.Errorf("found zero mappings delimited by %s in %s", PROXY_BACKEND_HOST_URL_MAP_ENTRY_DELIMITER, raw)
-		return hostURLMap, errors.Join(ErrEmptyHostMap, extraErr)
+		return hostURLMap, hostWeightMap, errors.Join(ErrEmptyHostMap, extraErr)
 	}
 
 	for _, entry := range entries {
@@ -213,23 +360,51 @@ func ParseRawProxyBackendHostURLMap(raw string) (map[string]url.URL, error) {
 		}
 
 		host := entryComponents[0]
-		rawBackendURL := entryComponents[1]
-		parsedBackendURL, err := url.Parse(rawBackendURL)
+		rawBackendURLs := strings.Split(entryComponents[1], PROXY_BACKEND_HOST_URL_MAP_POOL_DELIMITER)
+		parsedBackendURLs := make([]url.URL, 0, len(rawBackendURLs))
+		weights := BackendWeights{}
+		var invalidEntry bool
+
+		for _, rawBackendURL := range rawBackendURLs {
+			rawBackendURL, rawWeight, weighted := strings.Cut(rawBackendURL, PROXY_BACKEND_HOST_URL_MAP_WEIGHT_DELIMITER)
+			parsedBackendURL, err := url.Parse(rawBackendURL)
+
+			if err != nil || rawBackendURL == "" {
+				invalidEntry = true
+				break
+			}
 
-		if err != nil {
-			combinedErr = errors.Join(combinedErr, fmt.Errorf("expected map value of host to backend url delimited by %s, got %s", PROXY_BACKEND_HOST_URL_MAP_SUB_COMPONENT_DELIMITER, entry))
+			if weighted {
+				weight, err := strconv.Atoi(rawWeight)
+				if err != nil || weight < 1 {
+					combinedErr = errors.Join(combinedErr, fmt.Errorf("invalid weight %s of backend %s for host %s, weights must be positive integers", rawWeight, rawBackendURL, host))
+					invalidEntry = true
+					break
+				}
+				weights[parsedBackendURL.String()] = weight
+			}
+
+			parsedBackendURLs = append(parsedBackendURLs, *parsedBackendURL)
+		}
+
+		if invalidEntry {
+			combinedErr = errors.Join(combinedErr, fmt.Errorf("expected map value of host to backend url(s) delimited by %s, got %s", PROXY_BACKEND_HOST_URL_MAP_SUB_COMPONENT_DELIMITER, entry))
 
 			continue
 		}
 
-		hostURLMap[host] = *parsedBackendURL
+		hostURLMap[host] = parsedBackendURLs
+		if len(weights) > 0 {
+			hostWeightMap[host] = weights
+		}
 	}
 
-	return hostURLMap, combinedErr
+	return hostURLMap, hostWeightMap, combinedErr
 }
 
 // ParseRawShardRoutingBackendHostURLMap attempts to parse backend host URL mapping for shards.
-// The shard map is a map of host name => (map of end block => backend route)
+// The shard map is a map of host name => (map of end block => backend route(s)),
+// where redundant backend routes of a shard are delimited by PROXY_BACKEND_HOST_URL_MAP_POOL_DELIMITER
 // returning the mapping and error (if any)
 func ParseRawShardRoutingBackendHostURLMap(raw string) (map[string]IntervalURLMap, error) {
 	parsed := make(map[string]IntervalURLMap)
@@ -253,7 +428,7 @@ func ParseRawShardRoutingBackendHostURLMap(raw string) (map[string]IntervalURLMa
 		}
 
 		prevMaxHeight := uint64(0)
-		backendByEndHeight := make(map[uint64]*url.URL, len(endpointBackendValues)/2)
+		backendsByEndHeight := make(map[uint64][]*url.URL, len(endpointBackendValues)/2)
 		for i := 0; i < len(endpointBackendValues); i += 2 {
 			endHeight, err := strconv.ParseUint(endpointBackendValues[i], 10, 64)
 			if err != nil || endHeight == 0 {
@@ -262,7 +437,7 @@ func ParseRawShardRoutingBackendHostURLMap(raw string) (map[string]IntervalURLMa
 				)
 			}
 			// ensure this is the only shard defined with this endBlock for this host
-			if _, exists := backendByEndHeight[endHeight]; exists {
+			if _, exists := backendsByEndHeight[endHeight]; exists {
 				return parsed, fmt.Errorf("multiple shards defined for %s with end block %d", host, endHeight)
 			}
 			// require height definitions to be ordered
@@ -275,17 +450,175 @@ func ParseRawShardRoutingBackendHostURLMap(raw string) (map[string]IntervalURLMa
 				)
 			}
 
-			backendRoute, err := url.Parse(endpointBackendValues[i+1])
-			if err != nil || backendRoute.String() == "" {
-				return parsed, fmt.Errorf("invalid shard backend route (%s) for height %d of host %s: %s",
-					endpointBackendValues[i+1], endHeight, host, err,
-				)
+			// a shard may have redundant backends, in order of preference
+			rawBackendRoutes := strings.Split(endpointBackendValues[i+1], PROXY_BACKEND_HOST_URL_MAP_POOL_DELIMITER)
+			backendRoutes := make([]*url.URL, 0, len(rawBackendRoutes))
+			for _, rawBackendRoute := range rawBackendRoutes {
+				backendRoute, err := url.Parse(rawBackendRoute)
+				if err != nil || backendRoute.String() == "" {
+					return parsed, fmt.Errorf("invalid shard backend route (%s) for height %d of host %s: %s",
+						rawBackendRoute, endHeight, host, err,
+					)
+				}
+				backendRoutes = append(backendRoutes, backendRoute)
 			}
-			backendByEndHeight[endHeight] = backendRoute
+			backendsByEndHeight[endHeight] = backendRoutes
 			prevMaxHeight = endHeight
 		}
 
-		parsed[host] = NewIntervalURLMap(backendByEndHeight)
+		parsed[host] = NewIntervalURLPoolMap(backendsByEndHeight)
+	}
+
+	return parsed, nil
+}
+
+// ParseRawMethodRoutingBackendHostURLMap attempts to parse mappings of host to the method routing rules of the host
+// e.g. "evm.kava.io>debug_*|http://kava-tracing-1:8545+http://kava-tracing-2:8545|txpool_content|http://kava-tracing-1:8545"
+// returning the mapping and error (if any)
+func ParseRawMethodRoutingBackendHostURLMap(raw string) (map[string]MethodRoutingRules, error) {
+	parsed := make(map[string]MethodRoutingRules)
+	// allow empty method routing map (enabled but unused)
+	if raw == "" {
+		return parsed, nil
+	}
+	for _, hc := range strings.Split(raw, PROXY_BACKEND_HOST_URL_MAP_ENTRY_DELIMITER) {
+		pieces := strings.Split(hc, PROXY_BACKEND_HOST_URL_MAP_SUB_COMPONENT_DELIMITER)
+		if len(pieces) != 2 {
+			return parsed, fmt.Errorf("expected method routing definition like <host>>(<method>|<backend-route(s)>)+, found '%s'", hc)
+		}
+
+		host := pieces[0]
+		methodBackendValues := strings.Split(pieces[1], "|")
+		if len(methodBackendValues)%2 != 0 {
+			return parsed, fmt.Errorf("unexpected <method>|<backend-route(s)> sequence for %s: %s",
+				host, pieces[1],
+			)
+		}
+
+		rules := make(MethodRoutingRules, 0, len(methodBackendValues)/2)
+		for i := 0; i < len(methodBackendValues); i += 2 {
+			pattern := methodBackendValues[i]
+			if pattern == "" || pattern == MethodRoutingRuleWildcard {
+				return parsed, fmt.Errorf("invalid method routing pattern (%s) for host %s", pattern, host)
+			}
+
+			rawBackendURLs := strings.Split(methodBackendValues[i+1], PROXY_BACKEND_HOST_URL_MAP_POOL_DELIMITER)
+			backendURLs := make([]url.URL, 0, len(rawBackendURLs))
+			for _, rawBackendURL := range rawBackendURLs {
+				backendURL, err := url.Parse(rawBackendURL)
+				if err != nil || rawBackendURL == "" {
+					return parsed, fmt.Errorf("invalid method routing backend route (%s) for method %s of host %s: %s",
+						rawBackendURL, pattern, host, err,
+					)
+				}
+				backendURLs = append(backendURLs, *backendURL)
+			}
+
+			rules = append(rules, MethodRoutingRule{Pattern: pattern, BackendURLs: backendURLs})
+		}
+
+		parsed[host] = rules
+	}
+
+	return parsed, nil
+}
+
+// ParseRawBackendTimeoutMap attempts to parse mappings of host to the timeout rules of the host,
+// the rules of BackendTimeoutMapAnyHost apply to requests for every host
+// e.g. "*>debug_*|120|*|30,evm.kava.io>eth_call|10"
+// returning the mapping and error (if any)
+func ParseRawBackendTimeoutMap(raw string) (map[string]MethodTimeoutRules, error) {
+	parsed := make(map[string]MethodTimeoutRules)
+	// allow empty timeout map (enabled but unused)
+	if raw == "" {
+		return parsed, nil
+	}
+	for _, hc := range strings.Split(raw, PROXY_BACKEND_HOST_URL_MAP_ENTRY_DELIMITER) {
+		pieces := strings.Split(hc, PROXY_BACKEND_HOST_URL_MAP_SUB_COMPONENT_DELIMITER)
+		if len(pieces) != 2 {
+			return parsed, fmt.Errorf("expected timeout definition like <host>>(<method>|<timeout-seconds>)+, found '%s'", hc)
+		}
+
+		host := pieces[0]
+		methodTimeoutValues := strings.Split(pieces[1], "|")
+		if len(methodTimeoutValues)%2 != 0 {
+			return parsed, fmt.Errorf("unexpected <method>|<timeout-seconds> sequence for %s: %s",
+				host, pieces[1],
+			)
+		}
+
+		rules := make(MethodTimeoutRules, 0, len(methodTimeoutValues)/2)
+		for i := 0; i < len(methodTimeoutValues); i += 2 {
+			pattern := methodTimeoutValues[i]
+			if pattern == "" {
+				return parsed, fmt.Errorf("invalid timeout method pattern (%s) for host %s", pattern, host)
+			}
+
+			seconds, err := strconv.Atoi(methodTimeoutValues[i+1])
+			if err != nil || seconds < 1 {
+				return parsed, fmt.Errorf("invalid timeout (%s) for method %s of host %s, must be a positive number of seconds",
+					methodTimeoutValues[i+1], pattern, host,
+				)
+			}
+
+			rules = append(rules, MethodTimeoutRule{Pattern: pattern, Timeout: time.Duration(seconds) * time.Second})
+		}
+
+		parsed[host] = rules
+	}
+
+	return parsed, nil
+}
+
+// ParseRawQuorumReadMap attempts to parse mappings of host to the quorum rules of the host,
+// the rules of QuorumReadMapAnyHost apply to requests for every host
+// e.g. "*>eth_getTransactionReceipt|3|2,evm.kava.io>eth_getBalance|5|3"
+// returning the mapping and error (if any)
+func ParseRawQuorumReadMap(raw string) (map[string]MethodQuorumRules, error) {
+	parsed := make(map[string]MethodQuorumRules)
+	// allow empty quorum map (enabled but unused)
+	if raw == "" {
+		return parsed, nil
+	}
+	for _, hc := range strings.Split(raw, PROXY_BACKEND_HOST_URL_MAP_ENTRY_DELIMITER) {
+		pieces := strings.Split(hc, PROXY_BACKEND_HOST_URL_MAP_SUB_COMPONENT_DELIMITER)
+		if len(pieces) != 2 {
+			return parsed, fmt.Errorf("expected quorum definition like <host>>(<method>|<backends>|<agreements>)+, found '%s'", hc)
+		}
+
+		host := pieces[0]
+		methodQuorumValues := strings.Split(pieces[1], "|")
+		if len(methodQuorumValues)%3 != 0 {
+			return parsed, fmt.Errorf("unexpected <method>|<backends>|<agreements> sequence for %s: %s",
+				host, pieces[1],
+			)
+		}
+
+		rules := make(MethodQuorumRules, 0, len(methodQuorumValues)/3)
+		for i := 0; i < len(methodQuorumValues); i += 3 {
+			pattern := methodQuorumValues[i]
+			if pattern == "" {
+				return parsed, fmt.Errorf("invalid quorum method pattern (%s) for host %s", pattern, host)
+			}
+
+			backends, err := strconv.Atoi(methodQuorumValues[i+1])
+			if err != nil || backends < 1 {
+				return parsed, fmt.Errorf("invalid number of backends (%s) for method %s of host %s, must be a positive number",
+					methodQuorumValues[i+1], pattern, host,
+				)
+			}
+
+			agreements, err := strconv.Atoi(methodQuorumValues[i+2])
+			if err != nil || agreements < 1 || agreements > backends {
+				return parsed, fmt.Errorf("invalid number of agreements (%s) for method %s of host %s, must be between 1 and the number of backends",
+					methodQuorumValues[i+2], pattern, host,
+				)
+			}
+
+			rules = append(rules, MethodQuorumRule{Pattern: pattern, Backends: backends, Agreements: agreements})
+		}
+
+		parsed[host] = rules
 	}
 
 	return parsed, nil
@@ -322,81 +655,208 @@ func ParseRawHostnameToHeaderValueMap(raw string) (map[string]string, error) {
 	return hostnameToHeaderValueMap, combinedErr
 }
 
-// ReadConfig attempts to parse service config from environment values
-// the returned config may be invalid and should be validated via the `Validate`
-// function of the Config package before use
-func ReadConfig() Config {
-	rawProxyBackendHostURLMap := os.Getenv(PROXY_BACKEND_HOST_URL_MAP_ENVIRONMENT_KEY)
-	rawProxyPruningBackendHostURLMap := os.Getenv(PROXY_PRUNING_BACKEND_HOST_URL_MAP_ENVIRONMENT_KEY)
-	rawProxyShardedBackendHostURLMap := os.Getenv(PROXY_SHARD_BACKEND_HOST_URL_MAP_ENVIRONMENT_KEY)
+// ParseRawRetentionWindowMap attempts to parse mappings of hostname to the number of
+// most recent blocks the pruning backends for the host retain, e.g. "evm.kava.io>100000"
+// returning the mapping and error (if any)
+func ParseRawRetentionWindowMap(raw string) (map[string]uint64, error) {
+	retentionWindowMap := map[string]uint64{}
+	var combinedErr error
+
+	if raw == "" {
+		extraErr := fmt.Errorf("found zero mappings delimited by %s in %s", PROXY_BACKEND_HOST_URL_MAP_ENTRY_DELIMITER, raw)
+		return retentionWindowMap, errors.Join(ErrEmptyRetentionWindowMap, extraErr)
+	}
+
+	for _, entry := range strings.Split(raw, PROXY_BACKEND_HOST_URL_MAP_ENTRY_DELIMITER) {
+		entryComponents := strings.Split(entry, PROXY_BACKEND_HOST_URL_MAP_SUB_COMPONENT_DELIMITER)
+
+		if len(entryComponents) != 2 {
+			combinedErr = errors.Join(combinedErr, fmt.Errorf("expected map value of hostname to retention window delimited by %s, got %s", PROXY_BACKEND_HOST_URL_MAP_SUB_COMPONENT_DELIMITER, entry))
+
+			continue
+		}
+
+		hostname := entryComponents[0]
+		window, err := strconv.ParseUint(entryComponents[1], 10, 64)
+		if err != nil || window == 0 {
+			combinedErr = errors.Join(combinedErr, fmt.Errorf("expected retention window of %s to be a positive number of blocks, got %s", hostname, entryComponents[1]))
+
+			continue
+		}
+
+		retentionWindowMap[hostname] = window
+	}
+
+	return retentionWindowMap, combinedErr
+}
+
+// ReadConfig attempts to parse service config from the environment of the process
+// and the config file whose path is set in it, as for ReadConfigFrom
+func ReadConfig() (Config, error) {
+	return ReadConfigFrom(EnvironmentConfigSources())
+}
+
+// ReadConfigFrom attempts to parse service config from the sources, returning the config
+// and an error if the config file of the sources failed to load, in which case
+// the config is read from the environment only. The returned config may be invalid and
+// should be validated via the `Validate` function of the Config package before use
+func ReadConfigFrom(sources ConfigSources) (Config, error) {
+	values, configFileHosts, loadErr := sources.load()
+
+	rawProxyBackendHostURLMap := values.get(PROXY_BACKEND_HOST_URL_MAP_ENVIRONMENT_KEY)
+	rawProxyPruningBackendHostURLMap := values.get(PROXY_PRUNING_BACKEND_HOST_URL_MAP_ENVIRONMENT_KEY)
+	rawProxyShardedBackendHostURLMap := values.get(PROXY_SHARD_BACKEND_HOST_URL_MAP_ENVIRONMENT_KEY)
+	rawProxyPruningBackendRetentionWindowMap := values.get(PROXY_PRUNING_BACKEND_RETENTION_WINDOW_MAP_ENVIRONMENT_KEY)
+	rawProxyMethodRoutingBackendHostURLMap := values.get(PROXY_METHOD_ROUTING_BACKEND_HOST_URL_MAP_ENVIRONMENT_KEY)
+	rawProxyShadowBackendHostURLMap := values.get(PROXY_SHADOW_BACKEND_HOST_URL_MAP_ENVIRONMENT_KEY)
+	rawProxyBackendTransportMap := values.get(PROXY_BACKEND_TRANSPORT_MAP_ENVIRONMENT_KEY)
+	rawProxyBackendTimeoutMap := values.get(PROXY_BACKEND_TIMEOUT_MAP_ENVIRONMENT_KEY)
+	rawProxyQuorumReadMap := values.get(PROXY_QUORUM_READ_MAP_ENVIRONMENT_KEY)
 	// best effort to parse, callers are responsible for validating
 	// before using any values read
 	parsedProxyBackendHostURLMap, _ := ParseRawProxyBackendHostURLMap(rawProxyBackendHostURLMap)
 	parsedProxyPruningBackendHostURLMap, _ := ParseRawProxyBackendHostURLMap(rawProxyPruningBackendHostURLMap)
+	parsedProxyBackendHostURLWeightMap, _ := ParseRawProxyBackendHostURLWeightMap(rawProxyBackendHostURLMap)
+	parsedProxyPruningBackendHostURLWeightMap, _ := ParseRawProxyBackendHostURLWeightMap(rawProxyPruningBackendHostURLMap)
 	parsedProxyShardedBackendHostURLMap, _ := ParseRawShardRoutingBackendHostURLMap(rawProxyShardedBackendHostURLMap)
-
-	whitelistedHeaders := os.Getenv(WHITELISTED_HEADERS_ENVIRONMENT_KEY)
+	parsedProxyPruningBackendRetentionWindowMap, _ := ParseRawRetentionWindowMap(rawProxyPruningBackendRetentionWindowMap)
+	parsedProxyMethodRoutingBackendHostURLMap, _ := ParseRawMethodRoutingBackendHostURLMap(rawProxyMethodRoutingBackendHostURLMap)
+	parsedProxyShadowBackendHostURLMap, _ := ParseRawProxyBackendHostURLMap(rawProxyShadowBackendHostURLMap)
+	parsedProxyBackendTransportMap, _ := ParseRawBackendTransportMap(rawProxyBackendTransportMap)
+	parsedProxyBackendTimeoutMap, _ := ParseRawBackendTimeoutMap(rawProxyBackendTimeoutMap)
+	parsedProxyQuorumReadMap, _ := ParseRawQuorumReadMap(rawProxyQuorumReadMap)
+
+	whitelistedHeaders := values.get(WHITELISTED_HEADERS_ENVIRONMENT_KEY)
 	parsedWhitelistedHeaders := strings.Split(whitelistedHeaders, ",")
 	// strings.Split("", sep) returns []string{""} (slice with one empty string) which can be unexpected, so override it with more reasonable behaviour
 	if whitelistedHeaders == "" {
 		parsedWhitelistedHeaders = []string{}
 	}
 
-	rawHostnameToAccessControlAllowOriginValueMap := os.Getenv(HOSTNAME_TO_ACCESS_CONTROL_ALLOW_ORIGIN_VALUE_MAP_ENVIRONMENT_KEY)
+	// patterns are matched case insensitively against the messages of JSON-RPC errors
+	parsedProxyMissingStateErrorPatterns := []string{}
+	for _, pattern := range strings.Split(values.orDefault(PROXY_MISSING_STATE_ERROR_PATTERNS_ENVIRONMENT_KEY, DEFAULT_PROXY_MISSING_STATE_ERROR_PATTERNS), ",") {
+		if pattern = strings.ToLower(strings.TrimSpace(pattern)); pattern != "" {
+			parsedProxyMissingStateErrorPatterns = append(parsedProxyMissingStateErrorPatterns, pattern)
+		}
+	}
+
+	rawHostnameToAccessControlAllowOriginValueMap := values.get(HOSTNAME_TO_ACCESS_CONTROL_ALLOW_ORIGIN_VALUE_MAP_ENVIRONMENT_KEY)
 	// best effort to parse, callers are responsible for validating
 	// before using any values read
 	parsedHostnameToAccessControlAllowOriginValueMap, _ := ParseRawHostnameToHeaderValueMap(rawHostnameToAccessControlAllowOriginValueMap)
 
-	return Config{
-		ProxyServicePort:                              os.Getenv(PROXY_SERVICE_PORT_ENVIRONMENT_KEY),
-		LogLevel:                                      EnvOrDefault(LOG_LEVEL_ENVIRONMENT_KEY, DEFAULT_LOG_LEVEL),
-		ProxyBackendHostURLMapRaw:                     rawProxyBackendHostURLMap,
-		ProxyBackendHostURLMapParsed:                  parsedProxyBackendHostURLMap,
-		EnableHeightBasedRouting:                      EnvOrDefaultBool(PROXY_HEIGHT_BASED_ROUTING_ENABLED_KEY, false),
-		ProxyPruningBackendHostURLMapRaw:              rawProxyPruningBackendHostURLMap,
-		ProxyPruningBackendHostURLMap:                 parsedProxyPruningBackendHostURLMap,
-		EnableShardedRouting:                          EnvOrDefaultBool(PROXY_HEIGHT_BASED_ROUTING_ENABLED_KEY, false),
-		ProxyShardBackendHostURLMapRaw:                rawProxyShardedBackendHostURLMap,
-		ProxyShardBackendHostURLMap:                   parsedProxyShardedBackendHostURLMap,
-		ProxyMaximumBatchSize:                         EnvOrDefaultInt(PROXY_MAXIMUM_BATCH_SIZE_ENVIRONMENT_KEY, DEFAULT_PROXY_MAXIMUM_BATCH_SIZE),
-		DatabaseName:                                  os.Getenv(DATABASE_NAME_ENVIRONMENT_KEY),
-		DatabaseEndpointURL:                           os.Getenv(DATABASE_ENDPOINT_URL_ENVIRONMENT_KEY),
-		DatabaseUserName:                              os.Getenv(DATABASE_USERNAME_ENVIRONMENT_KEY),
-		DatabasePassword:                              os.Getenv(DATABASE_PASSWORD_ENVIRONMENT_KEY),
-		DatabaseSSLEnabled:                            EnvOrDefaultBool(DATABASE_SSL_ENABLED_ENVIRONMENT_KEY, false),
-		DatabaseReadTimeoutSeconds:                    EnvOrDefaultInt64(DATABASE_READ_TIMEOUT_SECONDS_ENVIRONMENT_KEY, DEFAULT_DATABASE_READ_TIMEOUT_SECONDS),
-		DatabaseWriteTimeoutSeconds:                   EnvOrDefaultInt64(DATABASE_WRITE_TIMEOUT_SECONDS_ENVIRONMENT_KEY, DEFAULT_DATABASE_WRITE_TIMEOUT_SECONDS),
-		DatabaseQueryLoggingEnabled:                   EnvOrDefaultBool(DATABASE_QUERY_LOGGING_ENABLED_ENVIRONMENT_KEY, true),
-		RunDatabaseMigrations:                         EnvOrDefaultBool(RUN_DATABASE_MIGRATIONS_ENVIRONMENT_KEY, false),
-		DatabaseMaxIdleConnections:                    EnvOrDefaultInt64(DATABASE_MAX_IDLE_CONNECTIONS_ENVIRONMENT_KEY, DEFAULT_DATABASE_MAX_IDLE_CONNECTIONS),
-		DatabaseConnectionMaxIdleSeconds:              EnvOrDefaultInt64(DATABASE_CONNECTION_MAX_IDLE_SECONDS_ENVIRONMENT_KEY, DEFAULT_DATABASE_CONNECTION_MAX_IDLE_SECONDS),
-		DatabaseMaxOpenConnections:                    EnvOrDefaultInt64(DATABASE_MAX_OPEN_CONNECTIONS_ENVIRONMENT_KEY, DEFAULT_DATABASE_MAX_OPEN_CONNECTIONS),
-		HTTPReadTimeoutSeconds:                        EnvOrDefaultInt64(HTTP_READ_TIMEOUT_ENVIRONMENT_KEY, DEFAULT_HTTP_READ_TIMEOUT),
-		HTTPWriteTimeoutSeconds:                       EnvOrDefaultInt64(HTTP_WRITE_TIMEOUT_ENVIRONMENT_KEY, DEFAULT_HTTP_WRITE_TIMEOUT),
-		MetricCompactionRoutineInterval:               time.Duration(time.Duration(EnvOrDefaultInt(METRIC_COMPACTION_ROUTINE_INTERVAL_ENVIRONMENT_KEY, DEFAULT_METRIC_COMPACTION_ROUTINE_INTERVAL_SECONDS)) * time.Second),
-		EvmQueryServiceURL:                            os.Getenv(EVM_QUERY_SERVICE_ENVIRONMENT_KEY),
-		MetricCollectionEnabled:                       EnvOrDefaultBool(METRIC_COLLECTION_ENABLED_ENVIRONMENT_KEY, DEFAULT_METRIC_COLLECTION_ENABLED),
-		MetricPartitioningRoutineInterval:             time.Duration(time.Duration(EnvOrDefaultInt(METRIC_PARTITIONING_ROUTINE_INTERVAL_SECONDS_ENVIRONMENT_KEY, DEFAULT_METRIC_PARTITIONING_ROUTINE_INTERVAL_SECONDS)) * time.Second),
-		MetricPartitioningRoutineDelayFirstRun:        time.Duration(time.Duration(EnvOrDefaultInt(METRIC_PARTITIONING_ROUTINE_DELAY_FIRST_RUN_SECONDS_ENVIRONMENT_KEY, DEFAULT_METRIC_PARTITIONING_ROUTINE_DELAY_FIRST_RUN_SECONDS)) * time.Second),
-		MetricPartitioningPrefillPeriodDays:           EnvOrDefaultInt(METRIC_PARTITIONING_PREFILL_PERIOD_DAYS_ENVIRONMENT_KEY, DEFAULT_METRIC_PARTITIONING_PREFILL_PERIOD_DAYS),
-		MetricPruningEnabled:                          EnvOrDefaultBool(METRIC_PRUNING_ENABLED_ENVIRONMENT_KEY, DEFAULT_METRIC_PRUNING_ENABLED),
-		MetricPruningRoutineInterval:                  time.Duration(time.Duration(EnvOrDefaultInt(METRIC_PRUNING_ROUTINE_INTERVAL_SECONDS_ENVIRONMENT_KEY, DEFAULT_METRIC_PRUNING_ROUTINE_INTERVAL_SECONDS)) * time.Second),
-		MetricPruningRoutineDelayFirstRun:             time.Duration(time.Duration(EnvOrDefaultInt(METRIC_PRUNING_ROUTINE_DELAY_FIRST_RUN_SECONDS_ENVIRONMENT_KEY, DEFAULT_METRIC_PRUNING_ROUTINE_DELAY_FIRST_RUN_SECONDS)) * time.Second),
-		MetricPruningMaxRequestMetricsHistoryDays:     EnvOrDefaultInt(METRIC_PRUNING_MAX_REQUEST_METRICS_HISTORY_DAYS_ENVIRONMENT_KEY, DEFAULT_METRIC_PRUNING_MAX_REQUEST_METRICS_HISTORY_DAYS),
-		MetricDatabaseEnabled:                         EnvOrDefaultBool(METRIC_DATABASE_ENABLED_ENVIRONMENT_KEY, DEFAULT_METRIC_DATABASE_ENABLED),
-		CacheEnabled:                                  EnvOrDefaultBool(CACHE_ENABLED_ENVIRONMENT_KEY, false),
-		RedisEndpointURL:                              os.Getenv(REDIS_ENDPOINT_URL_ENVIRONMENT_KEY),
-		RedisPassword:                                 os.Getenv(REDIS_PASSWORD_ENVIRONMENT_KEY),
-		CacheMethodHasBlockNumberParamTTL:             time.Duration(EnvOrDefaultInt(CACHE_METHOD_HAS_BLOCK_NUMBER_PARAM_TTL_ENVIRONMENT_KEY, 0)) * time.Second,
-		CacheMethodHasBlockHashParamTTL:               time.Duration(EnvOrDefaultInt(CACHE_METHOD_HAS_BLOCK_HASH_PARAM_TTL_ENVIRONMENT_KEY, 0)) * time.Second,
-		CacheStaticMethodTTL:                          time.Duration(EnvOrDefaultInt(CACHE_STATIC_METHOD_TTL_ENVIRONMENT_KEY, 0)) * time.Second,
-		CacheMethodHasTxHashParamTTL:                  time.Duration(EnvOrDefaultInt(CACHE_METHOD_HAS_TX_HASH_PARAM_TTL_ENVIRONMENT_KEY, 0)) * time.Second,
-		CachePrefix:                                   os.Getenv(CACHE_PREFIX_ENVIRONMENT_KEY),
-		WhitelistedHeaders:                            parsedWhitelistedHeaders,
-		DefaultAccessControlAllowOriginValue:          os.Getenv(DEFAULT_ACCESS_CONTROL_ALLOW_ORIGIN_VALUE_ENVIRONMENT_KEY),
-		HostnameToAccessControlAllowOriginValueMapRaw: rawHostnameToAccessControlAllowOriginValueMap,
-		HostnameToAccessControlAllowOriginValueMap:    parsedHostnameToAccessControlAllowOriginValueMap,
+	cfg := Config{
+		ProxyServicePort:                               values.get(PROXY_SERVICE_PORT_ENVIRONMENT_KEY),
+		LogLevel:                                       values.orDefault(LOG_LEVEL_ENVIRONMENT_KEY, DEFAULT_LOG_LEVEL),
+		ProxyBackendHostURLMapRaw:                      rawProxyBackendHostURLMap,
+		ProxyBackendHostURLMapParsed:                   parsedProxyBackendHostURLMap,
+		ProxyBackendHostURLWeightMap:                   parsedProxyBackendHostURLWeightMap,
+		ProxyBackendPoolStrategy:                       values.orDefault(PROXY_BACKEND_POOL_STRATEGY_ENVIRONMENT_KEY, DEFAULT_PROXY_BACKEND_POOL_STRATEGY),
+		EnableHeightBasedRouting:                       values.orDefaultBool(PROXY_HEIGHT_BASED_ROUTING_ENABLED_KEY, false),
+		ProxyPruningBackendHostURLMapRaw:               rawProxyPruningBackendHostURLMap,
+		ProxyPruningBackendHostURLMap:                  parsedProxyPruningBackendHostURLMap,
+		ProxyPruningBackendHostURLWeightMap:            parsedProxyPruningBackendHostURLWeightMap,
+		ProxyPruningBackendRetentionWindowMapRaw:       rawProxyPruningBackendRetentionWindowMap,
+		ProxyPruningBackendRetentionWindowMap:          parsedProxyPruningBackendRetentionWindowMap,
+		EnableShardedRouting:                           values.orDefaultBool(PROXY_HEIGHT_BASED_ROUTING_ENABLED_KEY, false),
+		ProxyShardBackendHostURLMapRaw:                 rawProxyShardedBackendHostURLMap,
+		ProxyShardBackendHostURLMap:                    parsedProxyShardedBackendHostURLMap,
+		ProxyShardDefaultFallbackEnabled:               values.orDefaultBool(PROXY_SHARD_DEFAULT_FALLBACK_ENABLED_ENVIRONMENT_KEY, true),
+		EnableMethodRouting:                            values.orDefaultBool(PROXY_METHOD_ROUTING_ENABLED_ENVIRONMENT_KEY, false),
+		ProxyMethodRoutingBackendHostURLMapRaw:         rawProxyMethodRoutingBackendHostURLMap,
+		ProxyMethodRoutingBackendHostURLMap:            parsedProxyMethodRoutingBackendHostURLMap,
+		ProxyMaximumBatchSize:                          values.orDefaultInt(PROXY_MAXIMUM_BATCH_SIZE_ENVIRONMENT_KEY, DEFAULT_PROXY_MAXIMUM_BATCH_SIZE),
+		ProxyBackendHealthCheckEnabled:                 values.orDefaultBool(PROXY_BACKEND_HEALTHCHECK_ENABLED_ENVIRONMENT_KEY, false),
+		ProxyBackendHealthCheckInterval:                time.Duration(values.orDefaultInt(PROXY_BACKEND_HEALTHCHECK_INTERVAL_SECONDS_ENVIRONMENT_KEY, DEFAULT_PROXY_BACKEND_HEALTHCHECK_INTERVAL_SECONDS)) * time.Second,
+		ProxyBackendHealthCheckTimeout:                 time.Duration(values.orDefaultInt(PROXY_BACKEND_HEALTHCHECK_TIMEOUT_SECONDS_ENVIRONMENT_KEY, DEFAULT_PROXY_BACKEND_HEALTHCHECK_TIMEOUT_SECONDS)) * time.Second,
+		ProxyBackendHealthCheckMethod:                  values.orDefault(PROXY_BACKEND_HEALTHCHECK_METHOD_ENVIRONMENT_KEY, DEFAULT_PROXY_BACKEND_HEALTHCHECK_METHOD),
+		ProxyBackendHealthCheckFailureThreshold:        values.orDefaultInt(PROXY_BACKEND_HEALTHCHECK_FAILURE_THRESHOLD_ENVIRONMENT_KEY, DEFAULT_PROXY_BACKEND_HEALTHCHECK_FAILURE_THRESHOLD),
+		ProxyBackendTransportMapRaw:                    rawProxyBackendTransportMap,
+		ProxyBackendTransportMap:                       parsedProxyBackendTransportMap,
+		ProxyBackendTimeoutEnabled:                     values.orDefaultBool(PROXY_BACKEND_TIMEOUT_ENABLED_ENVIRONMENT_KEY, false),
+		ProxyBackendTimeoutMapRaw:                      rawProxyBackendTimeoutMap,
+		ProxyBackendTimeoutMap:                         parsedProxyBackendTimeoutMap,
+		ProxyQuorumReadEnabled:                         values.orDefaultBool(PROXY_QUORUM_READ_ENABLED_ENVIRONMENT_KEY, false),
+		ProxyQuorumReadMapRaw:                          rawProxyQuorumReadMap,
+		ProxyQuorumReadMap:                             parsedProxyQuorumReadMap,
+		ProxyBackendCircuitBreakerEnabled:              values.orDefaultBool(PROXY_BACKEND_CIRCUIT_BREAKER_ENABLED_ENVIRONMENT_KEY, false),
+		ProxyBackendCircuitBreakerFailureThreshold:     values.orDefaultInt(PROXY_BACKEND_CIRCUIT_BREAKER_FAILURE_THRESHOLD_ENVIRONMENT_KEY, DEFAULT_PROXY_BACKEND_CIRCUIT_BREAKER_FAILURE_THRESHOLD),
+		ProxyBackendCircuitBreakerCooldown:             time.Duration(values.orDefaultInt(PROXY_BACKEND_CIRCUIT_BREAKER_COOLDOWN_SECONDS_ENVIRONMENT_KEY, DEFAULT_PROXY_BACKEND_CIRCUIT_BREAKER_COOLDOWN_SECONDS)) * time.Second,
+		ProxyBackendCircuitBreakerHalfOpenRequests:     values.orDefaultInt(PROXY_BACKEND_CIRCUIT_BREAKER_HALF_OPEN_REQUESTS_ENVIRONMENT_KEY, DEFAULT_PROXY_BACKEND_CIRCUIT_BREAKER_HALF_OPEN_REQUESTS),
+		ProxyBackendCircuitBreakerSlowRequestThreshold: time.Duration(values.orDefaultInt(PROXY_BACKEND_CIRCUIT_BREAKER_SLOW_REQUEST_THRESHOLD_MS_ENVIRONMENT_KEY, DEFAULT_PROXY_BACKEND_CIRCUIT_BREAKER_SLOW_REQUEST_THRESHOLD_MS)) * time.Millisecond,
+		ProxyBackendRetryEnabled:                       values.orDefaultBool(PROXY_BACKEND_RETRY_ENABLED_ENVIRONMENT_KEY, false),
+		ProxyBackendRetryMaxRetries:                    values.orDefaultInt(PROXY_BACKEND_RETRY_MAX_RETRIES_ENVIRONMENT_KEY, DEFAULT_PROXY_BACKEND_RETRY_MAX_RETRIES),
+		ProxyBackendRetryBackoff:                       time.Duration(values.orDefaultInt(PROXY_BACKEND_RETRY_BACKOFF_MILLISECONDS_ENVIRONMENT_KEY, DEFAULT_PROXY_BACKEND_RETRY_BACKOFF_MILLISECONDS)) * time.Millisecond,
+		ProxyBackendHedgingEnabled:                     values.orDefaultBool(PROXY_BACKEND_HEDGING_ENABLED_ENVIRONMENT_KEY, false),
+		ProxyBackendHedgingPercentile:                  values.orDefaultInt(PROXY_BACKEND_HEDGING_PERCENTILE_ENVIRONMENT_KEY, DEFAULT_PROXY_BACKEND_HEDGING_PERCENTILE),
+		ProxyBackendHedgingDefaultDelay:                time.Duration(values.orDefaultInt(PROXY_BACKEND_HEDGING_DEFAULT_DELAY_MILLISECONDS_ENVIRONMENT_KEY, DEFAULT_PROXY_BACKEND_HEDGING_DEFAULT_DELAY_MILLISECONDS)) * time.Millisecond,
+		ProxyBackendHedgingMinDelay:                    time.Duration(values.orDefaultInt(PROXY_BACKEND_HEDGING_MIN_DELAY_MILLISECONDS_ENVIRONMENT_KEY, DEFAULT_PROXY_BACKEND_HEDGING_MIN_DELAY_MILLISECONDS)) * time.Millisecond,
+		ProxyMissingStateFallbackEnabled:               values.orDefaultBool(PROXY_MISSING_STATE_FALLBACK_ENABLED_ENVIRONMENT_KEY, false),
+		ProxyMissingStateErrorPatterns:                 parsedProxyMissingStateErrorPatterns,
+		ProxyBackendHeadTrackingEnabled:                values.orDefaultBool(PROXY_BACKEND_HEAD_TRACKING_ENABLED_ENVIRONMENT_KEY, false),
+		ProxyBackendHeadTrackingInterval:               time.Duration(values.orDefaultInt(PROXY_BACKEND_HEAD_TRACKING_INTERVAL_SECONDS_ENVIRONMENT_KEY, DEFAULT_PROXY_BACKEND_HEAD_TRACKING_INTERVAL_SECONDS)) * time.Second,
+		ProxyBackendHeadTrackingTimeout:                time.Duration(values.orDefaultInt(PROXY_BACKEND_HEAD_TRACKING_TIMEOUT_SECONDS_ENVIRONMENT_KEY, DEFAULT_PROXY_BACKEND_HEAD_TRACKING_TIMEOUT_SECONDS)) * time.Second,
+		ProxyBackendHeadTrackingMaxLagBlocks:           values.orDefaultInt(PROXY_BACKEND_HEAD_TRACKING_MAX_LAG_BLOCKS_ENVIRONMENT_KEY, DEFAULT_PROXY_BACKEND_HEAD_TRACKING_MAX_LAG_BLOCKS),
+		ProxyStickyFilterRoutingEnabled:                values.orDefaultBool(PROXY_STICKY_FILTER_ROUTING_ENABLED_ENVIRONMENT_KEY, false),
+		ProxyStickyFilterRoutingTTL:                    time.Duration(values.orDefaultInt(PROXY_STICKY_FILTER_ROUTING_TTL_SECONDS_ENVIRONMENT_KEY, DEFAULT_PROXY_STICKY_FILTER_ROUTING_TTL_SECONDS)) * time.Second,
+		ProxyConfigFile:                                sources.ConfigFile,
+		ProxyRoutingConfigReloadInterval:               time.Duration(values.orDefaultInt(PROXY_ROUTING_CONFIG_RELOAD_INTERVAL_SECONDS_ENVIRONMENT_KEY, DEFAULT_PROXY_ROUTING_CONFIG_RELOAD_INTERVAL_SECONDS)) * time.Second,
+		ProxyAdminAPIEnabled:                           values.orDefaultBool(PROXY_ADMIN_API_ENABLED_ENVIRONMENT_KEY, false),
+		ProxyAdminAPIPort:                              values.orDefault(PROXY_ADMIN_API_PORT_ENVIRONMENT_KEY, DEFAULT_PROXY_ADMIN_API_PORT),
+		ProxyAdminAPIToken:                             values.get(PROXY_ADMIN_API_TOKEN_ENVIRONMENT_KEY),
+		ProxyAdminAPISharedStateEnabled:                values.orDefaultBool(PROXY_ADMIN_API_SHARED_STATE_ENABLED_ENVIRONMENT_KEY, false),
+		ProxyAdminAPISharedStateSyncInterval:           time.Duration(values.orDefaultInt(PROXY_ADMIN_API_SHARED_STATE_SYNC_INTERVAL_SECONDS_ENVIRONMENT_KEY, DEFAULT_PROXY_ADMIN_API_SHARED_STATE_SYNC_INTERVAL_SECONDS)) * time.Second,
+		ProxyWebsocketEnabled:                          values.orDefaultBool(PROXY_WEBSOCKET_ENABLED_ENVIRONMENT_KEY, false),
+		ProxyWebsocketSharedSubscriptionsEnabled:       values.orDefaultBool(PROXY_WEBSOCKET_SHARED_SUBSCRIPTIONS_ENABLED_ENVIRONMENT_KEY, false),
+		ProxyShadowEnabled:                             values.orDefaultBool(PROXY_SHADOW_ENABLED_ENVIRONMENT_KEY, false),
+		ProxyShadowBackendHostURLMapRaw:                rawProxyShadowBackendHostURLMap,
+		ProxyShadowBackendHostURLMap:                   parsedProxyShadowBackendHostURLMap,
+		ProxyShadowSampleRate:                          values.orDefaultFloat64(PROXY_SHADOW_SAMPLE_RATE_ENVIRONMENT_KEY, DEFAULT_PROXY_SHADOW_SAMPLE_RATE),
+		ProxyShadowTimeout:                             time.Duration(values.orDefaultInt(PROXY_SHADOW_TIMEOUT_SECONDS_ENVIRONMENT_KEY, DEFAULT_PROXY_SHADOW_TIMEOUT_SECONDS)) * time.Second,
+		ProxyShadowMaxInFlight:                         values.orDefaultInt(PROXY_SHADOW_MAX_IN_FLIGHT_ENVIRONMENT_KEY, DEFAULT_PROXY_SHADOW_MAX_IN_FLIGHT),
+		DatabaseName:                                   values.get(DATABASE_NAME_ENVIRONMENT_KEY),
+		DatabaseEndpointURL:                            values.get(DATABASE_ENDPOINT_URL_ENVIRONMENT_KEY),
+		DatabaseUserName:                               values.get(DATABASE_USERNAME_ENVIRONMENT_KEY),
+		DatabasePassword:                               values.get(DATABASE_PASSWORD_ENVIRONMENT_KEY),
+		DatabaseSSLEnabled:                             values.orDefaultBool(DATABASE_SSL_ENABLED_ENVIRONMENT_KEY, false),
+		DatabaseReadTimeoutSeconds:                     values.orDefaultInt64(DATABASE_READ_TIMEOUT_SECONDS_ENVIRONMENT_KEY, DEFAULT_DATABASE_READ_TIMEOUT_SECONDS),
+		DatabaseWriteTimeoutSeconds:                    values.orDefaultInt64(DATABASE_WRITE_TIMEOUT_SECONDS_ENVIRONMENT_KEY, DEFAULT_DATABASE_WRITE_TIMEOUT_SECONDS),
+		DatabaseQueryLoggingEnabled:                    values.orDefaultBool(DATABASE_QUERY_LOGGING_ENABLED_ENVIRONMENT_KEY, true),
+		RunDatabaseMigrations:                          values.orDefaultBool(RUN_DATABASE_MIGRATIONS_ENVIRONMENT_KEY, false),
+		DatabaseMaxIdleConnections:                     values.orDefaultInt64(DATABASE_MAX_IDLE_CONNECTIONS_ENVIRONMENT_KEY, DEFAULT_DATABASE_MAX_IDLE_CONNECTIONS),
+		DatabaseConnectionMaxIdleSeconds:               values.orDefaultInt64(DATABASE_CONNECTION_MAX_IDLE_SECONDS_ENVIRONMENT_KEY, DEFAULT_DATABASE_CONNECTION_MAX_IDLE_SECONDS),
+		DatabaseMaxOpenConnections:                     values.orDefaultInt64(DATABASE_MAX_OPEN_CONNECTIONS_ENVIRONMENT_KEY, DEFAULT_DATABASE_MAX_OPEN_CONNECTIONS),
+		HTTPReadTimeoutSeconds:                         values.orDefaultInt64(HTTP_READ_TIMEOUT_ENVIRONMENT_KEY, DEFAULT_HTTP_READ_TIMEOUT),
+		HTTPWriteTimeoutSeconds:                        values.orDefaultInt64(HTTP_WRITE_TIMEOUT_ENVIRONMENT_KEY, DEFAULT_HTTP_WRITE_TIMEOUT),
+		MetricCompactionRoutineInterval:                time.Duration(time.Duration(values.orDefaultInt(METRIC_COMPACTION_ROUTINE_INTERVAL_ENVIRONMENT_KEY, DEFAULT_METRIC_COMPACTION_ROUTINE_INTERVAL_SECONDS)) * time.Second),
+		EvmQueryServiceURL:                             values.get(EVM_QUERY_SERVICE_ENVIRONMENT_KEY),
+		MetricCollectionEnabled:                        values.orDefaultBool(METRIC_COLLECTION_ENABLED_ENVIRONMENT_KEY, DEFAULT_METRIC_COLLECTION_ENABLED),
+		MetricPartitioningRoutineInterval:              time.Duration(time.Duration(values.orDefaultInt(METRIC_PARTITIONING_ROUTINE_INTERVAL_SECONDS_ENVIRONMENT_KEY, DEFAULT_METRIC_PARTITIONING_ROUTINE_INTERVAL_SECONDS)) * time.Second),
+		MetricPartitioningRoutineDelayFirstRun:         time.Duration(time.Duration(values.orDefaultInt(METRIC_PARTITIONING_ROUTINE_DELAY_FIRST_RUN_SECONDS_ENVIRONMENT_KEY, DEFAULT_METRIC_PARTITIONING_ROUTINE_DELAY_FIRST_RUN_SECONDS)) * time.Second),
+		MetricPartitioningPrefillPeriodDays:            values.orDefaultInt(METRIC_PARTITIONING_PREFILL_PERIOD_DAYS_ENVIRONMENT_KEY, DEFAULT_METRIC_PARTITIONING_PREFILL_PERIOD_DAYS),
+		MetricPruningEnabled:                           values.orDefaultBool(METRIC_PRUNING_ENABLED_ENVIRONMENT_KEY, DEFAULT_METRIC_PRUNING_ENABLED),
+		MetricPruningRoutineInterval:                   time.Duration(time.Duration(values.orDefaultInt(METRIC_PRUNING_ROUTINE_INTERVAL_SECONDS_ENVIRONMENT_KEY, DEFAULT_METRIC_PRUNING_ROUTINE_INTERVAL_SECONDS)) * time.Second),
+		MetricPruningRoutineDelayFirstRun:              time.Duration(time.Duration(values.orDefaultInt(METRIC_PRUNING_ROUTINE_DELAY_FIRST_RUN_SECONDS_ENVIRONMENT_KEY, DEFAULT_METRIC_PRUNING_ROUTINE_DELAY_FIRST_RUN_SECONDS)) * time.Second),
+		MetricPruningMaxRequestMetricsHistoryDays:      values.orDefaultInt(METRIC_PRUNING_MAX_REQUEST_METRICS_HISTORY_DAYS_ENVIRONMENT_KEY, DEFAULT_METRIC_PRUNING_MAX_REQUEST_METRICS_HISTORY_DAYS),
+		MetricDatabaseEnabled:                          values.orDefaultBool(METRIC_DATABASE_ENABLED_ENVIRONMENT_KEY, DEFAULT_METRIC_DATABASE_ENABLED),
+		CacheEnabled:                                   values.orDefaultBool(CACHE_ENABLED_ENVIRONMENT_KEY, false),
+		RedisEndpointURL:                               values.get(REDIS_ENDPOINT_URL_ENVIRONMENT_KEY),
+		RedisPassword:                                  values.get(REDIS_PASSWORD_ENVIRONMENT_KEY),
+		CacheMethodHasBlockNumberParamTTL:              time.Duration(values.orDefaultInt(CACHE_METHOD_HAS_BLOCK_NUMBER_PARAM_TTL_ENVIRONMENT_KEY, 0)) * time.Second,
+		CacheMethodHasBlockHashParamTTL:                time.Duration(values.orDefaultInt(CACHE_METHOD_HAS_BLOCK_HASH_PARAM_TTL_ENVIRONMENT_KEY, 0)) * time.Second,
+		CacheStaticMethodTTL:                           time.Duration(values.orDefaultInt(CACHE_STATIC_METHOD_TTL_ENVIRONMENT_KEY, 0)) * time.Second,
+		CacheMethodHasTxHashParamTTL:                   time.Duration(values.orDefaultInt(CACHE_METHOD_HAS_TX_HASH_PARAM_TTL_ENVIRONMENT_KEY, 0)) * time.Second,
+		CachePrefix:                                    values.get(CACHE_PREFIX_ENVIRONMENT_KEY),
+		WhitelistedHeaders:                             parsedWhitelistedHeaders,
+		DefaultAccessControlAllowOriginValue:           values.get(DEFAULT_ACCESS_CONTROL_ALLOW_ORIGIN_VALUE_ENVIRONMENT_KEY),
+		HostnameToAccessControlAllowOriginValueMapRaw:  rawHostnameToAccessControlAllowOriginValueMap,
+		HostnameToAccessControlAllowOriginValueMap:     parsedHostnameToAccessControlAllowOriginValueMap,
 	}
+	// the hosts of the config file are used for the host settings not set in the environment
+	setConfigFileHosts(&cfg, values, configFileHosts)
+
+	return cfg, loadErr
 }
 
 func (cfg *Config) GetAccessControlAllowOriginValue(hostname string) string {
@@ -4,6 +4,7 @@ import (
 	"net/url"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/kava-labs/kava-proxy-service/config"
 	"github.com/stretchr/testify/assert"
@@ -46,7 +47,8 @@ func TestUnitTestEnvODefaultReturnsSetValue(t *testing.T) {
 func TestUnitTestReadConfigReturnsConfigWithValuesFromEnv(t *testing.T) {
 	setDefaultEnv()
 
-	readConfig := config.ReadConfig()
+	readConfig, err := config.ReadConfig()
+	assert.Nil(t, err)
 
 	assert.Equal(t, config.DEFAULT_LOG_LEVEL, readConfig.LogLevel)
 	assert.Equal(t, proxyServicePort, readConfig.ProxyServicePort)
@@ -57,6 +59,107 @@ func TestUnitTestParseHostMapReturnsErrEmptyHostMapWhenEmpty(t *testing.T) {
 	assert.ErrorIs(t, err, config.ErrEmptyHostMap)
 }
 
+func TestUnitTestParseHostMapParsesBackendPools(t *testing.T) {
+	parsed, err := config.ParseRawProxyBackendHostURLMap("localhost:7777>http://kava-1:8545+http://kava-2:8545,localhost:7778>http://kava-pruning:8545")
+	require.NoError(t, err)
+	expected := map[string][]url.URL{
+		"localhost:7777": {*mustUrl("http://kava-1:8545"), *mustUrl("http://kava-2:8545")},
+		"localhost:7778": {*mustUrl("http://kava-pruning:8545")},
+	}
+	require.Equal(t, expected, parsed)
+
+	_, err = config.ParseRawProxyBackendHostURLMap("localhost:7777>http://kava-1:8545+")
+	require.ErrorContains(t, err, "expected map value of host to backend url(s)")
+}
+
+func TestUnitTestParseHostMapParsesBackendWeights(t *testing.T) {
+	raw := "localhost:7777>http://kava-1:8545*95+http://kava-canary:8545*5,localhost:7778>http://kava-2:8545"
+	parsed, err := config.ParseRawProxyBackendHostURLMap(raw)
+	require.NoError(t, err)
+	require.Equal(t, []url.URL{*mustUrl("http://kava-1:8545"), *mustUrl("http://kava-canary:8545")}, parsed["localhost:7777"])
+
+	weights, err := config.ParseRawProxyBackendHostURLWeightMap(raw)
+	require.NoError(t, err)
+	require.Equal(t, map[string]config.BackendWeights{
+		"localhost:7777": {"http://kava-1:8545": 95, "http://kava-canary:8545": 5},
+	}, weights)
+	require.Equal(t, 5, weights["localhost:7777"].Weight(*mustUrl("http://kava-canary:8545")))
+	require.Equal(t, config.DefaultBackendWeight, weights["localhost:7778"].Weight(*mustUrl("http://kava-2:8545")))
+
+	for _, invalidWeight := range []string{"0", "-1", "five", ""} {
+		_, err = config.ParseRawProxyBackendHostURLMap("localhost:7777>http://kava-1:8545*" + invalidWeight)
+		require.ErrorContains(t, err, "weights must be positive integers")
+	}
+}
+
+func TestUnitTestParseRawMethodRoutingBackendHostURLMap(t *testing.T) {
+	parsed, err := config.ParseRawMethodRoutingBackendHostURLMap("localhost:7777>debug_*|http://kava-tracing-1:8545+http://kava-tracing-2:8545|txpool_content|http://kava-tracing-1:8545")
+	require.NoError(t, err)
+	expected := map[string]config.MethodRoutingRules{
+		"localhost:7777": {
+			{Pattern: "debug_*", BackendURLs: []url.URL{*mustUrl("http://kava-tracing-1:8545"), *mustUrl("http://kava-tracing-2:8545")}},
+			{Pattern: "txpool_content", BackendURLs: []url.URL{*mustUrl("http://kava-tracing-1:8545")}},
+		},
+	}
+	require.Equal(t, expected, parsed)
+
+	parsed, err = config.ParseRawMethodRoutingBackendHostURLMap("")
+	require.NoError(t, err)
+	require.Empty(t, parsed)
+
+	_, err = config.ParseRawMethodRoutingBackendHostURLMap("no-method-def")
+	require.ErrorContains(t, err, "expected method routing definition like <host>>(<method>|<backend-route(s)>)+")
+
+	_, err = config.ParseRawMethodRoutingBackendHostURLMap("odd-sequence>debug_*|http://kava-tracing:8545|txpool_*")
+	require.ErrorContains(t, err, "unexpected <method>|<backend-route(s)> sequence for odd-sequence")
+
+	_, err = config.ParseRawMethodRoutingBackendHostURLMap("match-everything>*|http://kava-tracing:8545")
+	require.ErrorContains(t, err, "invalid method routing pattern (*) for host match-everything")
+
+	_, err = config.ParseRawMethodRoutingBackendHostURLMap("missing-backend>debug_*|")
+	require.ErrorContains(t, err, "invalid method routing backend route () for method debug_* of host missing-backend")
+}
+
+func TestUnitTestMethodRoutingRulesLookup(t *testing.T) {
+	rules := config.MethodRoutingRules{
+		{Pattern: "debug_traceTransaction", BackendURLs: []url.URL{*mustUrl("http://kava-tx-tracing:8545")}},
+		{Pattern: "debug_*", BackendURLs: []url.URL{*mustUrl("http://kava-tracing:8545")}},
+	}
+
+	rule, found := rules.Lookup("debug_traceTransaction")
+	require.True(t, found)
+	require.Equal(t, "debug_traceTransaction", rule.Pattern)
+
+	rule, found = rules.Lookup("debug_traceCall")
+	require.True(t, found)
+	require.Equal(t, "debug_*", rule.Pattern)
+
+	_, found = rules.Lookup("eth_getBalance")
+	require.False(t, found)
+
+	_, found = rules.Lookup("debug")
+	require.False(t, found)
+}
+
+func TestUnitTestParseRawRetentionWindowMap(t *testing.T) {
+	parsed, err := config.ParseRawRetentionWindowMap("localhost:7777>100000,localhost:7778>5000")
+	require.NoError(t, err)
+	expected := map[string]uint64{
+		"localhost:7777": 100000,
+		"localhost:7778": 5000,
+	}
+	require.Equal(t, expected, parsed)
+
+	_, err = config.ParseRawRetentionWindowMap("")
+	require.ErrorIs(t, err, config.ErrEmptyRetentionWindowMap)
+
+	_, err = config.ParseRawRetentionWindowMap("localhost:7777")
+	require.ErrorContains(t, err, "expected map value of hostname to retention window")
+
+	_, err = config.ParseRawRetentionWindowMap("localhost:7777>-1")
+	require.ErrorContains(t, err, "expected retention window of localhost:7777 to be a positive number of blocks")
+}
+
 func TestUnitTestParseRawShardRoutingBackendHostURLMap(t *testing.T) {
 	parsed, err := config.ParseRawShardRoutingBackendHostURLMap("localhost:7777>10|http://kava-shard-10:8545|20|http://kava-shard-20:8545")
 	require.NoError(t, err)
@@ -68,6 +171,16 @@ func TestUnitTestParseRawShardRoutingBackendHostURLMap(t *testing.T) {
 	}
 	require.Equal(t, expected, parsed)
 
+	parsed, err = config.ParseRawShardRoutingBackendHostURLMap("localhost:7777>10|http://kava-shard-10:8545+http://kava-shard-10-2:8545|20|http://kava-shard-20:8545")
+	require.NoError(t, err)
+	expected = map[string]config.IntervalURLMap{
+		"localhost:7777": config.NewIntervalURLPoolMap(map[uint64][]*url.URL{
+			10: {mustUrl("http://kava-shard-10:8545"), mustUrl("http://kava-shard-10-2:8545")},
+			20: {mustUrl("http://kava-shard-20:8545")},
+		}),
+	}
+	require.Equal(t, expected, parsed)
+
 	_, err = config.ParseRawShardRoutingBackendHostURLMap("no-shard-def")
 	require.ErrorContains(t, err, "expected shard definition like <host>:<end-height>|<backend-route>")
 
@@ -96,3 +209,90 @@ func setDefaultEnv() {
 	os.Setenv(config.PROXY_SERVICE_PORT_ENVIRONMENT_KEY, proxyServicePort)
 	os.Setenv(config.LOG_LEVEL_ENVIRONMENT_KEY, config.DEFAULT_LOG_LEVEL)
 }
+
+func TestUnitTestParseRawBackendTransportMap(t *testing.T) {
+	parsed, err := config.ParseRawBackendTransportMap("http://kava-archive:8545>dial_timeout_seconds=5|response_header_timeout_seconds=300|max_idle_conns_per_host=100,https://kava-mtls:8545>client_cert_file=/certs/proxy.crt|client_key_file=/certs/proxy.key|ca_bundle_file=/certs/ca.pem")
+	require.NoError(t, err)
+	require.Equal(t, map[string]config.BackendTransportConfig{
+		"http://kava-archive:8545": {
+			DialTimeout:           5 * time.Second,
+			ResponseHeaderTimeout: 300 * time.Second,
+			MaxIdleConnsPerHost:   100,
+		},
+		"https://kava-mtls:8545": {
+			ClientCertFile: "/certs/proxy.crt",
+			ClientKeyFile:  "/certs/proxy.key",
+			CABundleFile:   "/certs/ca.pem",
+		},
+	}, parsed)
+
+	parsed, err = config.ParseRawBackendTransportMap("")
+	require.NoError(t, err)
+	require.Empty(t, parsed)
+
+	_, err = config.ParseRawBackendTransportMap("http://kava-archive:8545")
+	require.ErrorContains(t, err, "expected transport definition like <backend-route>><setting>=<value>")
+
+	_, err = config.ParseRawBackendTransportMap("http://kava-archive:8545>response_header_timeout_seconds=-1")
+	require.ErrorContains(t, err, "expected response_header_timeout_seconds to be a positive integer")
+
+	_, err = config.ParseRawBackendTransportMap("http://kava-archive:8545>keep_alive=true")
+	require.ErrorContains(t, err, "unknown setting keep_alive")
+}
+
+func TestUnitTestParseRawBackendTimeoutMap(t *testing.T) {
+	parsed, err := config.ParseRawBackendTimeoutMap("*>debug_*|120|*|30,evm.kava.io>eth_call|10")
+	require.NoError(t, err)
+	require.Equal(t, map[string]config.MethodTimeoutRules{
+		config.BackendTimeoutMapAnyHost: {
+			{Pattern: "debug_*", Timeout: 120 * time.Second},
+			{Pattern: "*", Timeout: 30 * time.Second},
+		},
+		"evm.kava.io": {
+			{Pattern: "eth_call", Timeout: 10 * time.Second},
+		},
+	}, parsed)
+
+	rule, found := parsed[config.BackendTimeoutMapAnyHost].Lookup("eth_getBalance")
+	require.True(t, found)
+	require.Equal(t, "*", rule.Pattern)
+
+	_, err = config.ParseRawBackendTimeoutMap("evm.kava.io")
+	require.ErrorContains(t, err, "expected timeout definition like <host>>(<method>|<timeout-seconds>)+")
+
+	_, err = config.ParseRawBackendTimeoutMap("evm.kava.io>eth_call")
+	require.ErrorContains(t, err, "unexpected <method>|<timeout-seconds> sequence for evm.kava.io")
+
+	_, err = config.ParseRawBackendTimeoutMap("evm.kava.io>eth_call|0")
+	require.ErrorContains(t, err, "invalid timeout (0) for method eth_call of host evm.kava.io")
+}
+
+func TestUnitTestParseRawQuorumReadMap(t *testing.T) {
+	parsed, err := config.ParseRawQuorumReadMap("*>eth_getTransactionReceipt|3|2,evm.kava.io>eth_getBalance|5|3|eth_get*|2|2")
+	require.NoError(t, err)
+	require.Equal(t, map[string]config.MethodQuorumRules{
+		config.QuorumReadMapAnyHost: {
+			{Pattern: "eth_getTransactionReceipt", Backends: 3, Agreements: 2},
+		},
+		"evm.kava.io": {
+			{Pattern: "eth_getBalance", Backends: 5, Agreements: 3},
+			{Pattern: "eth_get*", Backends: 2, Agreements: 2},
+		},
+	}, parsed)
+
+	rule, found := parsed["evm.kava.io"].Lookup("eth_getCode")
+	require.True(t, found)
+	require.Equal(t, "eth_get*", rule.Pattern)
+
+	_, err = config.ParseRawQuorumReadMap("evm.kava.io")
+	require.ErrorContains(t, err, "expected quorum definition like <host>>(<method>|<backends>|<agreements>)+")
+
+	_, err = config.ParseRawQuorumReadMap("evm.kava.io>eth_getBalance|3")
+	require.ErrorContains(t, err, "unexpected <method>|<backends>|<agreements> sequence for evm.kava.io")
+
+	_, err = config.ParseRawQuorumReadMap("evm.kava.io>eth_getBalance|0|0")
+	require.ErrorContains(t, err, "invalid number of backends (0) for method eth_getBalance of host evm.kava.io")
+
+	_, err = config.ParseRawQuorumReadMap("evm.kava.io>eth_getBalance|2|3")
+	require.ErrorContains(t, err, "invalid number of agreements (3) for method eth_getBalance of host evm.kava.io")
+}
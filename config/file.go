@@ -0,0 +1,421 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileConfig is the structure of the optional YAML or JSON config file
+// an alternative to expressing hosts and their backends with the mini syntax
+// of the environment variables, e.g.
+//
+//	hosts:
+//	  evm.kava.io:
+//	    backends:
+//	      - http://kava-archive-1:8545
+//	      - url: http://kava-canary:8545
+//	        weight: 5
+//	    pruning_backends: [http://kava-pruning:8545]
+//	    shards:
+//	      - end_height: 2000000
+//	        backend: http://kava-shard-2M:8545
+//	        redundant_backends: [http://kava-shard-2M-2:8545]
+type FileConfig struct {
+	LogLevel                        string                    `yaml:"log_level"`
+	Port                            *int                      `yaml:"port"`
+	BackendPoolStrategy             string                    `yaml:"backend_pool_strategy"`
+	HeightBasedRoutingEnabled       *bool                     `yaml:"height_based_routing_enabled"`
+	MethodRoutingEnabled            *bool                     `yaml:"method_routing_enabled"`
+	MaximumBatchSize                *int                      `yaml:"maximum_batch_size"`
+	DefaultAccessControlAllowOrigin *string                   `yaml:"default_access_control_allow_origin"`
+	Hosts                           map[string]HostFileConfig `yaml:"hosts"`
+	Cache                           CacheFileConfig           `yaml:"cache"`
+	Routines                        RoutinesFileConfig        `yaml:"routines"`
+	// values of any other setting by environment key, e.g. PROXY_BACKEND_RETRY_ENABLED: "true"
+	// overridden by the value of the same setting elsewhere in the file
+	Settings map[string]string `yaml:"settings"`
+}
+
+// HostFileConfig is the config file structure of the backends of a single host
+type HostFileConfig struct {
+	Backends                 []BackendFileConfig     `yaml:"backends"`
+	PruningBackends          []BackendFileConfig     `yaml:"pruning_backends"`
+	PruningRetentionWindow   uint64                  `yaml:"pruning_retention_window"`
+	Shards                   []ShardFileConfig       `yaml:"shards"`
+	MethodRoutes             []MethodRouteFileConfig `yaml:"method_routes"`
+	AccessControlAllowOrigin string                  `yaml:"access_control_allow_origin"`
+}
+
+// BackendFileConfig is the config file structure of a member of a pool of backends,
+// either its url, or a mapping of its url and weight
+type BackendFileConfig struct {
+	URL string `yaml:"url"`
+	// weight of the member in its pool, DefaultBackendWeight if not set
+	Weight *int `yaml:"weight"`
+}
+
+// UnmarshalYAML decodes the member of the pool from its url, or the mapping of its url and weight
+func (bc *BackendFileConfig) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		return value.Decode(&bc.URL)
+	}
+
+	// decoding a node doesn't reject unknown fields like the decoder of the file does
+	if value.Kind == yaml.MappingNode {
+		for i := 0; i < len(value.Content); i += 2 {
+			if field := value.Content[i].Value; field != "url" && field != "weight" {
+				return fmt.Errorf("line %d: field %s not found in type config.BackendFileConfig", value.Content[i].Line, field)
+			}
+		}
+	}
+	type plainBackendFileConfig BackendFileConfig
+	return value.Decode((*plainBackendFileConfig)(bc))
+}
+
+// ShardFileConfig is the config file structure of a shard of a host,
+// serving the blocks after the end height of the previous shard up to its end height
+type ShardFileConfig struct {
+	EndHeight uint64 `yaml:"end_height"`
+	Backend   string `yaml:"backend"`
+	// redundant backends of the shard, used in order when the backend is unavailable
+	RedundantBackends []string `yaml:"redundant_backends"`
+}
+
+// MethodRouteFileConfig is the config file structure of a method routing rule of a host
+type MethodRouteFileConfig struct {
+	Method   string   `yaml:"method"`
+	Backends []string `yaml:"backends"`
+}
+
+// CacheFileConfig is the config file structure of the cache settings
+type CacheFileConfig struct {
+	Enabled                             *bool    `yaml:"enabled"`
+	RedisEndpointURL                    string   `yaml:"redis_endpoint_url"`
+	Prefix                              string   `yaml:"prefix"`
+	MethodHasBlockNumberParamTTLSeconds *int     `yaml:"method_has_block_number_param_ttl_seconds"`
+	MethodHasBlockHashParamTTLSeconds   *int     `yaml:"method_has_block_hash_param_ttl_seconds"`
+	StaticMethodTTLSeconds              *int     `yaml:"static_method_ttl_seconds"`
+	MethodHasTxHashParamTTLSeconds      *int     `yaml:"method_has_tx_hash_param_ttl_seconds"`
+	WhitelistedHeaders                  []string `yaml:"whitelisted_headers"`
+}
+
+// RoutinesFileConfig is the config file structure of the settings of the metric routines
+type RoutinesFileConfig struct {
+	MetricCollectionEnabled                   *bool `yaml:"metric_collection_enabled"`
+	MetricCompactionIntervalSeconds           *int  `yaml:"metric_compaction_interval_seconds"`
+	MetricPartitioningIntervalSeconds         *int  `yaml:"metric_partitioning_interval_seconds"`
+	MetricPartitioningDelayFirstRunSeconds    *int  `yaml:"metric_partitioning_delay_first_run_seconds"`
+	MetricPartitioningPrefillPeriodDays       *int  `yaml:"metric_partitioning_prefill_period_days"`
+	MetricPruningEnabled                      *bool `yaml:"metric_pruning_enabled"`
+	MetricPruningIntervalSeconds              *int  `yaml:"metric_pruning_interval_seconds"`
+	MetricPruningDelayFirstRunSeconds         *int  `yaml:"metric_pruning_delay_first_run_seconds"`
+	MetricPruningMaxRequestMetricsHistoryDays *int  `yaml:"metric_pruning_max_request_metrics_history_days"`
+}
+
+// ParseConfigFile parses the YAML or JSON config file at path into the values of the settings
+// it defines by environment key and the routing config of its hosts, nil if it defines no hosts,
+// returning the values, hosts and error (if any)
+func ParseConfigFile(path string) (map[string]string, *HostsConfig, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// JSON is valid YAML so both are decoded as YAML
+	var fileConfig FileConfig
+	decoder := yaml.NewDecoder(bytes.NewReader(contents))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&fileConfig); err != nil && !errors.Is(err, io.EOF) {
+		return nil, nil, fmt.Errorf("failed to decode config file %s: %w", path, err)
+	}
+
+	hosts, err := fileConfig.HostsConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return fileConfig.EnvironmentValues(), hosts, nil
+}
+
+// EnvironmentValues returns the values of the settings defined by the config file
+// other than those of its hosts, by environment key in the syntax of the environment variables
+func (fc FileConfig) EnvironmentValues() map[string]string {
+	values := make(map[string]string, len(fc.Settings))
+	for key, value := range fc.Settings {
+		values[key] = value
+	}
+
+	setString(values, LOG_LEVEL_ENVIRONMENT_KEY, fc.LogLevel)
+	setInt(values, PROXY_SERVICE_PORT_ENVIRONMENT_KEY, fc.Port)
+	setString(values, PROXY_BACKEND_POOL_STRATEGY_ENVIRONMENT_KEY, fc.BackendPoolStrategy)
+	setBool(values, PROXY_HEIGHT_BASED_ROUTING_ENABLED_KEY, fc.HeightBasedRoutingEnabled)
+	setBool(values, PROXY_METHOD_ROUTING_ENABLED_ENVIRONMENT_KEY, fc.MethodRoutingEnabled)
+	setInt(values, PROXY_MAXIMUM_BATCH_SIZE_ENVIRONMENT_KEY, fc.MaximumBatchSize)
+	if fc.DefaultAccessControlAllowOrigin != nil {
+		values[DEFAULT_ACCESS_CONTROL_ALLOW_ORIGIN_VALUE_ENVIRONMENT_KEY] = *fc.DefaultAccessControlAllowOrigin
+	}
+
+	setBool(values, CACHE_ENABLED_ENVIRONMENT_KEY, fc.Cache.Enabled)
+	setString(values, REDIS_ENDPOINT_URL_ENVIRONMENT_KEY, fc.Cache.RedisEndpointURL)
+	setString(values, CACHE_PREFIX_ENVIRONMENT_KEY, fc.Cache.Prefix)
+	setInt(values, CACHE_METHOD_HAS_BLOCK_NUMBER_PARAM_TTL_ENVIRONMENT_KEY, fc.Cache.MethodHasBlockNumberParamTTLSeconds)
+	setInt(values, CACHE_METHOD_HAS_BLOCK_HASH_PARAM_TTL_ENVIRONMENT_KEY, fc.Cache.MethodHasBlockHashParamTTLSeconds)
+	setInt(values, CACHE_STATIC_METHOD_TTL_ENVIRONMENT_KEY, fc.Cache.StaticMethodTTLSeconds)
+	setInt(values, CACHE_METHOD_HAS_TX_HASH_PARAM_TTL_ENVIRONMENT_KEY, fc.Cache.MethodHasTxHashParamTTLSeconds)
+	if fc.Cache.WhitelistedHeaders != nil {
+		values[WHITELISTED_HEADERS_ENVIRONMENT_KEY] = strings.Join(fc.Cache.WhitelistedHeaders, ",")
+	}
+
+	setBool(values, METRIC_COLLECTION_ENABLED_ENVIRONMENT_KEY, fc.Routines.MetricCollectionEnabled)
+	setInt(values, METRIC_COMPACTION_ROUTINE_INTERVAL_ENVIRONMENT_KEY, fc.Routines.MetricCompactionIntervalSeconds)
+	setInt(values, METRIC_PARTITIONING_ROUTINE_INTERVAL_SECONDS_ENVIRONMENT_KEY, fc.Routines.MetricPartitioningIntervalSeconds)
+	setInt(values, METRIC_PARTITIONING_ROUTINE_DELAY_FIRST_RUN_SECONDS_ENVIRONMENT_KEY, fc.Routines.MetricPartitioningDelayFirstRunSeconds)
+	setInt(values, METRIC_PARTITIONING_PREFILL_PERIOD_DAYS_ENVIRONMENT_KEY, fc.Routines.MetricPartitioningPrefillPeriodDays)
+	setBool(values, METRIC_PRUNING_ENABLED_ENVIRONMENT_KEY, fc.Routines.MetricPruningEnabled)
+	setInt(values, METRIC_PRUNING_ROUTINE_INTERVAL_SECONDS_ENVIRONMENT_KEY, fc.Routines.MetricPruningIntervalSeconds)
+	setInt(values, METRIC_PRUNING_ROUTINE_DELAY_FIRST_RUN_SECONDS_ENVIRONMENT_KEY, fc.Routines.MetricPruningDelayFirstRunSeconds)
+	setInt(values, METRIC_PRUNING_MAX_REQUEST_METRICS_HISTORY_DAYS_ENVIRONMENT_KEY, fc.Routines.MetricPruningMaxRequestMetricsHistoryDays)
+
+	return values
+}
+
+// HostsConfig is the routing config of the hosts of the config file, built from the structure
+// of the file, for use in place of the host settings of the environment variables
+type HostsConfig struct {
+	BackendHostURLMap                map[string][]url.URL
+	BackendHostURLWeightMap          map[string]BackendWeights
+	PruningBackendHostURLMap         map[string][]url.URL
+	PruningBackendHostURLWeightMap   map[string]BackendWeights
+	PruningBackendRetentionWindowMap map[string]uint64
+	ShardBackendHostURLMap           map[string]IntervalURLMap
+	MethodRoutingBackendHostURLMap   map[string]MethodRoutingRules
+	AccessControlAllowOriginValueMap map[string]string
+}
+
+// HostsConfig returns the routing config of the hosts of the config file,
+// or nil if it defines no hosts, and error (if any)
+func (fc FileConfig) HostsConfig() (*HostsConfig, error) {
+	if len(fc.Hosts) == 0 {
+		return nil, nil
+	}
+
+	hosts := make([]string, 0, len(fc.Hosts))
+	for host := range fc.Hosts {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+
+	hostsConfig := &HostsConfig{
+		BackendHostURLMap:                make(map[string][]url.URL),
+		BackendHostURLWeightMap:          make(map[string]BackendWeights),
+		PruningBackendHostURLMap:         make(map[string][]url.URL),
+		PruningBackendHostURLWeightMap:   make(map[string]BackendWeights),
+		PruningBackendRetentionWindowMap: make(map[string]uint64),
+		ShardBackendHostURLMap:           make(map[string]IntervalURLMap),
+		MethodRoutingBackendHostURLMap:   make(map[string]MethodRoutingRules),
+		AccessControlAllowOriginValueMap: make(map[string]string),
+	}
+	for _, host := range hosts {
+		hostConfig := fc.Hosts[host]
+		if host == "" {
+			return nil, fmt.Errorf("empty host in config file")
+		}
+
+		if len(hostConfig.Backends) > 0 {
+			backendURLs, weights, err := parseFileConfigPool(host, hostConfig.Backends)
+			if err != nil {
+				return nil, err
+			}
+			hostsConfig.BackendHostURLMap[host] = backendURLs
+			if len(weights) > 0 {
+				hostsConfig.BackendHostURLWeightMap[host] = weights
+			}
+		}
+
+		if len(hostConfig.PruningBackends) > 0 {
+			backendURLs, weights, err := parseFileConfigPool(host, hostConfig.PruningBackends)
+			if err != nil {
+				return nil, err
+			}
+			hostsConfig.PruningBackendHostURLMap[host] = backendURLs
+			if len(weights) > 0 {
+				hostsConfig.PruningBackendHostURLWeightMap[host] = weights
+			}
+		}
+
+		if hostConfig.PruningRetentionWindow > 0 {
+			hostsConfig.PruningBackendRetentionWindowMap[host] = hostConfig.PruningRetentionWindow
+		}
+
+		if len(hostConfig.Shards) > 0 {
+			shards, err := shardsFromFileConfig(host, hostConfig.Shards)
+			if err != nil {
+				return nil, err
+			}
+			hostsConfig.ShardBackendHostURLMap[host] = shards
+		}
+
+		if len(hostConfig.MethodRoutes) > 0 {
+			rules := make(MethodRoutingRules, 0, len(hostConfig.MethodRoutes))
+			for _, route := range hostConfig.MethodRoutes {
+				if route.Method == "" || route.Method == MethodRoutingRuleWildcard {
+					return nil, fmt.Errorf("invalid method routing pattern (%s) in config file for host %s", route.Method, host)
+				}
+				if len(route.Backends) == 0 {
+					return nil, fmt.Errorf("no backends for method %s in config file for host %s", route.Method, host)
+				}
+				backendURLs, err := parseFileConfigURLs(host, route.Backends)
+				if err != nil {
+					return nil, err
+				}
+				rules = append(rules, MethodRoutingRule{Pattern: route.Method, BackendURLs: backendURLs})
+			}
+			hostsConfig.MethodRoutingBackendHostURLMap[host] = rules
+		}
+
+		if hostConfig.AccessControlAllowOrigin != "" {
+			hostsConfig.AccessControlAllowOriginValueMap[host] = hostConfig.AccessControlAllowOrigin
+		}
+	}
+
+	return hostsConfig, nil
+}
+
+// shardsFromFileConfig returns the IntervalURLMap of the shards of the host of the config file,
+// which must be ordered by their end heights, and error (if any)
+func shardsFromFileConfig(host string, shards []ShardFileConfig) (IntervalURLMap, error) {
+	prevEndHeight := uint64(0)
+	backendsByEndHeight := make(map[uint64][]*url.URL, len(shards))
+	for _, shard := range shards {
+		if shard.EndHeight == 0 {
+			return IntervalURLMap{}, fmt.Errorf("invalid shard end height (0) in config file for host %s", host)
+		}
+		if shard.EndHeight <= prevEndHeight {
+			return IntervalURLMap{}, fmt.Errorf(
+				"shards in config file for host %s must be ordered by unique end heights, shard for height %d found after shard for height %d",
+				host, shard.EndHeight, prevEndHeight,
+			)
+		}
+
+		backendURLs, err := parseFileConfigURLs(host, append([]string{shard.Backend}, shard.RedundantBackends...))
+		if err != nil {
+			return IntervalURLMap{}, err
+		}
+		backendRoutes := make([]*url.URL, 0, len(backendURLs))
+		for i := range backendURLs {
+			backendRoutes = append(backendRoutes, &backendURLs[i])
+		}
+
+		backendsByEndHeight[shard.EndHeight] = backendRoutes
+		prevEndHeight = shard.EndHeight
+	}
+
+	return NewIntervalURLPoolMap(backendsByEndHeight), nil
+}
+
+// parseFileConfigPool parses the members of a pool of backends of the host of the config file,
+// returning their urls, the weights of the members with a weight and error (if any)
+func parseFileConfigPool(host string, members []BackendFileConfig) ([]url.URL, BackendWeights, error) {
+	rawURLs := make([]string, 0, len(members))
+	for _, member := range members {
+		rawURLs = append(rawURLs, member.URL)
+	}
+	backendURLs, err := parseFileConfigURLs(host, rawURLs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	weights := BackendWeights{}
+	for i, member := range members {
+		if member.Weight == nil {
+			continue
+		}
+		if *member.Weight < 1 {
+			return nil, nil, fmt.Errorf("invalid weight %d of backend %s in config file for host %s, weights must be positive integers", *member.Weight, member.URL, host)
+		}
+		weights[backendURLs[i].String()] = *member.Weight
+	}
+	return backendURLs, weights, nil
+}
+
+// parseFileConfigURLs parses the backend urls of the host of the config file,
+// returning the urls and error (if any)
+func parseFileConfigURLs(host string, rawURLs []string) ([]url.URL, error) {
+	backendURLs := make([]url.URL, 0, len(rawURLs))
+	for _, rawURL := range rawURLs {
+		if rawURL == "" {
+			return nil, fmt.Errorf("empty backend url in config file for host %s", host)
+		}
+		backendURL, err := url.Parse(rawURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid backend url (%s) in config file for host %s: %w", rawURL, host, err)
+		}
+		backendURLs = append(backendURLs, *backendURL)
+	}
+	return backendURLs, nil
+}
+
+func setString(values map[string]string, key string, value string) {
+	if value != "" {
+		values[key] = value
+	}
+}
+
+func setBool(values map[string]string, key string, value *bool) {
+	if value != nil {
+		values[key] = strconv.FormatBool(*value)
+	}
+}
+
+func setInt(values map[string]string, key string, value *int) {
+	if value != nil {
+		values[key] = strconv.Itoa(*value)
+	}
+}
+
+// setConfigFileHosts sets each host setting of the config that is not set in the environment
+// from the hosts of the config file, if it defines any. Settings set
+// from the hosts of the config file have no raw value.
+func setConfigFileHosts(cfg *Config, values settingValues, hosts *HostsConfig) {
+	if hosts == nil {
+		return
+	}
+
+	// hosts with pruning backends, shards or method routes must have default backends,
+	// so the default map is always set and validated
+	if !values.isSetInEnvironment(PROXY_BACKEND_HOST_URL_MAP_ENVIRONMENT_KEY) {
+		cfg.ProxyBackendHostURLMapRaw = ""
+		cfg.ProxyBackendHostURLMapParsed = hosts.BackendHostURLMap
+		cfg.ProxyBackendHostURLWeightMap = hosts.BackendHostURLWeightMap
+	}
+	if !values.isSetInEnvironment(PROXY_PRUNING_BACKEND_HOST_URL_MAP_ENVIRONMENT_KEY) {
+		cfg.ProxyPruningBackendHostURLMapRaw = ""
+		cfg.ProxyPruningBackendHostURLMap = hosts.PruningBackendHostURLMap
+		cfg.ProxyPruningBackendHostURLWeightMap = hosts.PruningBackendHostURLWeightMap
+	}
+	if !values.isSetInEnvironment(PROXY_PRUNING_BACKEND_RETENTION_WINDOW_MAP_ENVIRONMENT_KEY) {
+		cfg.ProxyPruningBackendRetentionWindowMapRaw = ""
+		cfg.ProxyPruningBackendRetentionWindowMap = hosts.PruningBackendRetentionWindowMap
+	}
+	if !values.isSetInEnvironment(PROXY_SHARD_BACKEND_HOST_URL_MAP_ENVIRONMENT_KEY) {
+		cfg.ProxyShardBackendHostURLMapRaw = ""
+		cfg.ProxyShardBackendHostURLMap = hosts.ShardBackendHostURLMap
+	}
+	if !values.isSetInEnvironment(PROXY_METHOD_ROUTING_BACKEND_HOST_URL_MAP_ENVIRONMENT_KEY) {
+		cfg.ProxyMethodRoutingBackendHostURLMapRaw = ""
+		cfg.ProxyMethodRoutingBackendHostURLMap = hosts.MethodRoutingBackendHostURLMap
+	}
+	if !values.isSetInEnvironment(HOSTNAME_TO_ACCESS_CONTROL_ALLOW_ORIGIN_VALUE_MAP_ENVIRONMENT_KEY) {
+		cfg.HostnameToAccessControlAllowOriginValueMapRaw = ""
+		cfg.HostnameToAccessControlAllowOriginValueMap = hosts.AccessControlAllowOriginValueMap
+	}
+}
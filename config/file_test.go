@@ -0,0 +1,238 @@
+package config_test
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kava-labs/kava-proxy-service/config"
+	"github.com/stretchr/testify/require"
+)
+
+const testYAMLConfigFile = `
+log_level: DEBUG
+backend_pool_strategy: least-outstanding-requests
+height_based_routing_enabled: true
+hosts:
+  evm.kava.io:
+    backends:
+      - http://kava-archive-1:8545
+      - http://kava-archive-2:8545
+      - url: http://kava-canary:8545
+        weight: 5
+    pruning_backends:
+      - url: http://kava-pruning:8545
+        weight: 2
+    pruning_retention_window: 100000
+    shards:
+      - end_height: 100
+        backend: http://kava-shard-100:8545
+      - end_height: 200
+        backend: http://kava-shard-200:8545
+        redundant_backends: [http://kava-shard-200-2:8545]
+    method_routes:
+      - method: debug_*
+        backends: [http://kava-tracing:8545]
+    access_control_allow_origin: https://kava.io
+  evm.testnet.kava.io:
+    backends: [http://kava-testnet:8545]
+cache:
+  prefix: file-chain
+  static_method_ttl_seconds: 600
+settings:
+  PROXY_BACKEND_RETRY_ENABLED: "true"
+`
+
+// lookupEnvIn returns a function looking up settings in the environment of the values
+// instead of the environment of the process
+func lookupEnvIn(env map[string]string) func(key string) (string, bool) {
+	return func(key string) (string, bool) {
+		val, ok := env[key]
+		return val, ok
+	}
+}
+
+func writeConfigFile(t *testing.T, name string, contents string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(contents), 0o600))
+	return path
+}
+
+func TestUnitTestParseConfigFile(t *testing.T) {
+	values, hosts, err := config.ParseConfigFile(writeConfigFile(t, "config.yaml", testYAMLConfigFile))
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		config.LOG_LEVEL_ENVIRONMENT_KEY:                   "DEBUG",
+		config.PROXY_BACKEND_POOL_STRATEGY_ENVIRONMENT_KEY: "least-outstanding-requests",
+		config.PROXY_HEIGHT_BASED_ROUTING_ENABLED_KEY:      "true",
+		config.CACHE_PREFIX_ENVIRONMENT_KEY:                "file-chain",
+		config.CACHE_STATIC_METHOD_TTL_ENVIRONMENT_KEY:     "600",
+		config.PROXY_BACKEND_RETRY_ENABLED_ENVIRONMENT_KEY: "true",
+	}, values)
+
+	require.NotNil(t, hosts)
+	require.Equal(t, map[string][]url.URL{
+		"evm.kava.io": {
+			mustParseURL(t, "http://kava-archive-1:8545"),
+			mustParseURL(t, "http://kava-archive-2:8545"),
+			mustParseURL(t, "http://kava-canary:8545"),
+		},
+		"evm.testnet.kava.io": {mustParseURL(t, "http://kava-testnet:8545")},
+	}, hosts.BackendHostURLMap)
+	// only the pools of hosts with weighted members have weights
+	require.Equal(t, map[string]config.BackendWeights{
+		"evm.kava.io": {"http://kava-canary:8545": 5},
+	}, hosts.BackendHostURLWeightMap)
+	require.Equal(t, map[string][]url.URL{
+		"evm.kava.io": {mustParseURL(t, "http://kava-pruning:8545")},
+	}, hosts.PruningBackendHostURLMap)
+	require.Equal(t, map[string]config.BackendWeights{
+		"evm.kava.io": {"http://kava-pruning:8545": 2},
+	}, hosts.PruningBackendHostURLWeightMap)
+	require.Equal(t, map[string]uint64{"evm.kava.io": 100000}, hosts.PruningBackendRetentionWindowMap)
+	shards := hosts.ShardBackendHostURLMap["evm.kava.io"]
+	shardURLs, endHeight, found := shards.LookupAll(150)
+	require.True(t, found)
+	require.Equal(t, uint64(200), endHeight)
+	require.Equal(t, []string{"http://kava-shard-200:8545", "http://kava-shard-200-2:8545"}, []string{shardURLs[0].String(), shardURLs[1].String()})
+	require.Equal(t, config.MethodRoutingRules{
+		{Pattern: "debug_*", BackendURLs: []url.URL{mustParseURL(t, "http://kava-tracing:8545")}},
+	}, hosts.MethodRoutingBackendHostURLMap["evm.kava.io"])
+	require.Equal(t, map[string]string{"evm.kava.io": "https://kava.io"}, hosts.AccessControlAllowOriginValueMap)
+
+	// JSON is parsed the same as YAML
+	values, hosts, err = config.ParseConfigFile(writeConfigFile(t, "config.json", `{
+		"port": 7777,
+		"hosts": {"evm.kava.io": {"backends": ["http://kava-archive-1:8545"]}}
+	}`))
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{config.PROXY_SERVICE_PORT_ENVIRONMENT_KEY: "7777"}, values)
+	require.Equal(t, map[string][]url.URL{"evm.kava.io": {mustParseURL(t, "http://kava-archive-1:8545")}}, hosts.BackendHostURLMap)
+
+	// files without hosts leave the host settings to the environment
+	_, hosts, err = config.ParseConfigFile(writeConfigFile(t, "no-hosts.yaml", "log_level: INFO"))
+	require.NoError(t, err)
+	require.Nil(t, hosts)
+
+	_, _, err = config.ParseConfigFile(writeConfigFile(t, "unknown.yaml", "hostz: {}"))
+	require.ErrorContains(t, err, "field hostz not found")
+
+	// values are used as is, including the delimiters of the syntax of the environment variables
+	_, hosts, err = config.ParseConfigFile(writeConfigFile(t, "delimiter.yaml", `
+hosts:
+  evm.kava.io:
+    backends: ["http://kava-archive-1:8545/rpc?keys=a,b|c+d"]
+`))
+	require.NoError(t, err)
+	require.Equal(t, "http://kava-archive-1:8545/rpc?keys=a,b|c+d", hosts.BackendHostURLMap["evm.kava.io"][0].String())
+
+	_, _, err = config.ParseConfigFile(writeConfigFile(t, "empty-backend.yaml", `
+hosts:
+  evm.kava.io:
+    backends: [""]
+`))
+	require.ErrorContains(t, err, "empty backend url in config file for host evm.kava.io")
+
+	_, _, err = config.ParseConfigFile(writeConfigFile(t, "zero-weight.yaml", `
+hosts:
+  evm.kava.io:
+    backends: [{url: "http://kava-archive-1:8545", weight: 0}]
+`))
+	require.ErrorContains(t, err, "invalid weight 0 of backend http://kava-archive-1:8545")
+
+	_, _, err = config.ParseConfigFile(writeConfigFile(t, "unknown-backend-field.yaml", `
+hosts:
+  evm.kava.io:
+    backends: [{url: "http://kava-archive-1:8545", wieght: 5}]
+`))
+	require.ErrorContains(t, err, "field wieght not found")
+
+	_, _, err = config.ParseConfigFile(writeConfigFile(t, "unordered-shards.yaml", `
+hosts:
+  evm.kava.io:
+    backends: [http://kava-archive-1:8545]
+    shards:
+      - end_height: 200
+        backend: http://kava-shard-200:8545
+      - end_height: 100
+        backend: http://kava-shard-100:8545
+`))
+	require.ErrorContains(t, err, "must be ordered by unique end heights")
+
+	_, _, err = config.ParseConfigFile(writeConfigFile(t, "wildcard-method-route.yaml", `
+hosts:
+  evm.kava.io:
+    backends: [http://kava-archive-1:8545]
+    method_routes:
+      - method: "*"
+        backends: [http://kava-tracing:8545]
+`))
+	require.ErrorContains(t, err, "invalid method routing pattern")
+
+	_, _, err = config.ParseConfigFile(filepath.Join(t.TempDir(), "missing.yaml"))
+	require.Error(t, err)
+}
+
+func mustParseURL(t *testing.T, rawURL string) url.URL {
+	parsed, err := url.Parse(rawURL)
+	require.NoError(t, err)
+	return *parsed
+}
+
+func TestUnitTestReadConfigFromReadsConfigFile(t *testing.T) {
+	env := map[string]string{
+		config.PROXY_SERVICE_PORT_ENVIRONMENT_KEY:                      "7777",
+		config.CACHE_METHOD_HAS_BLOCK_NUMBER_PARAM_TTL_ENVIRONMENT_KEY: "600",
+		config.CACHE_METHOD_HAS_BLOCK_HASH_PARAM_TTL_ENVIRONMENT_KEY:   "600",
+		config.CACHE_METHOD_HAS_TX_HASH_PARAM_TTL_ENVIRONMENT_KEY:      "600",
+		// values in the environment override those of the config file
+		config.LOG_LEVEL_ENVIRONMENT_KEY:                           "ERROR",
+		config.REDIS_ENDPOINT_URL_ENVIRONMENT_KEY:                  "redis:6379",
+		config.PROXY_BACKEND_HEAD_TRACKING_ENABLED_ENVIRONMENT_KEY: "true",
+	}
+	sources := config.ConfigSources{
+		LookupEnv:  lookupEnvIn(env),
+		ConfigFile: writeConfigFile(t, "config.yaml", testYAMLConfigFile),
+	}
+
+	cfg, err := config.ReadConfigFrom(sources)
+	require.NoError(t, err)
+
+	require.Equal(t, "ERROR", cfg.LogLevel)
+	require.Equal(t, config.BackendPoolStrategyLeastOutstandingRequests, cfg.ProxyBackendPoolStrategy)
+	require.Len(t, cfg.ProxyBackendHostURLMapParsed["evm.kava.io"], 3)
+	require.Equal(t, 5, cfg.ProxyBackendHostURLWeightMap["evm.kava.io"].Weight(mustParseURL(t, "http://kava-canary:8545")))
+	require.Equal(t, config.DefaultBackendWeight, cfg.ProxyBackendHostURLWeightMap["evm.kava.io"].Weight(mustParseURL(t, "http://kava-archive-1:8545")))
+	require.Equal(t, "http://kava-pruning:8545", cfg.ProxyPruningBackendHostURLMap["evm.kava.io"][0].String())
+	require.Equal(t, 2, cfg.ProxyPruningBackendHostURLWeightMap["evm.kava.io"].Weight(mustParseURL(t, "http://kava-pruning:8545")))
+	require.Equal(t, uint64(100000), cfg.ProxyPruningBackendRetentionWindowMap["evm.kava.io"])
+	shards := cfg.ProxyShardBackendHostURLMap["evm.kava.io"]
+	shardURL, _, found := shards.Lookup(150)
+	require.True(t, found)
+	require.Equal(t, "http://kava-shard-200:8545", shardURL.String())
+	shardURLs, _, found := shards.LookupAll(150)
+	require.True(t, found)
+	require.Len(t, shardURLs, 2)
+	require.Equal(t, "http://kava-shard-200-2:8545", shardURLs[1].String())
+	require.Equal(t, "https://kava.io", cfg.GetAccessControlAllowOriginValue("evm.kava.io"))
+	require.Equal(t, "file-chain", cfg.CachePrefix)
+	require.True(t, cfg.ProxyBackendRetryEnabled)
+	require.True(t, cfg.ProxyBackendHeadTrackingEnabled)
+	require.NoError(t, config.Validate(cfg))
+
+	// host settings in the environment override the hosts of the config file
+	env[config.PROXY_PRUNING_BACKEND_HOST_URL_MAP_ENVIRONMENT_KEY] = "evm.kava.io>http://kava-pruning-env:8545"
+	cfg, err = config.ReadConfigFrom(sources)
+	require.NoError(t, err)
+	require.Equal(t, "http://kava-pruning-env:8545", cfg.ProxyPruningBackendHostURLMap["evm.kava.io"][0].String())
+	require.Empty(t, cfg.ProxyPruningBackendHostURLWeightMap)
+	require.Len(t, cfg.ProxyBackendHostURLMapParsed["evm.kava.io"], 3)
+	require.NoError(t, config.Validate(cfg))
+
+	// the config file failing to load is an error
+	sources.ConfigFile = filepath.Join(t.TempDir(), "missing.yaml")
+	cfg, err = config.ReadConfigFrom(sources)
+	require.ErrorContains(t, err, "failed to load PROXY_CONFIG_FILE")
+	require.Equal(t, "ERROR", cfg.LogLevel)
+}
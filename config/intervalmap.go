@@ -8,24 +8,39 @@ import (
 // IntervalURLMap stores URLs associated with a range of numbers.
 // The intervals are defined by their endpoints and must not overlap.
 // The intervals are inclusive of the endpoints.
+// Each interval may have redundant URLs, the first of which is its primary URL.
 type IntervalURLMap struct {
-	UrlByEndHeight map[uint64]*url.URL
-	endpoints      []uint64
+	UrlByEndHeight  map[uint64]*url.URL
+	URLsByEndHeight map[uint64][]*url.URL
+	endpoints       []uint64
 }
 
 // NewIntervalURLMap creates a new IntervalMap from a map of interval endpoint => url.
 // The intervals are inclusive of their endpoint.
 // ie. if the lowest value endpoint in the map is 10, the interval is for all numbers 1 through 10.
 func NewIntervalURLMap(urlByEndHeight map[uint64]*url.URL) IntervalURLMap {
-	endpoints := make([]uint64, 0, len(urlByEndHeight))
-	for e := range urlByEndHeight {
+	urlsByEndHeight := make(map[uint64][]*url.URL, len(urlByEndHeight))
+	for e, u := range urlByEndHeight {
+		urlsByEndHeight[e] = []*url.URL{u}
+	}
+	return NewIntervalURLPoolMap(urlsByEndHeight)
+}
+
+// NewIntervalURLPoolMap creates a new IntervalMap from a map of interval endpoint => redundant urls,
+// in order of preference. Every interval must have at least one url.
+func NewIntervalURLPoolMap(urlsByEndHeight map[uint64][]*url.URL) IntervalURLMap {
+	urlByEndHeight := make(map[uint64]*url.URL, len(urlsByEndHeight))
+	endpoints := make([]uint64, 0, len(urlsByEndHeight))
+	for e, urls := range urlsByEndHeight {
+		urlByEndHeight[e] = urls[0]
 		endpoints = append(endpoints, e)
 	}
 	sort.Slice(endpoints, func(i, j int) bool { return endpoints[i] < endpoints[j] })
 
 	return IntervalURLMap{
-		UrlByEndHeight: urlByEndHeight,
-		endpoints:      endpoints,
+		UrlByEndHeight:  urlByEndHeight,
+		URLsByEndHeight: urlsByEndHeight,
+		endpoints:       endpoints,
 	}
 }
 
@@ -39,3 +54,12 @@ func (im *IntervalURLMap) Lookup(num uint64) (*url.URL, uint64, bool) {
 
 	return nil, 0, false
 }
+
+// LookupAll finds every url associated with the interval containing the number, if it exists.
+func (im *IntervalURLMap) LookupAll(num uint64) ([]*url.URL, uint64, bool) {
+	_, endHeight, found := im.Lookup(num)
+	if !found {
+		return nil, 0, false
+	}
+	return im.URLsByEndHeight[endHeight], endHeight, true
+}
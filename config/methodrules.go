@@ -0,0 +1,110 @@
+package config
+
+import (
+	"net/url"
+	"strings"
+	"time"
+)
+
+// MethodRoutingRuleWildcard is the suffix of a method routing rule's pattern
+// that makes it match every method starting with the rest of the pattern.
+// ie. "debug_*" matches "debug_traceTransaction" & "debug_traceCall"
+const MethodRoutingRuleWildcard = "*"
+
+// MethodRoutingRule routes requests for JSON-RPC methods matching the pattern
+// to a dedicated pool of backends
+type MethodRoutingRule struct {
+	// a method name, or a method name prefix followed by MethodRoutingRuleWildcard
+	Pattern     string
+	BackendURLs []url.URL
+}
+
+// Matches returns true if the JSON-RPC method matches the pattern of the rule
+func (rule MethodRoutingRule) Matches(method string) bool {
+	return methodMatchesPattern(method, rule.Pattern)
+}
+
+// methodMatchesPattern returns true if the JSON-RPC method is the pattern, or starts with the
+// rest of the pattern if the pattern ends with MethodRoutingRuleWildcard
+func methodMatchesPattern(method string, pattern string) bool {
+	if prefix, isPrefix := strings.CutSuffix(pattern, MethodRoutingRuleWildcard); isPrefix {
+		return strings.HasPrefix(method, prefix)
+	}
+	return method == pattern
+}
+
+// MethodRoutingRules are the method routing rules of a host, in the order they are matched against requests
+type MethodRoutingRules []MethodRoutingRule
+
+// Lookup finds the first rule matching the JSON-RPC method, if it exists.
+func (rules MethodRoutingRules) Lookup(method string) (MethodRoutingRule, bool) {
+	for _, rule := range rules {
+		if rule.Matches(method) {
+			return rule, true
+		}
+	}
+	return MethodRoutingRule{}, false
+}
+
+// BackendTimeoutMapAnyHost is the host of the timeout rules of a backend timeout map
+// that apply to requests for hosts without a matching rule of their own
+const BackendTimeoutMapAnyHost = "*"
+
+// MethodTimeoutRule limits how long the backend may take to respond to requests
+// for JSON-RPC methods matching the pattern
+type MethodTimeoutRule struct {
+	// a method name, or a method name prefix followed by MethodRoutingRuleWildcard.
+	// MethodRoutingRuleWildcard alone matches every method
+	Pattern string
+	Timeout time.Duration
+}
+
+// Matches returns true if the JSON-RPC method matches the pattern of the rule
+func (rule MethodTimeoutRule) Matches(method string) bool {
+	return methodMatchesPattern(method, rule.Pattern)
+}
+
+// MethodTimeoutRules are the timeout rules of a host, in the order they are matched against requests
+type MethodTimeoutRules []MethodTimeoutRule
+
+// Lookup finds the first rule matching the JSON-RPC method, if it exists.
+func (rules MethodTimeoutRules) Lookup(method string) (MethodTimeoutRule, bool) {
+	for _, rule := range rules {
+		if rule.Matches(method) {
+			return rule, true
+		}
+	}
+	return MethodTimeoutRule{}, false
+}
+
+// QuorumReadMapAnyHost is the host of the quorum rules of a quorum read map
+// that apply to requests for hosts without a matching rule of their own
+const QuorumReadMapAnyHost = "*"
+
+// MethodQuorumRule requires requests for JSON-RPC methods matching the pattern to be sent to
+// Backends backends, and at least Agreements of them to respond with the same result
+type MethodQuorumRule struct {
+	// a method name, or a method name prefix followed by MethodRoutingRuleWildcard.
+	// MethodRoutingRuleWildcard alone matches every method
+	Pattern    string
+	Backends   int
+	Agreements int
+}
+
+// Matches returns true if the JSON-RPC method matches the pattern of the rule
+func (rule MethodQuorumRule) Matches(method string) bool {
+	return methodMatchesPattern(method, rule.Pattern)
+}
+
+// MethodQuorumRules are the quorum rules of a host, in the order they are matched against requests
+type MethodQuorumRules []MethodQuorumRule
+
+// Lookup finds the first rule matching the JSON-RPC method, if it exists.
+func (rules MethodQuorumRules) Lookup(method string) (MethodQuorumRule, bool) {
+	for _, rule := range rules {
+		if rule.Matches(method) {
+			return rule, true
+		}
+	}
+	return MethodQuorumRule{}, false
+}
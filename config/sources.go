@@ -0,0 +1,144 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// ConfigSources are the sources the settings of the service config are read from.
+// A setting set in both the environment and the config file takes its value from the environment.
+type ConfigSources struct {
+	// looks up the value of a setting in the environment by its environment key
+	LookupEnv func(key string) (string, bool)
+	// path of the YAML or JSON config file, none if empty
+	ConfigFile string
+}
+
+// EnvironmentConfigSources returns the sources of the service config of the process,
+// its environment and the config file whose path is set in it
+func EnvironmentConfigSources() ConfigSources {
+	return ConfigSources{
+		LookupEnv:  os.LookupEnv,
+		ConfigFile: os.Getenv(PROXY_CONFIG_FILE_ENVIRONMENT_KEY),
+	}
+}
+
+// HasConfigFile returns true if the settings are read from a config file
+func (cs ConfigSources) HasConfigFile() bool {
+	return cs.ConfigFile != ""
+}
+
+// load reads the config file of the sources, returning the values of the settings
+// in order of precedence, the hosts of the config file and error (if any)
+func (cs ConfigSources) load() (settingValues, *HostsConfig, error) {
+	values := settingValues{lookupEnv: cs.LookupEnv}
+	if values.lookupEnv == nil {
+		values.lookupEnv = noEnvironment
+	}
+
+	if cs.ConfigFile == "" {
+		return values, nil, nil
+	}
+
+	var hosts *HostsConfig
+	var err error
+	if values.configFile, hosts, err = ParseConfigFile(cs.ConfigFile); err != nil {
+		return values, nil, fmt.Errorf("failed to load %s %s: %w", PROXY_CONFIG_FILE_ENVIRONMENT_KEY, cs.ConfigFile, err)
+	}
+
+	return values, hosts, nil
+}
+
+// processEnvironment returns the values of the settings in the environment of the process only
+func processEnvironment() settingValues {
+	return settingValues{lookupEnv: os.LookupEnv}
+}
+
+func noEnvironment(string) (string, bool) {
+	return "", false
+}
+
+// settingValues looks up the values of settings by environment key
+// in the sources of the service config in order of precedence
+type settingValues struct {
+	lookupEnv  func(key string) (string, bool)
+	configFile map[string]string
+}
+
+// lookup returns the value of the setting from the source with the highest precedence it is set in
+func (sv settingValues) lookup(key string) (string, bool) {
+	if val, ok := sv.lookupEnv(key); ok {
+		return val, true
+	}
+	val, ok := sv.configFile[key]
+	return val, ok
+}
+
+// isSetInEnvironment returns true if the setting is set in the environment
+func (sv settingValues) isSetInEnvironment(key string) bool {
+	_, ok := sv.lookupEnv(key)
+	return ok
+}
+
+// get returns the value of the setting, or the empty string if not set
+func (sv settingValues) get(key string) string {
+	val, _ := sv.lookup(key)
+	return val
+}
+
+// orDefault returns the value of the setting, or if not set the fallback value
+func (sv settingValues) orDefault(key string, fallback string) string {
+	if val, ok := sv.lookup(key); ok {
+		return val
+	}
+	return fallback
+}
+
+// orDefaultBool returns the boolean value of the setting, or if not set or invalid the fallback value
+func (sv settingValues) orDefaultBool(key string, fallback bool) bool {
+	if val, ok := sv.lookup(key); ok {
+		val, err := strconv.ParseBool(val)
+		if err != nil {
+			return fallback
+		}
+		return val
+	}
+	return fallback
+}
+
+// orDefaultInt64 returns the int64 value of the setting, or if not set or invalid the fallback value
+func (sv settingValues) orDefaultInt64(key string, fallback int64) int64 {
+	if val, ok := sv.lookup(key); ok {
+		val, err := strconv.ParseInt(val, 0, 64)
+		if err != nil {
+			return fallback
+		}
+		return val
+	}
+	return fallback
+}
+
+// orDefaultInt returns the int value of the setting, or if not set or invalid the fallback value
+func (sv settingValues) orDefaultInt(key string, fallback int) int {
+	if val, ok := sv.lookup(key); ok {
+		val, err := strconv.Atoi(val)
+		if err != nil {
+			return fallback
+		}
+		return val
+	}
+	return fallback
+}
+
+// orDefaultFloat64 returns the float64 value of the setting, or if not set or invalid the fallback value
+func (sv settingValues) orDefaultFloat64(key string, fallback float64) float64 {
+	if val, ok := sv.lookup(key); ok {
+		val, err := strconv.ParseFloat(val, 64)
+		if err != nil {
+			return fallback
+		}
+		return val
+	}
+	return fallback
+}
@@ -0,0 +1,155 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// seperator for the settings of the transport of a single backend
+// e.g. http://kava-archive:8545>response_header_timeout_seconds=300|max_idle_conns_per_host=100
+const PROXY_BACKEND_TRANSPORT_MAP_SETTING_DELIMITER = "|"
+
+// seperator for the name and value of a single transport setting
+const PROXY_BACKEND_TRANSPORT_MAP_VALUE_DELIMITER = "="
+
+// Names of the settings of the transport of a backend
+const (
+	BackendTransportDialTimeoutSeconds           = "dial_timeout_seconds"
+	BackendTransportResponseHeaderTimeoutSeconds = "response_header_timeout_seconds"
+	BackendTransportMaxIdleConnsPerHost          = "max_idle_conns_per_host"
+	BackendTransportClientCertFile               = "client_cert_file"
+	BackendTransportClientKeyFile                = "client_key_file"
+	BackendTransportCABundleFile                 = "ca_bundle_file"
+)
+
+// BackendTransportConfig is the config of the transport used to proxy requests to a single backend.
+// Zero values use the defaults of the http.DefaultTransport.
+type BackendTransportConfig struct {
+	// maximum amount of time to wait for a connection to the backend to be established
+	DialTimeout time.Duration
+	// maximum amount of time to wait for the backend to respond with the headers of a response
+	// after the request has been written
+	ResponseHeaderTimeout time.Duration
+	// maximum number of idle connections to keep open to the backend
+	MaxIdleConnsPerHost int
+	// certificate & key presented to backends behind mTLS
+	ClientCertFile string
+	ClientKeyFile  string
+	// PEM encoded certificates of the authorities trusted to sign the certificate of the backend,
+	// the system's trusted authorities when empty
+	CABundleFile string
+}
+
+// HasTLSConfig returns true when the transport has a client certificate or CA bundle
+func (c BackendTransportConfig) HasTLSConfig() bool {
+	return c.ClientCertFile != "" || c.ClientKeyFile != "" || c.CABundleFile != ""
+}
+
+// TLSConfig loads the client certificate and CA bundle of the transport,
+// returning the TLS config and error (if any)
+func (c BackendTransportConfig) TLSConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if c.ClientCertFile != "" || c.ClientKeyFile != "" {
+		if c.ClientCertFile == "" || c.ClientKeyFile == "" {
+			return nil, fmt.Errorf("both %s and %s must be specified for a client certificate", BackendTransportClientCertFile, BackendTransportClientKeyFile)
+		}
+		certificate, err := tls.LoadX509KeyPair(c.ClientCertFile, c.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate %s: %w", c.ClientCertFile, err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+
+	if c.CABundleFile != "" {
+		bundle, err := os.ReadFile(c.CABundleFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle %s: %w", c.CABundleFile, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(bundle) {
+			return nil, fmt.Errorf("CA bundle %s contains no PEM encoded certificates", c.CABundleFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return tlsConfig, nil
+}
+
+// ParseRawBackendTransportMap attempts to parse mappings of backend url to the settings of its transport
+// e.g. "http://kava-archive:8545>response_header_timeout_seconds=300,https://kava-mtls:8545>client_cert_file=/certs/proxy.crt|client_key_file=/certs/proxy.key"
+// returning the mapping and error (if any)
+func ParseRawBackendTransportMap(raw string) (map[string]BackendTransportConfig, error) {
+	parsed := make(map[string]BackendTransportConfig)
+	// allow empty transport map (every backend uses the default transport)
+	if raw == "" {
+		return parsed, nil
+	}
+
+	var combinedErr error
+	for _, entry := range strings.Split(raw, PROXY_BACKEND_HOST_URL_MAP_ENTRY_DELIMITER) {
+		rawBackendURL, rawSettings, found := strings.Cut(entry, PROXY_BACKEND_HOST_URL_MAP_SUB_COMPONENT_DELIMITER)
+		if !found {
+			combinedErr = errors.Join(combinedErr, fmt.Errorf("expected transport definition like <backend-route>><setting>=<value>(|<setting>=<value>)*, found '%s'", entry))
+			continue
+		}
+
+		backendURL, err := url.Parse(rawBackendURL)
+		if err != nil || rawBackendURL == "" {
+			combinedErr = errors.Join(combinedErr, fmt.Errorf("invalid transport backend route (%s): %v", rawBackendURL, err))
+			continue
+		}
+
+		transportConfig, err := parseBackendTransportSettings(rawSettings)
+		if err != nil {
+			combinedErr = errors.Join(combinedErr, fmt.Errorf("invalid transport settings for backend %s: %w", rawBackendURL, err))
+			continue
+		}
+		parsed[backendURL.String()] = transportConfig
+	}
+
+	return parsed, combinedErr
+}
+
+// parseBackendTransportSettings parses the settings of the transport of a single backend
+func parseBackendTransportSettings(raw string) (BackendTransportConfig, error) {
+	var transportConfig BackendTransportConfig
+	for _, setting := range strings.Split(raw, PROXY_BACKEND_TRANSPORT_MAP_SETTING_DELIMITER) {
+		name, value, found := strings.Cut(setting, PROXY_BACKEND_TRANSPORT_MAP_VALUE_DELIMITER)
+		if !found || value == "" {
+			return transportConfig, fmt.Errorf("expected setting like <setting>=<value>, found '%s'", setting)
+		}
+
+		switch name {
+		case BackendTransportDialTimeoutSeconds, BackendTransportResponseHeaderTimeoutSeconds, BackendTransportMaxIdleConnsPerHost:
+			number, err := strconv.Atoi(value)
+			if err != nil || number < 1 {
+				return transportConfig, fmt.Errorf("expected %s to be a positive integer, got %s", name, value)
+			}
+			switch name {
+			case BackendTransportDialTimeoutSeconds:
+				transportConfig.DialTimeout = time.Duration(number) * time.Second
+			case BackendTransportResponseHeaderTimeoutSeconds:
+				transportConfig.ResponseHeaderTimeout = time.Duration(number) * time.Second
+			default:
+				transportConfig.MaxIdleConnsPerHost = number
+			}
+		case BackendTransportClientCertFile:
+			transportConfig.ClientCertFile = value
+		case BackendTransportClientKeyFile:
+			transportConfig.ClientKeyFile = value
+		case BackendTransportCABundleFile:
+			transportConfig.CABundleFile = value
+		default:
+			return transportConfig, fmt.Errorf("unknown setting %s", name)
+		}
+	}
+	return transportConfig, nil
+}
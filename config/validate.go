@@ -11,6 +11,12 @@ import (
 
 var (
 	ValidLogLevels = [4]string{"TRACE", "DEBUG", "INFO", "ERROR"}
+	// supported strategies for choosing a member of a backend pool
+	ValidBackendPoolStrategies = [3]string{
+		BackendPoolStrategyRoundRobin,
+		BackendPoolStrategyLeastOutstandingRequests,
+		BackendPoolStrategyRandomTwoChoices,
+	}
 	// restrict to max 1 month to guarantee constraint that
 	// metric partitioning routine never needs to create partitions
 	// spanning more than 2 calendar months
@@ -36,11 +42,15 @@ func Validate(config Config) error {
 		allErrs = fmt.Errorf("invalid %s specified %s, supported values are %v", LOG_LEVEL_ENVIRONMENT_KEY, config.LogLevel, ValidLogLevels)
 	}
 
-	if err = validateHostURLMap(config.ProxyBackendHostURLMapRaw, false); err != nil {
+	if err = validateHostURLMap(config.ProxyBackendHostURLMapRaw, config.ProxyBackendHostURLMapParsed, false); err != nil {
 		allErrs = errors.Join(allErrs, fmt.Errorf("invalid %s specified %s", PROXY_BACKEND_HOST_URL_MAP_ENVIRONMENT_KEY, config.ProxyBackendHostURLMapRaw), err)
 	}
 
-	if err = validateHostURLMap(config.ProxyPruningBackendHostURLMapRaw, true); err != nil {
+	if !isValidBackendPoolStrategy(config.ProxyBackendPoolStrategy) {
+		allErrs = errors.Join(allErrs, fmt.Errorf("invalid %s specified %s, supported values are %v", PROXY_BACKEND_POOL_STRATEGY_ENVIRONMENT_KEY, config.ProxyBackendPoolStrategy, ValidBackendPoolStrategies))
+	}
+
+	if err = validateHostURLMap(config.ProxyPruningBackendHostURLMapRaw, config.ProxyPruningBackendHostURLMap, true); err != nil {
 		allErrs = errors.Join(allErrs, fmt.Errorf("invalid %s specified %s", PROXY_PRUNING_BACKEND_HOST_URL_MAP_ENVIRONMENT_KEY, config.ProxyPruningBackendHostURLMapRaw), err)
 	}
 
@@ -48,6 +58,10 @@ func Validate(config Config) error {
 		allErrs = errors.Join(allErrs, fmt.Errorf("invalid %s specified %s", PROXY_SHARD_BACKEND_HOST_URL_MAP_ENVIRONMENT_KEY, config.ProxyShardBackendHostURLMapRaw), err)
 	}
 
+	if err = validateMethodRoutingBackendHostURLMap(config); err != nil {
+		allErrs = errors.Join(allErrs, fmt.Errorf("invalid %s specified %s", PROXY_METHOD_ROUTING_BACKEND_HOST_URL_MAP_ENVIRONMENT_KEY, config.ProxyMethodRoutingBackendHostURLMapRaw), err)
+	}
+
 	if err = validateDefaultHostMapContainsHosts(
 		PROXY_PRUNING_BACKEND_HOST_URL_MAP_ENVIRONMENT_KEY,
 		config.ProxyBackendHostURLMapParsed,
@@ -56,6 +70,86 @@ func Validate(config Config) error {
 		allErrs = errors.Join(allErrs, err)
 	}
 
+	if err = validateRetentionWindowMap(config); err != nil {
+		allErrs = errors.Join(allErrs, fmt.Errorf("invalid %s specified %s", PROXY_PRUNING_BACKEND_RETENTION_WINDOW_MAP_ENVIRONMENT_KEY, config.ProxyPruningBackendRetentionWindowMapRaw), err)
+	}
+
+	if err = validateBackendTransportMap(config); err != nil {
+		allErrs = errors.Join(allErrs, fmt.Errorf("invalid %s specified %s", PROXY_BACKEND_TRANSPORT_MAP_ENVIRONMENT_KEY, config.ProxyBackendTransportMapRaw), err)
+	}
+
+	if config.ProxyBackendTimeoutEnabled {
+		if _, err = ParseRawBackendTimeoutMap(config.ProxyBackendTimeoutMapRaw); err != nil {
+			allErrs = errors.Join(allErrs, fmt.Errorf("invalid %s specified %s", PROXY_BACKEND_TIMEOUT_MAP_ENVIRONMENT_KEY, config.ProxyBackendTimeoutMapRaw), err)
+		}
+	}
+
+	if config.ProxyQuorumReadEnabled {
+		if _, err = ParseRawQuorumReadMap(config.ProxyQuorumReadMapRaw); err != nil {
+			allErrs = errors.Join(allErrs, fmt.Errorf("invalid %s specified %s", PROXY_QUORUM_READ_MAP_ENVIRONMENT_KEY, config.ProxyQuorumReadMapRaw), err)
+		}
+	}
+
+	if config.ProxyBackendHealthCheckEnabled {
+		if err = validateBackendHealthCheckConfig(config); err != nil {
+			allErrs = errors.Join(allErrs, err)
+		}
+	}
+
+	if config.ProxyBackendCircuitBreakerEnabled {
+		if err = validateBackendCircuitBreakerConfig(config); err != nil {
+			allErrs = errors.Join(allErrs, err)
+		}
+	}
+
+	if config.ProxyBackendRetryEnabled {
+		if err = validateBackendRetryConfig(config); err != nil {
+			allErrs = errors.Join(allErrs, err)
+		}
+	}
+
+	if config.ProxyBackendHedgingEnabled {
+		if err = validateBackendHedgingConfig(config); err != nil {
+			allErrs = errors.Join(allErrs, err)
+		}
+	}
+
+	if config.ProxyMissingStateFallbackEnabled && len(config.ProxyMissingStateErrorPatterns) == 0 {
+		allErrs = errors.Join(allErrs, fmt.Errorf("invalid %s specified, must not be empty", PROXY_MISSING_STATE_ERROR_PATTERNS_ENVIRONMENT_KEY))
+	}
+
+	if config.ProxyShadowEnabled {
+		if err = validateShadowConfig(config); err != nil {
+			allErrs = errors.Join(allErrs, err)
+		}
+	}
+
+	if config.ProxyBackendHeadTrackingEnabled {
+		if err = validateBackendHeadTrackingConfig(config); err != nil {
+			allErrs = errors.Join(allErrs, err)
+		}
+	}
+
+	if config.ProxyAdminAPIEnabled {
+		if err = validateAdminAPIConfig(config); err != nil {
+			allErrs = errors.Join(allErrs, err)
+		}
+	}
+
+	if config.ProxyConfigFile != "" {
+		if _, _, err = ParseConfigFile(config.ProxyConfigFile); err != nil {
+			allErrs = errors.Join(allErrs, fmt.Errorf("invalid %s specified %s", PROXY_CONFIG_FILE_ENVIRONMENT_KEY, config.ProxyConfigFile), err)
+		}
+	}
+
+	if config.ProxyConfigFile != "" && config.ProxyRoutingConfigReloadInterval <= 0 {
+		allErrs = errors.Join(allErrs, fmt.Errorf("invalid %s specified %s, must be greater than zero", PROXY_ROUTING_CONFIG_RELOAD_INTERVAL_SECONDS_ENVIRONMENT_KEY, config.ProxyRoutingConfigReloadInterval))
+	}
+
+	if config.ProxyStickyFilterRoutingEnabled && config.ProxyStickyFilterRoutingTTL <= 0 {
+		allErrs = errors.Join(allErrs, fmt.Errorf("invalid %s specified %s, must be greater than zero", PROXY_STICKY_FILTER_ROUTING_TTL_SECONDS_ENVIRONMENT_KEY, config.ProxyStickyFilterRoutingTTL))
+	}
+
 	_, err = strconv.Atoi(config.ProxyServicePort)
 
 	if err != nil {
@@ -108,8 +202,162 @@ func checkTTLConfig(cacheTTL time.Duration, cacheTTLKey string) error {
 	return fmt.Errorf("invalid %s specified %s, must be greater than zero or -1", cacheTTLKey, cacheTTL)
 }
 
-// validateHostURLMap validates a raw backend host URL map, optionally allowing the map to be empty
-func validateHostURLMap(raw string, allowEmpty bool) error {
+// validateBackendHealthCheckConfig validates the settings for actively health checking backends
+func validateBackendHealthCheckConfig(config Config) error {
+	var allErrs error
+
+	if config.ProxyBackendHealthCheckInterval <= 0 {
+		allErrs = errors.Join(allErrs, fmt.Errorf("invalid %s specified %s, must be greater than zero", PROXY_BACKEND_HEALTHCHECK_INTERVAL_SECONDS_ENVIRONMENT_KEY, config.ProxyBackendHealthCheckInterval))
+	}
+	if config.ProxyBackendHealthCheckTimeout <= 0 {
+		allErrs = errors.Join(allErrs, fmt.Errorf("invalid %s specified %s, must be greater than zero", PROXY_BACKEND_HEALTHCHECK_TIMEOUT_SECONDS_ENVIRONMENT_KEY, config.ProxyBackendHealthCheckTimeout))
+	}
+	if config.ProxyBackendHealthCheckMethod == "" {
+		allErrs = errors.Join(allErrs, fmt.Errorf("invalid %s specified %s, must not be empty", PROXY_BACKEND_HEALTHCHECK_METHOD_ENVIRONMENT_KEY, config.ProxyBackendHealthCheckMethod))
+	}
+	if config.ProxyBackendHealthCheckFailureThreshold < 1 {
+		allErrs = errors.Join(allErrs, fmt.Errorf("invalid %s specified %d, must be at least 1", PROXY_BACKEND_HEALTHCHECK_FAILURE_THRESHOLD_ENVIRONMENT_KEY, config.ProxyBackendHealthCheckFailureThreshold))
+	}
+
+	return allErrs
+}
+
+// validateBackendCircuitBreakerConfig validates the settings for the circuit breaker of each backend
+func validateBackendCircuitBreakerConfig(config Config) error {
+	var allErrs error
+
+	if config.ProxyBackendCircuitBreakerFailureThreshold < 1 {
+		allErrs = errors.Join(allErrs, fmt.Errorf("invalid %s specified %d, must be at least 1", PROXY_BACKEND_CIRCUIT_BREAKER_FAILURE_THRESHOLD_ENVIRONMENT_KEY, config.ProxyBackendCircuitBreakerFailureThreshold))
+	}
+	if config.ProxyBackendCircuitBreakerCooldown <= 0 {
+		allErrs = errors.Join(allErrs, fmt.Errorf("invalid %s specified %s, must be greater than zero", PROXY_BACKEND_CIRCUIT_BREAKER_COOLDOWN_SECONDS_ENVIRONMENT_KEY, config.ProxyBackendCircuitBreakerCooldown))
+	}
+	if config.ProxyBackendCircuitBreakerHalfOpenRequests < 1 {
+		allErrs = errors.Join(allErrs, fmt.Errorf("invalid %s specified %d, must be at least 1", PROXY_BACKEND_CIRCUIT_BREAKER_HALF_OPEN_REQUESTS_ENVIRONMENT_KEY, config.ProxyBackendCircuitBreakerHalfOpenRequests))
+	}
+	if config.ProxyBackendCircuitBreakerSlowRequestThreshold < 0 {
+		allErrs = errors.Join(allErrs, fmt.Errorf("invalid %s specified %s, must not be negative", PROXY_BACKEND_CIRCUIT_BREAKER_SLOW_REQUEST_THRESHOLD_MS_ENVIRONMENT_KEY, config.ProxyBackendCircuitBreakerSlowRequestThreshold))
+	}
+
+	return allErrs
+}
+
+// validateBackendRetryConfig validates the settings for retrying failed requests against other backends
+func validateBackendRetryConfig(config Config) error {
+	var allErrs error
+
+	if config.ProxyBackendRetryMaxRetries < 1 {
+		allErrs = errors.Join(allErrs, fmt.Errorf("invalid %s specified %d, must be at least 1", PROXY_BACKEND_RETRY_MAX_RETRIES_ENVIRONMENT_KEY, config.ProxyBackendRetryMaxRetries))
+	}
+	if config.ProxyBackendRetryBackoff < 0 {
+		allErrs = errors.Join(allErrs, fmt.Errorf("invalid %s specified %s, must not be negative", PROXY_BACKEND_RETRY_BACKOFF_MILLISECONDS_ENVIRONMENT_KEY, config.ProxyBackendRetryBackoff))
+	}
+
+	return allErrs
+}
+
+// validateBackendHedgingConfig validates the settings for hedging slow requests to a second backend
+func validateBackendHedgingConfig(config Config) error {
+	var allErrs error
+
+	if config.ProxyBackendHedgingPercentile < 1 || config.ProxyBackendHedgingPercentile > 100 {
+		allErrs = errors.Join(allErrs, fmt.Errorf("invalid %s specified %d, must be between 1 and 100", PROXY_BACKEND_HEDGING_PERCENTILE_ENVIRONMENT_KEY, config.ProxyBackendHedgingPercentile))
+	}
+	if config.ProxyBackendHedgingDefaultDelay <= 0 {
+		allErrs = errors.Join(allErrs, fmt.Errorf("invalid %s specified %s, must be greater than zero", PROXY_BACKEND_HEDGING_DEFAULT_DELAY_MILLISECONDS_ENVIRONMENT_KEY, config.ProxyBackendHedgingDefaultDelay))
+	}
+	if config.ProxyBackendHedgingMinDelay < 0 {
+		allErrs = errors.Join(allErrs, fmt.Errorf("invalid %s specified %s, must not be negative", PROXY_BACKEND_HEDGING_MIN_DELAY_MILLISECONDS_ENVIRONMENT_KEY, config.ProxyBackendHedgingMinDelay))
+	}
+
+	return allErrs
+}
+
+// validateShadowConfig validates the settings for mirroring requests to shadow backends
+func validateShadowConfig(config Config) error {
+	var allErrs error
+
+	if err := validateHostURLMap(config.ProxyShadowBackendHostURLMapRaw, config.ProxyShadowBackendHostURLMap, false); err != nil {
+		allErrs = errors.Join(allErrs, fmt.Errorf("invalid %s specified %s", PROXY_SHADOW_BACKEND_HOST_URL_MAP_ENVIRONMENT_KEY, config.ProxyShadowBackendHostURLMapRaw), err)
+	}
+	for host, backendURLs := range config.ProxyShadowBackendHostURLMap {
+		if len(backendURLs) != 1 {
+			allErrs = errors.Join(allErrs, fmt.Errorf("invalid %s specified %s, host %s must have exactly one shadow backend", PROXY_SHADOW_BACKEND_HOST_URL_MAP_ENVIRONMENT_KEY, config.ProxyShadowBackendHostURLMapRaw, host))
+		}
+	}
+	if config.ProxyShadowSampleRate <= 0 || config.ProxyShadowSampleRate > 1 {
+		allErrs = errors.Join(allErrs, fmt.Errorf("invalid %s specified %v, must be greater than zero and at most 1", PROXY_SHADOW_SAMPLE_RATE_ENVIRONMENT_KEY, config.ProxyShadowSampleRate))
+	}
+	if config.ProxyShadowTimeout <= 0 {
+		allErrs = errors.Join(allErrs, fmt.Errorf("invalid %s specified %s, must be greater than zero", PROXY_SHADOW_TIMEOUT_SECONDS_ENVIRONMENT_KEY, config.ProxyShadowTimeout))
+	}
+	if config.ProxyShadowMaxInFlight < 1 {
+		allErrs = errors.Join(allErrs, fmt.Errorf("invalid %s specified %d, must be greater than zero", PROXY_SHADOW_MAX_IN_FLIGHT_ENVIRONMENT_KEY, config.ProxyShadowMaxInFlight))
+	}
+
+	return allErrs
+}
+
+// validateBackendTransportMap validates the transport settings of the backends,
+// including that their client certificates and CA bundles can be loaded
+func validateBackendTransportMap(config Config) error {
+	_, err := ParseRawBackendTransportMap(config.ProxyBackendTransportMapRaw)
+	if err != nil {
+		return err
+	}
+
+	var allErrs error
+	for backendURL, transportConfig := range config.ProxyBackendTransportMap {
+		if !transportConfig.HasTLSConfig() {
+			continue
+		}
+		if _, err := transportConfig.TLSConfig(); err != nil {
+			allErrs = errors.Join(allErrs, fmt.Errorf("invalid tls settings for backend %s: %w", backendURL, err))
+		}
+	}
+	return allErrs
+}
+
+// validateBackendHeadTrackingConfig validates the settings for tracking how far behind the chain tip each backend is
+func validateBackendHeadTrackingConfig(config Config) error {
+	var allErrs error
+
+	if config.ProxyBackendHeadTrackingInterval <= 0 {
+		allErrs = errors.Join(allErrs, fmt.Errorf("invalid %s specified %s, must be greater than zero", PROXY_BACKEND_HEAD_TRACKING_INTERVAL_SECONDS_ENVIRONMENT_KEY, config.ProxyBackendHeadTrackingInterval))
+	}
+	if config.ProxyBackendHeadTrackingTimeout <= 0 {
+		allErrs = errors.Join(allErrs, fmt.Errorf("invalid %s specified %s, must be greater than zero", PROXY_BACKEND_HEAD_TRACKING_TIMEOUT_SECONDS_ENVIRONMENT_KEY, config.ProxyBackendHeadTrackingTimeout))
+	}
+	if config.ProxyBackendHeadTrackingMaxLagBlocks < 0 {
+		allErrs = errors.Join(allErrs, fmt.Errorf("invalid %s specified %d, must not be negative", PROXY_BACKEND_HEAD_TRACKING_MAX_LAG_BLOCKS_ENVIRONMENT_KEY, config.ProxyBackendHeadTrackingMaxLagBlocks))
+	}
+
+	return allErrs
+}
+
+// validateAdminAPIConfig validates the settings for the admin api
+func validateAdminAPIConfig(config Config) error {
+	var allErrs error
+
+	if _, err := strconv.Atoi(config.ProxyAdminAPIPort); err != nil || config.ProxyAdminAPIPort == config.ProxyServicePort {
+		allErrs = errors.Join(allErrs, fmt.Errorf("invalid %s specified %s, must be a port other than %s", PROXY_ADMIN_API_PORT_ENVIRONMENT_KEY, config.ProxyAdminAPIPort, PROXY_SERVICE_PORT_ENVIRONMENT_KEY))
+	}
+	if config.ProxyAdminAPIToken == "" {
+		allErrs = errors.Join(allErrs, fmt.Errorf("invalid %s specified, must not be empty", PROXY_ADMIN_API_TOKEN_ENVIRONMENT_KEY))
+	}
+	if config.ProxyAdminAPISharedStateEnabled && config.ProxyAdminAPISharedStateSyncInterval <= 0 {
+		allErrs = errors.Join(allErrs, fmt.Errorf("invalid %s specified %s, must be greater than zero", PROXY_ADMIN_API_SHARED_STATE_SYNC_INTERVAL_SECONDS_ENVIRONMENT_KEY, config.ProxyAdminAPISharedStateSyncInterval))
+	}
+
+	return allErrs
+}
+
+// validateHostURLMap validates a raw backend host URL map, optionally allowing the map to be empty.
+// Maps set from the hosts of the config file have no raw value, and were validated when the file was parsed.
+func validateHostURLMap(raw string, parsed map[string][]url.URL, allowEmpty bool) error {
+	if raw == "" && len(parsed) > 0 {
+		return nil
+	}
 	_, err := ParseRawProxyBackendHostURLMap(raw)
 	if allowEmpty && errors.Is(err, ErrEmptyHostMap) {
 		err = nil
@@ -117,6 +365,41 @@ func validateHostURLMap(raw string, allowEmpty bool) error {
 	return err
 }
 
+// validateRetentionWindowMap validates the retention windows of the pruning backends, allowing the map to be empty.
+// Retention windows are relative to the head of the pruning backends, so they require head tracking.
+func validateRetentionWindowMap(config Config) error {
+	if config.ProxyPruningBackendRetentionWindowMapRaw != "" {
+		if _, err := ParseRawRetentionWindowMap(config.ProxyPruningBackendRetentionWindowMapRaw); err != nil {
+			return err
+		}
+	}
+	if len(config.ProxyPruningBackendRetentionWindowMap) == 0 {
+		return nil
+	}
+
+	if !config.ProxyBackendHeadTrackingEnabled {
+		return fmt.Errorf("retention windows require %s to be enabled", PROXY_BACKEND_HEAD_TRACKING_ENABLED_ENVIRONMENT_KEY)
+	}
+
+	for host := range config.ProxyPruningBackendRetentionWindowMap {
+		if _, found := config.ProxyPruningBackendHostURLMap[host]; !found {
+			return fmt.Errorf("host %s has a retention window but is not in %s", host, PROXY_PRUNING_BACKEND_HOST_URL_MAP_ENVIRONMENT_KEY)
+		}
+	}
+
+	return nil
+}
+
+// isValidBackendPoolStrategy returns true if the strategy is one of ValidBackendPoolStrategies
+func isValidBackendPoolStrategy(strategy string) bool {
+	for _, validStrategy := range ValidBackendPoolStrategies {
+		if strategy == validStrategy {
+			return true
+		}
+	}
+	return false
+}
+
 // validateHostnameToHeaderValueMap validates a raw hostname to header value map, optionally allowing the map to be empty
 func validateHostnameToHeaderValueMap(raw string, allowEmpty bool) error {
 	_, err := ParseRawHostnameToHeaderValueMap(raw)
@@ -132,10 +415,30 @@ func validateShardRoutingBackendHostURLMap(raw string) error {
 	return err
 }
 
+// validateMethodRoutingBackendHostURLMap validates the host-backend url map for method-based routing.
+// Hosts with method routing rules must have default backends for the requests that match no rule.
+func validateMethodRoutingBackendHostURLMap(config Config) error {
+	parsed, err := ParseRawMethodRoutingBackendHostURLMap(config.ProxyMethodRoutingBackendHostURLMapRaw)
+	if err != nil {
+		return err
+	}
+	// maps set from the hosts of the config file have no raw value
+	if config.ProxyMethodRoutingBackendHostURLMapRaw == "" {
+		parsed = config.ProxyMethodRoutingBackendHostURLMap
+	}
+
+	for host := range parsed {
+		if _, found := config.ProxyBackendHostURLMapParsed[host]; !found {
+			return fmt.Errorf("host %s is in %s but not in default host map", host, PROXY_METHOD_ROUTING_BACKEND_HOST_URL_MAP_ENVIRONMENT_KEY)
+		}
+	}
+	return nil
+}
+
 // validateDefaultHostMapContainsHosts returns an error if there are hosts in hostMap that
 // are not in defaultHostMap
 // example: hosts in the pruning map should always have a default fallback backend
-func validateDefaultHostMapContainsHosts(mapName string, defaultHostsMap, hostsMap map[string]url.URL) error {
+func validateDefaultHostMapContainsHosts(mapName string, defaultHostsMap, hostsMap map[string][]url.URL) error {
 	for host := range hostsMap {
 		if _, found := defaultHostsMap[host]; !found {
 			return fmt.Errorf("host %s is in %s but not in default host map", host, mapName)
@@ -2,6 +2,7 @@ package config_test
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
@@ -16,7 +17,11 @@ func init() {
 var (
 	defaultConfig = func() config.Config {
 		setDefaultEnv()
-		return config.ReadConfig()
+		cfg, err := config.ReadConfig()
+		if err != nil {
+			panic(err)
+		}
+		return cfg
 	}()
 )
 
@@ -47,6 +52,128 @@ func TestUnitTestValidateConfigReturnsErrorIfInvalidProxyBackendHostURL(t *testi
 	assert.NotNil(t, err)
 }
 
+func TestUnitTestValidateConfigReturnsErrorIfInvalidProxyBackendPoolStrategy(t *testing.T) {
+	testConfig := defaultConfig
+	testConfig.ProxyBackendPoolStrategy = "most-outstanding-requests"
+
+	err := config.Validate(testConfig)
+
+	assert.NotNil(t, err)
+}
+
+func TestUnitTestValidateConfigReturnsErrorIfInvalidCircuitBreakerConfig(t *testing.T) {
+	testConfig := defaultConfig
+	testConfig.ProxyBackendCircuitBreakerEnabled = true
+	testConfig.ProxyBackendCircuitBreakerFailureThreshold = 0
+
+	err := config.Validate(testConfig)
+
+	assert.NotNil(t, err)
+}
+
+func TestUnitTestValidateConfigReturnsErrorIfInvalidRetryConfig(t *testing.T) {
+	testConfig := defaultConfig
+	testConfig.ProxyBackendRetryEnabled = true
+	testConfig.ProxyBackendRetryMaxRetries = 0
+
+	err := config.Validate(testConfig)
+
+	assert.NotNil(t, err)
+}
+
+func TestUnitTestValidateConfigReturnsErrorIfInvalidHedgingConfig(t *testing.T) {
+	testConfig := defaultConfig
+	testConfig.ProxyBackendHedgingEnabled = true
+	testConfig.ProxyBackendHedgingPercentile = 101
+
+	err := config.Validate(testConfig)
+
+	assert.NotNil(t, err)
+}
+
+func TestUnitTestValidateConfigReturnsErrorIfInvalidHeadTrackingConfig(t *testing.T) {
+	testConfig := defaultConfig
+	testConfig.ProxyBackendHeadTrackingEnabled = true
+	testConfig.ProxyBackendHeadTrackingMaxLagBlocks = -1
+
+	err := config.Validate(testConfig)
+
+	assert.NotNil(t, err)
+}
+
+func TestUnitTestValidateConfigReturnsErrorIfInvalidStickyFilterRoutingConfig(t *testing.T) {
+	testConfig := defaultConfig
+	testConfig.ProxyStickyFilterRoutingEnabled = true
+	testConfig.ProxyStickyFilterRoutingTTL = 0
+
+	err := config.Validate(testConfig)
+
+	assert.NotNil(t, err)
+}
+
+func TestUnitTestValidateConfigReturnsErrorIfInvalidAdminAPIConfig(t *testing.T) {
+	testConfig := defaultConfig
+	testConfig.ProxyAdminAPIEnabled = true
+	testConfig.ProxyAdminAPIPort = "7780"
+	testConfig.ProxyAdminAPIToken = "secret"
+	assert.Nil(t, config.Validate(testConfig))
+
+	testConfig.ProxyAdminAPIToken = ""
+	assert.NotNil(t, config.Validate(testConfig))
+
+	testConfig.ProxyAdminAPIToken = "secret"
+	testConfig.ProxyAdminAPIPort = testConfig.ProxyServicePort
+	assert.NotNil(t, config.Validate(testConfig))
+
+	testConfig.ProxyAdminAPIPort = "7780"
+	testConfig.ProxyAdminAPISharedStateEnabled = true
+	testConfig.ProxyAdminAPISharedStateSyncInterval = 0
+	assert.NotNil(t, config.Validate(testConfig))
+}
+
+func TestUnitTestValidateConfigReturnsErrorIfInvalidShadowConfig(t *testing.T) {
+	testConfig := defaultConfig
+	testConfig.ProxyShadowEnabled = true
+	testConfig.ProxyShadowBackendHostURLMapRaw = "evm.kava.io>http://kava-shadow:8545"
+	testConfig.ProxyShadowBackendHostURLMap, _ = config.ParseRawProxyBackendHostURLMap(testConfig.ProxyShadowBackendHostURLMapRaw)
+	testConfig.ProxyShadowSampleRate = 0.01
+	testConfig.ProxyShadowTimeout = 10 * time.Second
+	testConfig.ProxyShadowMaxInFlight = 100
+	assert.Nil(t, config.Validate(testConfig))
+
+	testConfig.ProxyShadowSampleRate = 1.5
+	assert.NotNil(t, config.Validate(testConfig))
+
+	testConfig.ProxyShadowSampleRate = 0.01
+	testConfig.ProxyShadowBackendHostURLMapRaw = "evm.kava.io>http://kava-shadow-1:8545+http://kava-shadow-2:8545"
+	testConfig.ProxyShadowBackendHostURLMap, _ = config.ParseRawProxyBackendHostURLMap(testConfig.ProxyShadowBackendHostURLMapRaw)
+	assert.NotNil(t, config.Validate(testConfig))
+
+	testConfig.ProxyShadowBackendHostURLMapRaw = ""
+	testConfig.ProxyShadowBackendHostURLMap = nil
+	assert.NotNil(t, config.Validate(testConfig))
+}
+
+func TestUnitTestValidateConfigReturnsErrorIfInvalidBackendTransportMap(t *testing.T) {
+	testConfig := defaultConfig
+	testConfig.ProxyBackendTransportMapRaw = "http://kava-archive:8545>response_header_timeout_seconds=300"
+	testConfig.ProxyBackendTransportMap, _ = config.ParseRawBackendTransportMap(testConfig.ProxyBackendTransportMapRaw)
+	assert.Nil(t, config.Validate(testConfig))
+
+	testConfig.ProxyBackendTransportMapRaw = "http://kava-archive:8545>response_header_timeout_seconds=forever"
+	testConfig.ProxyBackendTransportMap, _ = config.ParseRawBackendTransportMap(testConfig.ProxyBackendTransportMapRaw)
+	assert.NotNil(t, config.Validate(testConfig))
+
+	// client certificates require both a certificate and key
+	testConfig.ProxyBackendTransportMapRaw = "https://kava-mtls:8545>client_cert_file=/certs/proxy.crt"
+	testConfig.ProxyBackendTransportMap, _ = config.ParseRawBackendTransportMap(testConfig.ProxyBackendTransportMapRaw)
+	assert.NotNil(t, config.Validate(testConfig))
+
+	testConfig.ProxyBackendTransportMapRaw = "https://kava-mtls:8545>ca_bundle_file=/does/not/exist.pem"
+	testConfig.ProxyBackendTransportMap, _ = config.ParseRawBackendTransportMap(testConfig.ProxyBackendTransportMapRaw)
+	assert.NotNil(t, config.Validate(testConfig))
+}
+
 func TestUnitTestValidateConfigReturnsNoErrorWhenPruningProxyBackendHostURLIsEmpty(t *testing.T) {
 	testConfig := defaultConfig
 	testConfig.ProxyPruningBackendHostURLMapRaw = ""
@@ -67,6 +194,31 @@ func TestUnitTestValidateConfigReturnsErrorWhenPruningMapHasHostsNotInDefault(t
 	assert.NotNil(t, err)
 }
 
+func TestUnitTestValidateConfigReturnsErrorIfInvalidRetentionWindowMap(t *testing.T) {
+	testConfig := defaultConfig
+	testConfig.ProxyBackendHeadTrackingEnabled = true
+	testConfig.ProxyPruningBackendHostURLMapRaw = "localhost:7777>http://kava-pruning:8545"
+	testConfig.ProxyPruningBackendHostURLMap, _ = config.ParseRawProxyBackendHostURLMap(testConfig.ProxyPruningBackendHostURLMapRaw)
+
+	testConfig.ProxyPruningBackendRetentionWindowMapRaw = "localhost:7777>100000"
+	testConfig.ProxyPruningBackendRetentionWindowMap, _ = config.ParseRawRetentionWindowMap(testConfig.ProxyPruningBackendRetentionWindowMapRaw)
+	assert.Nil(t, config.Validate(testConfig))
+
+	// retention windows are relative to the head of the backends so require head tracking
+	noHeadTrackingConfig := testConfig
+	noHeadTrackingConfig.ProxyBackendHeadTrackingEnabled = false
+	assert.NotNil(t, config.Validate(noHeadTrackingConfig))
+
+	// hosts with a retention window must have pruning backends
+	testConfig.ProxyPruningBackendRetentionWindowMapRaw = "localhost:7778>100000"
+	testConfig.ProxyPruningBackendRetentionWindowMap, _ = config.ParseRawRetentionWindowMap(testConfig.ProxyPruningBackendRetentionWindowMapRaw)
+	assert.NotNil(t, config.Validate(testConfig))
+
+	testConfig.ProxyPruningBackendRetentionWindowMapRaw = "localhost:7777>0"
+	testConfig.ProxyPruningBackendRetentionWindowMap, _ = config.ParseRawRetentionWindowMap(testConfig.ProxyPruningBackendRetentionWindowMapRaw)
+	assert.NotNil(t, config.Validate(testConfig))
+}
+
 func TestUnitTestValidateConfigReturnsErrorIfInvalidProxyBackendHostURLComponents(t *testing.T) {
 	testConfig := defaultConfig
 	testConfig.ProxyBackendHostURLMapRaw = "localhost:7777,localhost:7778>http://kava:8545$^,localhost:7777>http://kava:8545"
@@ -99,6 +251,19 @@ func TestUnitTestValidateConfigReturnsErrorIfInvaidShardRoutingBackendURLMap(t *
 	assert.NoError(t, err)
 }
 
+func TestUnitTestValidateConfigReturnsErrorIfInvalidMethodRoutingBackendHostURLMap(t *testing.T) {
+	testConfig := defaultConfig
+	testConfig.ProxyMethodRoutingBackendHostURLMapRaw = "localhost:7777>debug_*|http://kava-tracing:8545"
+	assert.Nil(t, config.Validate(testConfig))
+
+	testConfig.ProxyMethodRoutingBackendHostURLMapRaw = "localhost:7777>debug_*"
+	assert.NotNil(t, config.Validate(testConfig))
+
+	// hosts with method routing rules must have default backends
+	testConfig.ProxyMethodRoutingBackendHostURLMapRaw = "not-in-default:1234>debug_*|http://kava-tracing:8545"
+	assert.NotNil(t, config.Validate(testConfig))
+}
+
 func TestUnitTestValidateConfigReturnsErrorIfInvalidProxyServicePort(t *testing.T) {
 	testConfig := defaultConfig
 	testConfig.ProxyServicePort = "abc"
@@ -0,0 +1,19 @@
+package config
+
+import "net/url"
+
+// DefaultBackendWeight is the weight of members of a pool without a weight
+const DefaultBackendWeight = 1
+
+// BackendWeights are the weights of the members of a pool of backends by their url.
+// Members of a weighted pool serve a share of the requests for the host proportional to their weight,
+// e.g. a member with a weight of 5 in a pool with a total weight of 100 serves 5% of the requests.
+type BackendWeights map[string]int
+
+// Weight returns the weight of the member of the pool with the url
+func (weights BackendWeights) Weight(backendURL url.URL) int {
+	if weight, found := weights[backendURL.String()]; found {
+		return weight
+	}
+	return DefaultBackendWeight
+}
@@ -0,0 +1,269 @@
+package decode
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	// header Cosmos REST requests specify the height of the state to query with
+	CosmosBlockHeightHeaderKey = "x-cosmos-block-height"
+	// name of the height param of Tendermint RPC requests
+	tendermintHeightParam = "height"
+)
+
+// Errors that might result from decoding a Tendermint RPC or Cosmos REST request
+var (
+	ErrInvalidCosmosRPCRequest = errors.New("request is not valid for the tendermint rpc or cosmos rest api")
+)
+
+// Mapping of the position of the height param for Tendermint RPC methods
+// whose height is passed positionally, methods whose height is passed by name use the "height" param
+var TendermintMethodNameToHeightParamIndex = map[string]int{
+	"block":            0,
+	"block_results":    0,
+	"commit":           0,
+	"header":           0,
+	"validators":       0,
+	"consensus_params": 0,
+	"abci_query":       2,
+}
+
+// TendermintNoHistoryMethods is a list of Tendermint RPC methods that always
+// function correctly when served by a node with only the latest state
+var TendermintNoHistoryMethods = []string{
+	"health",
+	"status",
+	"net_info",
+	"genesis",
+	"genesis_chunked",
+	"abci_info",
+	"check_tx",
+	"consensus_state",
+	"dump_consensus_state",
+	"unconfirmed_txs",
+	"num_unconfirmed_txs",
+	"broadcast_tx_sync",
+	"broadcast_tx_async",
+	"broadcast_tx_commit",
+	"broadcast_evidence",
+}
+
+// TendermintOtherMethods is a list of Tendermint RPC methods that neither have a height param
+// nor can always be served by a node with only the latest state, e.g. for a tx by hash
+var TendermintOtherMethods = []string{
+	"blockchain",
+	"block_by_hash",
+	"header_by_hash",
+	"block_search",
+	"tx",
+	"tx_search",
+}
+
+// CosmosRESTPathPrefixes is a list of the path prefixes of Cosmos REST requests
+var CosmosRESTPathPrefixes = []string{
+	"/cosmos/",
+	"/kava/",
+	"/ibc/",
+	"/ethermint/",
+}
+
+// IsTendermintMethod returns true when the method is a Tendermint RPC method
+func IsTendermintMethod(method string) bool {
+	if _, hasHeightParam := TendermintMethodNameToHeightParamIndex[method]; hasHeightParam {
+		return true
+	}
+	if TendermintMethodRequiresNoHistory(method) {
+		return true
+	}
+	for _, tendermintMethod := range TendermintOtherMethods {
+		if method == tendermintMethod {
+			return true
+		}
+	}
+	return false
+}
+
+// TendermintMethodRequiresNoHistory returns true when the Tendermint RPC method always functions correctly
+// when sent to a node with only the latest state.
+func TendermintMethodRequiresNoHistory(method string) bool {
+	for _, nonHistoricalMethod := range TendermintNoHistoryMethods {
+		if method == nonHistoricalMethod {
+			return true
+		}
+	}
+	return false
+}
+
+// CosmosRPCRequest wraps the values of a request to the Tendermint RPC, made over JSON-RPC or as a URI,
+// or to the Cosmos REST api that are useful for routing the request and tracking it in metrics
+type CosmosRPCRequest struct {
+	// the Tendermint RPC method, e.g. "block", or the path of the Cosmos REST request
+	Method string
+	// the height of the state requested, block tags are encoded according to the BlockTagToNumberCodec map
+	// and requests for the latest state without an explicit height as "empty".
+	// nil when the height of the request is not known, e.g. for a tx by hash
+	Height *int64
+}
+
+// RequiresNoHistory returns true when the request always functions correctly
+// when sent to a node with only the latest state
+func (r *CosmosRPCRequest) RequiresNoHistory() bool {
+	return TendermintMethodRequiresNoHistory(r.Method)
+}
+
+// tendermintRPCRequestEnvelope is a Tendermint JSON-RPC request
+// whose params are either positional or by name
+type tendermintRPCRequestEnvelope struct {
+	JSONRPCVersion string `json:"jsonrpc"`
+	Method         string
+	Params         json.RawMessage
+}
+
+// DecodeCosmosRPCRequest attempts to decode the request as a Tendermint JSON-RPC request (if it has a body),
+// Cosmos REST request or Tendermint URI request, returning the decoded request and error (if any)
+func DecodeCosmosRPCRequest(requestURL *url.URL, header http.Header, body []byte) (*CosmosRPCRequest, error) {
+	if len(body) > 0 {
+		if request, err := DecodeTendermintRPCRequest(body); err == nil {
+			return request, nil
+		}
+	}
+
+	if request, err := DecodeCosmosRESTRequest(requestURL, header); err == nil {
+		return request, nil
+	}
+
+	// Tendermint URI requests are made with query params, not a body
+	if len(body) > 0 {
+		return nil, ErrInvalidCosmosRPCRequest
+	}
+	return DecodeTendermintURIRequest(requestURL)
+}
+
+// DecodeTendermintRPCRequest attempts to decode the body as a Tendermint JSON-RPC request
+func DecodeTendermintRPCRequest(body []byte) (*CosmosRPCRequest, error) {
+	var envelope tendermintRPCRequestEnvelope
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return nil, err
+	}
+	if !IsTendermintMethod(envelope.Method) {
+		return nil, fmt.Errorf("%w: unknown method %s", ErrInvalidCosmosRPCRequest, envelope.Method)
+	}
+
+	request := &CosmosRPCRequest{Method: envelope.Method}
+	paramIndex, hasHeightParam := TendermintMethodNameToHeightParamIndex[envelope.Method]
+	if !hasHeightParam {
+		return request, nil
+	}
+
+	var heightParam interface{}
+	var namedParams map[string]interface{}
+	var positionalParams []interface{}
+	switch {
+	case len(envelope.Params) == 0:
+	case json.Unmarshal(envelope.Params, &namedParams) == nil:
+		heightParam = namedParams[tendermintHeightParam]
+	case json.Unmarshal(envelope.Params, &positionalParams) == nil:
+		if paramIndex < len(positionalParams) {
+			heightParam = positionalParams[paramIndex]
+		}
+	default:
+		return nil, fmt.Errorf("error decoding params %s of tendermint request", envelope.Params)
+	}
+
+	height, err := parseTendermintHeight(heightParam)
+	if err != nil {
+		return nil, err
+	}
+	request.Height = &height
+	return request, nil
+}
+
+// DecodeTendermintURIRequest attempts to decode the url as a Tendermint URI request, e.g. /block?height=5
+func DecodeTendermintURIRequest(requestURL *url.URL) (*CosmosRPCRequest, error) {
+	method := strings.Trim(requestURL.Path, "/")
+	if !IsTendermintMethod(method) {
+		return nil, fmt.Errorf("%w: unknown method %s", ErrInvalidCosmosRPCRequest, method)
+	}
+
+	request := &CosmosRPCRequest{Method: method}
+	if _, hasHeightParam := TendermintMethodNameToHeightParamIndex[method]; !hasHeightParam {
+		return request, nil
+	}
+
+	var heightParam interface{}
+	if query := requestURL.Query(); query.Has(tendermintHeightParam) {
+		// string values of URI params may be quoted
+		heightParam = strings.Trim(query.Get(tendermintHeightParam), `"`)
+	}
+
+	height, err := parseTendermintHeight(heightParam)
+	if err != nil {
+		return nil, err
+	}
+	request.Height = &height
+	return request, nil
+}
+
+// DecodeCosmosRESTRequest attempts to decode the request as a Cosmos REST request,
+// which is made to one of the CosmosRESTPathPrefixes or specifies the height of the state to query with a header
+func DecodeCosmosRESTRequest(requestURL *url.URL, header http.Header) (*CosmosRPCRequest, error) {
+	heightHeader := header.Get(CosmosBlockHeightHeaderKey)
+
+	isRESTPath := false
+	for _, prefix := range CosmosRESTPathPrefixes {
+		if strings.HasPrefix(requestURL.Path, prefix) {
+			isRESTPath = true
+			break
+		}
+	}
+	if !isRESTPath && heightHeader == "" {
+		return nil, fmt.Errorf("%w: unknown path %s", ErrInvalidCosmosRPCRequest, requestURL.Path)
+	}
+
+	var heightParam interface{}
+	if heightHeader != "" {
+		heightParam = heightHeader
+	}
+
+	height, err := parseTendermintHeight(heightParam)
+	if err != nil {
+		return nil, err
+	}
+	return &CosmosRPCRequest{Method: requestURL.Path, Height: &height}, nil
+}
+
+// parseTendermintHeight parses the height of a Tendermint RPC or Cosmos REST request.
+// Omitted heights, and a height of zero, are requests for the latest state and encoded as "empty".
+func parseTendermintHeight(heightParam interface{}) (int64, error) {
+	var height int64
+	switch param := heightParam.(type) {
+	case nil:
+		return BlockTagToNumberCodec[BlockTagEmpty], nil
+	case string:
+		if param == "" {
+			return BlockTagToNumberCodec[BlockTagEmpty], nil
+		}
+		parsed, err := blockParamToInt64(param)
+		if err != nil {
+			return 0, err
+		}
+		height = parsed
+	case float64:
+		height = int64(param)
+	default:
+		return 0, fmt.Errorf("error decoding height param %+v", heightParam)
+	}
+
+	if height < 0 {
+		return 0, fmt.Errorf("invalid height %d", height)
+	}
+	if height == 0 {
+		return BlockTagToNumberCodec[BlockTagEmpty], nil
+	}
+	return height, nil
+}
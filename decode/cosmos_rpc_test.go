@@ -0,0 +1,127 @@
+package decode
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnitTest_DecodeCosmosRPCRequest(t *testing.T) {
+	empty := BlockTagToNumberCodec[BlockTagEmpty]
+
+	testCases := []struct {
+		name         string
+		url          string
+		header       http.Header
+		body         string
+		expectMethod string
+		expectHeight *int64
+		expectErr    bool
+	}{
+		{
+			name:         "tendermint json-rpc request with named height",
+			url:          "/",
+			body:         `{"jsonrpc":"2.0","id":1,"method":"block","params":{"height":"100"}}`,
+			expectMethod: "block",
+			expectHeight: int64Ptr(100),
+		},
+		{
+			name:         "tendermint json-rpc request with positional height",
+			url:          "/",
+			body:         `{"jsonrpc":"2.0","id":1,"method":"abci_query","params":["/store/bank/key","0x01",100,false]}`,
+			expectMethod: "abci_query",
+			expectHeight: int64Ptr(100),
+		},
+		{
+			name:         "tendermint json-rpc request without height",
+			url:          "/",
+			body:         `{"jsonrpc":"2.0","id":1,"method":"block_results","params":{}}`,
+			expectMethod: "block_results",
+			expectHeight: &empty,
+		},
+		{
+			name:         "tendermint json-rpc request for method without height",
+			url:          "/",
+			body:         `{"jsonrpc":"2.0","id":1,"method":"tx","params":{"hash":"0xabc"}}`,
+			expectMethod: "tx",
+		},
+		{
+			name:      "evm json-rpc request",
+			url:       "/",
+			body:      `{"jsonrpc":"2.0","id":1,"method":"eth_blockNumber","params":[]}`,
+			expectErr: true,
+		},
+		{
+			name:         "tendermint uri request with height",
+			url:          "/block?height=100",
+			expectMethod: "block",
+			expectHeight: int64Ptr(100),
+		},
+		{
+			name:         "tendermint uri request with quoted height",
+			url:          `/abci_query?path="/store/bank/key"&height="100"`,
+			expectMethod: "abci_query",
+			expectHeight: int64Ptr(100),
+		},
+		{
+			name:         "tendermint uri request for latest height",
+			url:          "/block?height=0",
+			expectMethod: "block",
+			expectHeight: &empty,
+		},
+		{
+			name:         "tendermint uri request for method without history",
+			url:          "/status",
+			expectMethod: "status",
+		},
+		{
+			name:      "tendermint uri request with invalid height",
+			url:       "/block?height=abc",
+			expectErr: true,
+		},
+		{
+			name:         "cosmos rest request with height header",
+			url:          "/cosmos/bank/v1beta1/balances/kava1abc",
+			header:       http.Header{"X-Cosmos-Block-Height": []string{"100"}},
+			expectMethod: "/cosmos/bank/v1beta1/balances/kava1abc",
+			expectHeight: int64Ptr(100),
+		},
+		{
+			name:         "cosmos rest request without height header",
+			url:          "/kava/cdp/v1beta1/params",
+			expectMethod: "/kava/cdp/v1beta1/params",
+			expectHeight: &empty,
+		},
+		{
+			name:      "unknown path",
+			url:       "/not/a/cosmos/path",
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			requestURL, err := url.Parse(tc.url)
+			require.NoError(t, err)
+			header := tc.header
+			if header == nil {
+				header = http.Header{}
+			}
+
+			request, err := DecodeCosmosRPCRequest(requestURL, header, []byte(tc.body))
+			if tc.expectErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expectMethod, request.Method)
+			require.Equal(t, tc.expectHeight, request.Height)
+		})
+	}
+}
+
+func int64Ptr(i int64) *int64 {
+	return &i
+}
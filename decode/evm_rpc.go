@@ -159,6 +159,201 @@ func MethodRequiresNoHistory(method string) bool {
 	return false
 }
 
+// NonIdempotentMethods is a list of JSON-RPC methods that send or sign transactions
+// or otherwise change the state of the node or chain.
+// They must not be sent more than once, so are never retried against another backend.
+var NonIdempotentMethods = []string{
+	"eth_sendRawTransaction",
+	"eth_sendTransaction",
+	"eth_sign",
+	"eth_signTransaction",
+	"eth_signTypedData",
+	"eth_signTypedData_v3",
+	"eth_signTypedData_v4",
+	"eth_submitWork",
+	"eth_submitHashrate",
+	"personal_sign",
+	"personal_sendTransaction",
+	"personal_signTransaction",
+}
+
+// MethodIsIdempotent returns true when sending a request for the JSON-RPC method
+// more than once has the same effect as sending it once, ie. the method only reads state.
+func MethodIsIdempotent(method string) bool {
+	for _, nonIdempotentMethod := range NonIdempotentMethods {
+		if method == nonIdempotentMethod {
+			return false
+		}
+	}
+	return true
+}
+
+// FilterCreatingMethods is a list of JSON-RPC methods that install a filter on the node,
+// responding with the id of the filter. Filters only exist on the node that installed them.
+var FilterCreatingMethods = []string{
+	"eth_newFilter",
+	"eth_newBlockFilter",
+	"eth_newPendingTransactionFilter",
+}
+
+// MethodCreatesFilter returns true when the JSON-RPC method installs a filter on the node
+func MethodCreatesFilter(method string) bool {
+	for _, filterCreatingMethod := range FilterCreatingMethods {
+		if method == filterCreatingMethod {
+			return true
+		}
+	}
+	return false
+}
+
+// SubscriptionMethods is a list of JSON-RPC methods that manage subscriptions,
+// which only exist on the websocket connection to the node that created them
+var SubscriptionMethods = []string{
+	"eth_subscribe",
+	"eth_unsubscribe",
+}
+
+// MethodIsSubscription returns true when the JSON-RPC method manages a subscription on the node
+func MethodIsSubscription(method string) bool {
+	for _, subscriptionMethod := range SubscriptionMethods {
+		if method == subscriptionMethod {
+			return true
+		}
+	}
+	return false
+}
+
+// Mapping of the position of the filter id param for methods
+// that use a filter previously installed on the node
+var MethodNameToFilterIDParamIndex = map[string]int{
+	"eth_getFilterChanges": 0,
+	"eth_getFilterLogs":    0,
+	"eth_uninstallFilter":  0,
+}
+
+// MethodHasFilterIDParam returns true when the method expects a filter id in the request parameters.
+func MethodHasFilterIDParam(method string) bool {
+	_, exists := MethodNameToFilterIDParamIndex[method]
+	return exists
+}
+
+// ParseFilterIDFromParams parses the filter id from a set of params
+// errors if method does not have a filter id in the param, or the param has an unexpected value
+func ParseFilterIDFromParams(methodName string, params []interface{}) (string, error) {
+	paramIndex, exists := MethodNameToFilterIDParamIndex[methodName]
+
+	if !exists {
+		return "", fmt.Errorf("method %s does not have a filter id param", methodName)
+	}
+
+	if paramIndex >= len(params) {
+		return "", fmt.Errorf("missing filter id param from params %+v at index %d", params, paramIndex)
+	}
+
+	filterID, isString := params[paramIndex].(string)
+
+	if !isString || filterID == "" {
+		return "", fmt.Errorf("error decoding filter id param from params %+v at index %d", params, paramIndex)
+	}
+
+	return filterID, nil
+}
+
+// Mapping of the position of the filter object param whose fromBlock & toBlock define the block range of the request
+var MethodNameToBlockRangeParamIndex = map[string]int{
+	"eth_getLogs": 0,
+}
+
+// MethodHasBlockRangeParam returns true when the method expects a filter object with a block range in the request parameters.
+func MethodHasBlockRangeParam(method string) bool {
+	_, exists := MethodNameToBlockRangeParamIndex[method]
+	return exists
+}
+
+// ParseBlockRangeFromParams parses the fromBlock & toBlock of the filter object in a set of params
+// errors if method does not have a filter object param, the filter is for a block hash, or a block has an unexpected value
+// block tags are encoded to an int64 according to the BlockTagToNumberCodec map, omitted blocks are encoded as "empty".
+func ParseBlockRangeFromParams(methodName string, params []interface{}) (int64, int64, error) {
+	paramIndex, exists := MethodNameToBlockRangeParamIndex[methodName]
+
+	if !exists {
+		return 0, 0, fmt.Errorf("method %s does not have a block range param", methodName)
+	}
+
+	if paramIndex >= len(params) {
+		return 0, 0, fmt.Errorf("missing filter param from params %+v at index %d", params, paramIndex)
+	}
+
+	filter, isObject := params[paramIndex].(map[string]interface{})
+
+	if !isObject {
+		return 0, 0, fmt.Errorf("error decoding filter param from params %+v at index %d", params, paramIndex)
+	}
+
+	if _, isForBlockHash := filter["blockHash"]; isForBlockHash {
+		return 0, 0, fmt.Errorf("filter %+v is for a block hash", filter)
+	}
+
+	fromBlock, err := parseFilterBlock(filter, "fromBlock")
+	if err != nil {
+		return 0, 0, err
+	}
+	toBlock, err := parseFilterBlock(filter, "toBlock")
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return fromBlock, toBlock, nil
+}
+
+// parseFilterBlock parses the block of the key of the filter, encoding block tags according to the BlockTagToNumberCodec map
+func parseFilterBlock(filter map[string]interface{}, key string) (int64, error) {
+	if filter[key] == nil {
+		return BlockTagToNumberCodec[BlockTagEmpty], nil
+	}
+
+	tag, isString := filter[key].(string)
+
+	if !isString {
+		return 0, fmt.Errorf("error decoding %s of filter %+v", key, filter)
+	}
+
+	blockNumber, exists := BlockTagToNumberCodec[tag]
+	if exists {
+		return blockNumber, nil
+	}
+
+	return blockParamToInt64(tag)
+}
+
+// SideEffectFreeMethods is a list of JSON-RPC methods that only read state and
+// do not create state on the node (ie. filters) that later requests depend on.
+// Requests for them can be sent to multiple backends at once with the first response used.
+var SideEffectFreeMethods = append(append([]string{
+	"web3_clientVersion",
+	"net_version",
+	"eth_syncing",
+	"eth_chainId",
+	"eth_gasPrice",
+	"eth_maxPriorityFeePerGas",
+	"eth_feeHistory",
+	"eth_blockNumber",
+	"eth_estimateGas",
+	"eth_getTransactionByHash",
+	"eth_getTransactionReceipt",
+	"eth_getLogs",
+}, CacheableByBlockNumberMethods...), CacheableByBlockHashMethods...)
+
+// MethodIsSideEffectFree returns true when the JSON-RPC method is known to only read state
+func MethodIsSideEffectFree(method string) bool {
+	for _, sideEffectFreeMethod := range SideEffectFreeMethods {
+		if method == sideEffectFreeMethod {
+			return true
+		}
+	}
+	return false
+}
+
 // List of evm methods that can be cached independent
 // of block number (i.e. by block or transaction hash, filter id, or time period)
 // TODO: break these out into separate list for methods that can be cached using the same key type
@@ -291,24 +486,39 @@ func (r *EVMRPCRequestEnvelope) ExtractBlockNumberFromEVMRPCRequest(ctx context.
 // Generic method to lookup the block number
 // based on the hash value in a set of params
 func lookupBlockNumberFromHashParam(ctx context.Context, blockGetter EVMBlockGetter, methodName string, params []interface{}) (int64, error) {
+	blockHash, err := ParseBlockHashFromParams(methodName, params)
+	if err != nil {
+		return 0, err
+	}
+
+	header, err := blockGetter.HeaderByHash(ctx, blockHash)
+	if err != nil {
+		return 0, fmt.Errorf("can't get header by %v block hash: %v", blockHash, err)
+	}
+
+	return header.Number.Int64(), nil
+}
+
+// Generic method to parse the block hash from a set of params
+// errors if method does not have a block hash in the param, or the param has an unexpected value
+func ParseBlockHashFromParams(methodName string, params []interface{}) (common.Hash, error) {
 	paramIndex, exists := MethodNameToBlockHashParamIndex[methodName]
 
 	if !exists {
-		return 0, ErrUncachaebleByBlockHashEthRequest
+		return common.Hash{}, ErrUncachaebleByBlockHashEthRequest
+	}
+
+	if paramIndex >= len(params) {
+		return common.Hash{}, fmt.Errorf("missing block hash param from params %+v at index %d", params, paramIndex)
 	}
 
 	blockHash, isString := params[paramIndex].(string)
 
 	if !isString {
-		return 0, fmt.Errorf(fmt.Sprintf("error decoding block hash param from params %+v at index %d", params, paramIndex))
-	}
-
-	header, err := blockGetter.HeaderByHash(ctx, common.HexToHash(blockHash))
-	if err != nil {
-		return 0, fmt.Errorf("can't get header by %v block hash: %v", blockHash, err)
+		return common.Hash{}, fmt.Errorf(fmt.Sprintf("error decoding block hash param from params %+v at index %d", params, paramIndex))
 	}
 
-	return header.Number.Int64(), nil
+	return common.HexToHash(blockHash), nil
 }
 
 // Generic method to parse the block number from a set of params
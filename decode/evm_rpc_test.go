@@ -24,6 +24,8 @@ func TestUnitTest_MethodCategorization(t *testing.T) {
 		hasBlockNumber bool
 		hasBlockHash   bool
 		needsNoHistory bool
+		idempotent     bool
+		sideEffectFree bool
 	}{
 		{
 			name:           "block number method",
@@ -31,6 +33,8 @@ func TestUnitTest_MethodCategorization(t *testing.T) {
 			hasBlockNumber: true,
 			hasBlockHash:   false,
 			needsNoHistory: false,
+			idempotent:     true,
+			sideEffectFree: true,
 		},
 		{
 			name:           "block hash method",
@@ -38,6 +42,8 @@ func TestUnitTest_MethodCategorization(t *testing.T) {
 			hasBlockNumber: false,
 			hasBlockHash:   true,
 			needsNoHistory: false,
+			idempotent:     true,
+			sideEffectFree: true,
 		},
 		{
 			name:           "needs no history",
@@ -45,6 +51,8 @@ func TestUnitTest_MethodCategorization(t *testing.T) {
 			hasBlockNumber: false,
 			hasBlockHash:   false,
 			needsNoHistory: true,
+			idempotent:     false,
+			sideEffectFree: false,
 		},
 		{
 			name:           "invalid method",
@@ -52,6 +60,8 @@ func TestUnitTest_MethodCategorization(t *testing.T) {
 			hasBlockNumber: false,
 			hasBlockHash:   false,
 			needsNoHistory: false,
+			idempotent:     true,
+			sideEffectFree: false,
 		},
 		{
 			name:           "empty method",
@@ -59,6 +69,8 @@ func TestUnitTest_MethodCategorization(t *testing.T) {
 			hasBlockNumber: false,
 			hasBlockHash:   false,
 			needsNoHistory: false,
+			idempotent:     true,
+			sideEffectFree: false,
 		},
 	}
 
@@ -67,6 +79,8 @@ func TestUnitTest_MethodCategorization(t *testing.T) {
 			require.Equal(t, tc.hasBlockNumber, MethodHasBlockNumberParam(tc.method), "unexpected MethodHasBlockNumberParam result")
 			require.Equal(t, tc.hasBlockHash, MethodHasBlockHashParam(tc.method), "unexpected MethodHasBlockHashParam result")
 			require.Equal(t, tc.needsNoHistory, MethodRequiresNoHistory(tc.method), "unexpected MethodRequiresNoHistory result")
+			require.Equal(t, tc.idempotent, MethodIsIdempotent(tc.method), "unexpected MethodIsIdempotent result")
+			require.Equal(t, tc.sideEffectFree, MethodIsSideEffectFree(tc.method), "unexpected MethodIsSideEffectFree result")
 		})
 	}
 }
@@ -278,3 +292,76 @@ func TestUnitTest_ParseBlockNumberFromParams(t *testing.T) {
 		})
 	}
 }
+
+func TestUnitTest_ParseBlockHashFromParams(t *testing.T) {
+	blockHash := "0xb8d6ffd1ebd2df7a735c72e755886c6dd6587e096ae788558c6f24f31469b271"
+
+	parsed, err := ParseBlockHashFromParams("eth_getBlockByHash", []interface{}{blockHash, false})
+	require.NoError(t, err)
+	require.Equal(t, blockHash, parsed.Hex())
+
+	_, err = ParseBlockHashFromParams("eth_getBlockByNumber", []interface{}{"0xd", false})
+	require.ErrorIs(t, err, ErrUncachaebleByBlockHashEthRequest)
+
+	_, err = ParseBlockHashFromParams("eth_getBlockByHash", []interface{}{false, false})
+	require.ErrorContains(t, err, "error decoding block hash param from params")
+
+	_, err = ParseBlockHashFromParams("eth_getBlockByHash", []interface{}{})
+	require.ErrorContains(t, err, "missing block hash param")
+}
+
+func TestUnitTest_ParseFilterIDFromParams(t *testing.T) {
+	filterID, err := ParseFilterIDFromParams("eth_getFilterChanges", []interface{}{"0x1a2b"})
+	require.NoError(t, err)
+	require.Equal(t, "0x1a2b", filterID)
+
+	require.True(t, MethodHasFilterIDParam("eth_uninstallFilter"))
+	require.False(t, MethodHasFilterIDParam("eth_newFilter"))
+	require.True(t, MethodCreatesFilter("eth_newBlockFilter"))
+	require.False(t, MethodCreatesFilter("eth_getFilterLogs"))
+	require.True(t, MethodIsSubscription("eth_unsubscribe"))
+	require.False(t, MethodIsSubscription("eth_getFilterChanges"))
+
+	_, err = ParseFilterIDFromParams("eth_getBlockByNumber", []interface{}{"0xd", false})
+	require.ErrorContains(t, err, "does not have a filter id param")
+
+	_, err = ParseFilterIDFromParams("eth_getFilterLogs", []interface{}{})
+	require.ErrorContains(t, err, "missing filter id param")
+
+	_, err = ParseFilterIDFromParams("eth_getFilterLogs", []interface{}{1})
+	require.ErrorContains(t, err, "error decoding filter id param")
+}
+
+func TestUnitTest_ParseBlockRangeFromParams(t *testing.T) {
+	fromBlock, toBlock, err := ParseBlockRangeFromParams("eth_getLogs", []interface{}{
+		map[string]interface{}{"fromBlock": "0xa", "toBlock": "latest", "address": "0x1a2b"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(10), fromBlock)
+	require.Equal(t, BlockTagToNumberCodec[BlockTagLatest], toBlock)
+
+	fromBlock, toBlock, err = ParseBlockRangeFromParams("eth_getLogs", []interface{}{map[string]interface{}{}})
+	require.NoError(t, err)
+	require.Equal(t, BlockTagToNumberCodec[BlockTagEmpty], fromBlock)
+	require.Equal(t, BlockTagToNumberCodec[BlockTagEmpty], toBlock)
+
+	require.True(t, MethodHasBlockRangeParam("eth_getLogs"))
+	require.False(t, MethodHasBlockRangeParam("eth_getBlockByNumber"))
+
+	_, _, err = ParseBlockRangeFromParams("eth_getBlockByNumber", []interface{}{"0xd", false})
+	require.ErrorContains(t, err, "does not have a block range param")
+
+	_, _, err = ParseBlockRangeFromParams("eth_getLogs", []interface{}{})
+	require.ErrorContains(t, err, "missing filter param")
+
+	_, _, err = ParseBlockRangeFromParams("eth_getLogs", []interface{}{"0xd"})
+	require.ErrorContains(t, err, "error decoding filter param")
+
+	_, _, err = ParseBlockRangeFromParams("eth_getLogs", []interface{}{
+		map[string]interface{}{"blockHash": "0xb8d6ffd1ebd2df7a735c72e755886c6dd6587e096ae788558c6f24f31469b271"},
+	})
+	require.ErrorContains(t, err, "is for a block hash")
+
+	_, _, err = ParseBlockRangeFromParams("eth_getLogs", []interface{}{map[string]interface{}{"fromBlock": 10}})
+	require.ErrorContains(t, err, "error decoding fromBlock")
+}
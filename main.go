@@ -21,9 +21,14 @@ var (
 )
 
 func init() {
-	serviceConfig = config.ReadConfig()
+	var err error
+	serviceConfig, err = config.ReadConfig()
 
-	err := config.Validate(serviceConfig)
+	if err != nil {
+		panic(err)
+	}
+
+	err = config.Validate(serviceConfig)
 
 	if err != nil {
 		panic(err)
@@ -258,6 +258,20 @@ func TestE2ETest_BatchEvmRequestErrorHandling(t *testing.T) {
 
 		require.NoError(t, err)
 		require.Equal(t, http.StatusBadGateway, resp.StatusCode)
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err, "failed to read response body")
+
+		var decoded []*jsonRpcResponse
+		err = json.Unmarshal(body, &decoded)
+		require.NoError(t, err, "failed to unmarshal response into array of responses")
+
+		// expect a JSON-RPC error for each request, with matching ids
+		require.Len(t, decoded, len(validReq))
+		for i, d := range decoded {
+			require.EqualValues(t, validReq[i].ID, d.Id)
+			require.Equal(t, -32051, d.JsonRpcError.Code)
+		}
 	})
 
 	t.Run("empty batch", func(t *testing.T) {
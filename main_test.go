@@ -524,6 +524,26 @@ func TestE2ETest_HeightBasedRouting(t *testing.T) {
 	}
 }
 
+func TestE2ETestBackendsStatusReportsHeadOfEachBackend(t *testing.T) {
+	client, err := service.NewProxyServiceClient(service.ProxyServiceClientConfig{
+		ProxyServiceHostname: proxyServiceURL,
+	})
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		status, err := client.GetBackendsStatus(testContext)
+		if err != nil || len(status.Backends) == 0 {
+			return false
+		}
+		for _, backend := range status.Backends {
+			if backend.HeadHeight == 0 {
+				return false
+			}
+		}
+		return true
+	}, 30*time.Second, time.Second, "expected the head of every backend to be tracked")
+}
+
 func TestE2ETestCachingMdwWithBlockNumberParam(t *testing.T) {
 	// create api and database clients
 	client, err := ethclient.Dial(proxyServiceURL)
@@ -0,0 +1,223 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/kava-labs/kava-proxy-service/clients/cache"
+	"github.com/kava-labs/kava-proxy-service/logging"
+)
+
+const (
+	// path of the admin api for listing backends, or getting a single one with the url query param
+	AdminBackendsPath = "/backends"
+	// paths of the admin api for changing the state of the backend with the url query param
+	AdminDrainBackendPath   = "/backends/drain"
+	AdminDisableBackendPath = "/backends/disable"
+	AdminEnableBackendPath  = "/backends/enable"
+	// cache item type of the keys storing the admin state of a backend
+	backendAdminStateCacheItemType = "backend-admin-state"
+)
+
+// ErrUnknownBackend is returned when changing the state of a backend the proxies do not know of
+var ErrUnknownBackend = errors.New("unknown backend")
+
+// BackendAdminStates changes the admin state of the backends of the proxies.
+// When a cache client is set, states are stored in the cache so that they are
+// shared by every replica of the proxy service, and periodically synced from it.
+type BackendAdminStates struct {
+	proxies     Proxies
+	cacheClient cache.Cache
+	cachePrefix string
+	*logging.ServiceLogger
+}
+
+// NewBackendAdminStates creates BackendAdminStates for the backends of the proxies,
+// sharing states through the cache client unless it is nil
+func NewBackendAdminStates(proxies Proxies, cacheClient cache.Cache, cachePrefix string, serviceLogger *logging.ServiceLogger) *BackendAdminStates {
+	return &BackendAdminStates{
+		proxies:       proxies,
+		cacheClient:   cacheClient,
+		cachePrefix:   cachePrefix,
+		ServiceLogger: serviceLogger,
+	}
+}
+
+// Backend returns the backend of the proxies with the url
+func (bs *BackendAdminStates) Backend(rawURL string) (*Backend, bool) {
+	for _, backend := range bs.proxies.Backends() {
+		backendURL := backend.URL()
+		if backendURL.String() == rawURL {
+			return backend, true
+		}
+	}
+	return nil, false
+}
+
+// Set changes the admin state of the backend with the url, returning the backend and error (if any).
+// The state is applied locally even if it fails to be shared through the cache.
+func (bs *BackendAdminStates) Set(ctx context.Context, rawURL string, state BackendAdminState) (*Backend, error) {
+	backend, found := bs.Backend(rawURL)
+	if !found {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownBackend, rawURL)
+	}
+
+	if backend.setAdminState(state) {
+		bs.Info().Str("backend", rawURL).Str("state", state.String()).Msg("backend admin state changed")
+	}
+
+	if bs.cacheClient == nil {
+		return backend, nil
+	}
+
+	var err error
+	if state == BackendAdminStateEnabled {
+		err = bs.cacheClient.Delete(ctx, bs.stateKey(rawURL))
+	} else {
+		err = bs.cacheClient.Set(ctx, bs.stateKey(rawURL), []byte(state.String()), -1)
+	}
+	if err != nil {
+		return backend, fmt.Errorf("failed to share state of backend %s: %w", rawURL, err)
+	}
+
+	return backend, nil
+}
+
+// Run syncs the admin state of every backend from the cache every interval
+// until the context is cancelled. It returns immediately if states are not shared.
+func (bs *BackendAdminStates) Run(ctx context.Context, interval time.Duration) {
+	if bs.cacheClient == nil {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		bs.SyncAll(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// SyncAll sets the admin state of every backend to its state in the cache.
+// Backends without a state in the cache are enabled, and backends
+// whose state fails to be read keep their current state.
+func (bs *BackendAdminStates) SyncAll(ctx context.Context) {
+	for _, backend := range bs.proxies.Backends() {
+		backendURL := backend.URL()
+
+		state := BackendAdminStateEnabled
+		rawState, err := bs.cacheClient.Get(ctx, bs.stateKey(backendURL.String()))
+		switch {
+		case errors.Is(err, cache.ErrNotFound):
+		case err != nil:
+			bs.Error().Err(err).Str("backend", backendURL.String()).Msg("failed to sync backend admin state")
+			continue
+		default:
+			if state, err = ParseBackendAdminState(string(rawState)); err != nil {
+				bs.Error().Err(err).Str("backend", backendURL.String()).Msg("invalid shared backend admin state")
+				continue
+			}
+		}
+
+		if backend.setAdminState(state) {
+			bs.Info().Str("backend", backendURL.String()).Str("state", state.String()).Msg("backend admin state changed by another replica")
+		}
+	}
+}
+
+// stateKey returns the cache key of the admin state of the backend
+func (bs *BackendAdminStates) stateKey(rawURL string) string {
+	return strings.Join([]string{bs.cachePrefix, backendAdminStateCacheItemType, rawURL}, ":")
+}
+
+// newAdminHandler creates the handler of the admin api,
+// which requires every request to be authenticated with the bearer token
+func newAdminHandler(states *BackendAdminStates, token string, serviceLogger *logging.ServiceLogger) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(AdminBackendsPath, createAdminBackendsHandler(states, serviceLogger))
+	mux.HandleFunc(AdminDrainBackendPath, createAdminBackendStateHandler(states, BackendAdminStateDraining, serviceLogger))
+	mux.HandleFunc(AdminDisableBackendPath, createAdminBackendStateHandler(states, BackendAdminStateDisabled, serviceLogger))
+	mux.HandleFunc(AdminEnableBackendPath, createAdminBackendStateHandler(states, BackendAdminStateEnabled, serviceLogger))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestToken, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !found || subtle.ConstantTimeCompare([]byte(requestToken), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// createAdminBackendsHandler creates a handler responding with the status of every backend,
+// or of the backend with the url query param
+func createAdminBackendsHandler(states *BackendAdminStates, serviceLogger *logging.ServiceLogger) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var response interface{}
+		if rawURL := r.URL.Query().Get("url"); rawURL != "" {
+			backend, found := states.Backend(rawURL)
+			if !found {
+				http.Error(w, fmt.Sprintf("%s: %s", ErrUnknownBackend, rawURL), http.StatusNotFound)
+				return
+			}
+			status := newBackendStatus(backend)
+			response = &status
+		} else {
+			backendsStatus := BackendsStatusResponse{Backends: []BackendStatus{}}
+			for _, backend := range states.proxies.Backends() {
+				backendsStatus.Backends = append(backendsStatus.Backends, newBackendStatus(backend))
+			}
+			response = &backendsStatus
+		}
+
+		if err := MarshalJSONResponse(response, w); err != nil {
+			serviceLogger.Error().Msg(fmt.Sprintf("error %s encoding %+v to json", err, response))
+		}
+	}
+}
+
+// createAdminBackendStateHandler creates a handler that changes the admin state of the backend
+// with the url query param to the state, responding with the status of the backend
+func createAdminBackendStateHandler(states *BackendAdminStates, state BackendAdminState, serviceLogger *logging.ServiceLogger) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		rawURL := r.URL.Query().Get("url")
+		backend, err := states.Set(r.Context(), rawURL, state)
+		switch {
+		case errors.Is(err, ErrUnknownBackend):
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		case err != nil:
+			serviceLogger.Error().Err(err).Msg("failed to share backend admin state")
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		status := newBackendStatus(backend)
+		if err := MarshalJSONResponse(&status, w); err != nil {
+			serviceLogger.Error().Msg(fmt.Sprintf("error %s encoding %+v to json", err, status))
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kava-labs/kava-proxy-service/clients/cache"
+	"github.com/kava-labs/kava-proxy-service/decode"
+	"github.com/stretchr/testify/require"
+)
+
+const testAdminToken = "secret"
+
+// adminTestRequest makes a request to the admin api authenticated with the token
+func adminTestRequest(t *testing.T, handler http.Handler, method string, target string, token string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, nil)
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+func TestUnitTestAdminAPI_ChangesBackendState(t *testing.T) {
+	first := newStatusBackend(t, http.StatusOK)
+	second := newStatusBackend(t, http.StatusOK)
+	proxies, logger := newTestHostProxies(t, first, second)
+	handler := newAdminHandler(NewBackendAdminStates(proxies, nil, "test", logger), testAdminToken, logger)
+
+	w := adminTestRequest(t, handler, http.MethodGet, AdminBackendsPath, "")
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+	w = adminTestRequest(t, handler, http.MethodGet, AdminBackendsPath, "wrong")
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = adminTestRequest(t, handler, http.MethodGet, AdminBackendsPath, testAdminToken)
+	require.Equal(t, http.StatusOK, w.Code)
+	var backends BackendsStatusResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &backends))
+	require.Len(t, backends.Backends, 2)
+	for _, backend := range backends.Backends {
+		require.Equal(t, "enabled", backend.State)
+	}
+
+	w = adminTestRequest(t, handler, http.MethodGet, AdminDrainBackendPath+"?url="+first.URL, testAdminToken)
+	require.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	w = adminTestRequest(t, handler, http.MethodPost, AdminDrainBackendPath+"?url=http://unknown:8545", testAdminToken)
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	w = adminTestRequest(t, handler, http.MethodPost, AdminDrainBackendPath+"?url="+first.URL, testAdminToken)
+	require.Equal(t, http.StatusOK, w.Code)
+	var status BackendStatus
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+	require.Equal(t, first.URL, status.URL)
+	require.Equal(t, "draining", status.State)
+
+	// requests are only routed to the backend that is not draining
+	r := httptest.NewRequest(http.MethodPost, "http://evm.kava.io", nil)
+	for i := 0; i < 4; i++ {
+		_, metadata, found := proxies.ProxyForRequest(r)
+		require.True(t, found)
+		require.Equal(t, second.URL, metadata.BackendRoute.String())
+	}
+
+	// no requests are routed to the host once all of its backends are out of rotation
+	w = adminTestRequest(t, handler, http.MethodPost, AdminDisableBackendPath+"?url="+second.URL, testAdminToken)
+	require.Equal(t, http.StatusOK, w.Code)
+	_, _, found := proxies.ProxyForRequest(r)
+	require.False(t, found)
+
+	w = adminTestRequest(t, handler, http.MethodPost, AdminEnableBackendPath+"?url="+first.URL, testAdminToken)
+	require.Equal(t, http.StatusOK, w.Code)
+	w = adminTestRequest(t, handler, http.MethodGet, AdminBackendsPath+"?url="+first.URL, testAdminToken)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+	require.Equal(t, "enabled", status.State)
+	_, metadata, found := proxies.ProxyForRequest(r)
+	require.True(t, found)
+	require.Equal(t, first.URL, metadata.BackendRoute.String())
+}
+
+func TestUnitTestBackendAdminStates_SharesStatesThroughCache(t *testing.T) {
+	backend := newStatusBackend(t, http.StatusOK)
+	cacheClient := cache.NewInMemoryCache()
+	proxies, logger := newTestHostProxies(t, backend)
+	replicaProxies, _ := newTestHostProxies(t, backend)
+	states := NewBackendAdminStates(proxies, cacheClient, "test", logger)
+	replicaStates := NewBackendAdminStates(replicaProxies, cacheClient, "test", logger)
+
+	_, err := states.Set(context.Background(), backend.URL, BackendAdminStateDisabled)
+	require.NoError(t, err)
+
+	replicaBackend, found := replicaStates.Backend(backend.URL)
+	require.True(t, found)
+	require.Equal(t, BackendAdminStateEnabled, replicaBackend.AdminState())
+	replicaStates.SyncAll(context.Background())
+	require.Equal(t, BackendAdminStateDisabled, replicaBackend.AdminState())
+
+	_, err = states.Set(context.Background(), backend.URL, BackendAdminStateEnabled)
+	require.NoError(t, err)
+	replicaStates.SyncAll(context.Background())
+	require.Equal(t, BackendAdminStateEnabled, replicaBackend.AdminState())
+}
+
+func TestUnitTestStickyFilterRouter_DrainingBackendServesItsFilters(t *testing.T) {
+	backend := newStatusBackend(t, http.StatusOK)
+	proxies, logger := newTestHostProxies(t, backend)
+	router := newStickyFilterRouter(cache.NewInMemoryCache(), "test", time.Minute, logger)
+	states := NewBackendAdminStates(proxies, nil, "test", logger)
+
+	newFilterReq := &decode.EVMRPCRequestEnvelope{Method: "eth_newFilter", Params: []interface{}{}}
+	_, installedBy, found := proxies.ProxyForRequest(filterTestRequest(newFilterReq))
+	require.True(t, found)
+	router.record(context.Background(), "evm.kava.io", newFilterReq, installedBy, http.StatusOK, []byte(`{"jsonrpc":"2.0","id":1,"result":"0x1"}`))
+
+	req := &decode.EVMRPCRequestEnvelope{Method: "eth_getFilterChanges", Params: []interface{}{"0x1"}}
+
+	_, err := states.Set(context.Background(), backend.URL, BackendAdminStateDraining)
+	require.NoError(t, err)
+	_, metadata, found, err := router.proxyForRequest(filterTestRequest(req), proxies, req)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, backend.URL, metadata.BackendRoute.String())
+
+	_, err = states.Set(context.Background(), backend.URL, BackendAdminStateDisabled)
+	require.NoError(t, err)
+	_, _, _, err = router.proxyForRequest(filterTestRequest(req), proxies, req)
+	require.ErrorIs(t, err, ErrFilterBackendGone)
+}
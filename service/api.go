@@ -6,3 +6,21 @@ type DatabaseStatusResponse struct {
 	LatestProxiedRequestMetricPartitionTableName string `json:"latest_proxied_request_metric_partition_table_name"` // name of the latest created and currently attached partition for the proxied_request_metrics table
 	TotalProxiedRequestMetricPartitions          int64  `json:"total_proxied_request_metric_partitions"`            // total number of attached partitions for the proxied_request_metrics table
 }
+
+// BackendsStatusResponse wraps values
+// returned by calls to /status/backends
+type BackendsStatusResponse struct {
+	Backends []BackendStatus `json:"backends"` // status of every backend requests may be proxied to
+}
+
+// BackendStatus wraps the status of a single backend
+type BackendStatus struct {
+	URL            string `json:"url"`             // url of the backend
+	Healthy        bool   `json:"healthy"`         // false if the backend has been marked down by health checks
+	CircuitBreaker string `json:"circuit_breaker"` // state of the circuit breaker of the backend: closed, open or half-open
+	HeadHeight     uint64 `json:"head_height"`     // latest block number reported by the backend, zero if unknown
+	HeadLag        uint64 `json:"head_lag"`        // number of blocks the backend is behind the highest head of all backends
+	Lagging        bool   `json:"lagging"`         // true if the backend is too far behind to serve requests for the latest state
+	InFlight       int64  `json:"in_flight"`       // number of requests proxied to the backend that have not completed
+	State          string `json:"state"`           // admin state of the backend: enabled, draining or disabled
+}
@@ -0,0 +1,465 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kava-labs/kava-proxy-service/config"
+	"github.com/kava-labs/kava-proxy-service/logging"
+)
+
+// BackendAdminState is the state of a backend as set by operators through the admin api
+type BackendAdminState int32
+
+const (
+	// the backend serves requests as usual
+	BackendAdminStateEnabled BackendAdminState = iota
+	// the backend is not sent new requests, except for requests for
+	// filters it installed, while the requests in flight to it complete
+	BackendAdminStateDraining
+	// the backend is not sent any requests
+	BackendAdminStateDisabled
+)
+
+// String returns the name of the state
+func (s BackendAdminState) String() string {
+	switch s {
+	case BackendAdminStateDraining:
+		return "draining"
+	case BackendAdminStateDisabled:
+		return "disabled"
+	default:
+		return "enabled"
+	}
+}
+
+// ParseBackendAdminState returns the state with the name and error (if any)
+func ParseBackendAdminState(name string) (BackendAdminState, error) {
+	for _, state := range []BackendAdminState{BackendAdminStateEnabled, BackendAdminStateDraining, BackendAdminStateDisabled} {
+		if state.String() == name {
+			return state, nil
+		}
+	}
+	return BackendAdminStateEnabled, fmt.Errorf("unknown backend state %s", name)
+}
+
+// Backend is a single upstream server that requests can be proxied to.
+// A Backend may be a member of multiple pools (ie. the same node may serve as
+// the pruning backend for one host and the default backend for another), in which
+// case all pools share the same Backend and its state.
+type Backend struct {
+	url url.URL
+	// transport requests are made to the backend with, replaced when its settings change
+	transport atomic.Pointer[backendTransport]
+
+	// number of requests proxied to the backend that have not yet completed
+	inFlight atomic.Int64
+
+	// health of the backend as determined by active health checks.
+	// backends are assumed to be healthy until a health check says otherwise.
+	unhealthy                     atomic.Bool
+	healthMu                      sync.Mutex
+	consecutiveFailedHealthChecks int
+	lastHealthCheckErr            error
+
+	// head of the chain as last reported by the backend (zero until known),
+	// and how many blocks it is behind the highest head reported by any backend
+	headHeight atomic.Uint64
+	headLag    atomic.Uint64
+	lagging    atomic.Bool
+
+	// passively detects failures from the outcome of proxied requests.
+	// nil when circuit breaking is disabled.
+	breaker *circuitBreaker
+
+	// whether operators have taken the backend out of rotation
+	adminState atomic.Int32
+
+	// logs the errors of requests to the backend
+	serviceLogger *logging.ServiceLogger
+}
+
+// backendTransport is the transport requests are made to a backend with,
+// the settings it was created from and the reverse proxy to the backend using it
+type backendTransport struct {
+	config    config.BackendTransportConfig
+	transport http.RoundTripper
+	proxy     *httputil.ReverseProxy
+}
+
+// newBackend creates a Backend with a reverse proxy to the target url
+// that tracks the number of requests in flight to the backend.
+// If circuitBreakerConfig is non-nil, the backend is routed around
+// when its circuit breaker trips.
+// Requests are made with a transport configured by transportConfig,
+// or the http.DefaultTransport if it has no settings.
+func newBackend(target url.URL, circuitBreakerConfig *CircuitBreakerConfig, transportConfig config.BackendTransportConfig, serviceLogger *logging.ServiceLogger) (*Backend, error) {
+	backend := &Backend{
+		url:           target,
+		serviceLogger: serviceLogger,
+	}
+	if err := backend.setTransport(transportConfig); err != nil {
+		return nil, err
+	}
+
+	if circuitBreakerConfig != nil {
+		backend.breaker = newCircuitBreaker(*circuitBreakerConfig)
+	}
+
+	return backend, nil
+}
+
+// setTransport replaces the transport & reverse proxy requests are made to the backend with
+// by ones configured by transportConfig, keeping the state of the backend. Requests in flight
+// complete with the transport they started with.
+func (b *Backend) setTransport(transportConfig config.BackendTransportConfig) error {
+	transport, err := newBackendTransport(transportConfig)
+	if err != nil {
+		return fmt.Errorf("failed to create transport for backend %s: %w", b.url.String(), err)
+	}
+
+	target := b.url
+	proxy := httputil.NewSingleHostReverseProxy(&target)
+	proxy.Transport = &inFlightTrackingTransport{
+		backend: b,
+		next:    transport,
+	}
+	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		writeBackendUnreachableError(w, r, err, b.serviceLogger)
+	}
+
+	previous := b.transport.Swap(&backendTransport{config: transportConfig, transport: transport, proxy: proxy})
+	// the default transport is shared by every backend without transport settings
+	if previous != nil && previous.transport != http.DefaultTransport {
+		if closer, ok := previous.transport.(interface{ CloseIdleConnections() }); ok {
+			closer.CloseIdleConnections()
+		}
+	}
+
+	return nil
+}
+
+// writeBackendUnreachableError is the error handler of the reverse proxies to backends,
+// responding with a JSON-RPC error when the backend can't be reached or fails to respond
+// with a valid http response. The status of the response remains a 502 so that the request
+// is retried against other backends, and counted as a failure of the backend.
+func writeBackendUnreachableError(w http.ResponseWriter, r *http.Request, err error, serviceLogger *logging.ServiceLogger) {
+	serviceLogger.Error().Msg(fmt.Sprintf("proxy error: %v", err))
+	if err := writeJSONRPCError(w, http.StatusBadGateway, decodedRequestFromContext(r), backendUnreachableErrorCode, backendUnreachableErrorMessage); err != nil {
+		serviceLogger.Error().Msg(fmt.Sprintf("can't write backend unreachable response: %v", err))
+	}
+}
+
+// newBackendTransport creates the transport for requests to a backend from its settings,
+// the http.DefaultTransport is shared by backends without any settings
+func newBackendTransport(transportConfig config.BackendTransportConfig) (http.RoundTripper, error) {
+	if transportConfig == (config.BackendTransportConfig{}) {
+		return http.DefaultTransport, nil
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if transportConfig.DialTimeout > 0 {
+		transport.DialContext = (&net.Dialer{
+			Timeout:   transportConfig.DialTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext
+	}
+	if transportConfig.ResponseHeaderTimeout > 0 {
+		transport.ResponseHeaderTimeout = transportConfig.ResponseHeaderTimeout
+	}
+	if transportConfig.MaxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = transportConfig.MaxIdleConnsPerHost
+		if transport.MaxIdleConns < transportConfig.MaxIdleConnsPerHost {
+			transport.MaxIdleConns = transportConfig.MaxIdleConnsPerHost
+		}
+	}
+	if transportConfig.HasTLSConfig() {
+		tlsConfig, err := transportConfig.TLSConfig()
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	return transport, nil
+}
+
+// URL returns the url of the backend
+func (b *Backend) URL() url.URL {
+	return b.url
+}
+
+// TransportConfig returns the settings of the transport requests are made to the backend with
+func (b *Backend) TransportConfig() config.BackendTransportConfig {
+	return b.transport.Load().config
+}
+
+// Proxy returns the reverse proxy for the backend
+func (b *Backend) Proxy() *httputil.ReverseProxy {
+	return b.transport.Load().proxy
+}
+
+// InFlight returns the number of requests proxied to the backend that have not yet completed
+func (b *Backend) InFlight() int64 {
+	return b.inFlight.Load()
+}
+
+// Healthy returns false when the backend has been marked down by active health checks
+func (b *Backend) Healthy() bool {
+	return !b.unhealthy.Load()
+}
+
+// LastHealthCheckError returns the error of the most recent health check of the backend,
+// nil if the check succeeded or the backend has not been checked.
+func (b *Backend) LastHealthCheckError() error {
+	b.healthMu.Lock()
+	defer b.healthMu.Unlock()
+	return b.lastHealthCheckErr
+}
+
+// HeadHeight returns the latest block number reported by the backend, zero if unknown
+func (b *Backend) HeadHeight() uint64 {
+	return b.headHeight.Load()
+}
+
+// HeadLag returns how many blocks the backend is behind the highest head of all backends
+func (b *Backend) HeadLag() uint64 {
+	return b.headLag.Load()
+}
+
+// Lagging returns true when the backend is too far behind the chain tip
+// to serve requests for the latest state of the chain
+func (b *Backend) Lagging() bool {
+	return b.lagging.Load()
+}
+
+// recordHeadLag updates how far behind the chain tip the backend is.
+// The backend is lagging if it is more than maxLag blocks behind.
+// Returns true if whether the backend is lagging changed.
+func (b *Backend) recordHeadLag(chainTip uint64, maxLag uint64) bool {
+	var lag uint64
+	if head := b.HeadHeight(); head < chainTip {
+		lag = chainTip - head
+	}
+	b.headLag.Store(lag)
+
+	lagging := lag > maxLag
+	return b.lagging.Swap(lagging) != lagging
+}
+
+// CircuitState returns the state of the circuit breaker of the backend:
+// "closed", "open" or "half-open". It is always "closed" when circuit breaking is disabled.
+func (b *Backend) CircuitState() string {
+	return b.breaker.State().String()
+}
+
+// AdminState returns whether operators have taken the backend out of rotation
+func (b *Backend) AdminState() BackendAdminState {
+	return BackendAdminState(b.adminState.Load())
+}
+
+// setAdminState updates the admin state of the backend,
+// returning true if the state changed
+func (b *Backend) setAdminState(state BackendAdminState) bool {
+	return BackendAdminState(b.adminState.Swap(int32(state))) != state
+}
+
+// Enabled returns true unless operators have taken the backend out of rotation
+func (b *Backend) Enabled() bool {
+	return b.AdminState() == BackendAdminStateEnabled
+}
+
+// Available returns true when the backend should be chosen to serve requests
+func (b *Backend) Available() bool {
+	return b.Enabled() && b.Healthy() && b.breaker.allow()
+}
+
+// startRequest records that a request is being proxied to the backend.
+// The returned func must be called with the outcome of the request once the response
+// has been written so that the circuit breaker of the backend can learn from it,
+// or with statusAbandoned if the proxy cancelled the request before it completed.
+func (b *Backend) startRequest() func(statusCode int, body []byte, latency time.Duration) {
+	if b == nil {
+		return func(int, []byte, time.Duration) {}
+	}
+	return b.breaker.start()
+}
+
+// recordHealthCheck updates the health of the backend with the result of a health check.
+// The backend is marked unhealthy after failureThreshold consecutive failed checks
+// and is marked healthy again by the next successful check.
+// Returns true if the health of the backend changed.
+func (b *Backend) recordHealthCheck(checkErr error, failureThreshold int) bool {
+	b.healthMu.Lock()
+	defer b.healthMu.Unlock()
+
+	b.lastHealthCheckErr = checkErr
+	wasHealthy := b.Healthy()
+
+	if checkErr == nil {
+		b.consecutiveFailedHealthChecks = 0
+		b.unhealthy.Store(false)
+		return !wasHealthy
+	}
+
+	b.consecutiveFailedHealthChecks++
+	if b.consecutiveFailedHealthChecks >= failureThreshold {
+		b.unhealthy.Store(true)
+	}
+
+	return wasHealthy != b.Healthy()
+}
+
+// inFlightTrackingTransport is an http.RoundTripper that counts the requests in flight
+// to a backend. A request is considered in flight until its response body is closed.
+type inFlightTrackingTransport struct {
+	backend *Backend
+	next    http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *inFlightTrackingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	t.backend.inFlight.Add(1)
+
+	resp, err := t.next.RoundTrip(r)
+	if err != nil {
+		t.backend.inFlight.Add(-1)
+		return resp, err
+	}
+
+	resp.Body = &inFlightTrackingBody{ReadCloser: resp.Body, backend: t.backend}
+
+	return resp, nil
+}
+
+// inFlightTrackingBody decrements the in flight count of the backend
+// the first time the response body is closed.
+type inFlightTrackingBody struct {
+	io.ReadCloser
+	backend *Backend
+	once    sync.Once
+}
+
+// Close implements io.Closer
+func (b *inFlightTrackingBody) Close() error {
+	b.once.Do(func() { b.backend.inFlight.Add(-1) })
+	return b.ReadCloser.Close()
+}
+
+// backendRegistry ensures only one Backend is created for each backend url
+// so that state about a backend is shared across every pool it is a member of.
+type backendRegistry struct {
+	mu                   sync.Mutex
+	backendsByURL        map[string]*Backend
+	circuitBreakerConfig *CircuitBreakerConfig
+	// settings of the transports of backends by url
+	transportConfigs map[string]config.BackendTransportConfig
+	*logging.ServiceLogger
+}
+
+// newBackendRegistry creates an empty backendRegistry
+// that creates backends according to the service config
+func newBackendRegistry(config config.Config, serviceLogger *logging.ServiceLogger) *backendRegistry {
+	registry := &backendRegistry{
+		backendsByURL:    make(map[string]*Backend),
+		transportConfigs: config.ProxyBackendTransportMap,
+		ServiceLogger:    serviceLogger,
+	}
+
+	if config.ProxyBackendCircuitBreakerEnabled {
+		registry.circuitBreakerConfig = &CircuitBreakerConfig{
+			FailureThreshold:     config.ProxyBackendCircuitBreakerFailureThreshold,
+			Cooldown:             config.ProxyBackendCircuitBreakerCooldown,
+			HalfOpenRequests:     config.ProxyBackendCircuitBreakerHalfOpenRequests,
+			SlowRequestThreshold: config.ProxyBackendCircuitBreakerSlowRequestThreshold,
+		}
+	}
+
+	return registry
+}
+
+// getOrCreate returns the Backend for the url, creating it if it does not exist.
+// The transport of an existing backend whose transport settings changed is replaced,
+// the backend keeps its state, ie. its health, circuit breaker & admin state.
+func (br *backendRegistry) getOrCreate(target url.URL) *Backend {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+
+	key := target.String()
+	transportConfig := br.transportConfigs[key]
+	if backend, found := br.backendsByURL[key]; found {
+		if backend.TransportConfig() != transportConfig {
+			if err := backend.setTransport(transportConfig); err != nil {
+				// transport settings are validated before use, so this only happens if
+				// e.g. a certificate file was removed after validation
+				br.Error().Msg(fmt.Sprintf("%s, keeping the current transport", err))
+			}
+		}
+		return backend
+	}
+
+	backend, err := newBackend(target, br.circuitBreakerConfig, transportConfig, br.ServiceLogger)
+	if err != nil {
+		br.Error().Msg(fmt.Sprintf("%s, using the default transport", err))
+		backend, _ = newBackend(target, br.circuitBreakerConfig, config.BackendTransportConfig{}, br.ServiceLogger)
+	}
+	br.backendsByURL[key] = backend
+
+	return backend
+}
+
+// setTransportConfigs replaces the settings of the transports of backends by url,
+// backends created afterwards use the new settings
+func (br *backendRegistry) setTransportConfigs(transportConfigs map[string]config.BackendTransportConfig) {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+	br.transportConfigs = transportConfigs
+}
+
+// retain removes every backend that is not in the list from the registry,
+// so that a backend that is added back later starts with a fresh state
+func (br *backendRegistry) retain(backends []*Backend) {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+
+	retained := make(map[*Backend]bool, len(backends))
+	for _, backend := range backends {
+		retained[backend] = true
+	}
+	for key, backend := range br.backendsByURL {
+		if !retained[backend] {
+			delete(br.backendsByURL, key)
+		}
+	}
+}
+
+// uniqueBackends merges lists of backends into a single list without duplicates,
+// sorted by url for stable output.
+func uniqueBackends(backendLists ...[]*Backend) []*Backend {
+	seen := make(map[*Backend]bool)
+	unique := make([]*Backend, 0)
+
+	for _, backends := range backendLists {
+		for _, backend := range backends {
+			if seen[backend] {
+				continue
+			}
+			seen[backend] = true
+			unique = append(unique, backend)
+		}
+	}
+
+	sort.Slice(unique, func(i, j int) bool {
+		return unique[i].url.String() < unique[j].url.String()
+	})
+
+	return unique
+}
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/kava-labs/kava-proxy-service/decode"
+	"github.com/kava-labs/kava-proxy-service/logging"
+	"github.com/kava-labs/kava-proxy-service/service/cachemdw"
+)
+
+// BackendHealthCheckerConfig wraps values used
+// for creating a new BackendHealthChecker
+type BackendHealthCheckerConfig struct {
+	// how often every backend is probed
+	Interval time.Duration
+	// how long to wait for a probe response before considering it failed
+	Timeout time.Duration
+	// the JSON-RPC method used to probe the backend, e.g. eth_blockNumber or eth_syncing
+	Method string
+	// number of consecutive failed probes before a backend is marked down
+	FailureThreshold int
+}
+
+// BackendHealthChecker periodically probes every backend known to the proxies
+// with a JSON-RPC request, marking backends that repeatedly fail the probe as down
+// so that they are skipped when routing requests.
+type BackendHealthChecker struct {
+	config     BackendHealthCheckerConfig
+	proxies    Proxies
+	httpClient *http.Client
+	*logging.ServiceLogger
+}
+
+// NewBackendHealthChecker creates a BackendHealthChecker for the backends of the proxies
+func NewBackendHealthChecker(config BackendHealthCheckerConfig, proxies Proxies, serviceLogger *logging.ServiceLogger) *BackendHealthChecker {
+	return &BackendHealthChecker{
+		config:        config,
+		proxies:       proxies,
+		httpClient:    &http.Client{Timeout: config.Timeout},
+		ServiceLogger: serviceLogger,
+	}
+}
+
+// Run probes all backends every interval until the context is cancelled
+func (hc *BackendHealthChecker) Run(ctx context.Context) {
+	ticker := time.NewTicker(hc.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		hc.CheckAll(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// CheckAll concurrently probes every backend once, updating the health of each backend
+func (hc *BackendHealthChecker) CheckAll(ctx context.Context) {
+	var wg sync.WaitGroup
+
+	for _, backend := range hc.proxies.Backends() {
+		wg.Add(1)
+		go func(backend *Backend) {
+			defer wg.Done()
+
+			checkErr := hc.probe(ctx, backend)
+			changed := backend.recordHealthCheck(checkErr, hc.config.FailureThreshold)
+
+			backendURL := backend.URL()
+			if !changed {
+				hc.Trace().Str("backend", backendURL.String()).Err(checkErr).Msg("backend health check complete")
+				return
+			}
+			if backend.Healthy() {
+				hc.Info().Str("backend", backendURL.String()).Msg("backend passed health check, marking healthy")
+			} else {
+				hc.Error().Str("backend", backendURL.String()).Err(checkErr).Msg("backend failed health checks, marking unhealthy")
+			}
+		}(backend)
+	}
+
+	wg.Wait()
+}
+
+// probe makes the configured JSON-RPC request to the backend, returning an error
+// if the backend does not respond with a successful JSON-RPC response
+func (hc *BackendHealthChecker) probe(ctx context.Context, backend *Backend) error {
+	jsonRpcResponse, err := callBackend(ctx, hc.httpClient, backend, hc.config.Method)
+	if err != nil {
+		return err
+	}
+
+	// a node that is still syncing responds to eth_syncing with an object describing its progress
+	if hc.config.Method == "eth_syncing" && string(jsonRpcResponse.Result) != "false" {
+		return fmt.Errorf("backend is syncing: %s", jsonRpcResponse.Result)
+	}
+
+	return nil
+}
+
+// callBackend makes a JSON-RPC request without params for the method directly to the backend,
+// returning an error if the backend does not respond with a successful JSON-RPC response
+func callBackend(ctx context.Context, httpClient *http.Client, backend *Backend, method string) (*cachemdw.JsonRpcResponse, error) {
+	body, err := json.Marshal(decode.EVMRPCRequestEnvelope{
+		JSONRPCVersion: "2.0",
+		ID:             1,
+		Method:         method,
+		Params:         []interface{}{},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	backendURL := backend.URL()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, backendURL.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	// use the transport of the backend so that e.g. backends behind mTLS can be called
+	backendClient := *httpClient
+	backendClient.Transport = backend.transport.Load().transport
+	resp, err := backendClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	jsonRpcResponse, err := cachemdw.UnmarshalJsonRpcResponse(respBody)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSON-RPC response: %w", err)
+	}
+	if err := jsonRpcResponse.Error(); err != nil {
+		return nil, err
+	}
+
+	return jsonRpcResponse, nil
+}
@@ -0,0 +1,208 @@
+package service_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kava-labs/kava-proxy-service/decode"
+	"github.com/kava-labs/kava-proxy-service/service"
+	"github.com/stretchr/testify/require"
+)
+
+// newJsonRpcBackend creates a test backend server that responds to every request with the result
+func newJsonRpcBackend(t *testing.T, statusCode int, result string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(statusCode)
+		w.Write([]byte(fmt.Sprintf(`{"jsonrpc":"2.0","id":1,"result":%s}`, result)))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newTestHealthChecker(proxies service.Proxies, method string, failureThreshold int) *service.BackendHealthChecker {
+	return service.NewBackendHealthChecker(service.BackendHealthCheckerConfig{
+		Interval:         time.Second,
+		Timeout:          time.Second,
+		Method:           method,
+		FailureThreshold: failureThreshold,
+	}, proxies, dummyLogger)
+}
+
+func requireBackendHealth(t *testing.T, proxies service.Proxies, expectedHealthByURL map[string]bool) {
+	backends := proxies.Backends()
+	require.Len(t, backends, len(expectedHealthByURL))
+	for _, backend := range backends {
+		backendURL := backend.URL()
+		expectedHealthy, found := expectedHealthByURL[backendURL.String()]
+		require.True(t, found, "unexpected backend %s", backendURL.String())
+		require.Equal(t, expectedHealthy, backend.Healthy(), "unexpected health for backend %s", backendURL.String())
+	}
+}
+
+func TestUnitTestBackendHealthChecker_MarksBackendsDownAfterFailureThreshold(t *testing.T) {
+	healthy := newJsonRpcBackend(t, http.StatusOK, `"0x10"`)
+	failing := newJsonRpcBackend(t, http.StatusBadGateway, `null`)
+
+	config := newConfig(t, fmt.Sprintf("evm.kava.io>%s+%s", healthy.URL, failing.URL), "", "")
+	proxies := service.NewProxies(config, nil, dummyLogger)
+	healthChecker := newTestHealthChecker(proxies, "eth_blockNumber", 2)
+
+	healthChecker.CheckAll(context.Background())
+	requireBackendHealth(t, proxies, map[string]bool{healthy.URL: true, failing.URL: true})
+
+	healthChecker.CheckAll(context.Background())
+	requireBackendHealth(t, proxies, map[string]bool{healthy.URL: true, failing.URL: false})
+
+	// down members of the pool are skipped
+	for i := 0; i < 4; i++ {
+		_, metadata, found := proxies.ProxyForRequest(mockReqForUrl("//evm.kava.io"))
+		require.True(t, found)
+		require.Equal(t, healthy.URL, metadata.BackendRoute.String())
+	}
+}
+
+func TestUnitTestBackendHealthChecker_SyncingBackendIsUnhealthy(t *testing.T) {
+	synced := newJsonRpcBackend(t, http.StatusOK, `false`)
+	syncing := newJsonRpcBackend(t, http.StatusOK, `{"currentBlock":"0x1","highestBlock":"0x10"}`)
+
+	config := newConfig(t, fmt.Sprintf("evm.kava.io>%s+%s", synced.URL, syncing.URL), "", "")
+	proxies := service.NewProxies(config, nil, dummyLogger)
+	healthChecker := newTestHealthChecker(proxies, "eth_syncing", 1)
+
+	healthChecker.CheckAll(context.Background())
+	requireBackendHealth(t, proxies, map[string]bool{synced.URL: true, syncing.URL: false})
+}
+
+func TestUnitTestBackendHealthChecker_FallsBackToNextTier(t *testing.T) {
+	archive := newJsonRpcBackend(t, http.StatusOK, `"0x10"`)
+	pruning := newJsonRpcBackend(t, http.StatusServiceUnavailable, `null`)
+	shard := newJsonRpcBackend(t, http.StatusServiceUnavailable, `null`)
+
+	config := newConfig(t,
+		fmt.Sprintf("archive.kava.io>%s", archive.URL),
+		fmt.Sprintf("archive.kava.io>%s", pruning.URL),
+		fmt.Sprintf("archive.kava.io>10|%s", shard.URL),
+	)
+	proxies := service.NewProxies(config, nil, dummyLogger)
+	healthChecker := newTestHealthChecker(proxies, "eth_blockNumber", 1)
+	healthChecker.CheckAll(context.Background())
+
+	t.Run("latest requests fall back to default when pruning backends are down", func(t *testing.T) {
+		req := mockJsonRpcReqToUrl("//archive.kava.io", &decode.EVMRPCRequestEnvelope{
+			Method: "eth_getBlockByNumber",
+			Params: []interface{}{"latest", false},
+		})
+		_, metadata, found := proxies.ProxyForRequest(req)
+		require.True(t, found)
+		require.Equal(t, service.ResponseBackendDefault, metadata.BackendName)
+		require.Equal(t, archive.URL, metadata.BackendRoute.String())
+	})
+
+	t.Run("shard requests fall back to default when shard backend is down", func(t *testing.T) {
+		req := mockJsonRpcReqToUrl("//archive.kava.io", &decode.EVMRPCRequestEnvelope{
+			Method: "eth_getBlockByNumber",
+			Params: []interface{}{"0x5", false},
+		})
+		_, metadata, found := proxies.ProxyForRequest(req)
+		require.True(t, found)
+		require.Equal(t, service.ResponseBackendShardFallback, metadata.BackendName)
+		require.Equal(t, archive.URL, metadata.BackendRoute.String())
+	})
+}
+
+func TestUnitTestBackendHealthChecker_RoutesToRedundantShardBackend(t *testing.T) {
+	archive := newJsonRpcBackend(t, http.StatusOK, `"0x10"`)
+	primary := newJsonRpcBackend(t, http.StatusServiceUnavailable, `null`)
+	redundant := newJsonRpcBackend(t, http.StatusOK, `"0x10"`)
+
+	config := newConfig(t,
+		fmt.Sprintf("archive.kava.io>%s", archive.URL),
+		"",
+		fmt.Sprintf("archive.kava.io>10|%s+%s", primary.URL, redundant.URL),
+	)
+	proxies := service.NewProxies(config, nil, dummyLogger)
+	req := mockJsonRpcReqToUrl("//archive.kava.io", &decode.EVMRPCRequestEnvelope{
+		Method: "eth_getBlockByNumber",
+		Params: []interface{}{"0x5", false},
+	})
+
+	// requests are routed to the primary backend of the shard while it is healthy
+	_, metadata, found := proxies.ProxyForRequest(req)
+	require.True(t, found)
+	require.Equal(t, service.ResponseBackendShard, metadata.BackendName)
+	require.Equal(t, primary.URL, metadata.BackendRoute.String())
+
+	healthChecker := newTestHealthChecker(proxies, "eth_blockNumber", 1)
+	healthChecker.CheckAll(context.Background())
+
+	_, metadata, found = proxies.ProxyForRequest(req)
+	require.True(t, found)
+	require.Equal(t, service.ResponseBackendShard, metadata.BackendName)
+	require.Equal(t, redundant.URL, metadata.BackendRoute.String())
+	require.Equal(t, uint64(10), metadata.ShardEndHeight)
+}
+
+func TestUnitTestBackendHealthChecker_ShardDefaultFallbackDisabled(t *testing.T) {
+	archive := newJsonRpcBackend(t, http.StatusOK, `"0x10"`)
+	shard := newJsonRpcBackend(t, http.StatusServiceUnavailable, `null`)
+
+	config := newConfig(t,
+		fmt.Sprintf("archive.kava.io>%s", archive.URL),
+		"",
+		fmt.Sprintf("archive.kava.io>10|%s", shard.URL),
+	)
+	config.ProxyShardDefaultFallbackEnabled = false
+	proxies := service.NewProxies(config, nil, dummyLogger)
+	healthChecker := newTestHealthChecker(proxies, "eth_blockNumber", 1)
+	healthChecker.CheckAll(context.Background())
+
+	// the default backend may not have the state for the height of the shard,
+	// so the request is still routed to the shard
+	req := mockJsonRpcReqToUrl("//archive.kava.io", &decode.EVMRPCRequestEnvelope{
+		Method: "eth_getBlockByNumber",
+		Params: []interface{}{"0x5", false},
+	})
+	_, metadata, found := proxies.ProxyForRequest(req)
+	require.True(t, found)
+	require.Equal(t, service.ResponseBackendShard, metadata.BackendName)
+	require.Equal(t, shard.URL, metadata.BackendRoute.String())
+}
+
+func TestUnitTestBackendHealthChecker_ShardDefaultFallbackDisabledSkipsDisabledBackends(t *testing.T) {
+	archive := newJsonRpcBackend(t, http.StatusOK, `"0x10"`)
+	primary := newJsonRpcBackend(t, http.StatusOK, `"0x10"`)
+	redundant := newJsonRpcBackend(t, http.StatusServiceUnavailable, `null`)
+
+	config := newConfig(t,
+		fmt.Sprintf("archive.kava.io>%s", archive.URL),
+		"",
+		fmt.Sprintf("archive.kava.io>10|%s+%s", primary.URL, redundant.URL),
+	)
+	config.ProxyShardDefaultFallbackEnabled = false
+	proxies := service.NewProxies(config, nil, dummyLogger)
+	healthChecker := newTestHealthChecker(proxies, "eth_blockNumber", 1)
+	healthChecker.CheckAll(context.Background())
+	adminStates := service.NewBackendAdminStates(proxies, nil, "test", dummyLogger)
+	_, err := adminStates.Set(context.Background(), primary.URL, service.BackendAdminStateDisabled)
+	require.NoError(t, err)
+
+	// the unhealthy backend of the shard is routed to rather than the one taken out of rotation
+	req := mockJsonRpcReqToUrl("//archive.kava.io", &decode.EVMRPCRequestEnvelope{
+		Method: "eth_getBlockByNumber",
+		Params: []interface{}{"0x5", false},
+	})
+	_, metadata, found := proxies.ProxyForRequest(req)
+	require.True(t, found)
+	require.Equal(t, service.ResponseBackendShard, metadata.BackendName)
+	require.Equal(t, redundant.URL, metadata.BackendRoute.String())
+
+	// no requests are routed to the shard once all of its backends are out of rotation
+	_, err = adminStates.Set(context.Background(), redundant.URL, service.BackendAdminStateDraining)
+	require.NoError(t, err)
+	_, _, found = proxies.ProxyForRequest(req)
+	require.False(t, found)
+}
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kava-labs/kava-proxy-service/config"
+	"github.com/kava-labs/kava-proxy-service/decode"
+	"github.com/kava-labs/kava-proxy-service/logging"
+	"github.com/stretchr/testify/require"
+)
+
+// proxyStatusCode proxies a request through the backend, returning the status code of the response
+func proxyStatusCode(backend *Backend) int {
+	recorder := httptest.NewRecorder()
+	backend.Proxy().ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/", nil))
+	return recorder.Code
+}
+
+func TestUnitTestBackend_TransportConfig(t *testing.T) {
+	logger, err := logging.New("ERROR")
+	require.NoError(t, err)
+
+	t.Run("response header timeout", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(200 * time.Millisecond)
+			w.WriteHeader(http.StatusOK)
+		}))
+		t.Cleanup(server.Close)
+		serverURL, err := url.Parse(server.URL)
+		require.NoError(t, err)
+
+		patient, err := newBackend(*serverURL, nil, config.BackendTransportConfig{}, &logger)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, proxyStatusCode(patient))
+
+		impatient, err := newBackend(*serverURL, nil, config.BackendTransportConfig{ResponseHeaderTimeout: 50 * time.Millisecond}, &logger)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusBadGateway, proxyStatusCode(impatient))
+	})
+
+	t.Run("ca bundle", func(t *testing.T) {
+		server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+		t.Cleanup(server.Close)
+		serverURL, err := url.Parse(server.URL)
+		require.NoError(t, err)
+
+		caBundleFile := filepath.Join(t.TempDir(), "ca.pem")
+		caBundle := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+		require.NoError(t, os.WriteFile(caBundleFile, caBundle, 0o600))
+
+		// the certificate of the test server is not signed by a trusted authority
+		untrusting, err := newBackend(*serverURL, nil, config.BackendTransportConfig{}, &logger)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusBadGateway, proxyStatusCode(untrusting))
+
+		trusting, err := newBackend(*serverURL, nil, config.BackendTransportConfig{CABundleFile: caBundleFile}, &logger)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, proxyStatusCode(trusting))
+
+		_, err = newBackend(*serverURL, nil, config.BackendTransportConfig{CABundleFile: filepath.Join(t.TempDir(), "missing.pem")}, &logger)
+		require.Error(t, err)
+	})
+}
+
+func TestUnitTestBackend_RespondsWithJSONRPCErrorWhenUnreachable(t *testing.T) {
+	logger, err := logging.New("ERROR")
+	require.NoError(t, err)
+
+	server := httptest.NewServer(http.NotFoundHandler())
+	serverURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	server.Close()
+
+	backend, err := newBackend(*serverURL, nil, config.BackendTransportConfig{}, &logger)
+	require.NoError(t, err)
+
+	decodedReq := &decode.EVMRPCRequestEnvelope{JSONRPCVersion: "2.0", ID: "abc", Method: "eth_blockNumber"}
+	r := httptest.NewRequest(http.MethodPost, "/", nil)
+	r = r.WithContext(context.WithValue(r.Context(), DecodedRequestContextKey, decodedReq))
+	w := httptest.NewRecorder()
+	backend.Proxy().ServeHTTP(w, r)
+
+	// the status remains a 502 so that the request is retried against other backends
+	require.Equal(t, http.StatusBadGateway, w.Code)
+	require.JSONEq(t, `{"jsonrpc":"2.0","id":"abc","error":{"code":-32052,"message":"backend unreachable"}}`, w.Body.String())
+}
+
+func TestUnitTestBackendRegistry_ReplacesTransportOfBackendsWhoseTransportChanged(t *testing.T) {
+	backendURL, err := url.Parse("http://kava-archive:8545")
+	require.NoError(t, err)
+
+	logger, err := logging.New("ERROR")
+	require.NoError(t, err)
+
+	registry := newBackendRegistry(config.Config{}, &logger)
+	original := registry.getOrCreate(*backendURL)
+	require.Same(t, original, registry.getOrCreate(*backendURL))
+	originalProxy := original.Proxy()
+	original.setAdminState(BackendAdminStateDraining)
+
+	transportConfig := config.BackendTransportConfig{ResponseHeaderTimeout: 5 * time.Minute, MaxIdleConnsPerHost: 100}
+	registry.setTransportConfigs(map[string]config.BackendTransportConfig{backendURL.String(): transportConfig})
+
+	// the backend keeps its state, only its transport & proxy are replaced
+	updated := registry.getOrCreate(*backendURL)
+	require.Same(t, original, updated)
+	require.Equal(t, transportConfig, updated.TransportConfig())
+	require.NotSame(t, originalProxy, updated.Proxy())
+	require.Equal(t, BackendAdminStateDraining, updated.AdminState())
+
+	// the transport is only replaced when its settings change
+	updatedProxy := updated.Proxy()
+	require.Same(t, updatedProxy, registry.getOrCreate(*backendURL).Proxy())
+
+	// the current transport is kept when the new one can't be created
+	registry.setTransportConfigs(map[string]config.BackendTransportConfig{backendURL.String(): {CABundleFile: "/does/not/exist.pem"}})
+	require.Same(t, original, registry.getOrCreate(*backendURL))
+	require.Same(t, updatedProxy, original.Proxy())
+}
@@ -79,8 +79,10 @@ func (bp *BatchProcessor) RequestAndServe(w http.ResponseWriter) error {
 	// write cache hit header based on results of all requests
 	w.Header().Set(cachemdw.CacheHeaderKey, cacheHitValue(len(bp.requests), bp.cacheHits))
 
-	// return error status if any sub-request returned a non-200 response
-	if bp.status != http.StatusOK {
+	// return error status if any sub-request returned a non-200 response.
+	// the responses are only included if they are all valid json,
+	// ie. when the errors are JSON-RPC errors (such as those of the proxy service)
+	if bp.status != http.StatusOK && !bp.responsesAreJSON() {
 		w.WriteHeader(bp.status)
 		w.Write(nil)
 		return nil
@@ -96,12 +98,22 @@ func (bp *BatchProcessor) RequestAndServe(w http.ResponseWriter) error {
 		return err
 	}
 
-	w.WriteHeader(http.StatusOK)
+	w.WriteHeader(bp.status)
 	w.Write(res)
 
 	return nil
 }
 
+// responsesAreJSON returns true if the response to every request is valid json
+func (bp *BatchProcessor) responsesAreJSON() bool {
+	for _, r := range bp.responses {
+		if r == nil || !json.Valid(r.Bytes()) {
+			return false
+		}
+	}
+	return true
+}
+
 // setResponse is a thread-safe method to set the response for the query with index idx
 func (bp *BatchProcessor) setResponse(idx int, res *bytes.Buffer) {
 	bp.mu.Lock()
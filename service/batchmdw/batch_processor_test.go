@@ -1,8 +1,11 @@
 package batchmdw
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
+	"github.com/kava-labs/kava-proxy-service/logging"
 	"github.com/kava-labs/kava-proxy-service/service/cachemdw"
 	"github.com/stretchr/testify/require"
 )
@@ -45,3 +48,43 @@ func TestUnitTest_cacheHitValue(t *testing.T) {
 		})
 	}
 }
+
+func TestUnitTestBatchProcessor_ErrorResponses(t *testing.T) {
+	logger, err := logging.New("ERROR")
+	require.NoError(t, err)
+
+	newRequests := func(n int) []*http.Request {
+		reqs := make([]*http.Request, 0, n)
+		for i := 0; i < n; i++ {
+			reqs = append(reqs, httptest.NewRequest(http.MethodPost, "/", nil))
+		}
+		return reqs
+	}
+
+	t.Run("JSON-RPC errors are responded with in an array", func(t *testing.T) {
+		handler := func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadGateway)
+			w.Write([]byte(`{"jsonrpc":"2.0","id":1,"error":{"code":-32052,"message":"backend unreachable"}}`))
+		}
+		w := httptest.NewRecorder()
+		require.NoError(t, NewBatchProcessor(&logger, handler, newRequests(2)).RequestAndServe(w))
+
+		require.Equal(t, http.StatusBadGateway, w.Code)
+		require.JSONEq(t, `[
+			{"jsonrpc":"2.0","id":1,"error":{"code":-32052,"message":"backend unreachable"}},
+			{"jsonrpc":"2.0","id":1,"error":{"code":-32052,"message":"backend unreachable"}}
+		]`, w.Body.String())
+	})
+
+	t.Run("errors that are not json are responded to without a body", func(t *testing.T) {
+		handler := func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte("service unavailable"))
+		}
+		w := httptest.NewRecorder()
+		require.NoError(t, NewBatchProcessor(&logger, handler, newRequests(2)).RequestAndServe(w))
+
+		require.Equal(t, http.StatusServiceUnavailable, w.Code)
+		require.Empty(t, w.Body.String())
+	})
+}
@@ -9,6 +9,15 @@ import (
 
 	"github.com/kava-labs/kava-proxy-service/decode"
 	"github.com/kava-labs/kava-proxy-service/logging"
+	"github.com/kava-labs/kava-proxy-service/service/cachemdw"
+)
+
+const (
+	// BatchTooLargeErrorCode is the JSON-RPC error code of the errors
+	// responded with for each request of a batch that is too large
+	BatchTooLargeErrorCode = -32053
+	// batchTooLargeErrorMessage is the message of the errors, formatted with the size of the batch and the maximum size
+	batchTooLargeErrorMessage = "request batch size is too large (%d>%d)"
 )
 
 // BatchMiddlewareConfig are the necessary configuration options for the Batch Processing Middleware
@@ -41,8 +50,9 @@ func CreateBatchProcessingMiddleware(
 		}
 		if len(batchReq) > config.MaximumBatchSize {
 			config.ServiceLogger.Debug().Int("size", len(batchReq)).Int("max allowed", config.MaximumBatchSize).Msg("request batch size too large")
-			w.WriteHeader(http.StatusRequestEntityTooLarge)
-			w.Write([]byte(fmt.Sprintf("request batch size is too large (%d>%d)", len(batchReq), config.MaximumBatchSize)))
+			if err := writeBatchTooLargeErrors(w, batchReq, config.MaximumBatchSize); err != nil {
+				config.ServiceLogger.Error().Err(err).Msg("[BatchProcessingMiddleware] can't write batch too large response")
+			}
 			return
 		}
 
@@ -84,6 +94,39 @@ func CreateBatchProcessingMiddleware(
 	}
 }
 
+// writeBatchTooLargeErrors responds to the batch with an array of JSON-RPC errors,
+// one for each request of the batch echoing the id of the request
+func writeBatchTooLargeErrors(w http.ResponseWriter, batchReq []*decode.EVMRPCRequestEnvelope, maximumBatchSize int) error {
+	responses := make([]*cachemdw.JsonRpcResponse, 0, len(batchReq))
+	for _, single := range batchReq {
+		var requestID interface{}
+		if single != nil {
+			requestID = single.ID
+		}
+		id, err := json.Marshal(requestID)
+		if err != nil {
+			return err
+		}
+		responses = append(responses, &cachemdw.JsonRpcResponse{
+			Version: "2.0",
+			ID:      id,
+			JsonRpcError: &cachemdw.JsonRpcError{
+				Code:    BatchTooLargeErrorCode,
+				Message: fmt.Sprintf(batchTooLargeErrorMessage, len(batchReq), maximumBatchSize),
+			},
+		})
+	}
+	body, err := json.Marshal(responses)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusRequestEntityTooLarge)
+	_, err = w.Write(body)
+	return err
+}
+
 // IsBatchContext returns true when the passed in context is for a batch EVM request
 func IsBatchContext(ctx context.Context, decodedBatchContextKey string) bool {
 	batch := ctx.Value(decodedBatchContextKey)
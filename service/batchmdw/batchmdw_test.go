@@ -0,0 +1,45 @@
+package batchmdw
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kava-labs/kava-proxy-service/decode"
+	"github.com/kava-labs/kava-proxy-service/logging"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnitTestBatchProcessingMiddleware_RespondsWithErrorsToBatchesThatAreTooLarge(t *testing.T) {
+	logger, err := logging.New("ERROR")
+	require.NoError(t, err)
+
+	config := &BatchMiddlewareConfig{
+		ServiceLogger:                  &logger,
+		ContextKeyDecodedRequestBatch:  "batch",
+		ContextKeyDecodedRequestSingle: "single",
+		MaximumBatchSize:               1,
+	}
+	handler := CreateBatchProcessingMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("requests of a batch that is too large should not be handled")
+	}, config)
+
+	batch := []*decode.EVMRPCRequestEnvelope{
+		{JSONRPCVersion: "2.0", ID: float64(1), Method: "eth_blockNumber"},
+		nil,
+		{JSONRPCVersion: "2.0", ID: "two", Method: "eth_chainId"},
+	}
+	r := httptest.NewRequest(http.MethodPost, "/", nil)
+	r = r.WithContext(context.WithValue(r.Context(), config.ContextKeyDecodedRequestBatch, batch))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	require.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	require.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	require.JSONEq(t, `[
+		{"jsonrpc":"2.0","id":1,"error":{"code":-32053,"message":"request batch size is too large (3>1)"}},
+		{"jsonrpc":"2.0","id":null,"error":{"code":-32053,"message":"request batch size is too large (3>1)"}},
+		{"jsonrpc":"2.0","id":"two","error":{"code":-32053,"message":"request batch size is too large (3>1)"}}
+	]`, w.Body.String())
+}
@@ -0,0 +1,249 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/kava-labs/kava-proxy-service/config"
+	"github.com/kava-labs/kava-proxy-service/decode"
+	"github.com/kava-labs/kava-proxy-service/service/cachemdw"
+)
+
+// blockRangeSegment is the part of the block range of a request served by a single backend
+type blockRangeSegment struct {
+	fromBlock int64
+	toBlock   int64
+	proxy     *httputil.ReverseProxy
+	metadata  ProxyMetadata
+}
+
+// blockRangeProxyForRequest routes requests for a range of blocks, ie. eth_getLogs, to the shards containing the range.
+// Ranges contained by a single shard are routed to it, ranges spanning multiple shards are split into
+// a request per shard (and the default proxy for blocks beyond the last shard) whose logs are merged.
+// Ranges including block tags other than "earliest" are routed to the default proxy, as are ranges including
+// any shard without an available backend when falling back to the default proxy is enabled.
+func (sp ShardProxies) blockRangeProxyForRequest(r *http.Request, shardsForHost config.IntervalURLMap, decodedReq *decode.EVMRPCRequestEnvelope, defaultRoute defaultRoute) (*httputil.ReverseProxy, ProxyMetadata, bool) {
+	fromBlock, toBlock, err := decode.ParseBlockRangeFromParams(decodedReq.Method, decodedReq.Params)
+	if err != nil {
+		sp.Debug().Msg(fmt.Sprintf("failed to parse block range for %+v: %s", decodedReq, err))
+		return defaultRoute.route()
+	}
+
+	// convert "earliest" to "1" so it routes to first shard
+	if fromBlock == decode.BlockTagToNumberCodec[decode.BlockTagEarliest] {
+		fromBlock = 1
+	}
+	if toBlock == decode.BlockTagToNumberCodec[decode.BlockTagEarliest] {
+		toBlock = 1
+	}
+	// ranges ending at the chain tip can only be served by the default proxy
+	if fromBlock < 1 || toBlock < fromBlock {
+		return defaultRoute.route()
+	}
+
+	var segments []blockRangeSegment
+	for height := fromBlock; height <= toBlock; {
+		urls, shardHeight, found := shardsForHost.LookupAll(uint64(height))
+		if !found {
+			// the rest of the range is beyond the last shard
+			if !defaultRoute.found {
+				return defaultRoute.route()
+			}
+			segments = append(segments, blockRangeSegment{fromBlock: height, toBlock: toBlock, proxy: defaultRoute.proxy, metadata: defaultRoute.metadata})
+			break
+		}
+
+		backend, url, fallBack := sp.shardBackendForRequest(r, urls)
+		if fallBack {
+			sp.Debug().Msg(fmt.Sprintf("every backend of shard for height %d is unavailable. routing to default proxy", height))
+			return defaultRoute.fallback()
+		}
+		if backend == nil {
+			return nil, ProxyMetadata{BackendName: ResponseBackendShard}, false
+		}
+
+		segmentToBlock := int64(shardHeight)
+		if segmentToBlock > toBlock {
+			segmentToBlock = toBlock
+		}
+		segment := blockRangeSegment{
+			fromBlock: height,
+			toBlock:   segmentToBlock,
+			proxy:     backend.Proxy(),
+			metadata: ProxyMetadata{
+				BackendName:    ResponseBackendShard,
+				BackendRoute:   *url,
+				ShardEndHeight: shardHeight,
+				backend:        backend,
+			},
+		}
+		segments = append(segments, segment)
+		height = segment.toBlock + 1
+	}
+
+	if len(segments) == 1 {
+		return segments[0].proxy, segments[0].metadata, true
+	}
+
+	sp.Trace().Msg(fmt.Sprintf("splitting request for blocks %d to %d across %d backends", fromBlock, toBlock, len(segments)))
+
+	last := segments[len(segments)-1].metadata
+	metadata := ProxyMetadata{
+		BackendName:    ResponseBackendShardSplit,
+		BackendRoute:   last.BackendRoute,
+		ShardEndHeight: last.ShardEndHeight,
+	}
+	return newBlockRangeProxy(decodedReq, r.Header.Clone(), segments), metadata, true
+}
+
+// newBlockRangeProxy returns a reverse proxy that sends a request for each segment of the block range
+// of the request concurrently, responding with the logs of every segment merged into a single response
+func newBlockRangeProxy(decodedReq *decode.EVMRPCRequestEnvelope, header http.Header, segments []blockRangeSegment) *httputil.ReverseProxy {
+	return &httputil.ReverseProxy{
+		// the transport makes the requests to the backends of the segments
+		Director: func(*http.Request) {},
+		Transport: blockRangeTransport{
+			decodedReq: decodedReq,
+			header:     header,
+			segments:   segments,
+		},
+	}
+}
+
+// blockRangeTransport is an http.RoundTripper that splits a request for a range of blocks
+// into a request per segment of the range
+type blockRangeTransport struct {
+	decodedReq *decode.EVMRPCRequestEnvelope
+	// headers of the original request, sent with the request for each segment
+	header   http.Header
+	segments []blockRangeSegment
+}
+
+// RoundTrip implements http.RoundTripper
+func (t blockRangeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	responses := make([]*bufferedResponseWriter, len(t.segments))
+
+	var wg sync.WaitGroup
+	for i, segment := range t.segments {
+		body, err := t.segmentRequestBody(segment)
+		if err != nil {
+			return nil, err
+		}
+
+		segmentReq := req.Clone(req.Context())
+		segmentReq.Header = t.header.Clone()
+		// let the transport decompress the responses of the backends so that they can be merged
+		segmentReq.Header.Del("Accept-Encoding")
+		segmentReq.Body = io.NopCloser(bytes.NewReader(body))
+		segmentReq.ContentLength = int64(len(body))
+
+		wg.Add(1)
+		go func(i int, segment blockRangeSegment) {
+			defer wg.Done()
+
+			response := newBufferedResponseWriter()
+			startedAt := time.Now()
+			recordOutcome := segment.metadata.backend.startRequest()
+			// an aborted response is merged as a failed segment
+			if !response.serve(segment.proxy, segmentReq) && segmentReq.Context().Err() != nil {
+				recordOutcome(statusAbandoned, nil, time.Since(startedAt))
+			} else {
+				recordOutcome(response.statusCode, response.body.Bytes(), time.Since(startedAt))
+			}
+
+			responses[i] = response
+		}(i, segment)
+	}
+	wg.Wait()
+
+	return t.mergeResponses(req, responses)
+}
+
+// segmentRequestBody returns the body of the original request with the block range of the segment
+func (t blockRangeTransport) segmentRequestBody(segment blockRangeSegment) ([]byte, error) {
+	paramIndex := decode.MethodNameToBlockRangeParamIndex[t.decodedReq.Method]
+	filter := make(map[string]interface{})
+	for key, value := range t.decodedReq.Params[paramIndex].(map[string]interface{}) {
+		filter[key] = value
+	}
+	filter["fromBlock"] = "0x" + strconv.FormatInt(segment.fromBlock, 16)
+	filter["toBlock"] = "0x" + strconv.FormatInt(segment.toBlock, 16)
+
+	params := make([]interface{}, len(t.decodedReq.Params))
+	copy(params, t.decodedReq.Params)
+	params[paramIndex] = filter
+
+	return json.Marshal(decode.EVMRPCRequestEnvelope{
+		JSONRPCVersion: t.decodedReq.JSONRPCVersion,
+		ID:             t.decodedReq.ID,
+		Method:         t.decodedReq.Method,
+		Params:         params,
+	})
+}
+
+// mergeResponses concatenates the logs of the responses of the segments, which are in block order
+// as the segments are. The response of the first segment that failed is returned as is.
+func (t blockRangeTransport) mergeResponses(req *http.Request, responses []*bufferedResponseWriter) (*http.Response, error) {
+	logs := make([]json.RawMessage, 0)
+	for _, response := range responses {
+		decodedResponse, err := cachemdw.UnmarshalJsonRpcResponse(response.body.Bytes())
+		if response.statusCode != http.StatusOK || err != nil || decodedResponse.JsonRpcError != nil {
+			return newHTTPResponse(req, response.statusCode, response.header, response.body.Bytes()), nil
+		}
+
+		var segmentLogs []json.RawMessage
+		if err := json.Unmarshal(decodedResponse.Result, &segmentLogs); err != nil {
+			return newHTTPResponse(req, response.statusCode, response.header, response.body.Bytes()), nil
+		}
+		logs = append(logs, segmentLogs...)
+	}
+
+	id, err := json.Marshal(t.decodedReq.ID)
+	if err != nil {
+		return nil, err
+	}
+	result, err := json.Marshal(logs)
+	if err != nil {
+		return nil, err
+	}
+	merged, err := (&cachemdw.JsonRpcResponse{
+		Version: t.decodedReq.JSONRPCVersion,
+		ID:      id,
+		Result:  result,
+	}).Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	header := make(http.Header)
+	header.Set("Content-Type", "application/json")
+	return newHTTPResponse(req, http.StatusOK, header, merged), nil
+}
+
+// newHTTPResponse creates the response to the request with the status, header & body
+func newHTTPResponse(req *http.Request, statusCode int, header http.Header, body []byte) *http.Response {
+	if statusCode == 0 {
+		statusCode = http.StatusOK
+	}
+	header = header.Clone()
+	header.Del("Content-Length")
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", statusCode, http.StatusText(statusCode)),
+		StatusCode:    statusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
@@ -0,0 +1,235 @@
+package service
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/kava-labs/kava-proxy-service/service/cachemdw"
+)
+
+// JSON-RPC error code for internal errors of the server handling the request
+// https://www.jsonrpc.org/specification#error_object
+const jsonRpcInternalErrorCode = -32603
+
+// statusAbandoned is reported as the outcome of a request that was cancelled by the proxy
+// before the backend responded (ie. the losing request of a hedged pair).
+// It says nothing about the health of the backend so is not counted as a success or failure.
+const statusAbandoned = 0
+
+// CircuitBreakerConfig wraps values used for creating the circuit breaker of each backend
+type CircuitBreakerConfig struct {
+	// number of consecutive failed requests that trips the breaker
+	FailureThreshold int
+	// how long a tripped breaker routes traffic away from the backend before allowing trial requests
+	Cooldown time.Duration
+	// number of concurrent trial requests allowed while half-open,
+	// and the number of consecutive successful trial requests that close the breaker
+	HalfOpenRequests int
+	// requests slower than this are considered failed, zero disables latency based failures
+	SlowRequestThreshold time.Duration
+}
+
+// circuitState is the state of a circuitBreaker
+type circuitState int
+
+const (
+	// requests flow normally
+	circuitClosed circuitState = iota
+	// the backend has tripped the breaker and is routed around
+	circuitOpen
+	// the cool down has elapsed and trial requests are allowed through
+	circuitHalfOpen
+)
+
+// String returns a human readable name of the state
+func (s circuitState) String() string {
+	switch s {
+	case circuitOpen:
+		return "open"
+	case circuitHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// circuitBreaker passively detects a failing backend from the outcome of proxied requests.
+// A nil circuitBreaker always allows requests.
+type circuitBreaker struct {
+	config CircuitBreakerConfig
+	now    func() time.Time
+
+	mu                  sync.Mutex
+	state               circuitState
+	consecutiveFailures int
+	openedAt            time.Time
+	trialsInFlight      int
+	trialSuccesses      int
+	// incremented every time the breaker trips, so that the trials of an earlier
+	// half-open period are not mistaken for those of the current one
+	generation uint64
+}
+
+// newCircuitBreaker creates a closed circuitBreaker
+func newCircuitBreaker(config CircuitBreakerConfig) *circuitBreaker {
+	return &circuitBreaker{
+		config: config,
+		now:    time.Now,
+	}
+}
+
+// allow returns true when a request may be sent to the backend.
+// An open breaker becomes half-open once its cool down has elapsed.
+func (cb *circuitBreaker) allow() bool {
+	if cb == nil {
+		return true
+	}
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	switch cb.currentState() {
+	case circuitOpen:
+		return false
+	case circuitHalfOpen:
+		return cb.trialsInFlight < cb.config.HalfOpenRequests
+	default:
+		return true
+	}
+}
+
+// State returns the current state of the breaker
+func (cb *circuitBreaker) State() circuitState {
+	if cb == nil {
+		return circuitClosed
+	}
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	return cb.currentState()
+}
+
+// currentState moves an open breaker to half-open if the cool down has elapsed.
+// callers must hold the lock.
+func (cb *circuitBreaker) currentState() circuitState {
+	if cb.state == circuitOpen && cb.now().Sub(cb.openedAt) >= cb.config.Cooldown {
+		cb.state = circuitHalfOpen
+		cb.trialsInFlight = 0
+		cb.trialSuccesses = 0
+	}
+	return cb.state
+}
+
+// start records that a request is being sent to the backend.
+// The returned func must be called with the outcome of the request once it completes.
+func (cb *circuitBreaker) start() func(statusCode int, body []byte, latency time.Duration) {
+	if cb == nil {
+		return func(int, []byte, time.Duration) {}
+	}
+
+	cb.mu.Lock()
+	isTrial := cb.currentState() == circuitHalfOpen
+	if isTrial {
+		cb.trialsInFlight++
+	}
+	generation := cb.generation
+	cb.mu.Unlock()
+
+	return func(statusCode int, body []byte, latency time.Duration) {
+		if statusCode == statusAbandoned {
+			cb.abandon(isTrial, generation)
+			return
+		}
+		cb.record(isTrial, generation, cb.isFailure(statusCode, body, latency))
+	}
+}
+
+// isCurrentTrial returns true if the request is a trial of the current half-open period,
+// ie. it was started while half-open and the breaker has not tripped since. callers must hold the lock.
+func (cb *circuitBreaker) isCurrentTrial(isTrial bool, generation uint64) bool {
+	return isTrial && generation == cb.generation
+}
+
+// record updates the state of the breaker with the outcome of a request
+// started in the generation of the breaker
+func (cb *circuitBreaker) record(isTrial bool, generation uint64, failed bool) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	// trials of an earlier half-open period no longer hold a trial slot
+	isTrial = cb.isCurrentTrial(isTrial, generation)
+	if isTrial {
+		cb.trialsInFlight--
+	}
+
+	switch cb.currentState() {
+	case circuitClosed:
+		if !failed {
+			cb.consecutiveFailures = 0
+			return
+		}
+		cb.consecutiveFailures++
+		if cb.consecutiveFailures >= cb.config.FailureThreshold {
+			cb.trip()
+		}
+	case circuitHalfOpen:
+		// outcomes of requests started before the breaker last opened say nothing about recovery
+		if !isTrial {
+			return
+		}
+		if failed {
+			cb.trip()
+			return
+		}
+		cb.trialSuccesses++
+		if cb.trialSuccesses >= cb.config.HalfOpenRequests {
+			cb.state = circuitClosed
+			cb.consecutiveFailures = 0
+		}
+	}
+}
+
+// abandon releases the trial slot of a request that will never complete
+func (cb *circuitBreaker) abandon(isTrial bool, generation uint64) {
+	if !isTrial {
+		return
+	}
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.isCurrentTrial(isTrial, generation) {
+		cb.trialsInFlight--
+	}
+}
+
+// trip opens the breaker, starting a new generation. callers must hold the lock.
+func (cb *circuitBreaker) trip() {
+	cb.generation++
+	cb.state = circuitOpen
+	cb.openedAt = cb.now()
+	cb.consecutiveFailures = 0
+}
+
+// isFailure returns true when the outcome of a request indicates the backend is failing:
+// the upstream was unreachable or errored (5xx), answered with a JSON-RPC internal error,
+// or took longer than the slow request threshold.
+func (cb *circuitBreaker) isFailure(statusCode int, body []byte, latency time.Duration) bool {
+	if statusCode >= http.StatusInternalServerError {
+		return true
+	}
+
+	if cb.config.SlowRequestThreshold > 0 && latency > cb.config.SlowRequestThreshold {
+		return true
+	}
+
+	response, err := cachemdw.UnmarshalJsonRpcResponse(body)
+	if err != nil {
+		// not every proxied response is a single JSON-RPC response
+		return false
+	}
+
+	return response.JsonRpcError != nil && response.JsonRpcError.Code == jsonRpcInternalErrorCode
+}
@@ -0,0 +1,191 @@
+package service
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+var testCircuitBreakerConfig = CircuitBreakerConfig{
+	FailureThreshold:     3,
+	Cooldown:             30 * time.Second,
+	HalfOpenRequests:     2,
+	SlowRequestThreshold: time.Second,
+}
+
+// newTestCircuitBreaker creates a circuit breaker with a clock that only moves when advanced
+func newTestCircuitBreaker() (*circuitBreaker, func(time.Duration)) {
+	now := time.Now()
+	cb := newCircuitBreaker(testCircuitBreakerConfig)
+	cb.now = func() time.Time { return now }
+
+	return cb, func(d time.Duration) { now = now.Add(d) }
+}
+
+func failRequest(cb *circuitBreaker) {
+	cb.start()(http.StatusBadGateway, nil, 0)
+}
+
+func succeedRequest(cb *circuitBreaker) {
+	cb.start()(http.StatusOK, []byte(`{"jsonrpc":"2.0","id":1,"result":"0x1"}`), 0)
+}
+
+func TestUnitTestCircuitBreaker_TripsAfterConsecutiveFailures(t *testing.T) {
+	cb, _ := newTestCircuitBreaker()
+
+	failRequest(cb)
+	failRequest(cb)
+	// a success resets the count of consecutive failures
+	succeedRequest(cb)
+	failRequest(cb)
+	failRequest(cb)
+	require.Equal(t, circuitClosed, cb.State())
+	require.True(t, cb.allow())
+
+	failRequest(cb)
+	require.Equal(t, circuitOpen, cb.State())
+	require.False(t, cb.allow())
+}
+
+func TestUnitTestCircuitBreaker_HalfOpenAfterCooldown(t *testing.T) {
+	cb, advance := newTestCircuitBreaker()
+	for i := 0; i < testCircuitBreakerConfig.FailureThreshold; i++ {
+		failRequest(cb)
+	}
+
+	advance(testCircuitBreakerConfig.Cooldown - time.Second)
+	require.Equal(t, circuitOpen, cb.State())
+
+	advance(time.Second)
+	require.Equal(t, circuitHalfOpen, cb.State())
+
+	// only a limited number of trial requests are let through at once
+	firstTrial := cb.start()
+	require.True(t, cb.allow())
+	secondTrial := cb.start()
+	require.False(t, cb.allow())
+
+	firstTrial(http.StatusOK, nil, 0)
+	require.Equal(t, circuitHalfOpen, cb.State())
+	secondTrial(http.StatusOK, nil, 0)
+	require.Equal(t, circuitClosed, cb.State())
+	require.True(t, cb.allow())
+}
+
+func TestUnitTestCircuitBreaker_ReopensOnFailedTrial(t *testing.T) {
+	cb, advance := newTestCircuitBreaker()
+
+	// started before the breaker trips, so it is not a trial request
+	staleRequest := cb.start()
+
+	for i := 0; i < testCircuitBreakerConfig.FailureThreshold; i++ {
+		failRequest(cb)
+	}
+	advance(testCircuitBreakerConfig.Cooldown)
+	require.Equal(t, circuitHalfOpen, cb.State())
+
+	staleRequest(http.StatusInternalServerError, nil, 0)
+	require.Equal(t, circuitHalfOpen, cb.State())
+
+	failRequest(cb)
+	require.Equal(t, circuitOpen, cb.State())
+	require.False(t, cb.allow())
+}
+
+func TestUnitTestCircuitBreaker_IgnoresTrialsOfEarlierHalfOpenPeriods(t *testing.T) {
+	for _, staleStatusCode := range []int{http.StatusOK, statusAbandoned} {
+		cb, advance := newTestCircuitBreaker()
+		for i := 0; i < testCircuitBreakerConfig.FailureThreshold; i++ {
+			failRequest(cb)
+		}
+		advance(testCircuitBreakerConfig.Cooldown)
+		require.Equal(t, circuitHalfOpen, cb.State())
+
+		staleTrial := cb.start()
+		// the other trial fails, tripping the breaker again while the stale trial is in flight
+		failRequest(cb)
+		require.Equal(t, circuitOpen, cb.State())
+
+		advance(testCircuitBreakerConfig.Cooldown)
+		require.Equal(t, circuitHalfOpen, cb.State())
+
+		// the trial of the earlier half-open period neither releases a trial slot
+		// of the current one nor counts towards closing the breaker
+		staleTrial(staleStatusCode, nil, 0)
+		require.Equal(t, circuitHalfOpen, cb.State())
+		require.Zero(t, cb.trialsInFlight)
+		require.Zero(t, cb.trialSuccesses)
+
+		for i := 0; i < testCircuitBreakerConfig.HalfOpenRequests; i++ {
+			require.True(t, cb.allow())
+			succeedRequest(cb)
+		}
+		require.Equal(t, circuitClosed, cb.State())
+	}
+}
+
+func TestUnitTestCircuitBreaker_IsFailure(t *testing.T) {
+	cb, _ := newTestCircuitBreaker()
+
+	testCases := []struct {
+		name       string
+		statusCode int
+		body       string
+		latency    time.Duration
+		isFailure  bool
+	}{
+		{
+			name:       "successful response",
+			statusCode: http.StatusOK,
+			body:       `{"jsonrpc":"2.0","id":1,"result":"0x1"}`,
+			isFailure:  false,
+		},
+		{
+			name:       "server error",
+			statusCode: http.StatusServiceUnavailable,
+			isFailure:  true,
+		},
+		{
+			name:       "JSON-RPC internal error",
+			statusCode: http.StatusOK,
+			body:       `{"jsonrpc":"2.0","id":1,"error":{"code":-32603,"message":"internal error"}}`,
+			isFailure:  true,
+		},
+		{
+			name:       "JSON-RPC error caused by the request",
+			statusCode: http.StatusOK,
+			body:       `{"jsonrpc":"2.0","id":1,"error":{"code":-32602,"message":"invalid params"}}`,
+			isFailure:  false,
+		},
+		{
+			name:       "slow response",
+			statusCode: http.StatusOK,
+			body:       `{"jsonrpc":"2.0","id":1,"result":"0x1"}`,
+			latency:    2 * time.Second,
+			isFailure:  true,
+		},
+		{
+			name:       "batch response",
+			statusCode: http.StatusOK,
+			body:       `[{"jsonrpc":"2.0","id":1,"result":"0x1"}]`,
+			isFailure:  false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.isFailure, cb.isFailure(tc.statusCode, []byte(tc.body), tc.latency))
+		})
+	}
+}
+
+func TestUnitTestCircuitBreaker_NilAlwaysAllows(t *testing.T) {
+	var cb *circuitBreaker
+
+	cb.start()(http.StatusInternalServerError, nil, 0)
+
+	require.True(t, cb.allow())
+	require.Equal(t, circuitClosed, cb.State())
+}
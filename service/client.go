@@ -12,6 +12,7 @@ import (
 
 const (
 	DatabaseStatusPath = "/status/database"
+	BackendsStatusPath = "/status/backends"
 )
 
 // ProxyServiceClient provides a client
@@ -59,6 +60,23 @@ func (c *ProxyServiceClient) GetDatabaseStatus(ctx context.Context) (DatabaseSta
 	return response, err
 }
 
+// GetBackendsStatus calls `BackendsStatusPath` to
+// get the health and head height of every backend
+func (c *ProxyServiceClient) GetBackendsStatus(ctx context.Context) (BackendsStatusResponse, error) {
+	var response BackendsStatusResponse
+	url := c.config.ProxyServiceHostname + BackendsStatusPath
+
+	request, err := CreateRequest(http.MethodGet, url, nil)
+
+	if err != nil {
+		return response, err
+	}
+
+	err = Call(*c, request, &response)
+
+	return response, err
+}
+
 // RequestError provides additional details about the failed request.
 type RequestError struct {
 	message    string
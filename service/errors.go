@@ -0,0 +1,75 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/kava-labs/kava-proxy-service/decode"
+	"github.com/kava-labs/kava-proxy-service/service/batchmdw"
+	"github.com/kava-labs/kava-proxy-service/service/cachemdw"
+)
+
+// JSON-RPC error codes of the errors originating from the proxy service rather than a backend.
+// The codes are part of the api of the proxy service and must not be changed or reused,
+// -32055 is reserved for requests exceeding a rate limit.
+//
+// The HTTP status of an error response describes the failure, as for errors of any other http api:
+// a 4xx when the request can't be served as sent (400 invalid request, 413 batch too large),
+// a 5xx when the proxy or its backends failed to serve it (502 no backend, backend unreachable
+// or quorum not reached, 504 backend timeout). Batches including an error response have its status.
+// Errors responded with in place of a backend, ie. for unknown filters, have the status of the backend's error.
+const (
+	// the backend failed to respond before the timeout of the request
+	backendTimeoutErrorCode = -32050
+	// no backend is configured for the host of the request
+	noBackendErrorCode = -32051
+	// the backend could not be reached or failed to respond with a valid http response
+	backendUnreachableErrorCode = -32052
+	// the batch of requests has more requests than are allowed
+	batchTooLargeErrorCode = batchmdw.BatchTooLargeErrorCode
+	// the body of the request could not be decoded
+	invalidRequestErrorCode = -32054
+	// not enough backends agreed on the result of the request
+	quorumNotReachedErrorCode = -32056
+)
+
+const (
+	noBackendErrorMessage          = "no proxy backend configured for request host"
+	backendUnreachableErrorMessage = "backend unreachable"
+	invalidRequestErrorMessage     = "could not decode request body"
+)
+
+// writeJSONRPCError responds to the request with a JSON-RPC error with the code and message,
+// echoing the id of the request (or null if it has none)
+func writeJSONRPCError(w http.ResponseWriter, status int, decodedReq *decode.EVMRPCRequestEnvelope, code int, message string) error {
+	var requestID interface{}
+	if decodedReq != nil {
+		requestID = decodedReq.ID
+	}
+	id, err := json.Marshal(requestID)
+	if err != nil {
+		return err
+	}
+	body, err := (&cachemdw.JsonRpcResponse{
+		Version: "2.0",
+		ID:      id,
+		JsonRpcError: &cachemdw.JsonRpcError{
+			Code:    code,
+			Message: message,
+		},
+	}).Marshal()
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, err = w.Write(body)
+	return err
+}
+
+// decodedRequestFromContext returns the decoded EVM request in the context of the request, or nil if it has none
+func decodedRequestFromContext(r *http.Request) *decode.EVMRPCRequestEnvelope {
+	decodedReq, _ := r.Context().Value(DecodedRequestContextKey).(*decode.EVMRPCRequestEnvelope)
+	return decodedReq
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"strings"
+	"time"
+
+	"github.com/kava-labs/kava-proxy-service/clients/cache"
+	"github.com/kava-labs/kava-proxy-service/decode"
+	"github.com/kava-labs/kava-proxy-service/logging"
+	"github.com/kava-labs/kava-proxy-service/service/cachemdw"
+)
+
+const (
+	// cache item type of the keys storing the backend that installed a filter
+	filterBackendCacheItemType = "filter-backend"
+	// JSON-RPC error code & message nodes respond with for unknown filters
+	filterNotFoundErrorCode    = -32000
+	filterNotFoundErrorMessage = "filter not found"
+)
+
+// ErrFilterBackendGone is returned when a request is for a filter
+// installed by a backend that requests are no longer proxied to
+var ErrFilterBackendGone = errors.New("backend that installed the filter is no longer available")
+
+// stickyFilterRouter routes requests for a filter to the backend that installed it,
+// as filters only exist on the node that installed them.
+// The backend of each filter is stored in the cache so that it is shared by every
+// replica of the proxy service, and forgotten once the filter is uninstalled
+// or has not been used for the ttl, ie. once the node has likely expired it.
+type stickyFilterRouter struct {
+	cacheClient cache.Cache
+	cachePrefix string
+	ttl         time.Duration
+	*logging.ServiceLogger
+}
+
+// newStickyFilterRouter creates a stickyFilterRouter storing the backend of each filter in the cache
+func newStickyFilterRouter(cacheClient cache.Cache, cachePrefix string, ttl time.Duration, serviceLogger *logging.ServiceLogger) *stickyFilterRouter {
+	return &stickyFilterRouter{
+		cacheClient:   cacheClient,
+		cachePrefix:   cachePrefix,
+		ttl:           ttl,
+		ServiceLogger: serviceLogger,
+	}
+}
+
+// proxyForRequest returns the proxy for the backend that installed the filter the request is for.
+// found is false when the request is not for a filter, or the backend of the filter is not known,
+// in which case the request should be routed as usual. ErrFilterBackendGone is returned
+// when the backend of the filter is not one of the backends of the proxies, or has been disabled.
+func (sf *stickyFilterRouter) proxyForRequest(r *http.Request, proxies Proxies, decodedReq *decode.EVMRPCRequestEnvelope) (*httputil.ReverseProxy, ProxyMetadata, bool, error) {
+	if sf == nil || !decode.MethodHasFilterIDParam(decodedReq.Method) {
+		return nil, ProxyMetadata{}, false, nil
+	}
+
+	filterID, err := decode.ParseFilterIDFromParams(decodedReq.Method, decodedReq.Params)
+	if err != nil {
+		sf.Debug().Msg(fmt.Sprintf("failed to parse filter id of %+v: %s", decodedReq, err))
+		return nil, ProxyMetadata{}, false, nil
+	}
+
+	rawBackendURL, err := sf.cacheClient.Get(r.Context(), sf.filterKey(r.Host, filterID))
+	if err != nil {
+		if !errors.Is(err, cache.ErrNotFound) {
+			sf.Error().Err(err).Msg(fmt.Sprintf("failed to get backend of filter %s", filterID))
+		}
+		return nil, ProxyMetadata{}, false, nil
+	}
+
+	for _, backend := range proxies.Backends() {
+		backendURL := backend.URL()
+		if backendURL.String() != string(rawBackendURL) {
+			continue
+		}
+		// draining backends keep serving the filters they installed
+		if backend.AdminState() == BackendAdminStateDisabled {
+			break
+		}
+
+		sf.Trace().Msg(fmt.Sprintf("routing request for filter %s to %s", filterID, backendURL.String()))
+		metadata := ProxyMetadata{
+			BackendName:  ResponseBackendStickyFilter,
+			BackendRoute: backendURL,
+			backend:      backend,
+		}
+		return backend.Proxy(), metadata, true, nil
+	}
+
+	return nil, ProxyMetadata{BackendName: ResponseBackendStickyFilter}, true, fmt.Errorf("%w: %s", ErrFilterBackendGone, rawBackendURL)
+}
+
+// record remembers the backend that served the request when it installed a filter,
+// extends how long it is remembered for when the filter is used,
+// and forgets it when the filter is uninstalled.
+func (sf *stickyFilterRouter) record(ctx context.Context, host string, decodedReq *decode.EVMRPCRequestEnvelope, metadata ProxyMetadata, statusCode int, responseBody []byte) {
+	if sf == nil || metadata.backend == nil || statusCode != http.StatusOK {
+		return
+	}
+
+	var filterID string
+	switch {
+	case decode.MethodCreatesFilter(decodedReq.Method):
+		response, err := cachemdw.UnmarshalJsonRpcResponse(responseBody)
+		if err != nil || response.Error() != nil {
+			return
+		}
+		if err := json.Unmarshal(response.Result, &filterID); err != nil || filterID == "" {
+			sf.Debug().Msg(fmt.Sprintf("unexpected %s result %s", decodedReq.Method, response.Result))
+			return
+		}
+	case decode.MethodHasFilterIDParam(decodedReq.Method):
+		var err error
+		if filterID, err = decode.ParseFilterIDFromParams(decodedReq.Method, decodedReq.Params); err != nil {
+			return
+		}
+	default:
+		return
+	}
+
+	key := sf.filterKey(host, filterID)
+	if decodedReq.Method == "eth_uninstallFilter" {
+		if err := sf.cacheClient.Delete(ctx, key); err != nil {
+			sf.Error().Err(err).Msg(fmt.Sprintf("failed to forget backend of filter %s", filterID))
+		}
+		return
+	}
+
+	backendURL := metadata.backend.URL()
+	if err := sf.cacheClient.Set(ctx, key, []byte(backendURL.String()), sf.ttl); err != nil {
+		sf.Error().Err(err).Msg(fmt.Sprintf("failed to remember backend of filter %s", filterID))
+	}
+}
+
+// filterKey returns the cache key of the backend of the filter installed for the host
+func (sf *stickyFilterRouter) filterKey(host string, filterID string) string {
+	return strings.Join([]string{sf.cachePrefix, filterBackendCacheItemType, host, strings.ToLower(filterID)}, ":")
+}
+
+// writeFilterNotFoundError responds to the request for a filter with the JSON-RPC error nodes respond with
+// for unknown filters, so that clients install a new filter
+func writeFilterNotFoundError(w http.ResponseWriter, decodedReq *decode.EVMRPCRequestEnvelope) error {
+	return writeJSONRPCError(w, http.StatusOK, decodedReq, filterNotFoundErrorCode, filterNotFoundErrorMessage)
+}
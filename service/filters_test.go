@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kava-labs/kava-proxy-service/clients/cache"
+	"github.com/kava-labs/kava-proxy-service/decode"
+	"github.com/stretchr/testify/require"
+)
+
+// filterTestRequest creates a request for evm.kava.io with the decoded request in its context
+func filterTestRequest(decodedReq *decode.EVMRPCRequestEnvelope) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "http://evm.kava.io", nil)
+	return r.WithContext(context.WithValue(r.Context(), DecodedRequestContextKey, decodedReq))
+}
+
+func TestUnitTestStickyFilterRouter_RoutesFilterRequestsToInstallingBackend(t *testing.T) {
+	first := newStatusBackend(t, http.StatusOK)
+	second := newStatusBackend(t, http.StatusOK)
+	proxies, logger := newTestHostProxies(t, first, second)
+	cacheClient := cache.NewInMemoryCache()
+	router := newStickyFilterRouter(cacheClient, "test", time.Minute, logger)
+
+	// install a filter on the second backend
+	newFilterReq := &decode.EVMRPCRequestEnvelope{Method: "eth_newBlockFilter", Params: []interface{}{}}
+	_, installedBy, found := proxies.ProxyForRequest(filterTestRequest(newFilterReq))
+	require.True(t, found)
+	_, installedBy, found = proxies.ProxyForRequest(filterTestRequest(newFilterReq))
+	require.True(t, found)
+	require.Equal(t, second.URL, installedBy.BackendRoute.String())
+	router.record(context.Background(), "evm.kava.io", newFilterReq, installedBy, http.StatusOK, []byte(`{"jsonrpc":"2.0","id":1,"result":"0xABC"}`))
+
+	for _, method := range []string{"eth_getFilterChanges", "eth_getFilterLogs", "eth_uninstallFilter"} {
+		req := &decode.EVMRPCRequestEnvelope{Method: method, Params: []interface{}{"0xabc"}}
+		for i := 0; i < 2; i++ {
+			proxy, metadata, found, err := router.proxyForRequest(filterTestRequest(req), proxies, req)
+			require.NoError(t, err)
+			require.True(t, found)
+			require.NotNil(t, proxy)
+			require.Equal(t, ResponseBackendStickyFilter, metadata.BackendName)
+			require.Equal(t, second.URL, metadata.BackendRoute.String())
+		}
+	}
+
+	// the backend of the filter is forgotten once the filter is uninstalled
+	uninstallReq := &decode.EVMRPCRequestEnvelope{Method: "eth_uninstallFilter", Params: []interface{}{"0xabc"}}
+	router.record(context.Background(), "evm.kava.io", uninstallReq, installedBy, http.StatusOK, []byte(`{"jsonrpc":"2.0","id":1,"result":true}`))
+	_, _, found, err := router.proxyForRequest(filterTestRequest(uninstallReq), proxies, uninstallReq)
+	require.NoError(t, err)
+	require.False(t, found)
+}
+
+func TestUnitTestStickyFilterRouter_IgnoresOtherRequests(t *testing.T) {
+	backend := newStatusBackend(t, http.StatusOK)
+	proxies, logger := newTestHostProxies(t, backend)
+	cacheClient := cache.NewInMemoryCache()
+	router := newStickyFilterRouter(cacheClient, "test", time.Minute, logger)
+	_, metadata, _ := proxies.ProxyForRequest(filterTestRequest(&decode.EVMRPCRequestEnvelope{}))
+
+	// requests that don't use a filter
+	req := &decode.EVMRPCRequestEnvelope{Method: "eth_getBlockByNumber", Params: []interface{}{"latest", false}}
+	_, _, found, err := router.proxyForRequest(filterTestRequest(req), proxies, req)
+	require.NoError(t, err)
+	require.False(t, found)
+
+	// requests for unknown filters
+	req = &decode.EVMRPCRequestEnvelope{Method: "eth_getFilterChanges", Params: []interface{}{"0x1"}}
+	_, _, found, err = router.proxyForRequest(filterTestRequest(req), proxies, req)
+	require.NoError(t, err)
+	require.False(t, found)
+
+	// failed attempts at installing a filter are not remembered
+	newFilterReq := &decode.EVMRPCRequestEnvelope{Method: "eth_newFilter", Params: []interface{}{map[string]interface{}{}}}
+	router.record(context.Background(), "evm.kava.io", newFilterReq, metadata, http.StatusOK, []byte(`{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":"invalid"}}`))
+	router.record(context.Background(), "evm.kava.io", newFilterReq, metadata, http.StatusBadGateway, []byte(`{"jsonrpc":"2.0","id":1,"result":"0x1"}`))
+	require.Empty(t, cacheClient.GetAll(context.Background()))
+
+	// a nil router never routes requests
+	var disabled *stickyFilterRouter
+	_, _, found, err = disabled.proxyForRequest(filterTestRequest(req), proxies, req)
+	require.NoError(t, err)
+	require.False(t, found)
+	disabled.record(context.Background(), "evm.kava.io", newFilterReq, metadata, http.StatusOK, []byte(`{"jsonrpc":"2.0","id":1,"result":"0x1"}`))
+}
+
+func TestUnitTestStickyFilterRouter_ErrorsWhenInstallingBackendIsGone(t *testing.T) {
+	backend := newStatusBackend(t, http.StatusOK)
+	proxies, logger := newTestHostProxies(t, backend)
+	cacheClient := cache.NewInMemoryCache()
+	router := newStickyFilterRouter(cacheClient, "test", time.Minute, logger)
+
+	req := &decode.EVMRPCRequestEnvelope{ID: 7, Method: "eth_getFilterChanges", Params: []interface{}{"0x1"}}
+	require.NoError(t, cacheClient.Set(context.Background(), router.filterKey("evm.kava.io", "0x1"), []byte("http://removed-backend:8545"), time.Minute))
+
+	_, _, found, err := router.proxyForRequest(filterTestRequest(req), proxies, req)
+	require.True(t, found)
+	require.ErrorIs(t, err, ErrFilterBackendGone)
+
+	w := httptest.NewRecorder()
+	require.NoError(t, writeFilterNotFoundError(w, req))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.JSONEq(t, `{"jsonrpc":"2.0","id":7,"error":{"code":-32000,"message":"filter not found"}}`, w.Body.String())
+}
@@ -7,11 +7,14 @@ import (
 	"fmt"
 	"github.com/kava-labs/kava-proxy-service/clients/database"
 	"net/http"
+	"strings"
 )
 
 // createHealthcheckHandler creates a health check handler function that
 // will respond 200 ok if the proxy service is able to connect to
-// it's dependencies and functioning as expected
+// it's dependencies and functioning as expected.
+// The health of each backend is included in the response, but a backend being
+// down does not fail the health check of the proxy service itself.
 func createHealthcheckHandler(service *ProxyService) func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var combinedErrors error
@@ -38,17 +41,40 @@ func createHealthcheckHandler(service *ProxyService) func(http.ResponseWriter, *
 			}
 		}
 
+		backendsHealth := describeBackendsHealth(service.proxies)
+
 		if combinedErrors != nil {
 			w.WriteHeader(http.StatusInternalServerError)
 
-			w.Write([]byte(combinedErrors.Error()))
+			w.Write([]byte(combinedErrors.Error() + backendsHealth))
 
 			return
 		}
 
 		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("proxy service is healthy"))
+		w.Write([]byte("proxy service is healthy" + backendsHealth))
+	}
+}
+
+// describeBackendsHealth returns one line per backend describing its health
+func describeBackendsHealth(proxies Proxies) string {
+	if proxies == nil {
+		return ""
+	}
+
+	var description strings.Builder
+	for _, backend := range proxies.Backends() {
+		backendURL := backend.URL()
+		if backend.Healthy() {
+			description.WriteString(fmt.Sprintf("\nbackend %s is healthy", backendURL.String()))
+		} else {
+			description.WriteString(fmt.Sprintf("\nbackend %s is unhealthy: %v", backendURL.String(), backend.LastHealthCheckError()))
+		}
+		if circuitState := backend.CircuitState(); circuitState != circuitClosed.String() {
+			description.WriteString(fmt.Sprintf(" (circuit breaker %s)", circuitState))
+		}
 	}
+	return description.String()
 }
 
 // createServicecheckHandler creates a service check handler function that
@@ -102,6 +128,42 @@ func createDatabaseStatusHandler(service *ProxyService, db database.MetricsDatab
 	}
 }
 
+// createBackendsStatusHandler creates a backends status handler
+// function responding to requests for the health and
+// head height of every backend requests may be proxied to
+func createBackendsStatusHandler(service *ProxyService) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		service.Debug().Msg("/status/backends called")
+
+		response := BackendsStatusResponse{Backends: []BackendStatus{}}
+		if service.proxies != nil {
+			for _, backend := range service.proxies.Backends() {
+				response.Backends = append(response.Backends, newBackendStatus(backend))
+			}
+		}
+
+		// return response for client
+		if err := MarshalJSONResponse(&response, w); err != nil {
+			service.Error().Msg(fmt.Sprintf("error %s encoding %+v to json", err, response))
+		}
+	}
+}
+
+// newBackendStatus returns the status of the backend
+func newBackendStatus(backend *Backend) BackendStatus {
+	backendURL := backend.URL()
+	return BackendStatus{
+		URL:            backendURL.String(),
+		Healthy:        backend.Healthy(),
+		CircuitBreaker: backend.CircuitState(),
+		HeadHeight:     backend.HeadHeight(),
+		HeadLag:        backend.HeadLag(),
+		Lagging:        backend.Lagging(),
+		InFlight:       backend.InFlight(),
+		State:          backend.AdminState().String(),
+	}
+}
+
 // MarshalJSONResponse marshals an interface into the response body and sets JSON content type headers
 func MarshalJSONResponse(obj interface{}, w http.ResponseWriter) error {
 	w.Header().Set("Content-Type", "application/json")
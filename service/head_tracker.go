@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/kava-labs/kava-proxy-service/logging"
+)
+
+// BackendHeadTrackerConfig wraps values used
+// for creating a new BackendHeadTracker
+type BackendHeadTrackerConfig struct {
+	// how often the head of every backend is polled
+	Interval time.Duration
+	// how long to wait for a backend to respond with its head
+	Timeout time.Duration
+	// number of blocks a backend may be behind the chain tip before
+	// it is no longer sent requests for the latest state of the chain
+	MaxLagBlocks uint64
+}
+
+// BackendHeadTracker periodically polls the latest block number of every backend
+// known to the proxies, and marks backends that are too far behind the highest
+// block number of all backends as lagging so that requests for the latest
+// state of the chain are routed to backends that are synced.
+type BackendHeadTracker struct {
+	config     BackendHeadTrackerConfig
+	proxies    Proxies
+	httpClient *http.Client
+	*logging.ServiceLogger
+}
+
+// NewBackendHeadTracker creates a BackendHeadTracker for the backends of the proxies
+func NewBackendHeadTracker(config BackendHeadTrackerConfig, proxies Proxies, serviceLogger *logging.ServiceLogger) *BackendHeadTracker {
+	return &BackendHeadTracker{
+		config:        config,
+		proxies:       proxies,
+		httpClient:    &http.Client{Timeout: config.Timeout},
+		ServiceLogger: serviceLogger,
+	}
+}
+
+// Run polls the heads of all backends every interval until the context is cancelled
+func (ht *BackendHeadTracker) Run(ctx context.Context) {
+	ticker := time.NewTicker(ht.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		ht.PollAll(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// PollAll concurrently polls the head of every backend once,
+// then updates how far behind the chain tip each backend is.
+// Backends that fail to respond keep their last known head.
+func (ht *BackendHeadTracker) PollAll(ctx context.Context) {
+	backends := ht.proxies.Backends()
+
+	var wg sync.WaitGroup
+	for _, backend := range backends {
+		wg.Add(1)
+		go func(backend *Backend) {
+			defer wg.Done()
+
+			head, err := ht.pollHead(ctx, backend)
+			if err != nil {
+				backendURL := backend.URL()
+				ht.Debug().Str("backend", backendURL.String()).Err(err).Msg("failed to poll backend head")
+				return
+			}
+			backend.headHeight.Store(head)
+		}(backend)
+	}
+	wg.Wait()
+
+	var chainTip uint64
+	for _, backend := range backends {
+		if head := backend.HeadHeight(); head > chainTip {
+			chainTip = head
+		}
+	}
+
+	for _, backend := range backends {
+		if !backend.recordHeadLag(chainTip, ht.config.MaxLagBlocks) {
+			continue
+		}
+
+		backendURL := backend.URL()
+		if backend.Lagging() {
+			ht.Error().
+				Str("backend", backendURL.String()).
+				Uint64("head", backend.HeadHeight()).
+				Uint64("lag", backend.HeadLag()).
+				Msg("backend is behind the chain tip, routing requests for latest state away from it")
+		} else {
+			ht.Info().
+				Str("backend", backendURL.String()).
+				Uint64("head", backend.HeadHeight()).
+				Msg("backend has caught up to the chain tip")
+		}
+	}
+}
+
+// pollHead returns the latest block number of the backend
+func (ht *BackendHeadTracker) pollHead(ctx context.Context, backend *Backend) (uint64, error) {
+	jsonRpcResponse, err := callBackend(ctx, ht.httpClient, backend, "eth_blockNumber")
+	if err != nil {
+		return 0, err
+	}
+
+	var encodedHead string
+	if err := json.Unmarshal(jsonRpcResponse.Result, &encodedHead); err != nil {
+		return 0, fmt.Errorf("invalid eth_blockNumber result %s: %w", jsonRpcResponse.Result, err)
+	}
+
+	return hexutil.DecodeUint64(encodedHead)
+}
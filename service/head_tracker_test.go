@@ -0,0 +1,171 @@
+package service_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/kava-labs/kava-proxy-service/decode"
+	"github.com/kava-labs/kava-proxy-service/service"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestHeadTracker(proxies service.Proxies, maxLagBlocks uint64) *service.BackendHeadTracker {
+	return service.NewBackendHeadTracker(service.BackendHeadTrackerConfig{
+		Interval:     time.Second,
+		Timeout:      time.Second,
+		MaxLagBlocks: maxLagBlocks,
+	}, proxies, dummyLogger)
+}
+
+func TestUnitTestBackendHeadTracker_TracksLagBehindChainTip(t *testing.T) {
+	synced := newJsonRpcBackend(t, http.StatusOK, `"0x64"`)
+	slightlyBehind := newJsonRpcBackend(t, http.StatusOK, `"0x60"`)
+	farBehind := newJsonRpcBackend(t, http.StatusOK, `"0x50"`)
+	down := newJsonRpcBackend(t, http.StatusServiceUnavailable, `null`)
+
+	config := newConfig(t, fmt.Sprintf("evm.kava.io>%s+%s+%s+%s", synced.URL, slightlyBehind.URL, farBehind.URL, down.URL), "", "")
+	proxies := service.NewProxies(config, nil, dummyLogger)
+	newTestHeadTracker(proxies, 10).PollAll(context.Background())
+
+	expectedHeads := map[string]struct {
+		head    uint64
+		lag     uint64
+		lagging bool
+	}{
+		synced.URL:         {head: 100, lag: 0, lagging: false},
+		slightlyBehind.URL: {head: 96, lag: 4, lagging: false},
+		farBehind.URL:      {head: 80, lag: 20, lagging: true},
+		down.URL:           {head: 0, lag: 100, lagging: true},
+	}
+	for _, backend := range proxies.Backends() {
+		backendURL := backend.URL()
+		expected := expectedHeads[backendURL.String()]
+		require.Equal(t, expected.head, backend.HeadHeight(), "unexpected head for %s", backendURL.String())
+		require.Equal(t, expected.lag, backend.HeadLag(), "unexpected lag for %s", backendURL.String())
+		require.Equal(t, expected.lagging, backend.Lagging(), "unexpected lagging for %s", backendURL.String())
+	}
+}
+
+func TestUnitTestBackendHeadTracker_RoutesLatestAwayFromLaggingBackends(t *testing.T) {
+	archive := newJsonRpcBackend(t, http.StatusOK, `"0x64"`)
+	syncedPruning := newJsonRpcBackend(t, http.StatusOK, `"0x64"`)
+	laggingPruning := newJsonRpcBackend(t, http.StatusOK, `"0x10"`)
+
+	config := newConfig(t,
+		fmt.Sprintf("evm.kava.io>%s,lagging.kava.io>%s", archive.URL, archive.URL),
+		fmt.Sprintf("evm.kava.io>%s+%s,lagging.kava.io>%s", syncedPruning.URL, laggingPruning.URL, laggingPruning.URL),
+		"",
+	)
+	proxies := service.NewProxies(config, nil, dummyLogger)
+	newTestHeadTracker(proxies, 10).PollAll(context.Background())
+
+	latestReq := func(host string) *http.Request {
+		return mockJsonRpcReqToUrl("//"+host, &decode.EVMRPCRequestEnvelope{
+			Method: "eth_getBlockByNumber",
+			Params: []interface{}{"latest", false},
+		})
+	}
+
+	t.Run("latest requests skip lagging pool members", func(t *testing.T) {
+		for i := 0; i < 4; i++ {
+			_, metadata, found := proxies.ProxyForRequest(latestReq("evm.kava.io"))
+			require.True(t, found)
+			require.Equal(t, service.ResponseBackendPruning, metadata.BackendName)
+			require.Equal(t, syncedPruning.URL, metadata.BackendRoute.String())
+		}
+	})
+
+	t.Run("no history requests skip lagging pool members", func(t *testing.T) {
+		req := mockJsonRpcReqToUrl("//evm.kava.io", &decode.EVMRPCRequestEnvelope{Method: "eth_blockNumber"})
+		for i := 0; i < 4; i++ {
+			_, metadata, found := proxies.ProxyForRequest(req)
+			require.True(t, found)
+			require.Equal(t, syncedPruning.URL, metadata.BackendRoute.String())
+		}
+	})
+
+	t.Run("latest requests fall back to default when every pruning backend is lagging", func(t *testing.T) {
+		_, metadata, found := proxies.ProxyForRequest(latestReq("lagging.kava.io"))
+		require.True(t, found)
+		require.Equal(t, service.ResponseBackendDefault, metadata.BackendName)
+		require.Equal(t, archive.URL, metadata.BackendRoute.String())
+	})
+}
+
+func TestUnitTestBackendHeadTracker_RoutesHeightsWithinRetentionWindowToPruning(t *testing.T) {
+	archive := newJsonRpcBackend(t, http.StatusOK, `"0x64"`)
+	pruning := newJsonRpcBackend(t, http.StatusOK, `"0x64"`)
+	unknownHead := newJsonRpcBackend(t, http.StatusServiceUnavailable, `null`)
+
+	config := newConfig(t,
+		fmt.Sprintf("evm.kava.io>%s,unknown.kava.io>%s", archive.URL, archive.URL),
+		fmt.Sprintf("evm.kava.io>%s,unknown.kava.io>%s", pruning.URL, unknownHead.URL),
+		"",
+	)
+	config.ProxyPruningBackendRetentionWindowMap = map[string]uint64{
+		"evm.kava.io":     50,
+		"unknown.kava.io": 50,
+	}
+	proxies := service.NewProxies(config, nil, dummyLogger)
+	newTestHeadTracker(proxies, 10).PollAll(context.Background())
+
+	testCases := []struct {
+		name          string
+		host          string
+		height        string
+		expectBackend string
+		expectRoute   string
+	}{
+		{
+			name:          "recent height routes to pruning",
+			host:          "evm.kava.io",
+			height:        "0x50",
+			expectBackend: service.ResponseBackendPruning,
+			expectRoute:   pruning.URL,
+		},
+		{
+			name:          "height at the head routes to pruning",
+			host:          "evm.kava.io",
+			height:        "0x64",
+			expectBackend: service.ResponseBackendPruning,
+			expectRoute:   pruning.URL,
+		},
+		{
+			name:          "height older than the retention window routes to default",
+			host:          "evm.kava.io",
+			height:        "0x32",
+			expectBackend: service.ResponseBackendDefault,
+			expectRoute:   archive.URL,
+		},
+		{
+			name:          "height beyond the head routes to default",
+			host:          "evm.kava.io",
+			height:        "0x65",
+			expectBackend: service.ResponseBackendDefault,
+			expectRoute:   archive.URL,
+		},
+		{
+			name:          "height routes to default while the pruning head is unknown",
+			host:          "unknown.kava.io",
+			height:        "0x50",
+			expectBackend: service.ResponseBackendDefault,
+			expectRoute:   archive.URL,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := mockJsonRpcReqToUrl("//"+tc.host, &decode.EVMRPCRequestEnvelope{
+				Method: "eth_getBlockByNumber",
+				Params: []interface{}{tc.height, false},
+			})
+			_, metadata, found := proxies.ProxyForRequest(req)
+			require.True(t, found)
+			require.Equal(t, tc.expectBackend, metadata.BackendName)
+			require.Equal(t, tc.expectRoute, metadata.BackendRoute.String())
+		})
+	}
+}
@@ -0,0 +1,203 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kava-labs/kava-proxy-service/config"
+	"github.com/kava-labs/kava-proxy-service/decode"
+	"github.com/kava-labs/kava-proxy-service/logging"
+)
+
+const (
+	// number of recent latencies of each method the hedging delay is calculated from
+	hedgingLatencyHistorySize = 100
+	// minimum number of recent latencies of a method before they are used for its hedging delay
+	hedgingMinLatencySamples = 20
+)
+
+// hedgingPolicy decides which requests are hedged and how long to wait for the first
+// backend to respond before sending the request to a second backend.
+// The delay of each method is the configured percentile of its recent latencies,
+// so that only requests slower than usual are hedged.
+type hedgingPolicy struct {
+	enabled      bool
+	percentile   int
+	defaultDelay time.Duration
+	minDelay     time.Duration
+
+	mu                sync.Mutex
+	latenciesByMethod map[string]*latencyHistory
+}
+
+// newHedgingPolicy creates the hedgingPolicy defined by the service config
+func newHedgingPolicy(config config.Config) *hedgingPolicy {
+	return &hedgingPolicy{
+		enabled:           config.ProxyBackendHedgingEnabled,
+		percentile:        config.ProxyBackendHedgingPercentile,
+		defaultDelay:      config.ProxyBackendHedgingDefaultDelay,
+		minDelay:          config.ProxyBackendHedgingMinDelay,
+		latenciesByMethod: make(map[string]*latencyHistory),
+	}
+}
+
+// appliesTo returns true when requests for the JSON-RPC method should be hedged.
+// Only methods without side effects are hedged as the request may be served by two backends.
+func (hp *hedgingPolicy) appliesTo(method string) bool {
+	return hp.enabled && decode.MethodIsSideEffectFree(method)
+}
+
+// delay returns how long to wait for a response to a request for the method
+// before hedging it. The default delay is used until enough latencies have been observed.
+func (hp *hedgingPolicy) delay(method string) time.Duration {
+	hp.mu.Lock()
+	defer hp.mu.Unlock()
+
+	history, found := hp.latenciesByMethod[method]
+	if !found || history.count() < hedgingMinLatencySamples {
+		return hp.defaultDelay
+	}
+
+	delay := history.percentile(hp.percentile)
+	if delay < hp.minDelay {
+		return hp.minDelay
+	}
+	return delay
+}
+
+// observe records the latency of a request for the method, or how long it ran for if it was cancelled
+func (hp *hedgingPolicy) observe(method string, latency time.Duration) {
+	hp.mu.Lock()
+	defer hp.mu.Unlock()
+
+	history, found := hp.latenciesByMethod[method]
+	if !found {
+		history = &latencyHistory{}
+		hp.latenciesByMethod[method] = history
+	}
+	history.add(latency)
+}
+
+// latencyHistory is a ring buffer of the most recent latencies
+type latencyHistory struct {
+	latencies [hedgingLatencyHistorySize]time.Duration
+	next      int
+	full      bool
+}
+
+func (lh *latencyHistory) add(latency time.Duration) {
+	lh.latencies[lh.next] = latency
+	lh.next = (lh.next + 1) % len(lh.latencies)
+	if lh.next == 0 {
+		lh.full = true
+	}
+}
+
+func (lh *latencyHistory) count() int {
+	if lh.full {
+		return len(lh.latencies)
+	}
+	return lh.next
+}
+
+// percentile returns the latency that p percent of the recorded latencies are less than or equal to
+func (lh *latencyHistory) percentile(p int) time.Duration {
+	sorted := make([]time.Duration, lh.count())
+	copy(sorted, lh.latencies[:lh.count()])
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	// nearest rank method
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// hedgedAttemptResult is the outcome of one of the requests of a hedged attempt
+type hedgedAttemptResult struct {
+	response *bufferedResponseWriter
+	metadata ProxyMetadata
+}
+
+// newHedgedProxyAttempt returns a proxyAttempt that sends the request body to the backend of the proxy,
+// and if it has not responded within the hedging delay of the method, to a second backend chosen by the proxies.
+// The first successful response is used and the other request is cancelled.
+func newHedgedProxyAttempt(requestBody []byte, method string, proxies Proxies, policy *hedgingPolicy, serviceLogger *logging.ServiceLogger) proxyAttempt {
+	return func(r *http.Request, proxy *httputil.ReverseProxy, metadata ProxyMetadata) (*bufferedResponseWriter, ProxyMetadata) {
+		results := make(chan hedgedAttemptResult, 2)
+
+		// send sends the request to the backend of the proxy in the background
+		send := func(proxy *httputil.ReverseProxy, metadata ProxyMetadata) context.CancelFunc {
+			ctx, cancel := context.WithCancel(r.Context())
+			req := r.Clone(ctx)
+			req.Body = io.NopCloser(bytes.NewReader(requestBody))
+
+			go func() {
+				response := newBufferedResponseWriter()
+
+				startedAt := time.Now()
+				recordOutcome := metadata.backend.startRequest()
+				response.serve(proxy, req)
+				latency := time.Since(startedAt)
+
+				if ctx.Err() != nil && r.Context().Err() == nil {
+					// cancelled because the other request won
+					recordOutcome(statusAbandoned, nil, latency)
+				} else {
+					recordOutcome(response.statusCode, response.body.Bytes(), latency)
+				}
+				// cancelled requests would have taken at least as long as they ran for, observing that as their
+				// latency keeps the delay from being biased towards the latencies of the backends that won
+				policy.observe(method, latency)
+
+				results <- hedgedAttemptResult{response: response, metadata: metadata}
+			}()
+
+			return cancel
+		}
+
+		cancelFirst := send(proxy, metadata)
+		defer cancelFirst()
+
+		timer := time.NewTimer(policy.delay(method))
+		defer timer.Stop()
+
+		select {
+		case result := <-results:
+			return result.response, result.metadata
+		case <-timer.C:
+		}
+
+		// the first backend is slow, send the request to another backend too
+		hedgeProxy, hedgeMetadata, found := proxies.ProxyForRequest(withExcludedBackend(r, metadata.backend))
+		if !found {
+			result := <-results
+			return result.response, result.metadata
+		}
+
+		serviceLogger.Debug().
+			Str("method", method).
+			Str("backend", metadata.BackendRoute.String()).
+			Str("hedge-backend", hedgeMetadata.BackendRoute.String()).
+			Msg("backend is slow to respond, hedging request")
+
+		cancelHedge := send(hedgeProxy, hedgeMetadata)
+		defer cancelHedge()
+
+		// prefer the response of the slower backend when the faster one failed to respond
+		result := <-results
+		if isRetryableStatus(result.response.statusCode) {
+			if other := <-results; !isRetryableStatus(other.response.statusCode) {
+				return other.response, other.metadata
+			}
+		}
+		return result.response, result.metadata
+	}
+}
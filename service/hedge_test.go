@@ -0,0 +1,245 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestHedgingPolicy() *hedgingPolicy {
+	return &hedgingPolicy{
+		enabled:           true,
+		percentile:        95,
+		defaultDelay:      10 * time.Millisecond,
+		minDelay:          time.Millisecond,
+		latenciesByMethod: make(map[string]*latencyHistory),
+	}
+}
+
+// newSlowBackend creates a test backend server that only responds once the request is cancelled
+// or the delay has elapsed. cancelled is closed when a request is cancelled.
+func newSlowBackend(t *testing.T, delay time.Duration) (server *httptest.Server, cancelled chan struct{}) {
+	cancelled = make(chan struct{})
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the server only notices the client went away once the request body has been read
+		io.ReadAll(r.Body)
+
+		select {
+		case <-r.Context().Done():
+			close(cancelled)
+		case <-time.After(delay):
+			w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"slow"}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, cancelled
+}
+
+// newStreamingBackend creates a test backend server that starts responding straight away,
+// but only finishes streaming the body once the request is cancelled or the delay has elapsed.
+// cancelled is closed when a request is cancelled.
+func newStreamingBackend(t *testing.T, delay time.Duration) (server *httptest.Server, cancelled chan struct{}) {
+	cancelled = make(chan struct{})
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,`))
+		w.(http.Flusher).Flush()
+
+		select {
+		case <-r.Context().Done():
+			close(cancelled)
+		case <-time.After(delay):
+			w.Write([]byte(`"result":"streamed"}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, cancelled
+}
+
+// newCountingBackend creates a test backend server that counts the requests it receives
+func newCountingBackend(t *testing.T, statusCode int) (*httptest.Server, *atomic.Int64) {
+	var requests atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(statusCode)
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"fast"}`))
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+// hedgeTestRequest makes a hedged eth_getBalance request for evm.kava.io
+// to the round robin pool of the servers
+func hedgeTestRequest(t *testing.T, policy *hedgingPolicy, servers ...*httptest.Server) (*bufferedResponseWriter, ProxyMetadata) {
+	proxies, logger := newTestHostProxies(t, servers...)
+
+	body := []byte(`{"jsonrpc":"2.0","id":1,"method":"eth_getBalance","params":["0x373CE8b6B3c4D0C8d4e1C9c7b7B3E5E1E9E2E3E4","latest"]}`)
+	r := httptest.NewRequest(http.MethodPost, "http://evm.kava.io", bytes.NewReader(body))
+	// as for requests received by the service, so that the reverse proxy aborts failed responses
+	r = r.WithContext(context.WithValue(r.Context(), http.ServerContextKey, &http.Server{}))
+	proxy, metadata, found := proxies.ProxyForRequest(r)
+	require.True(t, found)
+
+	attempt := newHedgedProxyAttempt(body, "eth_getBalance", proxies, policy, logger)
+	return attempt(r, proxy, metadata)
+}
+
+func TestUnitTestHedgedProxyAttempt_HedgesSlowBackend(t *testing.T) {
+	slow, cancelled := newSlowBackend(t, 10*time.Second)
+	fast, _ := newCountingBackend(t, http.StatusOK)
+
+	response, metadata := hedgeTestRequest(t, newTestHedgingPolicy(), slow, fast)
+
+	require.Equal(t, http.StatusOK, response.statusCode)
+	require.Contains(t, response.body.String(), "fast")
+	require.Equal(t, fast.URL, metadata.BackendRoute.String())
+
+	// the request to the slow backend is cancelled
+	select {
+	case <-cancelled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected request to slow backend to be cancelled")
+	}
+}
+
+func TestUnitTestHedgedProxyAttempt_NeverHedgesToExcludedBackend(t *testing.T) {
+	slow, _ := newSlowBackend(t, 100*time.Millisecond)
+	excluded, excludedRequests := newCountingBackend(t, http.StatusOK)
+	proxies, logger := newTestHostProxies(t, slow, excluded)
+
+	var excludedBackend *Backend
+	for _, backend := range proxies.Backends() {
+		if backendURL := backend.URL(); backendURL.String() == excluded.URL {
+			excludedBackend = backend
+		}
+	}
+	require.NotNil(t, excludedBackend)
+
+	body := []byte(`{"jsonrpc":"2.0","id":1,"method":"eth_getBalance","params":["0x373CE8b6B3c4D0C8d4e1C9c7b7B3E5E1E9E2E3E4","latest"]}`)
+	r := httptest.NewRequest(http.MethodPost, "http://evm.kava.io", bytes.NewReader(body))
+	r = withExcludedBackends(r, map[*Backend]bool{excludedBackend: true})
+	proxy, metadata, found := proxies.ProxyForRequest(r)
+	require.True(t, found)
+	require.Equal(t, slow.URL, metadata.BackendRoute.String())
+
+	attempt := newHedgedProxyAttempt(body, "eth_getBalance", proxies, newTestHedgingPolicy(), logger)
+	response, metadata := attempt(r, proxy, metadata)
+
+	// the hedge keeps the exclusions of the request, leaving no other backend to hedge to
+	require.Equal(t, http.StatusOK, response.statusCode)
+	require.Contains(t, response.body.String(), "slow")
+	require.Equal(t, slow.URL, metadata.BackendRoute.String())
+	require.Zero(t, excludedRequests.Load())
+}
+
+func TestUnitTestHedgedProxyAttempt_ObservesLatencyOfCancelledRequests(t *testing.T) {
+	slow, _ := newSlowBackend(t, 10*time.Second)
+	fast, _ := newCountingBackend(t, http.StatusOK)
+
+	policy := newTestHedgingPolicy()
+	hedgeTestRequest(t, policy, slow, fast)
+
+	// how long the cancelled request ran for is a lower bound of its latency, which is at least the hedging delay
+	var latencies []time.Duration
+	require.Eventually(t, func() bool {
+		policy.mu.Lock()
+		defer policy.mu.Unlock()
+		history := policy.latenciesByMethod["eth_getBalance"]
+		latencies = append([]time.Duration{}, history.latencies[:history.count()]...)
+		return len(latencies) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	require.GreaterOrEqual(t, latencies[1], policy.defaultDelay)
+}
+
+func TestUnitTestHedgedProxyAttempt_CancelsSlowlyStreamingBackend(t *testing.T) {
+	streaming, cancelled := newStreamingBackend(t, 10*time.Second)
+	fast, _ := newCountingBackend(t, http.StatusOK)
+
+	response, metadata := hedgeTestRequest(t, newTestHedgingPolicy(), streaming, fast)
+
+	require.Equal(t, http.StatusOK, response.statusCode)
+	require.Contains(t, response.body.String(), "fast")
+	require.Equal(t, fast.URL, metadata.BackendRoute.String())
+
+	select {
+	case <-cancelled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected request to streaming backend to be cancelled")
+	}
+	// the reverse proxy aborts copying the partial body of the cancelled request,
+	// which must not take down the service
+	time.Sleep(100 * time.Millisecond)
+}
+
+func TestUnitTestHedgedProxyAttempt_DoesNotHedgeFastBackend(t *testing.T) {
+	first, _ := newCountingBackend(t, http.StatusOK)
+	second, secondRequests := newCountingBackend(t, http.StatusOK)
+
+	policy := newTestHedgingPolicy()
+	policy.defaultDelay = 5 * time.Second
+	response, metadata := hedgeTestRequest(t, policy, first, second)
+
+	require.Equal(t, http.StatusOK, response.statusCode)
+	require.Equal(t, first.URL, metadata.BackendRoute.String())
+	require.Zero(t, secondRequests.Load())
+}
+
+func TestUnitTestHedgedProxyAttempt_PrefersSuccessfulResponse(t *testing.T) {
+	slow, _ := newSlowBackend(t, 100*time.Millisecond)
+	failing, _ := newCountingBackend(t, http.StatusServiceUnavailable)
+
+	response, metadata := hedgeTestRequest(t, newTestHedgingPolicy(), slow, failing)
+
+	require.Equal(t, http.StatusOK, response.statusCode)
+	require.Contains(t, response.body.String(), "slow")
+	require.Equal(t, slow.URL, metadata.BackendRoute.String())
+}
+
+func TestUnitTestHedgingPolicy_DelayFromRecentLatencies(t *testing.T) {
+	policy := newTestHedgingPolicy()
+
+	// the default delay is used until enough latencies have been observed
+	for i := 1; i < hedgingMinLatencySamples; i++ {
+		policy.observe("eth_getBalance", time.Duration(i)*time.Millisecond)
+	}
+	require.Equal(t, policy.defaultDelay, policy.delay("eth_getBalance"))
+
+	for i := hedgingMinLatencySamples; i <= hedgingLatencyHistorySize; i++ {
+		policy.observe("eth_getBalance", time.Duration(i)*time.Millisecond)
+	}
+	require.Equal(t, 95*time.Millisecond, policy.delay("eth_getBalance"))
+
+	// only the most recent latencies are considered
+	for i := 0; i < hedgingLatencyHistorySize; i++ {
+		policy.observe("eth_getBalance", 200*time.Millisecond)
+	}
+	require.Equal(t, 200*time.Millisecond, policy.delay("eth_getBalance"))
+
+	// the delay is never less than the minimum delay
+	policy.minDelay = time.Second
+	require.Equal(t, time.Second, policy.delay("eth_getBalance"))
+
+	// methods are tracked independently
+	require.Equal(t, policy.defaultDelay, policy.delay("eth_call"))
+}
+
+func TestUnitTestHedgingPolicy_AppliesTo(t *testing.T) {
+	policy := newTestHedgingPolicy()
+
+	require.True(t, policy.appliesTo("eth_getBalance"))
+	require.False(t, policy.appliesTo("eth_sendRawTransaction"))
+	require.False(t, policy.appliesTo("eth_newFilter"))
+
+	policy.enabled = false
+	require.False(t, policy.appliesTo("eth_getBalance"))
+}
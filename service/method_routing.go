@@ -0,0 +1,105 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+
+	"github.com/kava-labs/kava-proxy-service/config"
+	"github.com/kava-labs/kava-proxy-service/decode"
+	"github.com/kava-labs/kava-proxy-service/logging"
+)
+
+// MethodRuleProxies routes requests for JSON-RPC methods matching one of the method routing rules
+// of the request host to the pool of backends of the rule, ie. to send `debug_*` requests to tracing nodes.
+// Requests matching no rule are forwarded via the wrapped defaultProxies.
+type MethodRuleProxies struct {
+	*logging.ServiceLogger
+
+	defaultProxies Proxies
+	routesByHost   map[string][]methodRoute
+}
+
+// methodRoute is a method routing rule and the pool of backends it routes requests to
+type methodRoute struct {
+	rule config.MethodRoutingRule
+	pool *BackendPool
+}
+
+var _ Proxies = MethodRuleProxies{}
+
+// ProxyForRequest implements Proxies.
+// Decodes the method of the request
+// - routes to the pool of the first rule of the host matching the method
+// - otherwise routes via the default proxies
+func (mp MethodRuleProxies) ProxyForRequest(r *http.Request) (*httputil.ReverseProxy, ProxyMetadata, bool) {
+	// short circuit if host has no method routing rules
+	routes, found := mp.routesByHost[r.Host]
+	if !found {
+		return mp.defaultProxies.ProxyForRequest(r)
+	}
+
+	decodedReq, ok := r.Context().Value(DecodedRequestContextKey).(*decode.EVMRPCRequestEnvelope)
+	if !ok {
+		mp.Trace().Msg("MethodRuleProxies failed to find & cast the decoded request envelope from the request context")
+		return mp.defaultProxies.ProxyForRequest(r)
+	}
+
+	for _, route := range routes {
+		if !route.rule.Matches(decodedReq.Method) {
+			continue
+		}
+
+		mp.Trace().Msg(fmt.Sprintf("request method %s matches method routing rule %s", decodedReq.Method, route.rule.Pattern))
+
+		backend := route.pool.NextFor(newBackendFilter(r))
+		if backend == nil {
+			return nil, ProxyMetadata{BackendName: ResponseBackendMethodRule}, false
+		}
+		metadata := ProxyMetadata{
+			BackendName:  ResponseBackendMethodRule,
+			BackendRoute: backend.URL(),
+			backend:      backend,
+		}
+		return backend.Proxy(), metadata, true
+	}
+
+	return mp.defaultProxies.ProxyForRequest(r)
+}
+
+// Backends implements Proxies.
+func (mp MethodRuleProxies) Backends() []*Backend {
+	backendLists := [][]*Backend{mp.defaultProxies.Backends()}
+	for _, routes := range mp.routesByHost {
+		for _, route := range routes {
+			backendLists = append(backendLists, route.pool.Backends())
+		}
+	}
+	return uniqueBackends(backendLists...)
+}
+
+// newMethodRuleProxies creates a MethodRuleProxies from the method routing rules of each host,
+// forwarding requests that match no rule to the default proxies.
+func newMethodRuleProxies(rulesByHost map[string]config.MethodRoutingRules, defaultProxies Proxies, poolStrategy string, registry *backendRegistry, serviceLogger *logging.ServiceLogger) MethodRuleProxies {
+	routesByHost := make(map[string][]methodRoute, len(rulesByHost))
+
+	for host, rules := range rulesByHost {
+		routes := make([]methodRoute, 0, len(rules))
+		for _, rule := range rules {
+			serviceLogger.Debug().Msg(fmt.Sprintf("creating reverse proxy pool for methods %s of host %s to %+v", rule.Pattern, host, rule.BackendURLs))
+
+			backends := make([]*Backend, 0, len(rule.BackendURLs))
+			for _, backendURL := range rule.BackendURLs {
+				backends = append(backends, registry.getOrCreate(backendURL))
+			}
+			routes = append(routes, methodRoute{rule: rule, pool: newBackendPool(backends, poolStrategy)})
+		}
+		routesByHost[host] = routes
+	}
+
+	return MethodRuleProxies{
+		ServiceLogger:  serviceLogger,
+		defaultProxies: defaultProxies,
+		routesByHost:   routesByHost,
+	}
+}
@@ -0,0 +1,128 @@
+package service_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/kava-labs/kava-proxy-service/config"
+	"github.com/kava-labs/kava-proxy-service/decode"
+	"github.com/kava-labs/kava-proxy-service/service"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnitTest_MethodRuleProxies(t *testing.T) {
+	archiveBackend := "archivenode.kava.io/"
+	pruningBackend := "pruningnode.kava.io/"
+	shardBackend := "shard-1.kava.io/"
+	tracingBackend := "tracingnode.kava.io/"
+	txTracingBackend := "txtracingnode.kava.io/"
+	cfg := newConfig(t,
+		fmt.Sprintf("archive.kava.io>%s,pruning.kava.io>%s", archiveBackend, pruningBackend),
+		fmt.Sprintf("archive.kava.io>%s", pruningBackend),
+		fmt.Sprintf("archive.kava.io>10|%s", shardBackend),
+	)
+	rawMethodRoutingMap := fmt.Sprintf(
+		"archive.kava.io>debug_traceTransaction|%s|debug_*|%s|txpool_*|%s",
+		txTracingBackend, tracingBackend, tracingBackend,
+	)
+	var err error
+	cfg.EnableMethodRouting = true
+	cfg.ProxyMethodRoutingBackendHostURLMapRaw = rawMethodRoutingMap
+	cfg.ProxyMethodRoutingBackendHostURLMap, err = config.ParseRawMethodRoutingBackendHostURLMap(rawMethodRoutingMap)
+	require.NoError(t, err)
+
+	proxies := service.NewProxies(cfg, nil, dummyLogger)
+	require.IsType(t, service.MethodRuleProxies{}, proxies)
+
+	testCases := []struct {
+		name          string
+		url           string
+		req           *decode.EVMRPCRequestEnvelope
+		expectBackend string
+		expectRoute   string
+	}{
+		{
+			name: "routes method matching a prefix rule to its backend",
+			url:  "//archive.kava.io",
+			req: &decode.EVMRPCRequestEnvelope{
+				Method: "debug_traceCall",
+			},
+			expectBackend: service.ResponseBackendMethodRule,
+			expectRoute:   tracingBackend,
+		},
+		{
+			name: "routes method to the backend of the first matching rule",
+			url:  "//archive.kava.io",
+			req: &decode.EVMRPCRequestEnvelope{
+				Method: "debug_traceTransaction",
+			},
+			expectBackend: service.ResponseBackendMethodRule,
+			expectRoute:   txTracingBackend,
+		},
+		{
+			name: "routes method matching another rule to its backend",
+			url:  "//archive.kava.io",
+			req: &decode.EVMRPCRequestEnvelope{
+				Method: "txpool_content",
+			},
+			expectBackend: service.ResponseBackendMethodRule,
+			expectRoute:   tracingBackend,
+		},
+		{
+			name: "routes methods matching no rule by height to pruning",
+			url:  "//archive.kava.io",
+			req: &decode.EVMRPCRequestEnvelope{
+				Method: "eth_getBlockByNumber",
+				Params: []interface{}{"latest", false},
+			},
+			expectBackend: service.ResponseBackendPruning,
+			expectRoute:   pruningBackend,
+		},
+		{
+			name: "routes methods matching no rule by height to shards",
+			url:  "//archive.kava.io",
+			req: &decode.EVMRPCRequestEnvelope{
+				Method: "eth_getBlockByNumber",
+				Params: []interface{}{"0x5", false},
+			},
+			expectBackend: service.ResponseBackendShard,
+			expectRoute:   shardBackend,
+		},
+		{
+			name: "routes to default when host has no method routing rules",
+			url:  "//pruning.kava.io",
+			req: &decode.EVMRPCRequestEnvelope{
+				Method: "debug_traceCall",
+			},
+			expectBackend: service.ResponseBackendDefault,
+			expectRoute:   pruningBackend,
+		},
+		{
+			name:          "routes to default if it fails to decode req",
+			url:           "//archive.kava.io",
+			req:           nil,
+			expectBackend: service.ResponseBackendDefault,
+			expectRoute:   archiveBackend,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := mockJsonRpcReqToUrl(tc.url, tc.req)
+			proxy, metadata, found := proxies.ProxyForRequest(req)
+			require.True(t, found, "expected proxy to be found")
+			require.Equal(t, tc.expectBackend, metadata.BackendName)
+			require.Equal(t, tc.expectRoute, metadata.BackendRoute.String())
+			requireProxyRoutesToUrl(t, proxy, req, tc.expectRoute)
+		})
+	}
+
+	t.Run("Backends includes the backends of every rule", func(t *testing.T) {
+		routes := make([]string, 0)
+		for _, backend := range proxies.Backends() {
+			backendURL := backend.URL()
+			routes = append(routes, backendURL.String())
+		}
+		require.ElementsMatch(t, []string{archiveBackend, pruningBackend, shardBackend, tracingBackend, txTracingBackend}, routes)
+	})
+}
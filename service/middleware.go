@@ -3,6 +3,7 @@ package service
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"github.com/kava-labs/kava-proxy-service/clients/database"
 	"io"
@@ -24,6 +25,7 @@ const (
 	// Service defined context keys
 	DecodedRequestContextKey              = "X-KAVA-PROXY-DECODED-REQUEST-BODY"
 	DecodedBatchRequestContextKey         = "X-KAVA-PROXY-DECODED-BATCH-REQUEST-BODY"
+	DecodedCosmosRequestContextKey        = "X-KAVA-PROXY-DECODED-COSMOS-REQUEST"
 	OriginRoundtripLatencyMillisecondsKey = "X-KAVA-PROXY-ORIGIN-ROUNDTRIP-LATENCY-MILLISECONDS"
 	RequestStartTimeContextKey            = "X-KAVA-PROXY-REQUEST-START-TIME"
 	RequestHostnameContextKey             = "X-KAVA-PROXY-REQUEST-HOSTNAME"
@@ -89,12 +91,28 @@ func (w bodySaverResponseWriter) Write(b []byte) (int, error) {
 }
 
 // createDecodeRequestMiddleware is responsible for creating a middleware that
-// - decodes the incoming EVM request
+// - decodes the incoming EVM request, or Tendermint RPC or Cosmos REST request
 // - if successful, puts the decoded request into the context
 // - determines if the request is for a single or batch request
 // - routes batch requests to BatchProcessingMiddleware
 // - routes single requests to next()
 func createDecodeRequestMiddleware(next http.HandlerFunc, batchProcessingMiddleware http.HandlerFunc, serviceLogger *logging.ServiceLogger) http.HandlerFunc {
+	// serveCosmosRequest attempts to decode the request as a Tendermint RPC or Cosmos REST request,
+	// forwarding it along with the decoded request in context if successful
+	serveCosmosRequest := func(w http.ResponseWriter, r *http.Request, requestStartTimeContext context.Context, rawBody []byte) bool {
+		decodedRequest, err := decode.DecodeCosmosRPCRequest(r.URL, r.Header, rawBody)
+		if err != nil {
+			return false
+		}
+
+		serviceLogger.Trace().
+			Any("decoded request", decodedRequest).
+			Msg("successfully decoded cosmos request")
+		cosmosDecodedReqContext := context.WithValue(requestStartTimeContext, DecodedCosmosRequestContextKey, decodedRequest)
+		next.ServeHTTP(w, r.WithContext(cosmosDecodedReqContext))
+		return true
+	}
+
 	return func(w http.ResponseWriter, r *http.Request) {
 		// capture the initial request time in order to calculate response time & latency at the end
 		requestStartTimeContext := context.WithValue(r.Context(), RequestStartTimeContextKey, time.Now())
@@ -102,7 +120,9 @@ func createDecodeRequestMiddleware(next http.HandlerFunc, batchProcessingMiddlew
 		// skip processing if there is no body content
 		if r.Body == nil {
 			serviceLogger.Trace().Msg("no data in request body")
-			next.ServeHTTP(w, r)
+			if !serveCosmosRequest(w, r, requestStartTimeContext, nil) {
+				next.ServeHTTP(w, r)
+			}
 			return
 		}
 
@@ -123,13 +143,15 @@ func createDecodeRequestMiddleware(next http.HandlerFunc, batchProcessingMiddlew
 		// skip processing if body is empty
 		if len(rawBody) == 0 {
 			serviceLogger.Trace().Msg("no data in request body")
-			next.ServeHTTP(w, r)
+			if !serveCosmosRequest(w, r, requestStartTimeContext, nil) {
+				next.ServeHTTP(w, r)
+			}
 			return
 		}
 
 		// attempt to decode as single EVM request
 		decodedRequest, err := decode.DecodeEVMRPCRequest(rawBody)
-		if err == nil {
+		if err == nil && !decode.IsTendermintMethod(decodedRequest.Method) {
 			// successfully decoded request as a single valid EVM request
 			// forward along with decoded request in context
 			serviceLogger.Trace().
@@ -140,10 +162,23 @@ func createDecodeRequestMiddleware(next http.HandlerFunc, batchProcessingMiddlew
 			return
 		}
 
+		// attempt to decode as a Tendermint JSON-RPC or Cosmos REST request
+		if serveCosmosRequest(w, r, requestStartTimeContext, rawBody) {
+			return
+		}
+
 		// attempt to decode as list of requests
 		batchRequests, err := decode.DecodeEVMRPCRequestList(rawBody)
 		if err != nil {
 			serviceLogger.Debug().Msg(fmt.Sprintf("error %s parsing of request body %s", err, rawBody))
+			// bodies that are not even json can't be served by any backend,
+			// other bodies (such as those of cosmos rest api requests) are proxied as is
+			if !json.Valid(rawBody) {
+				if err := writeJSONRPCError(w, http.StatusBadRequest, nil, invalidRequestErrorCode, invalidRequestErrorMessage); err != nil {
+					serviceLogger.Error().Msg(fmt.Sprintf("can't write invalid request response: %v", err))
+				}
+				return
+			}
 			next.ServeHTTP(w, r)
 			return
 		}
@@ -167,12 +202,17 @@ func createDecodeRequestMiddleware(next http.HandlerFunc, batchProcessingMiddlew
 // forwards the request to the backend origin server
 // all afterRequestInterceptors will be iterated (in slice order)
 // through and executed before the response is written to the caller
-func createProxyRequestMiddleware(next http.Handler, config config.Config, serviceLogger *logging.ServiceLogger, beforeRequestInterceptors []RequestInterceptor, afterRequestInterceptors []RequestInterceptor) http.HandlerFunc {
-	// create an http handler that will proxy any request to the specified URL
-	reverseProxyForHost := NewProxies(config, serviceLogger)
+func createProxyRequestMiddleware(next http.Handler, config config.Config, reverseProxyForHost Proxies, stickyFilters *stickyFilterRouter, serviceLogger *logging.ServiceLogger, beforeRequestInterceptors []RequestInterceptor, afterRequestInterceptors []RequestInterceptor) http.HandlerFunc {
+	policies := newRequestPolicies(config)
+	shadower := newTrafficShadower(config, serviceLogger)
 
+	// create an http handler that will proxy any request to the backend chosen by the proxies
 	handler := func(proxies Proxies) func(http.ResponseWriter, *http.Request) {
 		return func(w http.ResponseWriter, r *http.Request) {
+			// route the request with the same routing configuration for its whole lifetime,
+			// even if the routing configuration is reloaded while the request is in flight
+			proxies, policies := currentRouting(proxies, policies)
+
 			req := r.Context().Value(DecodedRequestContextKey)
 			decodedReq, ok := (req).(*decode.EVMRPCRequestEnvelope)
 			if !ok {
@@ -195,16 +235,31 @@ func createProxyRequestMiddleware(next http.Handler, config config.Config, servi
 				serviceLogger:            serviceLogger,
 			}
 
+			// requests for a filter are proxied to the backend that installed it
+			proxy, proxyMetadata, isStickyFilterRequest, err := stickyFilters.proxyForRequest(r, proxies, decodedReq)
+			if err != nil {
+				serviceLogger.Debug().Msg(fmt.Sprintf("can't route request for filter %+v: %s", decodedReq, err))
+
+				if err := writeFilterNotFoundError(w, decodedReq); err != nil {
+					serviceLogger.Error().Msg(fmt.Sprintf("can't write filter not found response: %v", err))
+				}
+
+				return
+			}
+
 			// proxy the request to the backend origin server
 			// based on the request host
-			proxy, proxyMetadata, ok := proxies.ProxyForRequest(r)
+			ok = isStickyFilterRequest
+			if !isStickyFilterRequest {
+				proxy, proxyMetadata, ok = proxies.ProxyForRequest(r)
+			}
 
 			if !ok {
 				serviceLogger.Error().Msg(fmt.Sprintf("no matching proxy for host %s for request %+v\n configured proxies %+v", r.Host, r, proxies))
 
-				w.WriteHeader(http.StatusBadGateway)
-
-				w.Write([]byte("no proxy backend configured for request host"))
+				if err := writeJSONRPCError(w, http.StatusBadGateway, decodedReq, noBackendErrorCode, noBackendErrorMessage); err != nil {
+					serviceLogger.Error().Msg(fmt.Sprintf("can't write no backend response: %v", err))
+				}
 
 				return
 			}
@@ -219,6 +274,9 @@ func createProxyRequestMiddleware(next http.Handler, config config.Config, servi
 				r.Header.Set(LoadBalancerForwardedForHeaderKey, requestIPHeaderValues[len(requestIPHeaderValues)-1])
 			}
 
+			// the body sent to the backend, kept for replaying the request if it is retried
+			var requestBody []byte
+
 			// run before request interceptors
 			if r.Body != nil {
 				originalRequestBody, err := io.ReadAll(r.Body)
@@ -245,6 +303,7 @@ func createProxyRequestMiddleware(next http.Handler, config config.Config, servi
 				// update the request body to the modified version
 				// after all before request interceptors have run
 				r.Body = io.NopCloser(bytes.NewBuffer(modifiedRequestBody))
+				requestBody = modifiedRequestBody
 			} else {
 				serviceLogger.Trace().Msg("request body is empty, skipping before request interceptors")
 			}
@@ -289,7 +348,78 @@ func createProxyRequestMiddleware(next http.Handler, config config.Config, servi
 					Msg("cache miss")
 
 				w.Header().Add(cachemdw.CacheHeaderKey, cachemdw.CacheMissHeaderValue)
-				proxy.ServeHTTP(lrw, r)
+
+				// requests for methods with a quorum rule are sent to several backends in parallel,
+				// responding with a result only once enough of them agree on it.
+				// read only requests are retried against other backends when the backend fails to respond,
+				// read only requests routed to pruning backends are re-issued to the default backends
+				// when the pruning backend is missing the state for the request,
+				// and side effect free requests are hedged to a second backend when the backend is slow.
+				// all other requests, including requests that must be served by the backend of their filter,
+				// are proxied once with the response streamed back as is
+				// requests with a timeout are cancelled once it elapses,
+				// responding with a JSON-RPC error instead of the response of the backend
+				timeout := policies.timeout.timeoutFor(r.Host, decodedReq.Method)
+				serveWithTimeout := func(w http.ResponseWriter, r *http.Request, serve func(http.ResponseWriter, *http.Request)) {
+					if timeout <= 0 {
+						serve(w, r)
+						return
+					}
+					timedOut, err := proxyWithTimeout(w, r, timeout, decodedReq, serve)
+					if err != nil {
+						serviceLogger.Error().Msg(fmt.Sprintf("can't write backend timeout response: %v", err))
+					}
+					proxyMetadata.TimedOut = timedOut
+				}
+
+				quorumRule, hasQuorumRule := policies.quorum.ruleFor(r.Host, decodedReq.Method)
+				shouldReachQuorum := hasQuorumRule && !isStickyFilterRequest && proxyMetadata.backend != nil
+				shouldRetry := policies.retry.appliesTo(decodedReq.Method) && !isStickyFilterRequest
+				shouldHedge := policies.hedging.appliesTo(decodedReq.Method) && !isStickyFilterRequest
+				shouldFallBack := policies.missingState.appliesTo(decodedReq.Method, proxyMetadata) && !isStickyFilterRequest
+				if shouldReachQuorum {
+					servedBy := proxyMetadata
+					serveWithTimeout(lrw, r, func(w http.ResponseWriter, r *http.Request) {
+						servedBy, _ = proxyWithQuorum(w, r, proxies, proxy, proxyMetadata, quorumRule, requestBody, decodedReq, serviceLogger)
+					})
+					servedBy.TimedOut = proxyMetadata.TimedOut
+					proxyMetadata = servedBy
+				} else if shouldRetry || shouldHedge || shouldFallBack {
+					policy := policies.retry
+					if !shouldRetry {
+						policy = policies.retry.withoutRetries()
+					}
+					attempt := newProxyAttempt(requestBody)
+					if shouldHedge {
+						attempt = newHedgedProxyAttempt(requestBody, decodedReq.Method, proxies, policies.hedging, serviceLogger)
+					}
+					if shouldFallBack {
+						attempt = newMissingStateFallbackAttempt(attempt, policies.missingState, proxies, serviceLogger)
+					}
+					servedBy := proxyMetadata
+					serveWithTimeout(lrw, r, func(w http.ResponseWriter, r *http.Request) {
+						servedBy = proxyWithRetries(w, r, proxies, proxy, proxyMetadata, policy, attempt, serviceLogger)
+					})
+					servedBy.TimedOut = proxyMetadata.TimedOut
+					proxyMetadata = servedBy
+				} else {
+					// let the circuit breaker of the backend learn from the outcome of the request
+					recordOutcome := proxyMetadata.backend.startRequest()
+					serveWithTimeout(lrw, r, proxy.ServeHTTP)
+					if proxyMetadata.TimedOut {
+						recordOutcome(http.StatusGatewayTimeout, nil, time.Since(proxyRequestAt))
+					} else {
+						recordOutcome(lrw.Status(), lrw.body.Bytes(), time.Since(proxyRequestAt))
+					}
+				}
+
+				// remember which backend installed a filter for routing later requests for the filter
+				stickyFilters.record(r.Context(), r.Host, decodedReq, proxyMetadata, lrw.Status(), lrw.body.Bytes())
+
+				// mirror a sample of requests to the shadow backend of the host for comparing its responses
+				if !isStickyFilterRequest && !proxyMetadata.TimedOut && shadower.appliesTo(r.Host, decodedReq.Method) {
+					shadower.mirror(r.Host, decodedReq.Method, requestBody, proxyMetadata, lrw.Status(), lrw.body.Bytes())
+				}
 			}
 
 			serviceLogger.Trace().Msg(fmt.Sprintf("response %+v \nheaders %+v \nstatus %+v for request %+v", lrw.Status(), lrw.Header(), lrw.body, r))
@@ -392,8 +522,9 @@ func createAfterProxyFinalizer(service *ProxyService, config config.Config) http
 		// parse values added to the context by handlers further up the middleware chain
 		rawDecodedRequestBody := r.Context().Value(DecodedRequestContextKey)
 		decodedRequestBody, ok := (rawDecodedRequestBody).(*decode.EVMRPCRequestEnvelope)
+		decodedCosmosRequest, isCosmosRequest := r.Context().Value(DecodedCosmosRequestContextKey).(*decode.CosmosRPCRequest)
 
-		if !ok {
+		if !ok && !isCosmosRequest {
 			service.ServiceLogger.Trace().Msg(fmt.Sprintf("invalid context value %+v for value %s", rawDecodedRequestBody, DecodedRequestContextKey))
 
 			return
@@ -470,21 +601,30 @@ func createAfterProxyFinalizer(service *ProxyService, config config.Config) http
 			return
 		}
 
+		var methodName string
 		var blockNumber *int64
-		// TODO: Redundant ExtractBlockNumberFromEVMRPCRequest call here if request is cached
-		// using background context so method won't be terminated when request finishes
-		rawBlockNumber, err := decodedRequestBody.ExtractBlockNumberFromEVMRPCRequest(context.Background(), service.evmClient)
+		if isCosmosRequest {
+			// the method of cosmos requests is the Tendermint RPC method or path of the Cosmos REST request
+			methodName = decodedCosmosRequest.Method
+			blockNumber = decodedCosmosRequest.Height
+		} else {
+			methodName = decodedRequestBody.Method
 
-		if err != nil {
-			service.ServiceLogger.
-				Trace().
-				Err(err).
-				Str("method", decodedRequestBody.Method).
-				Msg(fmt.Sprintf("can't parse block number from request %+v", decodedRequestBody))
+			// TODO: Redundant ExtractBlockNumberFromEVMRPCRequest call here if request is cached
+			// using background context so method won't be terminated when request finishes
+			rawBlockNumber, err := decodedRequestBody.ExtractBlockNumberFromEVMRPCRequest(context.Background(), service.evmClient)
 
-			blockNumber = nil
-		} else {
-			blockNumber = &rawBlockNumber
+			if err != nil {
+				service.ServiceLogger.
+					Trace().
+					Err(err).
+					Str("method", decodedRequestBody.Method).
+					Msg(fmt.Sprintf("can't parse block number from request %+v", decodedRequestBody))
+
+				blockNumber = nil
+			} else {
+				blockNumber = &rawBlockNumber
+			}
 		}
 
 		partOfBatch := batchmdw.IsBatchContext(r.Context(), DecodedBatchRequestContextKey)
@@ -493,7 +633,7 @@ func createAfterProxyFinalizer(service *ProxyService, config config.Config) http
 
 		// create a metric for the request
 		metric := &database.ProxiedRequestMetric{
-			MethodName:                  decodedRequestBody.Method,
+			MethodName:                  methodName,
 			ResponseLatencyMilliseconds: originRoundtripLatencyMilliseconds,
 			RequestTime:                 requestStartTime,
 			Hostname:                    requestHostname,
@@ -506,12 +646,15 @@ func createAfterProxyFinalizer(service *ProxyService, config config.Config) http
 			ResponseBackendRoute:        proxyMetadata.BackendRoute.String(),
 			CacheHit:                    isCached,
 			PartOfBatch:                 partOfBatch,
+			Retries:                     int64(proxyMetadata.Retries),
+			TimedOut:                    proxyMetadata.TimedOut,
+			MissingStateFallback:        proxyMetadata.MissingStateFallback,
 		}
 
 		// save metric to database async
 		go func() {
 			// using background context so save won't be terminated when request finishes
-			err = service.Database.SaveProxiedRequestMetric(context.Background(), metric)
+			err := service.Database.SaveProxiedRequestMetric(context.Background(), metric)
 
 			if err != nil {
 				// TODO: consider only logging
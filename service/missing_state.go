@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"strings"
+
+	"github.com/kava-labs/kava-proxy-service/config"
+	"github.com/kava-labs/kava-proxy-service/decode"
+	"github.com/kava-labs/kava-proxy-service/logging"
+	"github.com/kava-labs/kava-proxy-service/service/cachemdw"
+)
+
+const (
+	// context key marking requests that must be routed to the default backends of their host
+	// because the pruning backend they were routed to is missing the state for the request
+	MissingStateFallbackContextKey = "X-KAVA-PROXY-MISSING-STATE-FALLBACK"
+)
+
+// missingStatePolicy decides which responses of pruning backends indicate that the backend is
+// missing the state for the request (ie. it has already been pruned, or has not been synced yet),
+// in which case the request is re-issued to the default (archive) backends of the host.
+type missingStatePolicy struct {
+	enabled bool
+	// lower case patterns matched against the messages of JSON-RPC errors
+	errorPatterns []string
+}
+
+// newMissingStatePolicy creates the missingStatePolicy defined by the service config
+func newMissingStatePolicy(config config.Config) missingStatePolicy {
+	return missingStatePolicy{
+		enabled:       config.ProxyMissingStateFallbackEnabled,
+		errorPatterns: config.ProxyMissingStateErrorPatterns,
+	}
+}
+
+// appliesTo returns true when requests for the JSON-RPC method served by the backend of the metadata
+// should fall back to the default backends if the backend is missing the state for them.
+// Only read only requests routed to pruning backends fall back, as a request that sends
+// a transaction must not be sent twice.
+func (mp missingStatePolicy) appliesTo(method string, metadata ProxyMetadata) bool {
+	return mp.enabled && method != "" && decode.MethodIsIdempotent(method) && metadata.BackendName == ResponseBackendPruning
+}
+
+// isMissingState returns true when the response body is a JSON-RPC error
+// whose message matches one of the error patterns of the policy
+func (mp missingStatePolicy) isMissingState(body []byte) bool {
+	response, err := cachemdw.UnmarshalJsonRpcResponse(body)
+	if err != nil || response.JsonRpcError == nil {
+		return false
+	}
+
+	message := strings.ToLower(response.JsonRpcError.Message)
+	for _, pattern := range mp.errorPatterns {
+		if strings.Contains(message, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// newMissingStateFallbackAttempt returns a proxyAttempt that makes the attempt, and if it was
+// served by a pruning backend that is missing the state for the request, makes the attempt again
+// against the default backends of the host, responding with their response instead.
+func newMissingStateFallbackAttempt(attempt proxyAttempt, policy missingStatePolicy, proxies Proxies, serviceLogger *logging.ServiceLogger) proxyAttempt {
+	return func(r *http.Request, proxy *httputil.ReverseProxy, metadata ProxyMetadata) (*bufferedResponseWriter, ProxyMetadata) {
+		response, servedBy := attempt(r, proxy, metadata)
+		if servedBy.BackendName != ResponseBackendPruning || !policy.isMissingState(response.body.Bytes()) {
+			return response, servedBy
+		}
+
+		fallbackRequest := withMissingStateFallback(r)
+		fallbackProxy, fallbackMetadata, found := proxies.ProxyForRequest(fallbackRequest)
+		if !found {
+			serviceLogger.Debug().Msg(fmt.Sprintf("no default backend to fall back to for request for host %s missing state", r.Host))
+			return response, servedBy
+		}
+
+		serviceLogger.Debug().
+			Str("backend", servedBy.BackendRoute.String()).
+			Str("fallback-backend", fallbackMetadata.BackendRoute.String()).
+			Msg("pruning backend is missing state for request, falling back to default backend")
+
+		fallbackResponse, fallbackServedBy := attempt(fallbackRequest, fallbackProxy, fallbackMetadata)
+		fallbackServedBy.MissingStateFallback = true
+		return fallbackResponse, fallbackServedBy
+	}
+}
+
+// withMissingStateFallback returns a shallow copy of the request that is routed to the default backends of its host
+func withMissingStateFallback(r *http.Request) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), MissingStateFallbackContextKey, true))
+}
+
+// isMissingStateFallback returns true when the request must be routed to the default backends of its host
+func isMissingStateFallback(r *http.Request) bool {
+	fallback, _ := r.Context().Value(MissingStateFallbackContextKey).(bool)
+	return fallback
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/kava-labs/kava-proxy-service/config"
+	"github.com/kava-labs/kava-proxy-service/decode"
+	"github.com/kava-labs/kava-proxy-service/logging"
+	"github.com/stretchr/testify/require"
+)
+
+var testMissingStatePolicy = missingStatePolicy{
+	enabled:       true,
+	errorPatterns: []string{"missing trie node", "header not found"},
+}
+
+// newBodyBackend creates a test backend server that responds to every request with the body
+func newBodyBackend(t *testing.T, body string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// proxyMissingStateTestRequest proxies an eth_getBalance request for the latest block of evm.kava.io
+// to the pruning server, falling back to the default server according to the policy
+func proxyMissingStateTestRequest(t *testing.T, policy missingStatePolicy, pruning *httptest.Server, archive *httptest.Server) (*httptest.ResponseRecorder, ProxyMetadata) {
+	logger, err := logging.New("ERROR")
+	require.NoError(t, err)
+
+	pruningURL, err := url.Parse(pruning.URL)
+	require.NoError(t, err)
+	archiveURL, err := url.Parse(archive.URL)
+	require.NoError(t, err)
+	proxies := newPruningOrDefaultProxies(config.Config{
+		ProxyPruningBackendHostURLMap: map[string][]url.URL{"evm.kava.io": {*pruningURL}},
+		ProxyBackendHostURLMapParsed:  map[string][]url.URL{"evm.kava.io": {*archiveURL}},
+	}, newBackendRegistry(config.Config{}, &logger), &logger)
+
+	body := []byte(`{"jsonrpc":"2.0","id":1,"method":"eth_getBalance","params":["0x0000000000000000000000000000000000000000","latest"]}`)
+	decodedReq, err := decode.DecodeEVMRPCRequest(body)
+	require.NoError(t, err)
+	r := httptest.NewRequest(http.MethodPost, "http://evm.kava.io", bytes.NewReader(body))
+	r = r.WithContext(context.WithValue(r.Context(), DecodedRequestContextKey, decodedReq))
+
+	proxy, metadata, found := proxies.ProxyForRequest(r)
+	require.True(t, found)
+	require.Equal(t, ResponseBackendPruning, metadata.BackendName)
+	require.True(t, policy.appliesTo(decodedReq.Method, metadata))
+
+	w := httptest.NewRecorder()
+	attempt := newMissingStateFallbackAttempt(newProxyAttempt(body), policy, proxies, &logger)
+	metadata = proxyWithRetries(w, r, proxies, proxy, metadata, testRetryPolicy.withoutRetries(), attempt, &logger)
+
+	return w, metadata
+}
+
+func TestUnitTestMissingStateFallback_FallsBackToDefaultBackend(t *testing.T) {
+	pruning := newBodyBackend(t, `{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":"Missing trie node 0xabc (path )"}}`)
+	archive := newBodyBackend(t, `{"jsonrpc":"2.0","id":1,"result":"0x10"}`)
+
+	w, metadata := proxyMissingStateTestRequest(t, testMissingStatePolicy, pruning, archive)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	require.JSONEq(t, `{"jsonrpc":"2.0","id":1,"result":"0x10"}`, w.Body.String())
+	require.True(t, metadata.MissingStateFallback)
+	require.Equal(t, ResponseBackendDefault, metadata.BackendName)
+	require.Equal(t, archive.URL, metadata.BackendRoute.String())
+}
+
+func TestUnitTestMissingStateFallback_DoesNotFallBackForOtherResponses(t *testing.T) {
+	archive := newBodyBackend(t, `{"jsonrpc":"2.0","id":1,"result":"0x10"}`)
+
+	for _, body := range []string{
+		`{"jsonrpc":"2.0","id":1,"result":"0x20"}`,
+		`{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":"execution reverted"}}`,
+	} {
+		pruning := newBodyBackend(t, body)
+
+		w, metadata := proxyMissingStateTestRequest(t, testMissingStatePolicy, pruning, archive)
+
+		require.JSONEq(t, body, w.Body.String())
+		require.False(t, metadata.MissingStateFallback)
+		require.Equal(t, ResponseBackendPruning, metadata.BackendName)
+	}
+}
+
+func TestUnitTestMissingStatePolicy_AppliesTo(t *testing.T) {
+	pruning := ProxyMetadata{BackendName: ResponseBackendPruning}
+
+	require.True(t, testMissingStatePolicy.appliesTo("eth_getBalance", pruning))
+	require.False(t, testMissingStatePolicy.appliesTo("eth_sendRawTransaction", pruning))
+	require.False(t, testMissingStatePolicy.appliesTo("eth_getBalance", ProxyMetadata{BackendName: ResponseBackendDefault}))
+	require.False(t, missingStatePolicy{}.appliesTo("eth_getBalance", pruning))
+}
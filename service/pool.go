@@ -0,0 +1,206 @@
+package service
+
+import (
+	"math/rand"
+	"sync/atomic"
+
+	"github.com/kava-labs/kava-proxy-service/config"
+)
+
+// BackendPool is a group of equivalent backends that can serve requests for a host.
+// The strategy of the pool decides which member serves each request.
+type BackendPool struct {
+	backends []*Backend
+	strategy poolStrategy
+}
+
+// newBackendPool creates a BackendPool of a non-empty list of backends that uses the
+// named strategy for choosing which backend should serve a request.
+func newBackendPool(backends []*Backend, strategyName string) *BackendPool {
+	return &BackendPool{
+		backends: backends,
+		strategy: newPoolStrategy(strategyName),
+	}
+}
+
+// newWeightedBackendPool creates a BackendPool of a non-empty list of backends whose members
+// serve a share of the requests proportional to their weight, instead of using a named strategy.
+func newWeightedBackendPool(backends []*Backend, weights config.BackendWeights) *BackendPool {
+	strategy := weightedRandomStrategy{weights: make(map[*Backend]int, len(backends))}
+	for _, backend := range backends {
+		strategy.weights[backend] = weights.Weight(backend.URL())
+	}
+
+	return &BackendPool{
+		backends: backends,
+		strategy: strategy,
+	}
+}
+
+// Next chooses the backend that should serve the next request.
+// Backends that are not available are skipped. If no member of the pool is available
+// a backend is chosen from all enabled members, because failing over to a backend
+// that might be down is preferable to failing the request outright.
+func (bp *BackendPool) Next() *Backend {
+	return bp.NextFor(backendFilter{})
+}
+
+// NextFor chooses the backend that should serve the next request like Next,
+// preferring the backends the filter prefers and never choosing a backend the filter excludes.
+// Returns nil if every member of the pool is excluded.
+func (bp *BackendPool) NextFor(filter backendFilter) *Backend {
+	if len(bp.backends) == 1 && filter.allows(bp.backends[0]) {
+		return bp.backends[0]
+	}
+
+	candidates := bp.filter(filter.prefers)
+	if len(candidates) == 0 {
+		candidates = bp.filter(filter.allows)
+	}
+
+	switch len(candidates) {
+	case 0:
+		return nil
+	case 1:
+		return candidates[0]
+	default:
+		return bp.strategy.choose(candidates)
+	}
+}
+
+// Available returns true when at least one member of the pool is preferred by the filter
+func (bp *BackendPool) Available(filter backendFilter) bool {
+	for _, backend := range bp.backends {
+		if filter.prefers(backend) {
+			return true
+		}
+	}
+	return false
+}
+
+// filter returns the members of the pool matching the predicate
+func (bp *BackendPool) filter(predicate func(*Backend) bool) []*Backend {
+	matching := make([]*Backend, 0, len(bp.backends))
+	for _, backend := range bp.backends {
+		if predicate(backend) {
+			matching = append(matching, backend)
+		}
+	}
+	return matching
+}
+
+// Backends returns all members of the pool
+func (bp *BackendPool) Backends() []*Backend {
+	return bp.backends
+}
+
+// backendFilter describes which members of a pool a request may be routed to
+type backendFilter struct {
+	// backends the request must never be routed to, ie. ones it has already failed against
+	excluded map[*Backend]bool
+	// when true, backends that are behind the chain tip are avoided
+	requiresChainTip bool
+	// when non-zero, backends known to be behind this height are avoided
+	minHeadHeight uint64
+}
+
+// allows returns true if the request may be routed to the backend.
+// Backends taken out of rotation by operators never are.
+func (f backendFilter) allows(backend *Backend) bool {
+	return !f.excluded[backend] && backend.Enabled()
+}
+
+// prefers returns true if the request may be routed to the backend
+// and the backend is able to serve it
+func (f backendFilter) prefers(backend *Backend) bool {
+	if !f.allows(backend) || !backend.Available() {
+		return false
+	}
+	if f.requiresChainTip && backend.Lagging() {
+		return false
+	}
+	if head := backend.HeadHeight(); f.minHeadHeight > 0 && head > 0 && head < f.minHeadHeight {
+		return false
+	}
+	return true
+}
+
+// poolStrategy chooses one backend out of a non-empty list of backends
+type poolStrategy interface {
+	choose(backends []*Backend) *Backend
+}
+
+// newPoolStrategy returns the poolStrategy for the strategy name.
+// strategy names are validated by the config, any unknown or unset
+// strategy falls back to round robin.
+func newPoolStrategy(name string) poolStrategy {
+	switch name {
+	case config.BackendPoolStrategyLeastOutstandingRequests:
+		return leastOutstandingRequestsStrategy{}
+	case config.BackendPoolStrategyRandomTwoChoices:
+		return randomTwoChoicesStrategy{}
+	default:
+		return &roundRobinStrategy{}
+	}
+}
+
+// roundRobinStrategy cycles through the backends in order
+type roundRobinStrategy struct {
+	next atomic.Uint64
+}
+
+func (s *roundRobinStrategy) choose(backends []*Backend) *Backend {
+	i := s.next.Add(1) - 1
+	return backends[i%uint64(len(backends))]
+}
+
+// leastOutstandingRequestsStrategy chooses the backend with the fewest requests in flight.
+// Ties are broken by the order of the backends.
+type leastOutstandingRequestsStrategy struct{}
+
+func (leastOutstandingRequestsStrategy) choose(backends []*Backend) *Backend {
+	chosen := backends[0]
+	for _, backend := range backends[1:] {
+		if backend.InFlight() < chosen.InFlight() {
+			chosen = backend
+		}
+	}
+	return chosen
+}
+
+// randomTwoChoicesStrategy picks two distinct backends at random
+// and chooses the one with fewer requests in flight.
+type randomTwoChoicesStrategy struct{}
+
+func (randomTwoChoicesStrategy) choose(backends []*Backend) *Backend {
+	i := rand.Intn(len(backends))
+	// offset the second pick so it is always distinct from the first
+	j := (i + 1 + rand.Intn(len(backends)-1)) % len(backends)
+
+	if backends[j].InFlight() < backends[i].InFlight() {
+		return backends[j]
+	}
+	return backends[i]
+}
+
+// weightedRandomStrategy chooses a backend at random with a probability
+// proportional to its weight out of the total weight of the backends
+type weightedRandomStrategy struct {
+	weights map[*Backend]int
+}
+
+func (s weightedRandomStrategy) choose(backends []*Backend) *Backend {
+	var totalWeight int
+	for _, backend := range backends {
+		totalWeight += s.weights[backend]
+	}
+
+	pick := rand.Intn(totalWeight)
+	for _, backend := range backends {
+		if pick < s.weights[backend] {
+			return backend
+		}
+		pick -= s.weights[backend]
+	}
+	return backends[len(backends)-1]
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/kava-labs/kava-proxy-service/config"
+	"github.com/kava-labs/kava-proxy-service/logging"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestBackends(t *testing.T, inFlight ...int64) []*Backend {
+	logger, err := logging.New("ERROR")
+	require.NoError(t, err)
+
+	backends := make([]*Backend, 0, len(inFlight))
+	for i, count := range inFlight {
+		target, err := url.Parse(fmt.Sprintf("http://backend-%d.kava.io", i))
+		require.NoError(t, err)
+
+		backend, err := newBackend(*target, nil, config.BackendTransportConfig{}, &logger)
+		require.NoError(t, err)
+		backend.inFlight.Store(count)
+		backends = append(backends, backend)
+	}
+	return backends
+}
+
+func TestUnitTestBackendPool_RoundRobin(t *testing.T) {
+	backends := newTestBackends(t, 0, 0, 0)
+	pool := newBackendPool(backends, config.BackendPoolStrategyRoundRobin)
+
+	for i := 0; i < 2*len(backends); i++ {
+		require.Equal(t, backends[i%len(backends)], pool.Next())
+	}
+}
+
+func TestUnitTestBackendPool_LeastOutstandingRequests(t *testing.T) {
+	backends := newTestBackends(t, 5, 2, 7)
+	pool := newBackendPool(backends, config.BackendPoolStrategyLeastOutstandingRequests)
+
+	require.Equal(t, backends[1], pool.Next())
+
+	backends[1].inFlight.Store(10)
+	require.Equal(t, backends[0], pool.Next())
+}
+
+func TestUnitTestBackendPool_RandomTwoChoices(t *testing.T) {
+	backends := newTestBackends(t, 3, 1)
+	pool := newBackendPool(backends, config.BackendPoolStrategyRandomTwoChoices)
+
+	// with two members both are always compared, so the least loaded always wins
+	for i := 0; i < 10; i++ {
+		require.Equal(t, backends[1], pool.Next())
+	}
+
+	// the busiest member of a larger pool is never chosen
+	backends = newTestBackends(t, 1, 1, 9)
+	pool = newBackendPool(backends, config.BackendPoolStrategyRandomTwoChoices)
+	for i := 0; i < 20; i++ {
+		require.NotEqual(t, backends[2], pool.Next())
+	}
+}
+
+func TestUnitTestBackendPool_Weighted(t *testing.T) {
+	backends := newTestBackends(t, 0, 0, 0)
+	canary := backends[2]
+	pool := newWeightedBackendPool(backends, config.BackendWeights{
+		"http://backend-0.kava.io": 60,
+		"http://backend-1.kava.io": 35,
+		canary.url.String():        5,
+	})
+
+	chosen := make(map[*Backend]int)
+	for i := 0; i < 10000; i++ {
+		chosen[pool.Next()]++
+	}
+	require.InDelta(t, 6000, chosen[backends[0]], 400)
+	require.InDelta(t, 3500, chosen[backends[1]], 400)
+	require.InDelta(t, 500, chosen[canary], 200)
+
+	// members without a weight have the default weight, and only weighted members that may be chosen share the traffic
+	pool = newWeightedBackendPool(backends, config.BackendWeights{"http://backend-0.kava.io": 1000})
+	chosen = make(map[*Backend]int)
+	for i := 0; i < 1000; i++ {
+		chosen[pool.NextFor(backendFilter{excluded: map[*Backend]bool{backends[0]: true}})]++
+	}
+	require.Zero(t, chosen[backends[0]])
+	require.InDelta(t, 500, chosen[backends[1]], 100)
+	require.InDelta(t, 500, chosen[canary], 100)
+}
+
+func TestUnitTestBackend_TracksInFlightRequests(t *testing.T) {
+	backends := newTestBackends(t, 0)
+	backend := backends[0]
+
+	transport := backend.Proxy().Transport.(*inFlightTrackingTransport)
+	transport.next = roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("{}"))}, nil
+	})
+
+	req, err := http.NewRequest(http.MethodPost, "http://backend-0.kava.io", nil)
+	require.NoError(t, err)
+
+	resp, err := transport.RoundTrip(req)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), backend.InFlight())
+
+	require.NoError(t, resp.Body.Close())
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, int64(0), backend.InFlight())
+}
+
+// roundTripperFunc adapts a function to an http.RoundTripper
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
@@ -7,6 +7,7 @@ import (
 	"net/url"
 
 	"github.com/kava-labs/kava-proxy-service/config"
+	"github.com/kava-labs/kava-proxy-service/decode"
 	"github.com/kava-labs/kava-proxy-service/logging"
 )
 
@@ -15,12 +16,23 @@ const (
 	ResponseBackendDefault = "DEFAULT"
 	ResponseBackendPruning = "PRUNING"
 	ResponseBackendShard   = "SHARD"
+	// requests for the height of a shard routed to the default backend
+	// because every backend of the shard was unavailable or failed
+	ResponseBackendShardFallback = "SHARD_FALLBACK"
+	// requests for a range of blocks split across multiple shards
+	ResponseBackendShardSplit = "SHARD_SPLIT"
+	// requests routed by a method routing rule
+	ResponseBackendMethodRule = "METHOD_RULE"
+	// requests for a filter routed to the backend that installed it
+	ResponseBackendStickyFilter = "FILTER"
 )
 
 // Proxies is an interface for getting a reverse proxy for a given request.
 // proxy is the reverse proxy to use for the request
 type Proxies interface {
 	ProxyForRequest(r *http.Request) (proxy *httputil.ReverseProxy, metadata ProxyMetadata, found bool)
+	// Backends returns every backend requests may be proxied to
+	Backends() []*Backend
 }
 
 // ProxyMetadata wraps details about the proxy used for a request.
@@ -31,63 +43,178 @@ type ProxyMetadata struct {
 	// url of the backend used
 	BackendRoute url.URL
 	// height interval endpoint of shard.
-	// only defined if BackendName is "SHARD"
+	// only defined if BackendName is "SHARD", or the last shard if "SHARD_SPLIT"
 	ShardEndHeight uint64
+	// number of times the request was retried against another backend
+	// after the previous backend failed to respond
+	Retries int
+	// whether the backend failed to respond before the timeout of the request,
+	// in which case the proxy responded with a JSON-RPC error
+	TimedOut bool
+	// whether the request was re-issued to the default backend
+	// after the pruning backend was missing the state for it
+	MissingStateFallback bool
+	// the backend used, for reporting the outcome of the request
+	backend *Backend
 }
 
 // NewProxies creates a Proxies instance based on the service configuration:
 // - for non-sharding configuration, it returns a HostProxies
 // - for height-based-routing configurations, it returns a PruningOrDefaultProxies
-func NewProxies(config config.Config, serviceLogger *logging.ServiceLogger) Proxies {
+// - for sharding configurations, the above is wrapped in a ShardProxies
+// - for method-based-routing configurations, the above is wrapped in a MethodRuleProxies
+// The block getter is used to resolve the height of requests for a block hash when sharding is enabled.
+func NewProxies(config config.Config, blockGetter decode.EVMBlockGetter, serviceLogger *logging.ServiceLogger) Proxies {
+	// backends are shared by every pool they are a member of
+	return newProxies(config, newBackendRegistry(config, serviceLogger), blockGetter, serviceLogger)
+}
+
+// newProxies creates a Proxies instance like NewProxies, getting the backends from the registry
+func newProxies(config config.Config, registry *backendRegistry, blockGetter decode.EVMBlockGetter, serviceLogger *logging.ServiceLogger) Proxies {
 	var proxies Proxies
 	// configure proxies for default &/or pruning cluster routing
 	if config.EnableHeightBasedRouting {
 		serviceLogger.Debug().Msg("configuring reverse proxies based on host AND height (pruning or default)")
-		proxies = newPruningOrDefaultProxies(config, serviceLogger)
+		proxies = newPruningOrDefaultProxies(config, registry, serviceLogger)
 	} else {
 		serviceLogger.Debug().Msg("configuring reverse proxies based solely on request host")
-		proxies = newHostProxies(ResponseBackendDefault, config.ProxyBackendHostURLMapParsed, serviceLogger)
+		proxies = newHostProxies(ResponseBackendDefault, config.ProxyBackendHostURLMapParsed, config.ProxyBackendHostURLWeightMap, config.ProxyBackendPoolStrategy, registry, serviceLogger)
 	}
 
 	// wrap the baseline proxies with shard info if enabled
 	if config.EnableShardedRouting {
-		return newShardProxies(config.ProxyShardBackendHostURLMap, proxies, serviceLogger)
+		proxies = newShardProxies(config.ProxyShardBackendHostURLMap, proxies, config.ProxyShardDefaultFallbackEnabled, registry, blockGetter, serviceLogger)
+	}
+
+	// route methods matching the method routing rules of a host to dedicated backends if enabled
+	if config.EnableMethodRouting {
+		return newMethodRuleProxies(config.ProxyMethodRoutingBackendHostURLMap, proxies, config.ProxyBackendPoolStrategy, registry, serviceLogger)
 	}
 	return proxies
 }
 
 // HostProxies chooses a proxy based solely on the Host of the incoming request,
-// and the host -> backend url map defined in the config.
+// and the host -> backend url(s) map defined in the config.
+// When multiple backend urls are defined for a host, the request is proxied
+// to the member of the host's pool chosen by the pool's strategy.
 // HostProxies name is the response backend provided for all requests
 type HostProxies struct {
-	name             string
-	proxyForHost     map[string]*httputil.ReverseProxy
-	targetUrlForHost map[string]url.URL
+	name        string
+	poolForHost map[string]*BackendPool
 }
 
 var _ Proxies = HostProxies{}
 
 // ProxyForRequest implements Proxies. It determines the proxy based solely on the request Host.
+// Backends the request is being retried away from are never chosen, and requests
+// for the latest state of the chain avoid backends that are behind the chain tip.
 func (hbp HostProxies) ProxyForRequest(r *http.Request) (*httputil.ReverseProxy, ProxyMetadata, bool) {
-	proxy, found := hbp.proxyForHost[r.Host]
+	pool, found := hbp.poolForHost[r.Host]
+	if !found {
+		return nil, ProxyMetadata{BackendName: hbp.name}, false
+	}
+
+	backend := pool.NextFor(newBackendFilter(r))
+	if backend == nil {
+		return nil, ProxyMetadata{BackendName: hbp.name}, false
+	}
 	metadata := ProxyMetadata{
 		BackendName:  hbp.name,
-		BackendRoute: hbp.targetUrlForHost[r.Host],
+		BackendRoute: backend.URL(),
+		backend:      backend,
+	}
+	return backend.Proxy(), metadata, true
+}
+
+// Backends implements Proxies
+func (hbp HostProxies) Backends() []*Backend {
+	backendLists := make([][]*Backend, 0, len(hbp.poolForHost))
+	for _, pool := range hbp.poolForHost {
+		backendLists = append(backendLists, pool.Backends())
+	}
+	return uniqueBackends(backendLists...)
+}
+
+// hasHost returns true when a backend pool is configured for the host
+func (hbp HostProxies) hasHost(host string) bool {
+	_, found := hbp.poolForHost[host]
+	return found
+}
+
+// hasAvailableBackend returns true when the pool for the host of the request
+// has at least one available backend the request may be routed to
+func (hbp HostProxies) hasAvailableBackend(r *http.Request) bool {
+	pool, found := hbp.poolForHost[r.Host]
+	return found && pool.Available(newBackendFilter(r))
+}
+
+// newBackendFilter creates the filter for the backends the request may be routed to
+func newBackendFilter(r *http.Request) backendFilter {
+	filter := backendFilter{excluded: excludedBackends(r)}
+
+	decodedReq, ok := r.Context().Value(DecodedRequestContextKey).(*decode.EVMRPCRequestEnvelope)
+	if !ok {
+		return filter
+	}
+
+	// requests for the latest state of the chain should not be served by a backend that is behind the chain tip
+	if decode.MethodRequiresNoHistory(decodedReq.Method) {
+		filter.requiresChainTip = true
+		return filter
+	}
+
+	if !decode.MethodHasBlockNumberParam(decodedReq.Method) {
+		return filter
+	}
+
+	height, err := decode.ParseBlockNumberFromParams(decodedReq.Method, decodedReq.Params)
+	switch {
+	case err != nil:
+	case shouldRouteToPruning(height):
+		filter.requiresChainTip = true
+	case height > 0:
+		// requests for an explicit height should not be served by a backend that has not reached it yet
+		filter.minHeadHeight = uint64(height)
+	}
+	return filter
+}
+
+// headHeight returns the highest head of the backends for the host,
+// or zero if the heads of the backends are not known
+func (hbp HostProxies) headHeight(host string) uint64 {
+	pool, found := hbp.poolForHost[host]
+	if !found {
+		return 0
+	}
+
+	var head uint64
+	for _, backend := range pool.Backends() {
+		if backendHead := backend.HeadHeight(); backendHead > head {
+			head = backendHead
+		}
 	}
-	return proxy, metadata, found
+	return head
 }
 
 // newHostProxies creates a HostProxies from the backend url map defined in the config.
-func newHostProxies(name string, hostURLMap map[string]url.URL, serviceLogger *logging.ServiceLogger) HostProxies {
-	reverseProxyForHost := make(map[string]*httputil.ReverseProxy)
+// Pools of hosts with weights serve a share of the requests for the host with each member proportional to its weight.
+func newHostProxies(name string, hostURLMap map[string][]url.URL, hostWeights map[string]config.BackendWeights, poolStrategy string, registry *backendRegistry, serviceLogger *logging.ServiceLogger) HostProxies {
+	poolForHost := make(map[string]*BackendPool)
 
-	for host, proxyBackendURL := range hostURLMap {
-		serviceLogger.Debug().Msg(fmt.Sprintf("creating reverse proxy for host %s to %+v", host, proxyBackendURL))
+	for host, proxyBackendURLs := range hostURLMap {
+		serviceLogger.Debug().Msg(fmt.Sprintf("creating reverse proxy pool for host %s to %+v", host, proxyBackendURLs))
 
-		targetURL := hostURLMap[host]
+		backends := make([]*Backend, 0, len(proxyBackendURLs))
+		for _, proxyBackendURL := range proxyBackendURLs {
+			backends = append(backends, registry.getOrCreate(proxyBackendURL))
+		}
 
-		reverseProxyForHost[host] = httputil.NewSingleHostReverseProxy(&targetURL)
+		if weights, weighted := hostWeights[host]; weighted {
+			poolForHost[host] = newWeightedBackendPool(backends, weights)
+		} else {
+			poolForHost[host] = newBackendPool(backends, poolStrategy)
+		}
 	}
 
-	return HostProxies{name: name, proxyForHost: reverseProxyForHost, targetUrlForHost: hostURLMap}
+	return HostProxies{name: name, poolForHost: poolForHost}
 }
@@ -29,6 +29,7 @@ func newConfig(t *testing.T, defaultHostMap string, pruningHostMap string, shard
 	}
 	if shardHostMap != "" {
 		result.EnableShardedRouting = true
+		result.ProxyShardDefaultFallbackEnabled = true
 		result.ProxyShardBackendHostURLMapRaw = shardHostMap
 		result.ProxyShardBackendHostURLMap, err = config.ParseRawShardRoutingBackendHostURLMap(shardHostMap)
 		require.NoError(t, err)
@@ -39,19 +40,19 @@ func newConfig(t *testing.T, defaultHostMap string, pruningHostMap string, shard
 func TestUnitTest_NewProxies(t *testing.T) {
 	t.Run("returns a HostProxies when sharding disabled", func(t *testing.T) {
 		config := newConfig(t, dummyConfig.ProxyBackendHostURLMapRaw, "", "")
-		proxies := service.NewProxies(config, dummyLogger)
+		proxies := service.NewProxies(config, nil, dummyLogger)
 		require.IsType(t, service.HostProxies{}, proxies)
 	})
 
 	t.Run("returns a PruningOrDefaultProxies when height-based routing enabled", func(t *testing.T) {
 		config := newConfig(t, dummyConfig.ProxyBackendHostURLMapRaw, dummyConfig.ProxyPruningBackendHostURLMapRaw, "")
-		proxies := service.NewProxies(config, dummyLogger)
+		proxies := service.NewProxies(config, nil, dummyLogger)
 		require.IsType(t, service.PruningOrDefaultProxies{}, proxies)
 	})
 
 	t.Run("returns a ShardProxies when sharding enabled", func(t *testing.T) {
 		config := newConfig(t, dummyConfig.ProxyBackendHostURLMapRaw, "", dummyConfig.ProxyShardBackendHostURLMapRaw)
-		proxies := service.NewProxies(config, dummyLogger)
+		proxies := service.NewProxies(config, nil, dummyLogger)
 		require.IsType(t, service.ShardProxies{}, proxies)
 	})
 }
@@ -61,7 +62,7 @@ func TestUnitTest_HostProxies(t *testing.T) {
 		"magic.kava.io>magicalbackend.kava.io,archive.kava.io>archivenode.kava.io,pruning.kava.io>pruningnode.kava.io",
 		"", "",
 	)
-	proxies := service.NewProxies(config, dummyLogger)
+	proxies := service.NewProxies(config, nil, dummyLogger)
 
 	t.Run("ProxyForHost maps to correct proxy", func(t *testing.T) {
 		req := mockReqForUrl("//magic.kava.io")
@@ -89,6 +90,31 @@ func TestUnitTest_HostProxies(t *testing.T) {
 	})
 }
 
+func TestUnitTest_HostProxies_BackendPool(t *testing.T) {
+	config := newConfig(t,
+		"magic.kava.io>magicalbackend-1.kava.io/+magicalbackend-2.kava.io/+magicalbackend-3.kava.io/",
+		"", "",
+	)
+	proxies := service.NewProxies(config, nil, dummyLogger)
+
+	t.Run("ProxyForHost cycles through the pool members", func(t *testing.T) {
+		expectedRoutes := []string{
+			"magicalbackend-1.kava.io/",
+			"magicalbackend-2.kava.io/",
+			"magicalbackend-3.kava.io/",
+			"magicalbackend-1.kava.io/",
+		}
+		for _, expectedRoute := range expectedRoutes {
+			req := mockReqForUrl("//magic.kava.io")
+			proxy, metadata, found := proxies.ProxyForRequest(req)
+			require.True(t, found, "expected proxy to be found")
+			require.Equal(t, service.ResponseBackendDefault, metadata.BackendName)
+			require.Equal(t, expectedRoute, metadata.BackendRoute.String())
+			requireProxyRoutesToUrl(t, proxy, req, expectedRoute)
+		}
+	})
+}
+
 func mockReqForUrl(reqUrl string) *http.Request {
 	parsed, err := url.Parse(reqUrl)
 	if err != nil {
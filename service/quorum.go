@@ -0,0 +1,195 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"time"
+
+	"github.com/kava-labs/kava-proxy-service/config"
+	"github.com/kava-labs/kava-proxy-service/decode"
+	"github.com/kava-labs/kava-proxy-service/logging"
+	"github.com/kava-labs/kava-proxy-service/service/cachemdw"
+)
+
+// JSON-RPC error message the proxy responds with when not enough backends agreed on the result of the request
+const quorumNotReachedErrorMessage = "backends did not reach quorum: %d of %d backends agreed on the result, %d required"
+
+// quorumPolicy decides which requests are sent to several backends in parallel,
+// and how many of them must respond with the same result before it is responded with.
+type quorumPolicy struct {
+	enabled     bool
+	rulesByHost map[string]config.MethodQuorumRules
+}
+
+// newQuorumPolicy creates the quorumPolicy defined by the service config
+func newQuorumPolicy(config config.Config) quorumPolicy {
+	return quorumPolicy{
+		enabled:     config.ProxyQuorumReadEnabled,
+		rulesByHost: config.ProxyQuorumReadMap,
+	}
+}
+
+// ruleFor returns the quorum rule of requests for the method to the host, and whether they have one.
+// The rules of the host take precedence over the rules for every host.
+// Only methods without side effects are sent to several backends.
+func (qp quorumPolicy) ruleFor(host string, method string) (config.MethodQuorumRule, bool) {
+	if !qp.enabled || method == "" || !decode.MethodIsSideEffectFree(method) {
+		return config.MethodQuorumRule{}, false
+	}
+	if rule, found := qp.rulesByHost[host].Lookup(method); found {
+		return rule, true
+	}
+	return qp.rulesByHost[config.QuorumReadMapAnyHost].Lookup(method)
+}
+
+// quorumTarget is a backend a request for a quorum is sent to
+type quorumTarget struct {
+	proxy    *httputil.ReverseProxy
+	metadata ProxyMetadata
+}
+
+// quorumResult is the response of one of the backends a request for a quorum was sent to
+type quorumResult struct {
+	response *bufferedResponseWriter
+	metadata ProxyMetadata
+}
+
+// proxyWithQuorum sends the request body to the backend of the proxy and to the other backends chosen by the proxies,
+// up to the number of backends of the rule, in parallel. The response of the first backend whose JSON-RPC result
+// is the same, byte for byte, as the result of enough other backends to make up the agreements of the rule is written to w,
+// and the requests to the remaining backends are cancelled. If the backends can not reach the quorum, a JSON-RPC error
+// is written to w instead, or the failed response of a backend if the request was cancelled. Returns the metadata of the backend whose response was written, and whether the quorum was reached.
+func proxyWithQuorum(
+	w http.ResponseWriter,
+	r *http.Request,
+	proxies Proxies,
+	proxy *httputil.ReverseProxy,
+	metadata ProxyMetadata,
+	rule config.MethodQuorumRule,
+	requestBody []byte,
+	decodedReq *decode.EVMRPCRequestEnvelope,
+	serviceLogger *logging.ServiceLogger,
+) (ProxyMetadata, bool) {
+	targets := quorumTargets(r, proxies, proxy, metadata, rule.Backends)
+	if len(targets) < rule.Agreements {
+		serviceLogger.Error().
+			Str("host", r.Host).
+			Str("method", decodedReq.Method).
+			Int("backends", len(targets)).
+			Int("agreements", rule.Agreements).
+			Msg("not enough backends available to reach quorum")
+		writeQuorumNotReachedError(w, decodedReq, 0, len(targets), rule.Agreements, serviceLogger)
+		return metadata, false
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	results := make(chan quorumResult, len(targets))
+	for _, target := range targets {
+		go func(target quorumTarget) {
+			results <- sendForQuorum(ctx, r, target, requestBody)
+		}(target)
+	}
+
+	// responses with the same result, by their result
+	agreeingResults := make(map[string][]quorumResult)
+	mostAgreements := 0
+	var lastFailed quorumResult
+	for received := 1; received <= len(targets); received++ {
+		result := <-results
+
+		response, err := cachemdw.UnmarshalJsonRpcResponse(result.response.body.Bytes())
+		if result.response.statusCode != http.StatusOK || err != nil || response.JsonRpcError != nil {
+			serviceLogger.Debug().
+				Int("status", result.response.statusCode).
+				Str("backend", result.metadata.BackendRoute.String()).
+				Msg("backend failed to respond with a result, not counting it towards the quorum")
+			lastFailed = result
+		} else {
+			key := string(response.Result)
+			agreeingResults[key] = append(agreeingResults[key], result)
+			if agreements := len(agreeingResults[key]); agreements >= rule.Agreements {
+				// the quorum is reached, the remaining requests are cancelled
+				agreeingResults[key][0].response.writeTo(w)
+				return agreeingResults[key][0].metadata, true
+			} else if agreements > mostAgreements {
+				mostAgreements = agreements
+			}
+		}
+
+		// stop waiting for the remaining backends once they can no longer make up the quorum
+		if mostAgreements+len(targets)-received < rule.Agreements {
+			break
+		}
+	}
+
+	if r.Context().Err() != nil && lastFailed.response != nil {
+		// the backends did not disagree, the request was cancelled before they could respond
+		lastFailed.response.writeTo(w)
+		return lastFailed.metadata, false
+	}
+
+	serviceLogger.Error().
+		Str("host", r.Host).
+		Str("method", decodedReq.Method).
+		Int("backends", len(targets)).
+		Int("agreed", mostAgreements).
+		Int("agreements", rule.Agreements).
+		Msg("backends did not reach quorum")
+	writeQuorumNotReachedError(w, decodedReq, mostAgreements, len(targets), rule.Agreements, serviceLogger)
+	return metadata, false
+}
+
+// quorumTargets returns the backend of the proxy and the other backends chosen by the proxies for the request,
+// up to count backends. Fewer backends are returned when the proxies have no other backend for the request.
+func quorumTargets(r *http.Request, proxies Proxies, proxy *httputil.ReverseProxy, metadata ProxyMetadata, count int) []quorumTarget {
+	targets := []quorumTarget{{proxy: proxy, metadata: metadata}}
+	chosen := map[*Backend]bool{metadata.backend: true}
+
+	for len(targets) < count {
+		nextProxy, nextMetadata, found := proxies.ProxyForRequest(withExcludedBackends(r, chosen))
+		// requests split between backends, ie. block ranges spanning shards, are only sent once
+		if !found || nextMetadata.backend == nil || chosen[nextMetadata.backend] {
+			break
+		}
+		targets = append(targets, quorumTarget{proxy: nextProxy, metadata: nextMetadata})
+		chosen[nextMetadata.backend] = true
+	}
+
+	return targets
+}
+
+// sendForQuorum sends the request body to the backend of the target, buffering its response.
+// An aborted response is a failed result, which does not count towards the quorum.
+func sendForQuorum(ctx context.Context, r *http.Request, target quorumTarget, requestBody []byte) quorumResult {
+	response := newBufferedResponseWriter()
+
+	req := r.Clone(ctx)
+	req.Body = io.NopCloser(bytes.NewReader(requestBody))
+
+	startedAt := time.Now()
+	recordOutcome := target.metadata.backend.startRequest()
+	response.serve(target.proxy, req)
+	if ctx.Err() != nil && r.Context().Err() == nil {
+		// cancelled because the quorum was reached, or could no longer be reached, without it
+		recordOutcome(statusAbandoned, nil, time.Since(startedAt))
+	} else {
+		recordOutcome(response.statusCode, response.body.Bytes(), time.Since(startedAt))
+	}
+
+	return quorumResult{response: response, metadata: target.metadata}
+}
+
+// writeQuorumNotReachedError writes a JSON-RPC error response for the request
+// whose backends did not reach the quorum
+func writeQuorumNotReachedError(w http.ResponseWriter, decodedReq *decode.EVMRPCRequestEnvelope, agreed int, backends int, agreements int, serviceLogger *logging.ServiceLogger) {
+	message := fmt.Sprintf(quorumNotReachedErrorMessage, agreed, backends, agreements)
+	if err := writeJSONRPCError(w, http.StatusBadGateway, decodedReq, quorumNotReachedErrorCode, message); err != nil {
+		serviceLogger.Error().Msg(fmt.Sprintf("can't write quorum not reached response: %v", err))
+	}
+}
@@ -0,0 +1,178 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kava-labs/kava-proxy-service/config"
+	"github.com/kava-labs/kava-proxy-service/decode"
+	"github.com/kava-labs/kava-proxy-service/service/cachemdw"
+	"github.com/stretchr/testify/require"
+)
+
+// quorumTestRequest proxies an eth_getTransactionReceipt request for evm.kava.io
+// to the servers until the quorum of the rule is reached
+func quorumTestRequest(t *testing.T, rule config.MethodQuorumRule, servers ...*httptest.Server) (*httptest.ResponseRecorder, ProxyMetadata, bool) {
+	return quorumTestRequestWithContext(t, context.Background(), rule, servers...)
+}
+
+// quorumTestRequestWithContext is quorumTestRequest for a request with the context
+func quorumTestRequestWithContext(t *testing.T, ctx context.Context, rule config.MethodQuorumRule, servers ...*httptest.Server) (*httptest.ResponseRecorder, ProxyMetadata, bool) {
+	proxies, logger := newTestHostProxies(t, servers...)
+
+	body := []byte(`{"jsonrpc":"2.0","id":1,"method":"eth_getTransactionReceipt","params":["0xe9bd10bc1d62b4406dd1fb3dbf3adb54f640bdb9ebbe3dd6dfc6bcc059275e54"]}`)
+	decodedReq, err := decode.DecodeEVMRPCRequest(body)
+	require.NoError(t, err)
+	r := httptest.NewRequest(http.MethodPost, "http://evm.kava.io", bytes.NewReader(body))
+	// as for requests received by the service, so that the reverse proxy aborts failed responses
+	r = r.WithContext(context.WithValue(ctx, http.ServerContextKey, &http.Server{}))
+	proxy, metadata, found := proxies.ProxyForRequest(r)
+	require.True(t, found)
+
+	w := httptest.NewRecorder()
+	metadata, reached := proxyWithQuorum(w, r, proxies, proxy, metadata, rule, body, decodedReq, logger)
+
+	return w, metadata, reached
+}
+
+// requireQuorumNotReachedError requires the response to be the JSON-RPC error for requests
+// whose backends did not reach quorum, returning the message of the error
+func requireQuorumNotReachedError(t *testing.T, w *httptest.ResponseRecorder) string {
+	require.Equal(t, http.StatusBadGateway, w.Code)
+	response, err := cachemdw.UnmarshalJsonRpcResponse(w.Body.Bytes())
+	require.NoError(t, err)
+	require.NotNil(t, response.JsonRpcError)
+	require.Equal(t, quorumNotReachedErrorCode, response.JsonRpcError.Code)
+	return response.JsonRpcError.Message
+}
+
+func TestUnitTestProxyWithQuorum_RespondsWithAgreedResult(t *testing.T) {
+	agreeing1 := newBodyBackend(t, `{"jsonrpc":"2.0","id":1,"result":{"status":"0x1"}}`)
+	agreeing2 := newBodyBackend(t, `{"jsonrpc":"2.0","id":1,"result":{"status":"0x1"}}`)
+	disagreeing := newBodyBackend(t, `{"jsonrpc":"2.0","id":1,"result":null}`)
+
+	w, metadata, reached := quorumTestRequest(t, config.MethodQuorumRule{Backends: 3, Agreements: 2}, agreeing1, disagreeing, agreeing2)
+
+	require.True(t, reached)
+	require.JSONEq(t, `{"jsonrpc":"2.0","id":1,"result":{"status":"0x1"}}`, w.Body.String())
+	require.Contains(t, []string{agreeing1.URL, agreeing2.URL}, metadata.BackendRoute.String())
+}
+
+func TestUnitTestProxyWithQuorum_RespondsWithErrorWhenBackendsDisagree(t *testing.T) {
+	w, _, reached := quorumTestRequest(t, config.MethodQuorumRule{Backends: 3, Agreements: 2},
+		newBodyBackend(t, `{"jsonrpc":"2.0","id":1,"result":"0x1"}`),
+		// results are compared byte for byte
+		newBodyBackend(t, `{"jsonrpc":"2.0","id":1,"result":"0x01"}`),
+		newBodyBackend(t, `{"jsonrpc":"2.0","id":1,"result":"0x2"}`),
+	)
+
+	require.False(t, reached)
+	message := requireQuorumNotReachedError(t, w)
+	require.Equal(t, "backends did not reach quorum: 1 of 3 backends agreed on the result, 2 required", message)
+}
+
+func TestUnitTestProxyWithQuorum_DoesNotCountFailedResponses(t *testing.T) {
+	w, _, reached := quorumTestRequest(t, config.MethodQuorumRule{Backends: 3, Agreements: 2},
+		newBodyBackend(t, `{"jsonrpc":"2.0","id":1,"result":"0x1"}`),
+		newStatusBackend(t, http.StatusBadGateway),
+		newBodyBackend(t, `{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":"header not found"}}`),
+	)
+
+	require.False(t, reached)
+	// the remaining backends are not waited for once the failed responses make the quorum unreachable
+	requireQuorumNotReachedError(t, w)
+}
+
+func TestUnitTestProxyWithQuorum_DoesNotCountAbortedResponses(t *testing.T) {
+	// starts responding with the agreed result, then fails before the body is complete
+	aborting := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x1"`))
+		w.(http.Flusher).Flush()
+		panic(http.ErrAbortHandler)
+	}))
+	t.Cleanup(aborting.Close)
+
+	w, _, reached := quorumTestRequest(t, config.MethodQuorumRule{Backends: 3, Agreements: 2},
+		newBodyBackend(t, `{"jsonrpc":"2.0","id":1,"result":"0x1"}`),
+		aborting,
+		newBodyBackend(t, `{"jsonrpc":"2.0","id":1,"result":"0x2"}`),
+	)
+
+	require.False(t, reached)
+	message := requireQuorumNotReachedError(t, w)
+	require.Equal(t, "backends did not reach quorum: 1 of 3 backends agreed on the result, 2 required", message)
+}
+
+func TestUnitTestProxyWithQuorum_RespondsWithFailedResponseWhenCancelled(t *testing.T) {
+	slow1, _ := newSlowBackend(t, time.Minute)
+	slow2, _ := newSlowBackend(t, time.Minute)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	w, _, reached := quorumTestRequestWithContext(t, ctx, config.MethodQuorumRule{Backends: 2, Agreements: 2}, slow1, slow2)
+
+	require.False(t, reached)
+	// the backends did not disagree, so the quorum not reached error is not responded with
+	require.Equal(t, http.StatusBadGateway, w.Code)
+}
+
+func TestUnitTestProxyWithQuorum_CancelsRemainingRequestsOnceReached(t *testing.T) {
+	slow, cancelled := newSlowBackend(t, time.Minute)
+
+	_, _, reached := quorumTestRequest(t, config.MethodQuorumRule{Backends: 3, Agreements: 2},
+		slow,
+		newBodyBackend(t, `{"jsonrpc":"2.0","id":1,"result":"0x1"}`),
+		newBodyBackend(t, `{"jsonrpc":"2.0","id":1,"result":"0x1"}`),
+	)
+
+	require.True(t, reached)
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("expected request to slow backend to be cancelled")
+	}
+}
+
+func TestUnitTestProxyWithQuorum_RespondsWithErrorWithoutEnoughBackends(t *testing.T) {
+	w, _, reached := quorumTestRequest(t, config.MethodQuorumRule{Backends: 3, Agreements: 2},
+		newBodyBackend(t, `{"jsonrpc":"2.0","id":1,"result":"0x1"}`),
+	)
+
+	require.False(t, reached)
+	message := requireQuorumNotReachedError(t, w)
+	require.Equal(t, "backends did not reach quorum: 0 of 1 backends agreed on the result, 2 required", message)
+}
+
+func TestUnitTestQuorumPolicy_RuleFor(t *testing.T) {
+	policy := quorumPolicy{
+		enabled: true,
+		rulesByHost: map[string]config.MethodQuorumRules{
+			config.QuorumReadMapAnyHost: {{Pattern: "eth_get*", Backends: 3, Agreements: 2}},
+			"evm.kava.io":               {{Pattern: "eth_getBalance", Backends: 5, Agreements: 3}},
+		},
+	}
+
+	rule, found := policy.ruleFor("evm.kava.io", "eth_getBalance")
+	require.True(t, found)
+	require.Equal(t, 5, rule.Backends)
+
+	rule, found = policy.ruleFor("evm.kava.io", "eth_getTransactionReceipt")
+	require.True(t, found)
+	require.Equal(t, 3, rule.Backends)
+
+	_, found = policy.ruleFor("evm.kava.io", "eth_blockNumber")
+	require.False(t, found)
+
+	// requests with side effects are never sent to several backends
+	policy.rulesByHost["evm.kava.io"] = config.MethodQuorumRules{{Pattern: "*", Backends: 3, Agreements: 2}}
+	_, found = policy.ruleFor("evm.kava.io", "eth_sendRawTransaction")
+	require.False(t, found)
+
+	policy.enabled = false
+	_, found = policy.ruleFor("evm.kava.io", "eth_getBalance")
+	require.False(t, found)
+}
@@ -0,0 +1,234 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/kava-labs/kava-proxy-service/config"
+	"github.com/kava-labs/kava-proxy-service/decode"
+	"github.com/kava-labs/kava-proxy-service/logging"
+)
+
+// ReloadableProxies is a Proxies whose routing configuration can be replaced while the service is running.
+// Backends that are in both the old and new configuration keep their state, ie. their health & circuit breaker.
+// The policies deciding how requests are proxied to the backends are replaced along with the proxies.
+type ReloadableProxies struct {
+	current atomic.Pointer[proxiesHolder]
+
+	// serializes reloads so that the registry only ever holds the backends of the current proxies
+	mu          sync.Mutex
+	registry    *backendRegistry
+	blockGetter decode.EVMBlockGetter
+	*logging.ServiceLogger
+}
+
+// proxiesHolder allows Proxies of any type to be stored in an atomic.Pointer,
+// along with the request policies of the same routing configuration
+type proxiesHolder struct {
+	Proxies
+	policies *requestPolicies
+}
+
+// requestPolicies decide how requests are proxied to the backends chosen by the proxies
+type requestPolicies struct {
+	retry        retryPolicy
+	hedging      *hedgingPolicy
+	timeout      timeoutPolicy
+	missingState missingStatePolicy
+	quorum       quorumPolicy
+}
+
+// newRequestPolicies creates the requestPolicies defined by the service config
+func newRequestPolicies(config config.Config) *requestPolicies {
+	return &requestPolicies{
+		retry:        newRetryPolicy(config),
+		hedging:      newHedgingPolicy(config),
+		timeout:      newTimeoutPolicy(config),
+		missingState: newMissingStatePolicy(config),
+		quorum:       newQuorumPolicy(config),
+	}
+}
+
+var _ Proxies = &ReloadableProxies{}
+
+// NewReloadableProxies creates a ReloadableProxies for the routing configuration of the service config
+func NewReloadableProxies(config config.Config, blockGetter decode.EVMBlockGetter, serviceLogger *logging.ServiceLogger) *ReloadableProxies {
+	registry := newBackendRegistry(config, serviceLogger)
+
+	rp := &ReloadableProxies{
+		registry:      registry,
+		blockGetter:   blockGetter,
+		ServiceLogger: serviceLogger,
+	}
+	rp.current.Store(&proxiesHolder{
+		Proxies:  newProxies(config, registry, blockGetter, serviceLogger),
+		policies: newRequestPolicies(config),
+	})
+
+	return rp
+}
+
+// ProxyForRequest implements Proxies using the current routing configuration
+func (rp *ReloadableProxies) ProxyForRequest(r *http.Request) (*httputil.ReverseProxy, ProxyMetadata, bool) {
+	return rp.Current().ProxyForRequest(r)
+}
+
+// Backends implements Proxies using the current routing configuration
+func (rp *ReloadableProxies) Backends() []*Backend {
+	return rp.Current().Backends()
+}
+
+// Current returns the proxies of the current routing configuration.
+// Requests use the returned proxies for their whole lifetime, so that
+// requests in flight during a reload finish with the routing they started with.
+func (rp *ReloadableProxies) Current() Proxies {
+	return rp.current.Load().Proxies
+}
+
+// Reload validates the config and replaces the current routing configuration with it,
+// keeping the current routing configuration if the config is invalid.
+func (rp *ReloadableProxies) Reload(newConfig config.Config) error {
+	if err := config.Validate(newConfig); err != nil {
+		return err
+	}
+
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+
+	rp.registry.setTransportConfigs(newConfig.ProxyBackendTransportMap)
+	proxies := newProxies(newConfig, rp.registry, rp.blockGetter, rp.ServiceLogger)
+	// the latencies observed for hedging are learned again with the new policies
+	rp.current.Store(&proxiesHolder{Proxies: proxies, policies: newRequestPolicies(newConfig)})
+	rp.registry.retain(proxies.Backends())
+
+	return nil
+}
+
+// currentRouting returns the proxies & policies a request should use for its whole lifetime,
+// which are the current ones of reloadable proxies, otherwise the proxies & policies as is
+func currentRouting(proxies Proxies, policies *requestPolicies) (Proxies, *requestPolicies) {
+	if reloadable, ok := proxies.(*ReloadableProxies); ok {
+		current := reloadable.current.Load()
+		return current.Proxies, current.policies
+	}
+	return proxies, policies
+}
+
+// currentProxies returns the proxies a request should use for its whole lifetime
+func currentProxies(proxies Proxies) Proxies {
+	if reloadable, ok := proxies.(*ReloadableProxies); ok {
+		return reloadable.Current()
+	}
+	return proxies
+}
+
+// RoutingConfigReloader reloads the routing configuration of the proxies when the service
+// receives a SIGHUP, or when the contents of the config file change.
+type RoutingConfigReloader struct {
+	proxies *ReloadableProxies
+	// sources the config is read from again on every reload
+	sources config.ConfigSources
+	// how often the config file is checked for changes
+	interval time.Duration
+
+	lastConfigFileContents []byte
+	*logging.ServiceLogger
+}
+
+// NewRoutingConfigReloader creates a RoutingConfigReloader for the proxies
+// reading the config from the sources & watching their config file
+func NewRoutingConfigReloader(proxies *ReloadableProxies, sources config.ConfigSources, interval time.Duration, serviceLogger *logging.ServiceLogger) *RoutingConfigReloader {
+	lastConfigFileContents, _ := os.ReadFile(sources.ConfigFile)
+	return &RoutingConfigReloader{
+		proxies:                proxies,
+		sources:                sources,
+		interval:               interval,
+		lastConfigFileContents: lastConfigFileContents,
+		ServiceLogger:          serviceLogger,
+	}
+}
+
+// routingConfigSources returns the sources the service config is read from again on reload,
+// the environment of the process and the config file of the service config
+func routingConfigSources(serviceConfig config.Config) config.ConfigSources {
+	return config.ConfigSources{
+		LookupEnv:  os.LookupEnv,
+		ConfigFile: serviceConfig.ProxyConfigFile,
+	}
+}
+
+// Run reloads the routing configuration on every SIGHUP and change of the config file
+// until the context is cancelled. The reloader should only be run when the sources have
+// a config file, as the environment the config is otherwise read from can't change.
+func (rl *RoutingConfigReloader) Run(ctx context.Context) {
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+	defer signal.Stop(hangups)
+
+	fileChecks := time.NewTicker(rl.interval)
+	defer fileChecks.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangups:
+			rl.Info().Msg("received SIGHUP, reloading routing config")
+			rl.Reload()
+		case <-fileChecks.C:
+			rl.ReloadIfConfigFileChanged()
+		}
+	}
+}
+
+// ReloadIfConfigFileChanged reloads the routing configuration if the contents of the config file
+// changed since it was last checked. Returns true if the routing configuration was reloaded.
+func (rl *RoutingConfigReloader) ReloadIfConfigFileChanged() bool {
+	if !rl.configFileChanged() {
+		return false
+	}
+
+	rl.Info().Msg(fmt.Sprintf("config file %s changed, reloading routing config", rl.sources.ConfigFile))
+	rl.Reload()
+	return true
+}
+
+// Reload reads the config from the sources and replaces the routing configuration
+// of the proxies with it if the config file loaded and it is valid
+func (rl *RoutingConfigReloader) Reload() error {
+	newConfig, err := config.ReadConfigFrom(rl.sources)
+	if err != nil {
+		rl.Error().Err(err).Msg("failed to load config file, keeping current routing config")
+		return err
+	}
+
+	if err := rl.proxies.Reload(newConfig); err != nil {
+		rl.Error().Err(err).Msg("invalid routing config, keeping current routing config")
+		return err
+	}
+
+	rl.Info().Msg("reloaded routing config")
+	return nil
+}
+
+// configFileChanged returns true if the contents of the config file changed since it was last checked
+func (rl *RoutingConfigReloader) configFileChanged() bool {
+	contents, err := os.ReadFile(rl.sources.ConfigFile)
+	if err != nil {
+		rl.Error().Err(err).Msg(fmt.Sprintf("failed to read config file %s", rl.sources.ConfigFile))
+		return false
+	}
+
+	changed := !bytes.Equal(contents, rl.lastConfigFileContents)
+	rl.lastConfigFileContents = contents
+	return changed
+}
@@ -0,0 +1,140 @@
+package service_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kava-labs/kava-proxy-service/config"
+	"github.com/kava-labs/kava-proxy-service/decode"
+	"github.com/kava-labs/kava-proxy-service/service"
+	"github.com/stretchr/testify/require"
+)
+
+// newReloadTestConfig returns a valid service config that routes evm.kava.io to the backends
+func newReloadTestConfig(t *testing.T, hostMap string) config.Config {
+	cfg, err := config.ReadConfig()
+	require.NoError(t, err)
+	cfg.EnableHeightBasedRouting = false
+	cfg.EnableShardedRouting = false
+	cfg.EnableMethodRouting = false
+	cfg.ProxyBackendHostURLMapRaw = hostMap
+	cfg.ProxyBackendHostURLMapParsed = newConfig(t, hostMap, "", "").ProxyBackendHostURLMapParsed
+	cfg.ProxyPruningBackendHostURLMapRaw = ""
+	cfg.ProxyPruningBackendHostURLMap = nil
+	cfg.ProxyPruningBackendRetentionWindowMapRaw = ""
+	cfg.ProxyPruningBackendRetentionWindowMap = nil
+	require.NoError(t, config.Validate(cfg))
+	return cfg
+}
+
+func requireRoutesTo(t *testing.T, proxies service.Proxies, expectedRoute string) {
+	_, metadata, found := proxies.ProxyForRequest(mockJsonRpcReqToUrl("//evm.kava.io", &decode.EVMRPCRequestEnvelope{}))
+	require.True(t, found)
+	require.Equal(t, expectedRoute, metadata.BackendRoute.String())
+}
+
+func TestUnitTestReloadableProxies_Reload(t *testing.T) {
+	proxies := service.NewReloadableProxies(newReloadTestConfig(t, "evm.kava.io>http://kava-1:8545"), nil, dummyLogger)
+	requireRoutesTo(t, proxies, "http://kava-1:8545")
+	kava1 := proxies.Backends()[0]
+
+	// requests in flight keep the routing configuration they started with
+	inFlight := proxies.Current()
+
+	require.NoError(t, proxies.Reload(newReloadTestConfig(t, "evm.kava.io>http://kava-1:8545+http://kava-2:8545")))
+
+	requireRoutesTo(t, inFlight, "http://kava-1:8545")
+	requireRoutesTo(t, inFlight, "http://kava-1:8545")
+	requireRoutesTo(t, proxies, "http://kava-1:8545")
+	requireRoutesTo(t, proxies, "http://kava-2:8545")
+
+	// backends in both routing configurations keep their state
+	require.Len(t, proxies.Backends(), 2)
+	require.Contains(t, proxies.Backends(), kava1)
+
+	t.Run("keeps the current routing config when the new one is invalid", func(t *testing.T) {
+		invalidConfig := newReloadTestConfig(t, "evm.kava.io>http://kava-3:8545")
+		invalidConfig.ProxyBackendPoolStrategy = "unknown-strategy"
+
+		require.Error(t, proxies.Reload(invalidConfig))
+		require.Len(t, proxies.Backends(), 2)
+		require.Contains(t, proxies.Backends(), kava1)
+	})
+}
+
+// writeReloadTestConfigFile writes a config file that routes evm.kava.io to the backends
+func writeReloadTestConfigFile(t *testing.T, path string, backends ...string) {
+	contents := "hosts:\n  evm.kava.io:\n    backends:\n"
+	for _, backend := range backends {
+		contents += fmt.Sprintf("      - %s\n", backend)
+	}
+	require.NoError(t, os.WriteFile(path, []byte(contents), 0o600))
+}
+
+// newReloadTestSources returns the sources of a config read from the environment of the process
+// without its routing settings, and the config file
+func newReloadTestSources(configFile string) config.ConfigSources {
+	return config.ConfigSources{
+		LookupEnv: func(key string) (string, bool) {
+			switch key {
+			case config.PROXY_BACKEND_HOST_URL_MAP_ENVIRONMENT_KEY,
+				config.PROXY_PRUNING_BACKEND_HOST_URL_MAP_ENVIRONMENT_KEY,
+				config.PROXY_HEIGHT_BASED_ROUTING_ENABLED_KEY,
+				config.PROXY_METHOD_ROUTING_ENABLED_ENVIRONMENT_KEY:
+				return "", false
+			}
+			return os.LookupEnv(key)
+		},
+		ConfigFile: configFile,
+	}
+}
+
+func TestUnitTestRoutingConfigReloader_ReloadsConfigFile(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	writeReloadTestConfigFile(t, configFile, "http://kava-1:8545")
+	sources := newReloadTestSources(configFile)
+
+	cfg, err := config.ReadConfigFrom(sources)
+	require.NoError(t, err)
+	require.NoError(t, config.Validate(cfg))
+	proxies := service.NewReloadableProxies(cfg, nil, dummyLogger)
+	requireRoutesTo(t, proxies, "http://kava-1:8545")
+
+	reloader := service.NewRoutingConfigReloader(proxies, sources, time.Second, dummyLogger)
+
+	writeReloadTestConfigFile(t, configFile, "http://kava-2:8545")
+	require.NoError(t, reloader.Reload())
+	requireRoutesTo(t, proxies, "http://kava-2:8545")
+
+	// invalid routing config, here without any hosts, is not applied
+	require.NoError(t, os.WriteFile(configFile, []byte("log_level: INFO\n"), 0o600))
+	require.Error(t, reloader.Reload())
+	requireRoutesTo(t, proxies, "http://kava-2:8545")
+
+	// nor is the routing config of a file that fails to load
+	writeReloadTestConfigFile(t, configFile, "")
+	require.Error(t, reloader.Reload())
+	requireRoutesTo(t, proxies, "http://kava-2:8545")
+}
+
+func TestUnitTestRoutingConfigReloader_ReloadsWhenConfigFileChanges(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	writeReloadTestConfigFile(t, configFile, "http://kava-1:8545")
+	sources := newReloadTestSources(configFile)
+
+	cfg, err := config.ReadConfigFrom(sources)
+	require.NoError(t, err)
+	proxies := service.NewReloadableProxies(cfg, nil, dummyLogger)
+	reloader := service.NewRoutingConfigReloader(proxies, sources, time.Second, dummyLogger)
+	require.False(t, reloader.ReloadIfConfigFileChanged())
+
+	writeReloadTestConfigFile(t, configFile, "http://kava-2:8545")
+	require.True(t, reloader.ReloadIfConfigFileChanged())
+	requireRoutesTo(t, proxies, "http://kava-2:8545")
+
+	// the change was only picked up once
+	require.False(t, reloader.ReloadIfConfigFileChanged())
+}
@@ -0,0 +1,231 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"time"
+
+	"github.com/kava-labs/kava-proxy-service/config"
+	"github.com/kava-labs/kava-proxy-service/decode"
+	"github.com/kava-labs/kava-proxy-service/logging"
+)
+
+const (
+	// context key for the set of backends a retried request must not be routed to
+	ExcludedBackendsContextKey = "X-KAVA-PROXY-EXCLUDED-BACKENDS"
+)
+
+// retryPolicy decides which requests are retried against another backend
+// when the backend they were proxied to fails to respond, and how often.
+type retryPolicy struct {
+	enabled    bool
+	maxRetries int
+	// delay before the first retry, doubled for every following retry
+	backoff time.Duration
+}
+
+// newRetryPolicy creates the retryPolicy defined by the service config
+func newRetryPolicy(config config.Config) retryPolicy {
+	return retryPolicy{
+		enabled:    config.ProxyBackendRetryEnabled,
+		maxRetries: config.ProxyBackendRetryMaxRetries,
+		backoff:    config.ProxyBackendRetryBackoff,
+	}
+}
+
+// appliesTo returns true when requests for the JSON-RPC method should be retried.
+// Only read only methods are retried because a request that sends or signs a transaction
+// may have reached the chain even though the backend failed to respond.
+func (rp retryPolicy) appliesTo(method string) bool {
+	return rp.enabled && method != "" && decode.MethodIsIdempotent(method)
+}
+
+// withoutRetries returns a copy of the policy that never retries requests
+func (rp retryPolicy) withoutRetries() retryPolicy {
+	rp.maxRetries = 0
+	return rp
+}
+
+// delay returns how long to wait before making the nth retry
+func (rp retryPolicy) delay(retry int) time.Duration {
+	return rp.backoff * time.Duration(1<<(retry-1))
+}
+
+// isRetryableStatus returns true when the status code of a response indicates
+// the backend failed to respond, ie. it was unreachable, overloaded or timed out.
+// The reverse proxy responds with a 502 when it fails to connect to the backend.
+func isRetryableStatus(statusCode int) bool {
+	switch statusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// excludedBackends returns the backends the request must not be routed to
+// because it has already failed against them
+func excludedBackends(r *http.Request) map[*Backend]bool {
+	excluded, _ := r.Context().Value(ExcludedBackendsContextKey).(map[*Backend]bool)
+	return excluded
+}
+
+// proxyAttempt makes a single attempt at proxying the request with the proxy,
+// returning the buffered response and the metadata of the proxy that served it
+type proxyAttempt func(r *http.Request, proxy *httputil.ReverseProxy, metadata ProxyMetadata) (*bufferedResponseWriter, ProxyMetadata)
+
+// newProxyAttempt returns a proxyAttempt that sends the request body to the backend of the proxy once
+func newProxyAttempt(requestBody []byte) proxyAttempt {
+	return func(r *http.Request, proxy *httputil.ReverseProxy, metadata ProxyMetadata) (*bufferedResponseWriter, ProxyMetadata) {
+		response := newBufferedResponseWriter()
+		r.Body = io.NopCloser(bytes.NewReader(requestBody))
+
+		startedAt := time.Now()
+		recordOutcome := metadata.backend.startRequest()
+		response.serve(proxy, r)
+		recordOutcome(response.statusCode, response.body.Bytes(), time.Since(startedAt))
+
+		return response, metadata
+	}
+}
+
+// proxyWithRetries proxies the request, retrying it against other backends with an exponential backoff
+// while the backend fails to respond and the retry budget of the policy allows it.
+// The response of the last attempt is written to w, and the metadata of the proxy
+// that served it is returned with the number of retries made.
+func proxyWithRetries(
+	w http.ResponseWriter,
+	r *http.Request,
+	proxies Proxies,
+	proxy *httputil.ReverseProxy,
+	metadata ProxyMetadata,
+	policy retryPolicy,
+	attempt proxyAttempt,
+	serviceLogger *logging.ServiceLogger,
+) ProxyMetadata {
+	failedBackends := make(map[*Backend]bool)
+
+	for retries := 0; ; retries++ {
+		// buffer the response so that it can be discarded if the request is retried
+		response, servedBy := attempt(r, proxy, metadata)
+		servedBy.Retries = retries
+
+		if !isRetryableStatus(response.statusCode) || retries >= policy.maxRetries || r.Context().Err() != nil {
+			response.writeTo(w)
+			return servedBy
+		}
+
+		// never retry against a backend the request has already failed against
+		failedBackends[metadata.backend] = true
+		failedBackends[servedBy.backend] = true
+
+		nextProxy, nextMetadata, found := proxies.ProxyForRequest(withExcludedBackends(r, failedBackends))
+		if !found {
+			serviceLogger.Debug().Msg(fmt.Sprintf("no other backend to retry request for host %s against", r.Host))
+			response.writeTo(w)
+			return servedBy
+		}
+
+		serviceLogger.Debug().
+			Int("status", response.statusCode).
+			Str("backend", servedBy.BackendRoute.String()).
+			Str("retry-backend", nextMetadata.BackendRoute.String()).
+			Msg("backend failed to respond, retrying request")
+
+		select {
+		case <-time.After(policy.delay(retries + 1)):
+		case <-r.Context().Done():
+			response.writeTo(w)
+			return servedBy
+		}
+
+		proxy, metadata = nextProxy, nextMetadata
+	}
+}
+
+// withExcludedBackends returns a shallow copy of the request that is never routed to the excluded backends
+func withExcludedBackends(r *http.Request, excluded map[*Backend]bool) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), ExcludedBackendsContextKey, excluded))
+}
+
+// withExcludedBackend returns a shallow copy of the request that is never routed to the backend,
+// nor to the backends the request is already excluded from
+func withExcludedBackend(r *http.Request, backend *Backend) *http.Request {
+	excluded := make(map[*Backend]bool, len(excludedBackends(r))+1)
+	for excludedBackend := range excludedBackends(r) {
+		excluded[excludedBackend] = true
+	}
+	excluded[backend] = true
+	return withExcludedBackends(r, excluded)
+}
+
+// bufferedResponseWriter is an http.ResponseWriter that holds
+// the response in memory until it is written to another ResponseWriter
+type bufferedResponseWriter struct {
+	header     http.Header
+	statusCode int
+	body       bytes.Buffer
+}
+
+func newBufferedResponseWriter() *bufferedResponseWriter {
+	return &bufferedResponseWriter{header: make(http.Header)}
+}
+
+// Header implements http.ResponseWriter
+func (w *bufferedResponseWriter) Header() http.Header {
+	return w.header
+}
+
+// WriteHeader implements http.ResponseWriter
+func (w *bufferedResponseWriter) WriteHeader(statusCode int) {
+	if w.statusCode == 0 {
+		w.statusCode = statusCode
+	}
+}
+
+// Write implements http.ResponseWriter
+func (w *bufferedResponseWriter) Write(b []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.body.Write(b)
+}
+
+// serve proxies the request with the proxy into the buffered response, returning false if the response was aborted.
+// The reverse proxy aborts by panicking with http.ErrAbortHandler when the request is cancelled or the backend fails
+// while the response is being copied, which would take down the service when proxying in a goroutine, so the panic
+// is recovered and the partial response is replaced with a 502. Any other panic is a bug and is not recovered.
+func (w *bufferedResponseWriter) serve(proxy http.Handler, r *http.Request) (completed bool) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			if recovered != http.ErrAbortHandler {
+				panic(recovered)
+			}
+			w.header = make(http.Header)
+			w.statusCode = http.StatusBadGateway
+			w.body.Reset()
+			completed = false
+		}
+	}()
+
+	proxy.ServeHTTP(w, r)
+	return true
+}
+
+// writeTo writes the buffered response to dst
+func (w *bufferedResponseWriter) writeTo(dst http.ResponseWriter) {
+	for key, values := range w.header {
+		for _, value := range values {
+			dst.Header().Add(key, value)
+		}
+	}
+
+	statusCode := w.statusCode
+	if statusCode == 0 {
+		statusCode = http.StatusOK
+	}
+	dst.WriteHeader(statusCode)
+	dst.Write(w.body.Bytes())
+}
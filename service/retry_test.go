@@ -0,0 +1,178 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/kava-labs/kava-proxy-service/config"
+	"github.com/kava-labs/kava-proxy-service/logging"
+	"github.com/stretchr/testify/require"
+)
+
+var testRetryPolicy = retryPolicy{
+	enabled:    true,
+	maxRetries: 2,
+	backoff:    time.Millisecond,
+}
+
+// newStatusBackend creates a test backend server that responds to every request with the status code
+func newStatusBackend(t *testing.T, statusCode int) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(statusCode)
+		w.Write([]byte(fmt.Sprintf(`{"jsonrpc":"2.0","id":1,"result":"%d"}`, statusCode)))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// newTestHostProxies creates proxies for evm.kava.io that round robin between the servers
+func newTestHostProxies(t *testing.T, servers ...*httptest.Server) (HostProxies, *logging.ServiceLogger) {
+	logger, err := logging.New("ERROR")
+	require.NoError(t, err)
+
+	backendURLs := make([]url.URL, 0, len(servers))
+	for _, server := range servers {
+		backendURL, err := url.Parse(server.URL)
+		require.NoError(t, err)
+		backendURLs = append(backendURLs, *backendURL)
+	}
+	proxies := newHostProxies(
+		ResponseBackendDefault,
+		map[string][]url.URL{"evm.kava.io": backendURLs},
+		nil,
+		config.BackendPoolStrategyRoundRobin,
+		newBackendRegistry(config.Config{}, &logger),
+		&logger,
+	)
+
+	return proxies, &logger
+}
+
+// proxyTestRequest proxies an eth_blockNumber request for evm.kava.io
+// to the round robin pool of the servers, retrying according to the policy
+func proxyTestRequest(t *testing.T, policy retryPolicy, servers ...*httptest.Server) (*httptest.ResponseRecorder, ProxyMetadata) {
+	proxies, logger := newTestHostProxies(t, servers...)
+
+	body := []byte(`{"jsonrpc":"2.0","id":1,"method":"eth_blockNumber","params":[]}`)
+	r := httptest.NewRequest(http.MethodPost, "http://evm.kava.io", bytes.NewReader(body))
+	// as for requests received by the service, so that the reverse proxy aborts failed responses
+	r = r.WithContext(context.WithValue(r.Context(), http.ServerContextKey, &http.Server{}))
+	proxy, metadata, found := proxies.ProxyForRequest(r)
+	require.True(t, found)
+
+	w := httptest.NewRecorder()
+	metadata = proxyWithRetries(w, r, proxies, proxy, metadata, policy, newProxyAttempt(body), logger)
+
+	return w, metadata
+}
+
+func TestUnitTestProxyWithRetries_RetriesAgainstAnotherBackend(t *testing.T) {
+	failing := newStatusBackend(t, http.StatusServiceUnavailable)
+	healthy := newStatusBackend(t, http.StatusOK)
+
+	w, metadata := proxyTestRequest(t, testRetryPolicy, failing, healthy)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, 1, metadata.Retries)
+	require.Equal(t, healthy.URL, metadata.BackendRoute.String())
+}
+
+func TestUnitTestProxyWithRetries_RetriesUnreachableBackend(t *testing.T) {
+	unreachable := newStatusBackend(t, http.StatusOK)
+	unreachable.Close()
+	healthy := newStatusBackend(t, http.StatusOK)
+
+	w, metadata := proxyTestRequest(t, testRetryPolicy, unreachable, healthy)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, 1, metadata.Retries)
+	require.Equal(t, healthy.URL, metadata.BackendRoute.String())
+}
+
+func TestUnitTestProxyWithRetries_RetriesAbortedResponse(t *testing.T) {
+	aborting := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"jsonrpc":"2.0",`))
+		w.(http.Flusher).Flush()
+		// the connection is closed before the response is complete
+		panic(http.ErrAbortHandler)
+	}))
+	t.Cleanup(aborting.Close)
+	healthy := newStatusBackend(t, http.StatusOK)
+
+	w, metadata := proxyTestRequest(t, testRetryPolicy, aborting, healthy)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, 1, metadata.Retries)
+	require.Equal(t, healthy.URL, metadata.BackendRoute.String())
+}
+
+func TestUnitTestBufferedResponseWriter_OnlyRecoversAbortedResponses(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "http://evm.kava.io", nil)
+
+	response := newBufferedResponseWriter()
+	completed := response.serve(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"jsonrpc":"2.0",`))
+		panic(http.ErrAbortHandler)
+	}), r)
+	require.False(t, completed)
+	require.Equal(t, http.StatusBadGateway, response.statusCode)
+	require.Zero(t, response.body.Len())
+
+	require.PanicsWithValue(t, "bug", func() {
+		newBufferedResponseWriter().serve(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			panic("bug")
+		}), r)
+	})
+}
+
+func TestUnitTestProxyWithRetries_StopsWhenRetryBudgetIsSpent(t *testing.T) {
+	servers := make([]*httptest.Server, 0, testRetryPolicy.maxRetries+2)
+	for i := 0; i < testRetryPolicy.maxRetries+2; i++ {
+		servers = append(servers, newStatusBackend(t, http.StatusServiceUnavailable))
+	}
+
+	w, metadata := proxyTestRequest(t, testRetryPolicy, servers...)
+
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+	require.Equal(t, testRetryPolicy.maxRetries, metadata.Retries)
+}
+
+func TestUnitTestProxyWithRetries_NeverRetriesSameBackend(t *testing.T) {
+	failing := newStatusBackend(t, http.StatusServiceUnavailable)
+
+	w, metadata := proxyTestRequest(t, testRetryPolicy, failing)
+
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+	require.Equal(t, 0, metadata.Retries)
+}
+
+func TestUnitTestProxyWithRetries_DoesNotRetryOtherErrors(t *testing.T) {
+	failing := newStatusBackend(t, http.StatusInternalServerError)
+	healthy := newStatusBackend(t, http.StatusOK)
+
+	w, metadata := proxyTestRequest(t, testRetryPolicy, failing, healthy)
+
+	require.Equal(t, http.StatusInternalServerError, w.Code)
+	require.Equal(t, 0, metadata.Retries)
+}
+
+func TestUnitTestRetryPolicy_AppliesTo(t *testing.T) {
+	require.True(t, testRetryPolicy.appliesTo("eth_getBalance"))
+	require.False(t, testRetryPolicy.appliesTo("eth_sendRawTransaction"))
+	require.False(t, testRetryPolicy.appliesTo(""))
+	require.False(t, retryPolicy{}.appliesTo("eth_getBalance"))
+}
+
+func TestUnitTestRetryPolicy_Delay(t *testing.T) {
+	policy := retryPolicy{backoff: 10 * time.Millisecond}
+
+	require.Equal(t, 10*time.Millisecond, policy.delay(1))
+	require.Equal(t, 20*time.Millisecond, policy.delay(2))
+	require.Equal(t, 40*time.Millisecond, policy.delay(3))
+}
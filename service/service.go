@@ -26,7 +26,10 @@ type ProxyService struct {
 	Database  database.MetricsDatabase
 	Cache     *cachemdw.ServiceCache
 	httpProxy *http.Server
-	evmClient *ethclient.Client
+	// nil when the admin api is disabled
+	adminServer *http.Server
+	evmClient   *ethclient.Client
+	proxies     Proxies
 	*logging.ServiceLogger
 }
 
@@ -56,10 +59,11 @@ func New(ctx context.Context, config config.Config, serviceLogger *logging.Servi
 	}
 
 	// create cache client
-	serviceCache, err := createServiceCache(ctx, config, serviceLogger, evmClient)
+	redisCache, err := createRedisCache(config, serviceLogger)
 	if err != nil {
 		return ProxyService{}, err
 	}
+	serviceCache := createServiceCache(config, serviceLogger, redisCache, evmClient)
 
 	// create an http router for registering handlers for a given route
 	mux := http.NewServeMux()
@@ -78,11 +82,81 @@ func New(ctx context.Context, config config.Config, serviceLogger *logging.Servi
 	//   - response is present in context
 	cacheAfterProxyMiddleware := serviceCache.CachingMiddleware(afterProxyFinalizer)
 
+	// Proxies decide which backend a request is forwarded to
+	proxies := NewReloadableProxies(config, evmClient, serviceLogger)
+
+	// RoutingConfigReloader reloads the routing configuration of the proxies
+	// on SIGHUP, or when the config file changes.
+	// the environment can't change while the service runs, so without
+	// a config file there is nothing to reload the routing configuration from
+	if routingConfigSources := routingConfigSources(config); routingConfigSources.HasConfigFile() {
+		routingConfigReloader := NewRoutingConfigReloader(
+			proxies,
+			routingConfigSources,
+			config.ProxyRoutingConfigReloadInterval,
+			serviceLogger,
+		)
+		go routingConfigReloader.Run(ctx)
+	} else {
+		serviceLogger.Info().Msg("routing config reload unavailable, PROXY_CONFIG_FILE is not set")
+	}
+
+	// BackendHealthChecker probes every backend in the background
+	// so that requests are not routed to backends that are down
+	if config.ProxyBackendHealthCheckEnabled {
+		healthChecker := NewBackendHealthChecker(BackendHealthCheckerConfig{
+			Interval:         config.ProxyBackendHealthCheckInterval,
+			Timeout:          config.ProxyBackendHealthCheckTimeout,
+			Method:           config.ProxyBackendHealthCheckMethod,
+			FailureThreshold: config.ProxyBackendHealthCheckFailureThreshold,
+		}, proxies, serviceLogger)
+
+		go healthChecker.Run(ctx)
+	}
+
+	// BackendHeadTracker polls the latest block of every backend in the background
+	// so that requests for the latest state of the chain are not routed to backends that are behind
+	if config.ProxyBackendHeadTrackingEnabled {
+		headTracker := NewBackendHeadTracker(BackendHeadTrackerConfig{
+			Interval:     config.ProxyBackendHeadTrackingInterval,
+			Timeout:      config.ProxyBackendHeadTrackingTimeout,
+			MaxLagBlocks: uint64(config.ProxyBackendHeadTrackingMaxLagBlocks),
+		}, proxies, serviceLogger)
+
+		go headTracker.Run(ctx)
+	}
+
+	// StickyFilterRouter remembers which backend installed each filter
+	// so that requests for the filter are routed to the same backend
+	var stickyFilters *stickyFilterRouter
+	if config.ProxyStickyFilterRoutingEnabled {
+		stickyFilters = newStickyFilterRouter(redisCache, config.CachePrefix, config.ProxyStickyFilterRoutingTTL, serviceLogger)
+	}
+
+	// AdminServer serves the admin api for taking backends out of rotation,
+	// on its own port so that it is never exposed alongside the public api
+	var adminServer *http.Server
+	if config.ProxyAdminAPIEnabled {
+		var sharedStates cache.Cache
+		if config.ProxyAdminAPISharedStateEnabled {
+			sharedStates = redisCache
+		}
+		adminStates := NewBackendAdminStates(proxies, sharedStates, config.CachePrefix, serviceLogger)
+		go adminStates.Run(ctx, config.ProxyAdminAPISharedStateSyncInterval)
+
+		adminServer = &http.Server{
+			Addr:         fmt.Sprintf(":%s", config.ProxyAdminAPIPort),
+			Handler:      newAdminHandler(adminStates, config.ProxyAdminAPIToken, serviceLogger),
+			WriteTimeout: time.Duration(config.HTTPWriteTimeoutSeconds) * time.Second,
+			ReadTimeout:  time.Duration(config.HTTPReadTimeoutSeconds) * time.Second,
+		}
+	}
+
 	// ProxyRequestMiddleware responds to the client with
 	// - cached data if present in the context
 	// - a forwarded request to the appropriate backend
 	// Backend is decided by the Proxies configuration for a particular host.
-	proxyMiddleware := createProxyRequestMiddleware(cacheAfterProxyMiddleware, config, serviceLogger, []RequestInterceptor{}, []RequestInterceptor{})
+	proxyMiddleware := createProxyRequestMiddleware(cacheAfterProxyMiddleware, config, proxies, stickyFilters, serviceLogger, []RequestInterceptor{}, []RequestInterceptor{})
 
 	// IsCachedMiddleware works in the following way:
 	// - tries to get response from the cache
@@ -106,6 +180,7 @@ func New(ctx context.Context, config config.Config, serviceLogger *logging.Servi
 	// If successful, the decoded request is put into the request context:
 	// - if decoded as a single EVM request: it forwards it to the single request middleware sequence
 	// - if decoded as a batch EVM request: it forwards it to the batchProcessingMiddleware
+	// - if decoded as a Tendermint RPC (JSON-RPC or URI) or Cosmos REST request: it forwards it to the single request middleware sequence
 	// - if fails to decode: it passes to single request middleware sequence which will proxy the request
 	// When requests fail to decode, no context value is set.
 	decodeRequestMiddleware := createDecodeRequestMiddleware(cacheMiddleware, batchProcessingMiddleware, serviceLogger)
@@ -118,8 +193,23 @@ func New(ctx context.Context, config config.Config, serviceLogger *logging.Servi
 	// to determine if the service is ready to receive requests
 	mux.HandleFunc("/servicecheck", createServicecheckHandler(&service))
 
+	// WebsocketMiddleware upgrades websocket connections, forwarding subscription calls
+	// to a backend websocket and every other call through the middleware chain over http
+	// so that calls made over websockets are cached & metriced like any other request.
+	// Requests that are not websocket upgrades are passed to the middleware chain unchanged.
+	// SubscriptionHub shares a single upstream subscription between every client
+	// of a host subscribing to new heads or logs with the same params.
+	rootHandler := decodeRequestMiddleware
+	if config.ProxyWebsocketEnabled {
+		var subscriptionHub *subscriptionHub
+		if config.ProxyWebsocketSharedSubscriptionsEnabled {
+			subscriptionHub = newSubscriptionHub(proxies, serviceLogger)
+		}
+		rootHandler = createWebsocketMiddleware(decodeRequestMiddleware, proxies, subscriptionHub, serviceLogger)
+	}
+
 	// register middleware chain as the default handler for any request to the proxy service
-	mux.HandleFunc("/", decodeRequestMiddleware)
+	mux.HandleFunc("/", rootHandler)
 
 	// create an http server for the caller to start on demand with a call to ProxyService.Run()
 	server := &http.Server{
@@ -136,12 +226,19 @@ func New(ctx context.Context, config config.Config, serviceLogger *logging.Servi
 	// partitioning
 	mux.HandleFunc("/status/database", createDatabaseStatusHandler(&service, db))
 
+	// register backends status handler
+	// for responding to requests for the health
+	// and head height of each backend
+	mux.HandleFunc(BackendsStatusPath, createBackendsStatusHandler(&service))
+
 	service = ProxyService{
 		httpProxy:     server,
+		adminServer:   adminServer,
 		ServiceLogger: serviceLogger,
 		Database:      db,
 		Cache:         serviceCache,
 		evmClient:     evmClient,
+		proxies:       proxies,
 	}
 
 	return service, nil
@@ -219,12 +316,9 @@ func createDatabaseClient(ctx context.Context, config config.Config, logger *log
 	return &serviceDatabase, err
 }
 
-func createServiceCache(
-	ctx context.Context,
-	config config.Config,
-	logger *logging.ServiceLogger,
-	evmclient *ethclient.Client,
-) (*cachemdw.ServiceCache, error) {
+// createRedisCache creates a client for the redis cache
+// using the specified config, returning the client and error (if any)
+func createRedisCache(config config.Config, logger *logging.ServiceLogger) (*cache.RedisCache, error) {
 	cfg := cache.RedisConfig{
 		Address:  config.RedisEndpointURL,
 		Password: config.RedisPassword,
@@ -239,6 +333,15 @@ func createServiceCache(
 		return nil, err
 	}
 
+	return redisCache, nil
+}
+
+func createServiceCache(
+	config config.Config,
+	logger *logging.ServiceLogger,
+	redisCache cache.Cache,
+	evmclient *ethclient.Client,
+) *cachemdw.ServiceCache {
 	cacheConfig := cachemdw.Config{
 		CacheMethodHasBlockNumberParamTTL: config.CacheMethodHasBlockNumberParamTTL,
 		CacheMethodHasBlockHashParamTTL:   config.CacheMethodHasBlockHashParamTTL,
@@ -259,11 +362,19 @@ func createServiceCache(
 		logger,
 	)
 
-	return serviceCache, nil
+	return serviceCache
 }
 
 // Run runs the proxy service, returning error (if any) in the event
 // the proxy service stops
 func (p *ProxyService) Run() error {
+	if p.adminServer != nil {
+		go func() {
+			if err := p.adminServer.ListenAndServe(); err != nil {
+				p.Error().Msg(fmt.Sprintf("admin api stopped with error %s", err))
+			}
+		}()
+	}
+
 	return p.httpProxy.ListenAndServe()
 }
@@ -0,0 +1,202 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"reflect"
+	"time"
+
+	"github.com/kava-labs/kava-proxy-service/config"
+	"github.com/kava-labs/kava-proxy-service/decode"
+	"github.com/kava-labs/kava-proxy-service/logging"
+	"github.com/kava-labs/kava-proxy-service/service/cachemdw"
+)
+
+// maximum number of bytes of each response included in the log of a mismatch
+const shadowMismatchLogMaxBytes = 1024
+
+// trafficShadower mirrors a sample of the requests for hosts with a shadow backend to it,
+// comparing the response of the shadow backend with the response of the backend that served the client.
+// Mirrored requests never affect the response to the client.
+type trafficShadower struct {
+	backendForHost map[string]url.URL
+	sampleRate     float64
+	timeout        time.Duration
+	// limits the number of mirrored requests in flight,
+	// requests are not mirrored while it is full
+	inFlight chan struct{}
+	client   *http.Client
+	*logging.ServiceLogger
+}
+
+// newTrafficShadower creates the trafficShadower defined by the service config,
+// returning nil if shadowing is disabled
+func newTrafficShadower(config config.Config, serviceLogger *logging.ServiceLogger) *trafficShadower {
+	if !config.ProxyShadowEnabled {
+		return nil
+	}
+
+	backendForHost := make(map[string]url.URL, len(config.ProxyShadowBackendHostURLMap))
+	for host, backendURLs := range config.ProxyShadowBackendHostURLMap {
+		backendForHost[host] = backendURLs[0]
+	}
+
+	return &trafficShadower{
+		backendForHost: backendForHost,
+		sampleRate:     config.ProxyShadowSampleRate,
+		timeout:        config.ProxyShadowTimeout,
+		inFlight:       make(chan struct{}, config.ProxyShadowMaxInFlight),
+		client:         &http.Client{},
+		ServiceLogger:  serviceLogger,
+	}
+}
+
+// appliesTo returns true when the request for the JSON-RPC method to the host is sampled to be mirrored.
+// Only methods without side effects are mirrored, as the request is served by both backends.
+func (ts *trafficShadower) appliesTo(host string, method string) bool {
+	if ts == nil || !decode.MethodIsSideEffectFree(method) {
+		return false
+	}
+	if _, found := ts.backendForHost[host]; !found {
+		return false
+	}
+	return rand.Float64() < ts.sampleRate
+}
+
+// mirror asynchronously sends the request to the shadow backend of the host and compares
+// its response with the response of the primary backend. Requests the primary backend failed
+// to serve are not mirrored.
+func (ts *trafficShadower) mirror(host string, method string, requestBody []byte, primary ProxyMetadata, primaryStatus int, primaryBody []byte) {
+	if primaryStatus != http.StatusOK {
+		return
+	}
+
+	select {
+	case ts.inFlight <- struct{}{}:
+	default:
+		ts.Debug().Str("host", host).Str("method", method).Msg("too many shadow requests in flight, not mirroring request")
+		return
+	}
+
+	// the buffer of the primary response is reused once the request completes
+	primaryBody = bytes.Clone(primaryBody)
+
+	go func() {
+		defer func() { <-ts.inFlight }()
+		ts.compare(host, method, requestBody, primary, primaryBody)
+	}()
+}
+
+// compare sends the request to the shadow backend of the host,
+// logging the responses of both backends if they do not match
+func (ts *trafficShadower) compare(host string, method string, requestBody []byte, primary ProxyMetadata, primaryBody []byte) {
+	shadowURL := ts.backendForHost[host]
+
+	shadowBody, err := ts.proxy(shadowURL, requestBody)
+	if err != nil {
+		ts.Warn().Err(err).
+			Str("host", host).
+			Str("method", method).
+			Str("shadow_backend", shadowURL.String()).
+			Msg("shadow request failed")
+		return
+	}
+
+	if reason, match := compareShadowResponse(primaryBody, shadowBody); !match {
+		ts.Warn().
+			Str("host", host).
+			Str("method", method).
+			Str("primary_backend", primary.BackendRoute.String()).
+			Str("shadow_backend", shadowURL.String()).
+			Str("reason", reason).
+			Str("request", truncateForLog(requestBody)).
+			Str("primary_response", truncateForLog(primaryBody)).
+			Str("shadow_response", truncateForLog(shadowBody)).
+			Msg("shadow response mismatch")
+		return
+	}
+
+	ts.Trace().Str("host", host).Str("method", method).Msg("shadow response matched")
+}
+
+// proxy sends the request body to the shadow backend, returning the body of its response and error (if any)
+func (ts *trafficShadower) proxy(shadowURL url.URL, requestBody []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), ts.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, shadowURL.String(), bytes.NewReader(requestBody))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := ts.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("shadow backend responded with status %d: %s", res.StatusCode, truncateForLog(body))
+	}
+
+	return body, nil
+}
+
+// compareShadowResponse compares the JSON-RPC responses of the primary & shadow backends,
+// returning the reason they do not match and whether they match. Results are compared
+// by their decoded value, and errors by their code as messages vary between node versions.
+func compareShadowResponse(primaryBody []byte, shadowBody []byte) (string, bool) {
+	primary, err := cachemdw.UnmarshalJsonRpcResponse(primaryBody)
+	if err != nil {
+		// only JSON-RPC responses are compared
+		return "", true
+	}
+	shadow, err := cachemdw.UnmarshalJsonRpcResponse(shadowBody)
+	if err != nil {
+		return fmt.Sprintf("invalid shadow response: %s", err), false
+	}
+
+	switch {
+	case primary.JsonRpcError != nil && shadow.JsonRpcError != nil:
+		if primary.JsonRpcError.Code != shadow.JsonRpcError.Code {
+			return "error codes differ", false
+		}
+		return "", true
+	case primary.JsonRpcError != nil:
+		return "only primary responded with an error", false
+	case shadow.JsonRpcError != nil:
+		return "only shadow responded with an error", false
+	}
+
+	var primaryResult, shadowResult interface{}
+	if err := json.Unmarshal(primary.Result, &primaryResult); err != nil && len(primary.Result) > 0 {
+		return "", true
+	}
+	if err := json.Unmarshal(shadow.Result, &shadowResult); err != nil && len(shadow.Result) > 0 {
+		return fmt.Sprintf("invalid shadow result: %s", err), false
+	}
+	if !reflect.DeepEqual(primaryResult, shadowResult) {
+		return "results differ", false
+	}
+
+	return "", true
+}
+
+// truncateForLog returns the body as a string of at most shadowMismatchLogMaxBytes bytes
+func truncateForLog(body []byte) string {
+	if len(body) > shadowMismatchLogMaxBytes {
+		return string(body[:shadowMismatchLogMaxBytes]) + "..."
+	}
+	return string(body)
+}
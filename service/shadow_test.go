@@ -0,0 +1,107 @@
+package service
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/kava-labs/kava-proxy-service/config"
+	"github.com/kava-labs/kava-proxy-service/logging"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnitTestCompareShadowResponse(t *testing.T) {
+	testCases := []struct {
+		name    string
+		primary string
+		shadow  string
+		match   bool
+	}{
+		{
+			name:    "same result with different formatting",
+			primary: `{"jsonrpc":"2.0","id":1,"result":{"number":"0x1","hash":"0xabc"}}`,
+			shadow:  `{"id":1,"jsonrpc":"2.0","result":{"hash":"0xabc", "number":"0x1"}}`,
+			match:   true,
+		},
+		{
+			name:    "different results",
+			primary: `{"jsonrpc":"2.0","id":1,"result":"0x1"}`,
+			shadow:  `{"jsonrpc":"2.0","id":1,"result":"0x2"}`,
+			match:   false,
+		},
+		{
+			name:    "errors with the same code",
+			primary: `{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":"header not found"}}`,
+			shadow:  `{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":"block not found"}}`,
+			match:   true,
+		},
+		{
+			name:    "only shadow errors",
+			primary: `{"jsonrpc":"2.0","id":1,"result":"0x1"}`,
+			shadow:  `{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":"missing trie node"}}`,
+			match:   false,
+		},
+		{
+			name:    "null result",
+			primary: `{"jsonrpc":"2.0","id":1,"result":null}`,
+			shadow:  `{"jsonrpc":"2.0","id":1,"result":{"number":"0x1"}}`,
+			match:   false,
+		},
+		{
+			name:    "invalid shadow response",
+			primary: `{"jsonrpc":"2.0","id":1,"result":"0x1"}`,
+			shadow:  `bad gateway`,
+			match:   false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, match := compareShadowResponse([]byte(tc.primary), []byte(tc.shadow))
+			require.Equal(t, tc.match, match)
+		})
+	}
+}
+
+func TestUnitTestTrafficShadower_MirrorsSampledRequests(t *testing.T) {
+	mirrored := make(chan []byte, 1)
+	shadow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mirrored <- body
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x2"}`))
+	}))
+	t.Cleanup(shadow.Close)
+	shadowURL, err := url.Parse(shadow.URL)
+	require.NoError(t, err)
+
+	logger, err := logging.New("ERROR")
+	require.NoError(t, err)
+	require.Nil(t, newTrafficShadower(config.Config{}, &logger))
+	shadower := newTrafficShadower(config.Config{
+		ProxyShadowEnabled:           true,
+		ProxyShadowBackendHostURLMap: map[string][]url.URL{"evm.kava.io": {*shadowURL}},
+		ProxyShadowSampleRate:        1,
+		ProxyShadowTimeout:           time.Second,
+		ProxyShadowMaxInFlight:       1,
+	}, &logger)
+
+	require.True(t, shadower.appliesTo("evm.kava.io", "eth_blockNumber"))
+	require.False(t, shadower.appliesTo("evm.kava.io", "eth_sendRawTransaction"))
+	require.False(t, shadower.appliesTo("evm.testnet.kava.io", "eth_blockNumber"))
+
+	// requests the primary backend failed to serve are not mirrored
+	request := []byte(`{"jsonrpc":"2.0","id":1,"method":"eth_blockNumber","params":[]}`)
+	shadower.mirror("evm.kava.io", "eth_blockNumber", request, ProxyMetadata{}, http.StatusBadGateway, nil)
+	shadower.mirror("evm.kava.io", "eth_blockNumber", request, ProxyMetadata{}, http.StatusOK, []byte(`{"jsonrpc":"2.0","id":1,"result":"0x1"}`))
+
+	select {
+	case body := <-mirrored:
+		require.Equal(t, request, body)
+	case <-time.After(5 * time.Second):
+		t.Fatal("request was not mirrored to the shadow backend")
+	}
+	require.Empty(t, mirrored)
+}
@@ -1,44 +1,62 @@
 package service
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"time"
 
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/lru"
 	"github.com/kava-labs/kava-proxy-service/config"
 	"github.com/kava-labs/kava-proxy-service/decode"
 	"github.com/kava-labs/kava-proxy-service/logging"
 )
 
 // PruningOrDefaultProxies routes traffic based on the host _and_ the height of the query.
-// If the height is "latest" (or equivalent), return Pruning node proxy host.
+// If the height is "latest" (or equivalent), or within the retention window of the host's
+// pruning nodes, return Pruning node proxy host.
 // Otherwise return default node proxy host.
 type PruningOrDefaultProxies struct {
 	*logging.ServiceLogger
 
 	pruningProxies HostProxies
 	defaultProxies HostProxies
+	// number of most recent blocks retained by the pruning nodes of each host
+	retentionWindowForHost map[string]uint64
 }
 
 var _ Proxies = PruningOrDefaultProxies{}
 
 // ProxyForRequest implements Proxies.
 // Decodes height of request
-// - routes to Pruning proxy if defined & height is "latest"
+// - routes to Pruning proxy if defined, available & height is "latest"
+// - routes to Pruning proxy if defined, available & height is within the pruning nodes' retention window
 // - otherwise routes to Default proxy
+// Requests falling back from a pruning backend that is missing their state are always routed to the Default proxy.
 func (hsp PruningOrDefaultProxies) ProxyForRequest(r *http.Request) (*httputil.ReverseProxy, ProxyMetadata, bool) {
-	_, _, found := hsp.pruningProxies.ProxyForRequest(r)
 	// if the host isn't in the pruning proxies, short circuit fallback to default
-	if !found {
+	if !hsp.pruningProxies.hasHost(r.Host) {
 		hsp.Trace().Msg(fmt.Sprintf("no pruning host backend configured for %s", r.Host))
 		return hsp.defaultProxies.ProxyForRequest(r)
 	}
 
+	if isMissingStateFallback(r) {
+		hsp.Trace().Msg("request is falling back from a pruning backend missing its state. routing to default proxy")
+		return hsp.defaultProxies.ProxyForRequest(r)
+	}
+
 	// parse the height of the request
 	req := r.Context().Value(DecodedRequestContextKey)
 	decodedReq, ok := (req).(*decode.EVMRPCRequestEnvelope)
 	if !ok {
+		// route Tendermint RPC & Cosmos REST requests by their height
+		if cosmosReq, ok := r.Context().Value(DecodedCosmosRequestContextKey).(*decode.CosmosRPCRequest); ok {
+			return hsp.proxyForCosmosRequest(r, cosmosReq)
+		}
 		hsp.Trace().Msg("PruningOrDefaultProxies failed to find & cast the decoded request envelope from the request context")
 		return hsp.defaultProxies.ProxyForRequest(r)
 	}
@@ -46,7 +64,7 @@ func (hsp PruningOrDefaultProxies) ProxyForRequest(r *http.Request) (*httputil.R
 	// some RPC methods can always be routed to the latest block
 	if decode.MethodRequiresNoHistory(decodedReq.Method) {
 		hsp.Trace().Msg(fmt.Sprintf("request method %s can always use latest block. routing to pruning proxy", decodedReq.Method))
-		return hsp.pruningProxies.ProxyForRequest(r)
+		return hsp.pruningProxyForRequest(r)
 	}
 
 	// short circuit if requesting a method that doesn't include block height number
@@ -66,24 +84,94 @@ func (hsp PruningOrDefaultProxies) ProxyForRequest(r *http.Request) (*httputil.R
 		return hsp.defaultProxies.ProxyForRequest(r)
 	}
 
+	return hsp.proxyForHeight(r, height)
+}
+
+// proxyForCosmosRequest routes Tendermint RPC & Cosmos REST requests like EVM requests:
+// - routes to Pruning proxy if the method requires no history
+// - routes to Pruning proxy if the height is "latest" or within the pruning nodes' retention window
+// - otherwise routes to Default proxy
+func (hsp PruningOrDefaultProxies) proxyForCosmosRequest(r *http.Request, cosmosReq *decode.CosmosRPCRequest) (*httputil.ReverseProxy, ProxyMetadata, bool) {
+	if cosmosReq.RequiresNoHistory() {
+		hsp.Trace().Msg(fmt.Sprintf("request method %s can always use latest block. routing to pruning proxy", cosmosReq.Method))
+		return hsp.pruningProxyForRequest(r)
+	}
+	if cosmosReq.Height == nil {
+		hsp.Trace().Msg(fmt.Sprintf("request does not include height (%s). routing to default proxy", cosmosReq.Method))
+		return hsp.defaultProxies.ProxyForRequest(r)
+	}
+	return hsp.proxyForHeight(r, *cosmosReq.Height)
+}
+
+// proxyForHeight routes requests for "latest" or heights within the retention window of the pruning nodes
+// to the pruning proxy, otherwise to the default proxy
+func (hsp PruningOrDefaultProxies) proxyForHeight(r *http.Request, height int64) (*httputil.ReverseProxy, ProxyMetadata, bool) {
 	// route "latest" to pruning proxy, otherwise route to default
 	if shouldRouteToPruning(height) {
 		hsp.Trace().Msg(fmt.Sprintf("request is for latest height (%d). routing to pruning proxy", height))
-		return hsp.pruningProxies.ProxyForRequest(r)
+		return hsp.pruningProxyForRequest(r)
+	}
+	if hsp.isWithinRetentionWindow(r.Host, height) {
+		hsp.Trace().Msg(fmt.Sprintf("request is for height (%d) retained by pruning nodes. routing to pruning proxy", height))
+		return hsp.pruningProxyForRequest(r)
 	}
 	hsp.Trace().Msg(fmt.Sprintf("request is for specific height (%d). routing to default proxy", height))
 	return hsp.defaultProxies.ProxyForRequest(r)
 }
 
+// isWithinRetentionWindow returns true when the height is one of the most recent blocks
+// retained by the pruning nodes of the host. Heights are never considered retained
+// while the head of the pruning nodes is unknown.
+func (hsp PruningOrDefaultProxies) isWithinRetentionWindow(host string, height int64) bool {
+	window, found := hsp.retentionWindowForHost[host]
+	if !found || height <= 0 {
+		return false
+	}
+
+	head := hsp.pruningProxies.headHeight(host)
+	if head == 0 || uint64(height) > head {
+		return false
+	}
+	return head-uint64(height) < window
+}
+
+// pruningProxyForRequest routes the request to the pruning proxy of the host
+// unless every pruning backend for the host is down, in which case it falls back
+// to the default proxy.
+func (hsp PruningOrDefaultProxies) pruningProxyForRequest(r *http.Request) (*httputil.ReverseProxy, ProxyMetadata, bool) {
+	if !hsp.pruningProxies.hasAvailableBackend(r) {
+		hsp.Debug().Msg(fmt.Sprintf("no pruning backend available for %s. routing to default proxy", r.Host))
+		return hsp.defaultProxies.ProxyForRequest(r)
+	}
+	return hsp.pruningProxies.ProxyForRequest(r)
+}
+
+// Backends implements Proxies.
+func (hsp PruningOrDefaultProxies) Backends() []*Backend {
+	return uniqueBackends(hsp.pruningProxies.Backends(), hsp.defaultProxies.Backends())
+}
+
 // newPruningOrDefaultProxies creates a new PruningOrDefaultProxies from the service config.
-func newPruningOrDefaultProxies(config config.Config, serviceLogger *logging.ServiceLogger) PruningOrDefaultProxies {
+func newPruningOrDefaultProxies(config config.Config, registry *backendRegistry, serviceLogger *logging.ServiceLogger) PruningOrDefaultProxies {
 	return PruningOrDefaultProxies{
-		ServiceLogger:  serviceLogger,
-		pruningProxies: newHostProxies(ResponseBackendPruning, config.ProxyPruningBackendHostURLMap, serviceLogger),
-		defaultProxies: newHostProxies(ResponseBackendDefault, config.ProxyBackendHostURLMapParsed, serviceLogger),
+		ServiceLogger:          serviceLogger,
+		pruningProxies:         newHostProxies(ResponseBackendPruning, config.ProxyPruningBackendHostURLMap, config.ProxyPruningBackendHostURLWeightMap, config.ProxyBackendPoolStrategy, registry, serviceLogger),
+		defaultProxies:         newHostProxies(ResponseBackendDefault, config.ProxyBackendHostURLMapParsed, config.ProxyBackendHostURLWeightMap, config.ProxyBackendPoolStrategy, registry, serviceLogger),
+		retentionWindowForHost: config.ProxyPruningBackendRetentionWindowMap,
 	}
 }
 
+const (
+	// number of block hashes whose height is remembered for routing requests for blocks by hash to shards
+	blockHashHeightCacheSize = 10000
+	// how long a block hash is looked up for before its request is routed to the default backends instead
+	blockHashLookupTimeout = 2 * time.Second
+	// how long requests for block hashes that failed to resolve are routed to the default backends
+	// without looking the hash up again, so that a request for an unknown hash and its retries,
+	// hedges & quorum reads only look it up once
+	blockHashLookupFailureTTL = 10 * time.Second
+)
+
 // lookup map for block tags that all represent "latest".
 // maps encoded block tag -> true if the block tag should route to pruning cluster.
 var blockTagEncodingsRoutedToLatest = map[int64]bool{
@@ -101,15 +189,21 @@ func shouldRouteToPruning(encodedHeight int64) bool {
 }
 
 // ShardProxies handles routing requests for specific heights to backends that contain the height.
-// The height is parsed out of requests that would route to the default backend of the underlying `defaultProxies`
-// If the height is contained by a backend in the host's IntervalURLMap, it is routed to that url.
+// The height is parsed out of requests that would route to the default backend of the underlying `defaultProxies`,
+// or for requests for a block hash, resolved from the hash of the block.
+// If the height is contained by a backend in the host's IntervalURLMap, it is routed to that url,
+// or the first of the redundant urls of the shard that is available.
 // Otherwise, it forwards the request via the wrapped defaultProxies.
 type ShardProxies struct {
 	*logging.ServiceLogger
 
 	defaultProxies Proxies
 	shardsByHost   map[string]config.IntervalURLMap
-	proxyByURL     map[*url.URL]*httputil.ReverseProxy
+	backendByURL   map[*url.URL]*Backend
+	blockHeights   *blockHeightsByHash
+	// whether requests for the heights of shards may fall back to the default proxy,
+	// ie. whether the default backends are archive nodes
+	defaultFallbackEnabled bool
 }
 
 var _ Proxies = ShardProxies{}
@@ -122,18 +216,42 @@ func (sp ShardProxies) ProxyForRequest(r *http.Request) (*httputil.ReverseProxy,
 		return sp.defaultProxies.ProxyForRequest(r)
 	}
 
-	// handle unsupported hosts or routing to pruning (if enabled)
+	// handle routing to pruning (if enabled)
 	proxy, metadata, found := sp.defaultProxies.ProxyForRequest(r)
-	if metadata.BackendName != ResponseBackendDefault || !found {
+	if metadata.BackendName != ResponseBackendDefault {
 		return proxy, metadata, found
 	}
+	// the default backend is only picked once per request, so that the pool strategy of the default proxies
+	// sees one pick per request, and is used whenever the request is not routed to a shard.
+	// requests for the heights of shards are still routed to the shards when the default pool
+	// has no backend the request can be routed to, ie. when its backends are drained or disabled
+	defaultRoute := defaultRoute{proxy: proxy, metadata: metadata, found: found}
 
 	// get decoded request
 	req := r.Context().Value(DecodedRequestContextKey)
 	decodedReq, ok := (req).(*decode.EVMRPCRequestEnvelope)
 	if !ok {
+		// route Tendermint RPC & Cosmos REST requests for a height to the shard containing it
+		if cosmosReq, ok := r.Context().Value(DecodedCosmosRequestContextKey).(*decode.CosmosRPCRequest); ok && cosmosReq.Height != nil {
+			return sp.proxyForParsedHeight(r, shardsForHost, *cosmosReq.Height, defaultRoute)
+		}
 		sp.Trace().Msg("PruningOrDefaultProxies failed to find & cast the decoded request envelope from the request context")
-		return sp.defaultProxies.ProxyForRequest(r)
+		return defaultRoute.route()
+	}
+
+	// route requests for a range of blocks to the shards containing the range
+	if decode.MethodHasBlockRangeParam(decodedReq.Method) {
+		return sp.blockRangeProxyForRequest(r, shardsForHost, decodedReq, defaultRoute)
+	}
+
+	// resolve the height of requests for a block hash
+	if decode.MethodHasBlockHashParam(decodedReq.Method) {
+		height, err := sp.blockHeights.heightForRequest(r.Context(), decodedReq)
+		if err != nil {
+			sp.Debug().Msg(fmt.Sprintf("failed to resolve block hash to height for %+v: %s", decodedReq, err))
+			return defaultRoute.route()
+		}
+		return sp.shardProxyForHeight(r, shardsForHost, height, defaultRoute)
 	}
 
 	// parse height from the request
@@ -145,9 +263,27 @@ func (sp ShardProxies) ProxyForRequest(r *http.Request) (*httputil.ReverseProxy,
 		if decodedReq.Method != "eth_call" && err != decode.ErrUncachaebleByBlockNumberEthRequest {
 			sp.Error().Msg(fmt.Sprintf("expected but failed to parse block number for %+v: %s", decodedReq, err))
 		}
-		return sp.defaultProxies.ProxyForRequest(r)
+		return defaultRoute.route()
 	}
 
+	return sp.proxyForParsedHeight(r, shardsForHost, parsedHeight, defaultRoute)
+}
+
+// defaultRoute is the proxy & metadata the default proxies routed a request to
+type defaultRoute struct {
+	proxy    *httputil.ReverseProxy
+	metadata ProxyMetadata
+	found    bool
+}
+
+// route returns the default route as returned by Proxies.ProxyForRequest
+func (dr defaultRoute) route() (*httputil.ReverseProxy, ProxyMetadata, bool) {
+	return dr.proxy, dr.metadata, dr.found
+}
+
+// proxyForParsedHeight routes the request for the parsed height, which may be an encoded block tag,
+// to the shard of the host that contains the height
+func (sp ShardProxies) proxyForParsedHeight(r *http.Request, shardsForHost config.IntervalURLMap, parsedHeight int64, defaultRoute defaultRoute) (*httputil.ReverseProxy, ProxyMetadata, bool) {
 	// handle encoded block numbers
 	height := parsedHeight
 	if height == decode.BlockTagToNumberCodec[decode.BlockTagEarliest] {
@@ -157,37 +293,152 @@ func (sp ShardProxies) ProxyForRequest(r *http.Request) (*httputil.ReverseProxy,
 		// route all other encoded tags to default proxy.
 		// in practice, this is unreachable because they will be handled by the pruning Proxies
 		// if shard routing is enabled without PruningOrDefaultProxies, this handles all special block tags
-		return sp.defaultProxies.ProxyForRequest(r)
+		return defaultRoute.route()
 	}
 
+	return sp.shardProxyForHeight(r, shardsForHost, height, defaultRoute)
+}
+
+// shardProxyForHeight routes the request to the shard of the host that contains the height.
+// Heights not contained by any shard are routed to the default proxy, as are heights whose
+// shard has no backend the request can be routed to when falling back to the default proxy is enabled.
+func (sp ShardProxies) shardProxyForHeight(r *http.Request, shardsForHost config.IntervalURLMap, height int64, defaultRoute defaultRoute) (*httputil.ReverseProxy, ProxyMetadata, bool) {
 	// look for shard including height
-	url, shardHeight, found := shardsForHost.Lookup(uint64(height))
+	urls, shardHeight, found := shardsForHost.LookupAll(uint64(height))
 	if !found {
-		return sp.defaultProxies.ProxyForRequest(r)
+		return defaultRoute.route()
+	}
+
+	// shard exists, but fall back to the default proxy if all of its backends are down
+	// or the request is being retried away from them
+	backend, url, fallBack := sp.shardBackendForRequest(r, urls)
+	if fallBack {
+		sp.Debug().Msg(fmt.Sprintf("every backend of shard for height %d is unavailable. routing to default proxy", height))
+		return defaultRoute.fallback()
+	}
+	if backend == nil {
+		return nil, ProxyMetadata{BackendName: ResponseBackendShard}, false
 	}
 
 	// shard exists, route to it!
-	metadata = ProxyMetadata{
+	metadata := ProxyMetadata{
 		BackendName:    ResponseBackendShard,
 		BackendRoute:   *url,
 		ShardEndHeight: shardHeight,
+		backend:        backend,
+	}
+	return backend.Proxy(), metadata, true
+}
+
+// shardBackendForRequest returns the first of the redundant backends of a shard that is available
+// and that the request is not being retried away from. When there is no such backend, fallBack
+// is true if the request should be routed to the default proxy instead, otherwise the first backend that is
+// enabled and that the request is not being retried away from is returned even if it is unhealthy, or nil if there is none.
+func (sp ShardProxies) shardBackendForRequest(r *http.Request, urls []*url.URL) (backend *Backend, backendURL *url.URL, fallBack bool) {
+	excluded := excludedBackends(r)
+	for _, url := range urls {
+		if candidate := sp.backendByURL[url]; candidate.Available() && !excluded[candidate] {
+			return candidate, url, false
+		}
+	}
+
+	// the default backends can only serve requests for the heights of shards when they are archive nodes
+	if sp.defaultFallbackEnabled {
+		return nil, nil, true
+	}
+	for _, url := range urls {
+		if candidate := sp.backendByURL[url]; candidate.Enabled() && !excluded[candidate] {
+			return candidate, url, false
+		}
+	}
+	return nil, nil, false
+}
+
+// fallback returns the default route for a request for the height of a shard that has no backend
+// the request can be routed to
+func (dr defaultRoute) fallback() (*httputil.ReverseProxy, ProxyMetadata, bool) {
+	metadata := dr.metadata
+	metadata.BackendName = ResponseBackendShardFallback
+	return dr.proxy, metadata, dr.found
+}
+
+// Backends implements Proxies.
+func (sp ShardProxies) Backends() []*Backend {
+	shardBackends := make([]*Backend, 0, len(sp.backendByURL))
+	for _, backend := range sp.backendByURL {
+		shardBackends = append(shardBackends, backend)
 	}
-	return sp.proxyByURL[url], metadata, true
+	return uniqueBackends(shardBackends, sp.defaultProxies.Backends())
 }
 
-func newShardProxies(shardHostMap map[string]config.IntervalURLMap, beyondShardProxies Proxies, serviceLogger *logging.ServiceLogger) ShardProxies {
-	// create reverse proxy for each backend url
-	proxyByURL := make(map[*url.URL]*httputil.ReverseProxy)
+func newShardProxies(shardHostMap map[string]config.IntervalURLMap, beyondShardProxies Proxies, defaultFallbackEnabled bool, registry *backendRegistry, blockGetter decode.EVMBlockGetter, serviceLogger *logging.ServiceLogger) ShardProxies {
+	// find or create the backend for each shard url
+	backendByURL := make(map[*url.URL]*Backend)
 	for _, shards := range shardHostMap {
-		for _, route := range shards.UrlByEndHeight {
-			proxyByURL[route] = httputil.NewSingleHostReverseProxy(route)
+		for _, routes := range shards.URLsByEndHeight {
+			for _, route := range routes {
+				backendByURL[route] = registry.getOrCreate(*route)
+			}
 		}
 	}
 
 	return ShardProxies{
-		ServiceLogger:  serviceLogger,
-		shardsByHost:   shardHostMap,
-		defaultProxies: beyondShardProxies,
-		proxyByURL:     proxyByURL,
+		ServiceLogger:          serviceLogger,
+		shardsByHost:           shardHostMap,
+		defaultProxies:         beyondShardProxies,
+		backendByURL:           backendByURL,
+		blockHeights:           newBlockHeightsByHash(blockGetter, blockHashHeightCacheSize),
+		defaultFallbackEnabled: defaultFallbackEnabled,
+	}
+}
+
+// blockHeightsByHash resolves block hashes to the height of the block,
+// remembering the heights of the most recently resolved hashes and the hashes that recently failed to resolve
+type blockHeightsByHash struct {
+	blockGetter decode.EVMBlockGetter
+	heights     *lru.Cache[common.Hash, int64]
+	// when block hashes that failed to resolve may be looked up again
+	failedUntil *lru.Cache[common.Hash, time.Time]
+}
+
+func newBlockHeightsByHash(blockGetter decode.EVMBlockGetter, size int) *blockHeightsByHash {
+	return &blockHeightsByHash{
+		blockGetter: blockGetter,
+		heights:     lru.NewCache[common.Hash, int64](size),
+		failedUntil: lru.NewCache[common.Hash, time.Time](size),
+	}
+}
+
+// heightForRequest returns the height of the block whose hash is in the params of the request
+func (bh *blockHeightsByHash) heightForRequest(ctx context.Context, decodedReq *decode.EVMRPCRequestEnvelope) (int64, error) {
+	blockHash, err := decode.ParseBlockHashFromParams(decodedReq.Method, decodedReq.Params)
+	if err != nil {
+		return 0, err
 	}
+
+	if height, found := bh.heights.Get(blockHash); found {
+		return height, nil
+	}
+
+	if bh.blockGetter == nil {
+		return 0, errors.New("no client configured for looking up blocks by hash")
+	}
+	if failedUntil, found := bh.failedUntil.Get(blockHash); found && time.Now().Before(failedUntil) {
+		return 0, fmt.Errorf("block hash %v recently failed to resolve", blockHash)
+	}
+
+	lookupCtx, cancel := context.WithTimeout(ctx, blockHashLookupTimeout)
+	defer cancel()
+	header, err := bh.blockGetter.HeaderByHash(lookupCtx, blockHash)
+	if err != nil {
+		// the request being cancelled says nothing about the block hash
+		if ctx.Err() == nil {
+			bh.failedUntil.Add(blockHash, time.Now().Add(blockHashLookupFailureTTL))
+		}
+		return 0, fmt.Errorf("can't get header by %v block hash: %w", blockHash, err)
+	}
+
+	height := header.Number.Int64()
+	bh.heights.Add(blockHash, height)
+	return height, nil
 }
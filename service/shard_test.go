@@ -1,9 +1,19 @@
 package service_test
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	ethctypes "github.com/ethereum/go-ethereum/core/types"
 	"github.com/kava-labs/kava-proxy-service/decode"
 	"github.com/kava-labs/kava-proxy-service/service"
 	"github.com/stretchr/testify/require"
@@ -17,7 +27,7 @@ func TestUnitTest_PruningOrDefaultProxies(t *testing.T) {
 		fmt.Sprintf("archive.kava.io>%s", pruningBackend),
 		"",
 	)
-	proxies := service.NewProxies(config, dummyLogger)
+	proxies := service.NewProxies(config, nil, dummyLogger)
 	require.IsType(t, service.PruningOrDefaultProxies{}, proxies)
 
 	testCases := []struct {
@@ -175,7 +185,7 @@ func TestUnitTest_ShardProxies(t *testing.T) {
 		fmt.Sprintf("archive.kava.io>%s", pruningBackend),
 		fmt.Sprintf("archive.kava.io>10|%s|20|%s", shard1Backend, shard2Backend),
 	)
-	proxies := service.NewProxies(config, dummyLogger)
+	proxies := service.NewProxies(config, nil, dummyLogger)
 	require.IsType(t, service.ShardProxies{}, proxies)
 
 	testCases := []struct {
@@ -377,3 +387,421 @@ func TestUnitTest_ShardProxies(t *testing.T) {
 		})
 	}
 }
+
+// mockBlockGetter is a decode.EVMBlockGetter for a fixed set of block hashes
+type mockBlockGetter struct {
+	heightByHash map[common.Hash]int64
+	calls        int
+	// number of calls made without a deadline
+	callsWithoutDeadline int
+}
+
+func (bg *mockBlockGetter) HeaderByHash(ctx context.Context, hash common.Hash) (*ethctypes.Header, error) {
+	bg.calls++
+	if _, hasDeadline := ctx.Deadline(); !hasDeadline {
+		bg.callsWithoutDeadline++
+	}
+	height, found := bg.heightByHash[hash]
+	if !found {
+		return nil, ethereum.NotFound
+	}
+	return &ethctypes.Header{Number: big.NewInt(height)}, nil
+}
+
+func TestUnitTest_ShardProxies_BlockHash(t *testing.T) {
+	archiveBackend := "archivenode.kava.io/"
+	shard1Backend := "shard-1.kava.io/"
+	shard2Backend := "shard-2.kava.io/"
+	config := newConfig(t,
+		fmt.Sprintf("archive.kava.io>%s", archiveBackend),
+		"",
+		fmt.Sprintf("archive.kava.io>10|%s|20|%s", shard1Backend, shard2Backend),
+	)
+
+	shard1Hash := common.HexToHash("0x01")
+	shard2Hash := common.HexToHash("0x02")
+	beyondShardsHash := common.HexToHash("0x03")
+	blockGetter := &mockBlockGetter{heightByHash: map[common.Hash]int64{
+		shard1Hash:       5,
+		shard2Hash:       15,
+		beyondShardsHash: 25,
+	}}
+	proxies := service.NewProxies(config, blockGetter, dummyLogger)
+
+	testCases := []struct {
+		name          string
+		req           *decode.EVMRPCRequestEnvelope
+		expectBackend string
+		expectRoute   string
+	}{
+		{
+			name: "routes block hash in shard 1 to shard 1",
+			req: &decode.EVMRPCRequestEnvelope{
+				Method: "eth_getBlockByHash",
+				Params: []interface{}{shard1Hash.Hex(), false},
+			},
+			expectBackend: service.ResponseBackendShard,
+			expectRoute:   shard1Backend,
+		},
+		{
+			name: "routes block hash in shard 2 to shard 2",
+			req: &decode.EVMRPCRequestEnvelope{
+				Method: "eth_getTransactionByBlockHashAndIndex",
+				Params: []interface{}{shard2Hash.Hex(), "0x0"},
+			},
+			expectBackend: service.ResponseBackendShard,
+			expectRoute:   shard2Backend,
+		},
+		{
+			name: "routes block hash beyond latest shard to default",
+			req: &decode.EVMRPCRequestEnvelope{
+				Method: "eth_getBlockByHash",
+				Params: []interface{}{beyondShardsHash.Hex(), false},
+			},
+			expectBackend: service.ResponseBackendDefault,
+			expectRoute:   archiveBackend,
+		},
+		{
+			name: "routes unknown block hash to default",
+			req: &decode.EVMRPCRequestEnvelope{
+				Method: "eth_getBlockByHash",
+				Params: []interface{}{common.HexToHash("0x04").Hex(), false},
+			},
+			expectBackend: service.ResponseBackendDefault,
+			expectRoute:   archiveBackend,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := mockJsonRpcReqToUrl("//archive.kava.io", tc.req)
+			proxy, metadata, found := proxies.ProxyForRequest(req)
+			require.True(t, found, "expected proxy to be found")
+			require.Equal(t, tc.expectBackend, metadata.BackendName)
+			require.Equal(t, tc.expectRoute, metadata.BackendRoute.String())
+			requireProxyRoutesToUrl(t, proxy, req, tc.expectRoute)
+		})
+	}
+
+	t.Run("remembers the height of resolved block hashes", func(t *testing.T) {
+		calls := blockGetter.calls
+		req := mockJsonRpcReqToUrl("//archive.kava.io", &decode.EVMRPCRequestEnvelope{
+			Method: "eth_getBlockByHash",
+			Params: []interface{}{shard1Hash.Hex(), false},
+		})
+		_, metadata, found := proxies.ProxyForRequest(req)
+		require.True(t, found)
+		require.Equal(t, shard1Backend, metadata.BackendRoute.String())
+		require.Equal(t, calls, blockGetter.calls)
+	})
+
+	t.Run("remembers block hashes that failed to resolve", func(t *testing.T) {
+		calls := blockGetter.calls
+		req := mockJsonRpcReqToUrl("//archive.kava.io", &decode.EVMRPCRequestEnvelope{
+			Method: "eth_getBlockByHash",
+			Params: []interface{}{common.HexToHash("0x04").Hex(), false},
+		})
+		// as for the retries, hedges & quorum reads of the request
+		for i := 0; i < 3; i++ {
+			_, metadata, found := proxies.ProxyForRequest(req)
+			require.True(t, found)
+			require.Equal(t, archiveBackend, metadata.BackendRoute.String())
+		}
+		require.Equal(t, calls, blockGetter.calls)
+	})
+
+	t.Run("looks up block hashes with a timeout", func(t *testing.T) {
+		require.NotZero(t, blockGetter.calls)
+		require.Zero(t, blockGetter.callsWithoutDeadline)
+	})
+}
+
+func TestUnitTest_ShardProxies_DefaultPoolDistribution(t *testing.T) {
+	archiveBackend1 := "archivenode-1.kava.io/"
+	archiveBackend2 := "archivenode-2.kava.io/"
+	shard1Backend := "shard-1.kava.io/"
+	config := newConfig(t,
+		fmt.Sprintf("archive.kava.io>%s+%s", archiveBackend1, archiveBackend2),
+		"",
+		fmt.Sprintf("archive.kava.io>10|%s", shard1Backend),
+	)
+	proxies := service.NewProxies(config, nil, dummyLogger)
+	require.IsType(t, service.ShardProxies{}, proxies)
+
+	testCases := []struct {
+		name string
+		req  *decode.EVMRPCRequestEnvelope
+	}{
+		{
+			name: "requests without block number",
+			req:  &decode.EVMRPCRequestEnvelope{Method: "eth_chainId"},
+		},
+		{
+			name: "requests for heights beyond latest shard",
+			req: &decode.EVMRPCRequestEnvelope{
+				Method: "eth_getBlockByNumber",
+				Params: []interface{}{"0x20", false},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			routes := make(map[string]int)
+			for i := 0; i < 10; i++ {
+				_, metadata, found := proxies.ProxyForRequest(mockJsonRpcReqToUrl("//archive.kava.io", tc.req))
+				require.True(t, found, "expected proxy to be found")
+				require.Equal(t, service.ResponseBackendDefault, metadata.BackendName)
+				routes[metadata.BackendRoute.String()]++
+			}
+
+			// the round robin pool of the default backends is only picked from once per request
+			require.Equal(t, map[string]int{archiveBackend1: 5, archiveBackend2: 5}, routes)
+		})
+	}
+}
+
+func TestUnitTest_ShardProxies_DefaultBackendDrained(t *testing.T) {
+	archiveBackend := "archivenode.kava.io/"
+	shard1Backend := "shard-1.kava.io/"
+	config := newConfig(t,
+		fmt.Sprintf("archive.kava.io>%s", archiveBackend),
+		"",
+		fmt.Sprintf("archive.kava.io>10|%s", shard1Backend),
+	)
+	proxies := service.NewProxies(config, nil, dummyLogger)
+	require.IsType(t, service.ShardProxies{}, proxies)
+
+	adminStates := service.NewBackendAdminStates(proxies, nil, "test", dummyLogger)
+	_, err := adminStates.Set(context.Background(), archiveBackend, service.BackendAdminStateDraining)
+	require.NoError(t, err)
+
+	t.Run("requests for heights of shards are routed to the shards", func(t *testing.T) {
+		req := &decode.EVMRPCRequestEnvelope{
+			Method: "eth_getBlockByNumber",
+			Params: []interface{}{"0x5", false},
+		}
+		_, metadata, found := proxies.ProxyForRequest(mockJsonRpcReqToUrl("//archive.kava.io", req))
+		require.True(t, found, "expected proxy to be found")
+		require.Equal(t, service.ResponseBackendShard, metadata.BackendName)
+		require.Equal(t, shard1Backend, metadata.BackendRoute.String())
+	})
+
+	t.Run("requests routed to the default backend are not found", func(t *testing.T) {
+		for _, req := range []*decode.EVMRPCRequestEnvelope{
+			{Method: "eth_chainId"},
+			{Method: "eth_getBlockByNumber", Params: []interface{}{"0x20", false}},
+		} {
+			_, _, found := proxies.ProxyForRequest(mockJsonRpcReqToUrl("//archive.kava.io", req))
+			require.False(t, found, "expected no proxy to be found for %s", req.Method)
+		}
+	})
+}
+
+// mockLogsBackend is a backend responding to eth_getLogs with a log for the first & last block of the requested range
+func mockLogsBackend(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req, err := decode.DecodeEVMRPCRequest(mustReadAll(t, r.Body))
+		require.NoError(t, err)
+		filter := req.Params[0].(map[string]interface{})
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%v,"result":[{"blockNumber":"%s"},{"blockNumber":"%s"}]}`, req.ID, filter["fromBlock"], filter["toBlock"])
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func mustReadAll(t *testing.T, r io.Reader) []byte {
+	body, err := io.ReadAll(r)
+	require.NoError(t, err)
+	return body
+}
+
+func TestUnitTest_ShardProxies_BlockRange(t *testing.T) {
+	archiveBackend := mockLogsBackend(t).URL + "/"
+	shard1Backend := mockLogsBackend(t).URL + "/"
+	shard2Backend := mockLogsBackend(t).URL + "/"
+	config := newConfig(t,
+		fmt.Sprintf("archive.kava.io>%s", archiveBackend),
+		"",
+		fmt.Sprintf("archive.kava.io>10|%s|20|%s", shard1Backend, shard2Backend),
+	)
+	proxies := service.NewProxies(config, nil, dummyLogger)
+
+	getLogs := func(fromBlock, toBlock string) *decode.EVMRPCRequestEnvelope {
+		return &decode.EVMRPCRequestEnvelope{
+			JSONRPCVersion: "2.0",
+			ID:             float64(1),
+			Method:         "eth_getLogs",
+			Params:         []interface{}{map[string]interface{}{"fromBlock": fromBlock, "toBlock": toBlock, "address": "0x1a2b"}},
+		}
+	}
+
+	testCases := []struct {
+		name          string
+		req           *decode.EVMRPCRequestEnvelope
+		expectBackend string
+		expectRoute   string
+		expectResult  string
+	}{
+		{
+			name:          "routes range within a single shard to the shard",
+			req:           getLogs("0x2", "0x5"),
+			expectBackend: service.ResponseBackendShard,
+			expectRoute:   shard1Backend,
+		},
+		{
+			name:          "routes range ending at latest to default",
+			req:           getLogs("0x2", "latest"),
+			expectBackend: service.ResponseBackendDefault,
+			expectRoute:   archiveBackend,
+		},
+		{
+			name:          "routes range beyond latest shard to default",
+			req:           getLogs("0x15", "0x20"),
+			expectBackend: service.ResponseBackendDefault,
+			expectRoute:   archiveBackend,
+		},
+		{
+			name:          "splits range spanning shards",
+			req:           getLogs("earliest", "0xf"),
+			expectBackend: service.ResponseBackendShardSplit,
+			expectRoute:   shard2Backend,
+			expectResult:  `[{"blockNumber":"0x1"},{"blockNumber":"0xa"},{"blockNumber":"0xb"},{"blockNumber":"0xf"}]`,
+		},
+		{
+			name:          "splits range spanning shards and beyond latest shard",
+			req:           getLogs("0x5", "0x19"),
+			expectBackend: service.ResponseBackendShardSplit,
+			expectRoute:   archiveBackend,
+			expectResult:  `[{"blockNumber":"0x5"},{"blockNumber":"0xa"},{"blockNumber":"0xb"},{"blockNumber":"0x14"},{"blockNumber":"0x15"},{"blockNumber":"0x19"}]`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := mockJsonRpcReqToUrl("//archive.kava.io", tc.req)
+			proxy, metadata, found := proxies.ProxyForRequest(req)
+			require.True(t, found, "expected proxy to be found")
+			require.Equal(t, tc.expectBackend, metadata.BackendName)
+			require.Equal(t, tc.expectRoute, metadata.BackendRoute.String())
+
+			if tc.expectResult == "" {
+				requireProxyRoutesToUrl(t, proxy, req, tc.expectRoute)
+				return
+			}
+
+			body, err := json.Marshal(tc.req)
+			require.NoError(t, err)
+			req.Body = io.NopCloser(bytes.NewReader(body))
+			rec := httptest.NewRecorder()
+			proxy.ServeHTTP(rec, req)
+
+			require.Equal(t, http.StatusOK, rec.Code)
+			require.JSONEq(t, fmt.Sprintf(`{"jsonrpc":"2.0","id":1,"result":%s}`, tc.expectResult), rec.Body.String())
+		})
+	}
+}
+
+func TestUnitTest_ShardProxies_BlockRangeSegmentAborted(t *testing.T) {
+	archiveBackend := mockLogsBackend(t).URL + "/"
+	shard1Backend := mockLogsBackend(t).URL + "/"
+	// starts responding, then fails before the body is complete
+	aborting := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":[`))
+		w.(http.Flusher).Flush()
+		panic(http.ErrAbortHandler)
+	}))
+	t.Cleanup(aborting.Close)
+	config := newConfig(t,
+		fmt.Sprintf("archive.kava.io>%s", archiveBackend),
+		"",
+		fmt.Sprintf("archive.kava.io>10|%s|20|%s/", shard1Backend, aborting.URL),
+	)
+	proxies := service.NewProxies(config, nil, dummyLogger)
+
+	getLogs := &decode.EVMRPCRequestEnvelope{
+		JSONRPCVersion: "2.0",
+		ID:             float64(1),
+		Method:         "eth_getLogs",
+		Params:         []interface{}{map[string]interface{}{"fromBlock": "0x5", "toBlock": "0xf"}},
+	}
+	req := mockJsonRpcReqToUrl("//archive.kava.io", getLogs)
+	// as for requests received by the service, so that the reverse proxy aborts failed responses
+	req = req.WithContext(context.WithValue(req.Context(), http.ServerContextKey, &http.Server{}))
+	proxy, metadata, found := proxies.ProxyForRequest(req)
+	require.True(t, found, "expected proxy to be found")
+	require.Equal(t, service.ResponseBackendShardSplit, metadata.BackendName)
+
+	body, err := json.Marshal(getLogs)
+	require.NoError(t, err)
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	rec := httptest.NewRecorder()
+	proxy.ServeHTTP(rec, req)
+
+	// the aborted segment fails the request instead of taking down the service
+	require.Equal(t, http.StatusBadGateway, rec.Code)
+}
+
+func TestUnitTest_ShardProxies_CosmosRequests(t *testing.T) {
+	archiveBackend := "archivenode.kava.io/"
+	pruningBackend := "pruningnode.kava.io/"
+	shard1Backend := "shard-1.kava.io/"
+	config := newConfig(t,
+		fmt.Sprintf("archive.kava.io>%s", archiveBackend),
+		fmt.Sprintf("archive.kava.io>%s", pruningBackend),
+		fmt.Sprintf("archive.kava.io>10|%s", shard1Backend),
+	)
+	proxies := service.NewProxies(config, nil, dummyLogger)
+
+	height := func(h int64) *int64 { return &h }
+
+	testCases := []struct {
+		name          string
+		req           *decode.CosmosRPCRequest
+		expectBackend string
+		expectRoute   string
+	}{
+		{
+			name:          "routes methods without history to pruning",
+			req:           &decode.CosmosRPCRequest{Method: "status"},
+			expectBackend: service.ResponseBackendPruning,
+			expectRoute:   pruningBackend,
+		},
+		{
+			name:          "routes latest height to pruning",
+			req:           &decode.CosmosRPCRequest{Method: "block", Height: height(decode.BlockTagToNumberCodec[decode.BlockTagEmpty])},
+			expectBackend: service.ResponseBackendPruning,
+			expectRoute:   pruningBackend,
+		},
+		{
+			name:          "routes height in shard to shard",
+			req:           &decode.CosmosRPCRequest{Method: "/cosmos/bank/v1beta1/balances/kava1abc", Height: height(5)},
+			expectBackend: service.ResponseBackendShard,
+			expectRoute:   shard1Backend,
+		},
+		{
+			name:          "routes height beyond latest shard to default",
+			req:           &decode.CosmosRPCRequest{Method: "block_results", Height: height(50)},
+			expectBackend: service.ResponseBackendDefault,
+			expectRoute:   archiveBackend,
+		},
+		{
+			name:          "routes methods without height to default",
+			req:           &decode.CosmosRPCRequest{Method: "tx"},
+			expectBackend: service.ResponseBackendDefault,
+			expectRoute:   archiveBackend,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := mockReqForUrl("//archive.kava.io")
+			req = req.WithContext(context.WithValue(req.Context(), service.DecodedCosmosRequestContextKey, tc.req))
+			proxy, metadata, found := proxies.ProxyForRequest(req)
+			require.True(t, found, "expected proxy to be found")
+			require.Equal(t, tc.expectBackend, metadata.BackendName)
+			require.Equal(t, tc.expectRoute, metadata.BackendRoute.String())
+			requireProxyRoutesToUrl(t, proxy, req, tc.expectRoute)
+		})
+	}
+}
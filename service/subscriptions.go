@@ -0,0 +1,337 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/kava-labs/kava-proxy-service/logging"
+	"github.com/kava-labs/kava-proxy-service/service/cachemdw"
+)
+
+const (
+	// how long to wait for a backend to create an upstream subscription
+	upstreamSubscribeTimeout = 10 * time.Second
+	// number of notifications buffered for a client before it is considered too slow
+	// and its subscription is ended, so that it never holds up the other clients
+	clientSubscriptionBufferSize = 128
+	// method of the notifications sent by nodes for subscriptions
+	subscriptionNotificationMethod = "eth_subscription"
+)
+
+var (
+	// ErrUpstreamSubscriptionClosed is the reason a subscription ends when the backend closes the upstream subscription
+	ErrUpstreamSubscriptionClosed = errors.New("upstream subscription closed")
+	// ErrSubscriberTooSlow is the reason a subscription ends when the client does not keep up with its notifications
+	ErrSubscriberTooSlow = errors.New("subscriber too slow to receive notifications")
+)
+
+// sharedSubscriptionTypes are the eth_subscribe subscription types whose upstream
+// subscription is shared by every client of the host subscribing with the same params
+var sharedSubscriptionTypes = map[string]bool{
+	"newHeads": true,
+	"logs":     true,
+}
+
+// isSharedSubscription returns true when the params of an eth_subscribe call are for a shared subscription type
+func isSharedSubscription(params []interface{}) bool {
+	if len(params) == 0 {
+		return false
+	}
+	subscriptionType, ok := params[0].(string)
+	return ok && sharedSubscriptionTypes[subscriptionType]
+}
+
+// upstreamSubscriptionError is returned when a backend responds to eth_subscribe with an error
+type upstreamSubscriptionError struct {
+	rpcError *cachemdw.JsonRpcError
+}
+
+// Error implements error
+func (e *upstreamSubscriptionError) Error() string {
+	return fmt.Sprintf("backend rejected subscription: %s", e.rpcError.String())
+}
+
+// subscriptionNotification is the notification sent by nodes for each event of a subscription
+type subscriptionNotification struct {
+	Version string                         `json:"jsonrpc"`
+	Method  string                         `json:"method"`
+	Params  subscriptionNotificationParams `json:"params"`
+}
+
+type subscriptionNotificationParams struct {
+	Subscription string          `json:"subscription"`
+	Result       json.RawMessage `json:"result"`
+}
+
+// subscriptionHub maintains a single upstream subscription per host, subscription type & filter,
+// fanning its notifications out to every client subscribed with the same params.
+// An upstream subscription is torn down once its last client unsubscribes.
+type subscriptionHub struct {
+	proxies Proxies
+	// guards upstreams & the subscribers of every upstream subscription
+	mu        sync.Mutex
+	upstreams map[string]*upstreamSubscription
+	*logging.ServiceLogger
+}
+
+// upstreamSubscription is a subscription on a websocket connection to a backend
+type upstreamSubscription struct {
+	key string
+	// closed once the subscription is created on the backend, or failed to be
+	ready chan struct{}
+	// set before ready is closed
+	err  error
+	conn *websocket.Conn
+	id   string
+	// clients subscribed to the upstream subscription, by the id of their subscription
+	subscribers map[string]*clientSubscription
+}
+
+// clientSubscription is the subscription of a client to an upstream subscription,
+// with an id of its own that notifications are rewritten with
+type clientSubscription struct {
+	id       string
+	upstream *upstreamSubscription
+	// notifications for the client, closed when the subscription ends
+	notifications chan []byte
+	// reason the subscription was ended other than the client unsubscribing,
+	// set before notifications is closed
+	err error
+}
+
+func newSubscriptionHub(proxies Proxies, serviceLogger *logging.ServiceLogger) *subscriptionHub {
+	return &subscriptionHub{
+		proxies:       proxies,
+		upstreams:     make(map[string]*upstreamSubscription),
+		ServiceLogger: serviceLogger,
+	}
+}
+
+// subscribe subscribes the client of the websocket upgrade request to the upstream subscription
+// for its host & the params, creating the upstream subscription if no other client is subscribed to it
+func (h *subscriptionHub) subscribe(ctx context.Context, r *http.Request, params []interface{}) (*clientSubscription, error) {
+	rawParams, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+	// params are marshalled with sorted keys, so equivalent filters share a key
+	key := strings.Join([]string{r.Host, string(rawParams)}, ":")
+
+	id, err := newSubscriptionID()
+	if err != nil {
+		return nil, err
+	}
+
+	h.mu.Lock()
+	upstream, exists := h.upstreams[key]
+	if !exists {
+		upstream = &upstreamSubscription{
+			key:         key,
+			ready:       make(chan struct{}),
+			subscribers: make(map[string]*clientSubscription),
+		}
+		h.upstreams[key] = upstream
+	}
+	sub := &clientSubscription{
+		id:            id,
+		upstream:      upstream,
+		notifications: make(chan []byte, clientSubscriptionBufferSize),
+	}
+	upstream.subscribers[id] = sub
+	h.mu.Unlock()
+
+	if !exists {
+		upstream.err = h.open(upstream, r, rawParams)
+		close(upstream.ready)
+	}
+
+	select {
+	case <-upstream.ready:
+	case <-ctx.Done():
+		h.unsubscribe(sub)
+		return nil, ctx.Err()
+	}
+
+	if upstream.err != nil {
+		h.unsubscribe(sub)
+		return nil, upstream.err
+	}
+
+	return sub, nil
+}
+
+// unsubscribe ends the subscription of the client, tearing down
+// the upstream subscription if no other client is subscribed to it
+func (h *subscriptionHub) unsubscribe(sub *clientSubscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.endLocked(sub, nil)
+}
+
+// endLocked ends the subscription of the client for the reason (if any).
+// Must be called while holding the mutex of the hub.
+func (h *subscriptionHub) endLocked(sub *clientSubscription, reason error) {
+	upstream := sub.upstream
+	if upstream.subscribers[sub.id] != sub {
+		// already ended
+		return
+	}
+
+	delete(upstream.subscribers, sub.id)
+	sub.err = reason
+	close(sub.notifications)
+
+	if len(upstream.subscribers) == 0 && h.upstreams[upstream.key] == upstream {
+		delete(h.upstreams, upstream.key)
+		go h.close(upstream)
+	}
+}
+
+// open dials a backend for the host of the request and creates the upstream subscription on it
+func (h *subscriptionHub) open(upstream *upstreamSubscription, r *http.Request, rawParams json.RawMessage) error {
+	_, metadata, found := currentProxies(h.proxies).ProxyForRequest(r)
+	if !found {
+		return errors.New("no backend available for subscription")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), upstreamSubscribeTimeout)
+	defer cancel()
+
+	backendURL := websocketURL(metadata.BackendRoute)
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, backendURL.String(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to dial backend for subscription: %w", err)
+	}
+
+	id, err := createUpstreamSubscription(conn, rawParams)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	upstream.conn = conn
+	upstream.id = id
+	go h.fanOut(upstream)
+
+	h.Debug().Str("host", r.Host).Str("backend", metadata.BackendRoute.String()).Str("subscription", upstream.key).Msg("created upstream subscription")
+
+	return nil
+}
+
+// createUpstreamSubscription makes the eth_subscribe call on the backend connection, returning the id of the subscription
+func createUpstreamSubscription(conn *websocket.Conn, rawParams json.RawMessage) (string, error) {
+	conn.SetReadDeadline(time.Now().Add(upstreamSubscribeTimeout))
+	defer conn.SetReadDeadline(time.Time{})
+
+	call := fmt.Sprintf(`{"jsonrpc":"2.0","id":1,"method":"eth_subscribe","params":%s}`, rawParams)
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(call)); err != nil {
+		return "", fmt.Errorf("failed to create upstream subscription: %w", err)
+	}
+
+	_, message, err := conn.ReadMessage()
+	if err != nil {
+		return "", fmt.Errorf("failed to create upstream subscription: %w", err)
+	}
+
+	response, err := cachemdw.UnmarshalJsonRpcResponse(message)
+	if err != nil {
+		return "", fmt.Errorf("invalid response of backend to eth_subscribe: %w", err)
+	}
+	if response.JsonRpcError != nil {
+		return "", &upstreamSubscriptionError{rpcError: response.JsonRpcError}
+	}
+
+	var id string
+	if err := json.Unmarshal(response.Result, &id); err != nil {
+		return "", fmt.Errorf("invalid subscription id %s in response of backend to eth_subscribe: %w", response.Result, err)
+	}
+
+	return id, nil
+}
+
+// fanOut sends every notification of the upstream subscription to its subscribers,
+// with the subscription id of each subscriber, until the backend connection closes
+func (h *subscriptionHub) fanOut(upstream *upstreamSubscription) {
+	for {
+		_, message, err := upstream.conn.ReadMessage()
+		if err != nil {
+			break
+		}
+
+		var notification subscriptionNotification
+		if err := json.Unmarshal(message, &notification); err != nil ||
+			notification.Method != subscriptionNotificationMethod ||
+			notification.Params.Subscription != upstream.id {
+			// e.g. the response to the call tearing down the subscription
+			continue
+		}
+
+		h.mu.Lock()
+		for _, sub := range upstream.subscribers {
+			notification.Params.Subscription = sub.id
+			rewritten, err := json.Marshal(&notification)
+			if err != nil {
+				h.Error().Err(err).Msg("failed to rewrite subscription notification")
+				continue
+			}
+
+			select {
+			case sub.notifications <- rewritten:
+			default:
+				h.endLocked(sub, ErrSubscriberTooSlow)
+			}
+		}
+		h.mu.Unlock()
+	}
+
+	// end the subscriptions of any clients still subscribed
+	h.mu.Lock()
+	if len(upstream.subscribers) > 0 {
+		h.Error().Str("subscription", upstream.key).Msg("backend closed upstream subscription")
+	}
+	for _, sub := range upstream.subscribers {
+		h.endLocked(sub, ErrUpstreamSubscriptionClosed)
+	}
+	if h.upstreams[upstream.key] == upstream {
+		delete(h.upstreams, upstream.key)
+	}
+	h.mu.Unlock()
+
+	upstream.conn.Close()
+}
+
+// close tears down the upstream subscription once it has no subscribers
+func (h *subscriptionHub) close(upstream *upstreamSubscription) {
+	<-upstream.ready
+	if upstream.err != nil {
+		return
+	}
+
+	call := fmt.Sprintf(`{"jsonrpc":"2.0","id":2,"method":"eth_unsubscribe","params":[%q]}`, upstream.id)
+	if err := upstream.conn.WriteMessage(websocket.TextMessage, []byte(call)); err != nil {
+		h.Debug().Err(err).Str("subscription", upstream.key).Msg("failed to tear down upstream subscription")
+	}
+	upstream.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(websocketCloseTimeout))
+	upstream.conn.Close()
+
+	h.Debug().Str("subscription", upstream.key).Msg("tore down upstream subscription")
+}
+
+// newSubscriptionID returns a random subscription id in the format used by nodes
+func newSubscriptionID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return "0x" + hex.EncodeToString(id), nil
+}
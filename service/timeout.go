@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/kava-labs/kava-proxy-service/config"
+	"github.com/kava-labs/kava-proxy-service/decode"
+)
+
+// JSON-RPC error message the proxy responds with when the backend
+// failed to respond before the timeout of the request
+const backendTimeoutErrorMessage = "backend timed out after %s"
+
+// timeoutPolicy decides how long backends may take to respond to requests
+// for each host & JSON-RPC method before the request is cancelled
+type timeoutPolicy struct {
+	enabled     bool
+	rulesByHost map[string]config.MethodTimeoutRules
+}
+
+// newTimeoutPolicy creates the timeoutPolicy defined by the service config
+func newTimeoutPolicy(config config.Config) timeoutPolicy {
+	return timeoutPolicy{
+		enabled:     config.ProxyBackendTimeoutEnabled,
+		rulesByHost: config.ProxyBackendTimeoutMap,
+	}
+}
+
+// timeoutFor returns the timeout of requests for the method to the host, or zero if they have none.
+// The rules of the host take precedence over the rules for every host.
+func (tp timeoutPolicy) timeoutFor(host string, method string) time.Duration {
+	if !tp.enabled {
+		return 0
+	}
+	if rule, found := tp.rulesByHost[host].Lookup(method); found {
+		return rule.Timeout
+	}
+	if rule, found := tp.rulesByHost[config.BackendTimeoutMapAnyHost].Lookup(method); found {
+		return rule.Timeout
+	}
+	return 0
+}
+
+// proxyWithTimeout proxies the request with serve, cancelling the request to the backend
+// once the timeout elapses. The response is buffered so that if the backend fails to respond
+// in time, the partial response is discarded and a JSON-RPC error is written to w instead.
+// A response that completed is written to w even if the timeout elapsed just after it did.
+// Returns true if the request timed out.
+func proxyWithTimeout(w http.ResponseWriter, r *http.Request, timeout time.Duration, decodedReq *decode.EVMRPCRequestEnvelope, serve func(http.ResponseWriter, *http.Request)) (bool, error) {
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
+	response := newBufferedResponseWriter()
+	completed := response.serve(http.HandlerFunc(serve), r.WithContext(ctx))
+
+	// once the request is cancelled the proxy fails to reach the backend and responds with a 502,
+	// which is not a response of the backend
+	failedToRespond := !completed || (ctx.Err() != nil && isRetryableStatus(response.statusCode))
+	if !failedToRespond || !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		response.writeTo(w)
+		return false, nil
+	}
+
+	return true, writeBackendTimeoutError(w, decodedReq, timeout)
+}
+
+// writeBackendTimeoutError writes a JSON-RPC error response for the request
+// whose backend failed to respond before the timeout
+func writeBackendTimeoutError(w http.ResponseWriter, decodedReq *decode.EVMRPCRequestEnvelope, timeout time.Duration) error {
+	return writeJSONRPCError(w, http.StatusGatewayTimeout, decodedReq, backendTimeoutErrorCode, fmt.Sprintf(backendTimeoutErrorMessage, timeout))
+}
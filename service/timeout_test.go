@@ -0,0 +1,147 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kava-labs/kava-proxy-service/config"
+	"github.com/kava-labs/kava-proxy-service/decode"
+	"github.com/kava-labs/kava-proxy-service/logging"
+	"github.com/kava-labs/kava-proxy-service/service/cachemdw"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnitTestTimeoutPolicy_TimeoutFor(t *testing.T) {
+	rulesByHost, err := config.ParseRawBackendTimeoutMap("*>debug_*|120|*|30,evm.kava.io>eth_call|10")
+	require.NoError(t, err)
+	policy := timeoutPolicy{enabled: true, rulesByHost: rulesByHost}
+
+	require.Equal(t, 10*time.Second, policy.timeoutFor("evm.kava.io", "eth_call"))
+	require.Equal(t, 120*time.Second, policy.timeoutFor("evm.kava.io", "debug_traceTransaction"))
+	require.Equal(t, 30*time.Second, policy.timeoutFor("evm.kava.io", "eth_getBalance"))
+	require.Equal(t, 30*time.Second, policy.timeoutFor("evm.testnet.kava.io", "eth_call"))
+
+	policy.enabled = false
+	require.Zero(t, policy.timeoutFor("evm.kava.io", "eth_call"))
+}
+
+func TestUnitTestProxyWithTimeout(t *testing.T) {
+	decodedReq := &decode.EVMRPCRequestEnvelope{JSONRPCVersion: "2.0", ID: float64(7), Method: "debug_traceTransaction"}
+	body := []byte(`{"jsonrpc":"2.0","id":7,"method":"debug_traceTransaction","params":[]}`)
+
+	// proxyTestRequest proxies the request to the server with the timeout, serving it with the proxy
+	// to the server wrapped by serve if it is not nil
+	proxyTestRequest := func(server *httptest.Server, timeout time.Duration, serve func(proxy http.Handler) http.HandlerFunc) (*httptest.ResponseRecorder, bool) {
+		proxies, _ := newTestHostProxies(t, server)
+		r := httptest.NewRequest(http.MethodPost, "http://evm.kava.io", bytes.NewReader(body))
+		// as for requests received by the service, so that the reverse proxy aborts failed responses
+		r = r.WithContext(context.WithValue(r.Context(), http.ServerContextKey, &http.Server{}))
+		proxy, _, found := proxies.ProxyForRequest(r)
+		require.True(t, found)
+		if serve == nil {
+			serve = func(proxy http.Handler) http.HandlerFunc { return proxy.ServeHTTP }
+		}
+
+		w := httptest.NewRecorder()
+		timedOut, err := proxyWithTimeout(w, r, timeout, decodedReq, serve(proxy))
+		require.NoError(t, err)
+		return w, timedOut
+	}
+
+	requireBackendTimeoutError := func(t *testing.T, w *httptest.ResponseRecorder) {
+		require.Equal(t, http.StatusGatewayTimeout, w.Code)
+		response, err := cachemdw.UnmarshalJsonRpcResponse(w.Body.Bytes())
+		require.NoError(t, err)
+		require.Equal(t, json.RawMessage("7"), response.ID)
+		require.Equal(t, backendTimeoutErrorCode, response.JsonRpcError.Code)
+		require.Equal(t, "backend timed out after 50ms", response.JsonRpcError.Message)
+	}
+
+	t.Run("responds with the response of the backend within the timeout", func(t *testing.T) {
+		server, _ := newSlowBackend(t, 0)
+		w, timedOut := proxyTestRequest(server, time.Second, nil)
+
+		require.False(t, timedOut)
+		require.Equal(t, http.StatusOK, w.Code)
+		require.JSONEq(t, `{"jsonrpc":"2.0","id":1,"result":"slow"}`, w.Body.String())
+	})
+
+	t.Run("responds with a JSON-RPC error with the id of the request after the timeout", func(t *testing.T) {
+		server, cancelled := newSlowBackend(t, 5*time.Second)
+		w, timedOut := proxyTestRequest(server, 50*time.Millisecond, nil)
+
+		require.True(t, timedOut)
+		// the request to the backend is cancelled
+		select {
+		case <-cancelled:
+		case <-time.After(time.Second):
+			t.Fatal("request to backend was not cancelled")
+		}
+		requireBackendTimeoutError(t, w)
+	})
+
+	t.Run("responds with a JSON-RPC error when the response is aborted by the timeout", func(t *testing.T) {
+		server, cancelled := newStreamingBackend(t, 5*time.Second)
+		w, timedOut := proxyTestRequest(server, 50*time.Millisecond, nil)
+
+		require.True(t, timedOut)
+		select {
+		case <-cancelled:
+		case <-time.After(time.Second):
+			t.Fatal("request to backend was not cancelled")
+		}
+		requireBackendTimeoutError(t, w)
+	})
+
+	t.Run("responds with the response of the backend that completed as the timeout elapsed", func(t *testing.T) {
+		server, _ := newSlowBackend(t, 0)
+		w, timedOut := proxyTestRequest(server, 50*time.Millisecond, func(proxy http.Handler) http.HandlerFunc {
+			return func(w http.ResponseWriter, r *http.Request) {
+				proxy.ServeHTTP(w, r)
+				// the timeout elapses after the response completed, before it is written
+				<-r.Context().Done()
+			}
+		})
+
+		require.False(t, timedOut)
+		require.Equal(t, http.StatusOK, w.Code)
+		require.JSONEq(t, `{"jsonrpc":"2.0","id":1,"result":"slow"}`, w.Body.String())
+	})
+}
+
+func TestUnitTestReloadableProxies_ReloadsTimeoutPolicy(t *testing.T) {
+	newTimeoutConfig := func(rawTimeoutMap string) config.Config {
+		cfg, err := config.ReadConfig()
+		require.NoError(t, err)
+		cfg.EnableHeightBasedRouting = false
+		cfg.EnableShardedRouting = false
+		cfg.EnableMethodRouting = false
+		cfg.ProxyPruningBackendRetentionWindowMap = nil
+		cfg.ProxyPruningBackendRetentionWindowMapRaw = ""
+		cfg.ProxyBackendTimeoutEnabled = true
+		cfg.ProxyBackendTimeoutMapRaw = rawTimeoutMap
+		rulesByHost, err := config.ParseRawBackendTimeoutMap(rawTimeoutMap)
+		require.NoError(t, err)
+		cfg.ProxyBackendTimeoutMap = rulesByHost
+		require.NoError(t, config.Validate(cfg))
+		return cfg
+	}
+
+	logger, err := logging.New("ERROR")
+	require.NoError(t, err)
+	proxies := NewReloadableProxies(newTimeoutConfig("*>eth_call|10"), nil, &logger)
+	_, policies := currentRouting(proxies, nil)
+	require.Equal(t, 10*time.Second, policies.timeout.timeoutFor("evm.kava.io", "eth_call"))
+
+	require.NoError(t, proxies.Reload(newTimeoutConfig("*>eth_call|20")))
+
+	// requests in flight keep the policies they started with
+	require.Equal(t, 10*time.Second, policies.timeout.timeoutFor("evm.kava.io", "eth_call"))
+	_, policies = currentRouting(proxies, nil)
+	require.Equal(t, 20*time.Second, policies.timeout.timeoutFor("evm.kava.io", "eth_call"))
+}
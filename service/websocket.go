@@ -0,0 +1,380 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/kava-labs/kava-proxy-service/decode"
+	"github.com/kava-labs/kava-proxy-service/logging"
+	"github.com/kava-labs/kava-proxy-service/service/cachemdw"
+)
+
+const (
+	// how long to wait for a close message to be written to either side of the connection
+	websocketCloseTimeout = time.Second
+	// code of the JSON-RPC error responded with when a subscription fails to be created
+	subscriptionErrorCode = -32000
+)
+
+// websocketHeadersNotForwarded are the headers of the upgrade request that are
+// not copied to the http requests made for the calls of a websocket connection
+var websocketHeadersNotForwarded = []string{
+	"Connection",
+	"Upgrade",
+	"Sec-Websocket-Key",
+	"Sec-Websocket-Version",
+	"Sec-Websocket-Extensions",
+	"Sec-Websocket-Protocol",
+}
+
+// createWebsocketMiddleware creates a middleware that upgrades websocket connections,
+// passing every other request to next unchanged.
+// Every call made over the connection is decoded: calls for subscriptions are forwarded
+// to a websocket connection to a backend of the host, whose subscription notifications are
+// passed through to the client, unless the subscription hub is set and the subscription
+// is of a type it shares between clients, while every other call is proxied by next as an http request
+// so that it is cached & metriced the same as calls made over http.
+func createWebsocketMiddleware(next http.HandlerFunc, proxies Proxies, hub *subscriptionHub, serviceLogger *logging.ServiceLogger) http.HandlerFunc {
+	upgrader := websocket.Upgrader{
+		// access control of the host applies to the calls made over the connection
+		CheckOrigin: func(r *http.Request) bool { return true },
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !websocket.IsWebSocketUpgrade(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// reject connections for hosts without backends before upgrading them
+		if _, _, found := currentProxies(proxies).ProxyForRequest(r); !found {
+			serviceLogger.Error().Str("host", r.Host).Msg("no matching proxy for websocket connection")
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			// the upgrader has already responded to the client
+			serviceLogger.Debug().Err(err).Msg("failed to upgrade websocket connection")
+			return
+		}
+
+		session := newWebsocketSession(conn, r, next, proxies, hub, serviceLogger)
+		session.serve()
+	}
+}
+
+// websocketSession proxies the calls made over the websocket connection of a client
+type websocketSession struct {
+	client *websocket.Conn
+	// serializes the writes to the client, which may come from
+	// the backend connection and the calls proxied over http at the same time
+	clientMu sync.Mutex
+	// upgrade request of the client, used for routing and as the template of the http requests for calls
+	upgradeReq *http.Request
+	next       http.Handler
+	proxies    Proxies
+	// websocket connection to the backend serving the subscriptions of the client,
+	// dialed when the first subscription call is made
+	backend   *websocket.Conn
+	backendMu sync.Mutex
+	// nil when subscriptions are not shared between clients
+	hub *subscriptionHub
+	// subscriptions of the client shared through the hub, by their id.
+	// only accessed by the goroutine reading the calls of the client
+	sharedSubscriptions map[string]*clientSubscription
+	// tracks calls being proxied over http so that the connection is closed once they complete
+	calls  sync.WaitGroup
+	ctx    context.Context
+	cancel context.CancelFunc
+	*logging.ServiceLogger
+}
+
+func newWebsocketSession(client *websocket.Conn, upgradeReq *http.Request, next http.Handler, proxies Proxies, hub *subscriptionHub, serviceLogger *logging.ServiceLogger) *websocketSession {
+	// the session outlives the upgrade request, so its context is not derived from it
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &websocketSession{
+		client:              client,
+		upgradeReq:          upgradeReq,
+		next:                next,
+		proxies:             proxies,
+		hub:                 hub,
+		sharedSubscriptions: make(map[string]*clientSubscription),
+		ctx:                 ctx,
+		cancel:              cancel,
+		ServiceLogger:       serviceLogger,
+	}
+}
+
+// serve proxies the calls of the client until either side closes its connection
+func (s *websocketSession) serve() {
+	defer s.close()
+
+	// the deadlines of the http server only apply to the upgrade request
+	s.client.SetReadDeadline(time.Time{})
+	s.client.SetWriteDeadline(time.Time{})
+
+	for {
+		messageType, message, err := s.client.ReadMessage()
+		if err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				s.Debug().Err(err).Msg("websocket client connection closed")
+			}
+			return
+		}
+
+		decodedReq, err := decode.DecodeEVMRPCRequest(message)
+		if err == nil && decode.MethodIsSubscription(decodedReq.Method) {
+			if s.serveSharedSubscriptionCall(decodedReq) {
+				continue
+			}
+			if err := s.forwardToBackend(messageType, message); err != nil {
+				s.Error().Err(err).Str("host", s.upgradeReq.Host).Msg("failed to forward subscription call to backend")
+				return
+			}
+			continue
+		}
+
+		s.calls.Add(1)
+		go func() {
+			defer s.calls.Done()
+			s.proxyCall(message)
+		}()
+	}
+}
+
+// serveSharedSubscriptionCall serves calls for subscriptions shared through the hub,
+// returning false for calls that are not for shared subscriptions
+func (s *websocketSession) serveSharedSubscriptionCall(decodedReq *decode.EVMRPCRequestEnvelope) bool {
+	if s.hub == nil {
+		return false
+	}
+
+	switch decodedReq.Method {
+	case "eth_subscribe":
+		if !isSharedSubscription(decodedReq.Params) {
+			return false
+		}
+
+		sub, err := s.hub.subscribe(s.ctx, s.upgradeReq, decodedReq.Params)
+		if err != nil {
+			s.Debug().Err(err).Str("host", s.upgradeReq.Host).Msg("failed to create shared subscription")
+
+			rpcError := &cachemdw.JsonRpcError{Code: subscriptionErrorCode, Message: err.Error()}
+			var upstreamErr *upstreamSubscriptionError
+			if errors.As(err, &upstreamErr) {
+				rpcError = upstreamErr.rpcError
+			}
+			s.writeResponse(decodedReq, nil, rpcError)
+			return true
+		}
+
+		s.sharedSubscriptions[sub.id] = sub
+		// the response is written before any notification of the subscription
+		s.writeResponse(decodedReq, sub.id, nil)
+		go s.passThroughNotifications(sub)
+	case "eth_unsubscribe":
+		if len(decodedReq.Params) == 0 {
+			return false
+		}
+		id, ok := decodedReq.Params[0].(string)
+		if !ok {
+			return false
+		}
+		sub, found := s.sharedSubscriptions[id]
+		if !found {
+			return false
+		}
+
+		delete(s.sharedSubscriptions, id)
+		s.hub.unsubscribe(sub)
+		s.writeResponse(decodedReq, true, nil)
+	default:
+		return false
+	}
+
+	return true
+}
+
+// passThroughNotifications writes the notifications of the shared subscription to the client
+// until it ends, closing the connection of the client if it ended without the client unsubscribing
+func (s *websocketSession) passThroughNotifications(sub *clientSubscription) {
+	for notification := range sub.notifications {
+		if err := s.writeToClient(websocket.TextMessage, notification); err != nil {
+			s.Debug().Err(err).Msg("failed to write subscription notification")
+		}
+	}
+
+	if sub.err != nil && s.ctx.Err() == nil {
+		s.Error().Err(sub.err).Str("host", s.upgradeReq.Host).Msg("shared subscription ended")
+		// closing the client connection stops serve, which closes the session
+		s.closeConnection(s.client, websocket.CloseGoingAway, sub.err.Error())
+	}
+}
+
+// writeResponse writes the JSON-RPC response to the call to the client
+func (s *websocketSession) writeResponse(decodedReq *decode.EVMRPCRequestEnvelope, result interface{}, rpcError *cachemdw.JsonRpcError) {
+	response := cachemdw.JsonRpcResponse{Version: "2.0", JsonRpcError: rpcError}
+
+	var err error
+	if response.ID, err = json.Marshal(decodedReq.ID); err == nil && rpcError == nil {
+		response.Result, err = json.Marshal(result)
+	}
+	if err != nil {
+		s.Error().Err(err).Msg("failed to encode response of websocket call")
+		return
+	}
+
+	body, err := response.Marshal()
+	if err != nil {
+		s.Error().Err(err).Msg("failed to encode response of websocket call")
+		return
+	}
+
+	if err := s.writeToClient(websocket.TextMessage, body); err != nil {
+		s.Debug().Err(err).Msg("failed to write response of websocket call")
+	}
+}
+
+// proxyCall proxies the call as an http request through the middleware of the service,
+// writing the response to the client
+func (s *websocketSession) proxyCall(message []byte) {
+	req, err := http.NewRequestWithContext(s.ctx, http.MethodPost, s.upgradeReq.URL.String(), bytes.NewReader(message))
+	if err != nil {
+		s.Error().Err(err).Msg("failed to create request for websocket call")
+		return
+	}
+
+	req.Host = s.upgradeReq.Host
+	req.RemoteAddr = s.upgradeReq.RemoteAddr
+	req.Header = s.upgradeReq.Header.Clone()
+	for _, header := range websocketHeadersNotForwarded {
+		req.Header.Del(header)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	response := newBufferedResponseWriter()
+	s.next.ServeHTTP(response, req)
+
+	// the client has gone away while the call was being proxied
+	if s.ctx.Err() != nil {
+		return
+	}
+
+	if err := s.writeToClient(websocket.TextMessage, response.body.Bytes()); err != nil {
+		s.Debug().Err(err).Msg("failed to write response of websocket call")
+	}
+}
+
+// forwardToBackend writes the message to the backend websocket connection,
+// dialing a backend of the host if the connection is not open yet
+func (s *websocketSession) forwardToBackend(messageType int, message []byte) error {
+	s.backendMu.Lock()
+	defer s.backendMu.Unlock()
+
+	if s.backend == nil {
+		backend, err := s.dialBackend()
+		if err != nil {
+			return err
+		}
+		s.backend = backend
+
+		go s.passThroughBackendMessages(backend)
+	}
+
+	return s.backend.WriteMessage(messageType, message)
+}
+
+// dialBackend opens a websocket connection to a backend of the host of the client
+func (s *websocketSession) dialBackend() (*websocket.Conn, error) {
+	_, metadata, found := currentProxies(s.proxies).ProxyForRequest(s.upgradeReq)
+	if !found {
+		return nil, errors.New("no backend available for websocket connection")
+	}
+
+	backendURL := websocketURL(metadata.BackendRoute)
+	backend, _, err := websocket.DefaultDialer.DialContext(s.ctx, backendURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	s.Debug().Str("host", s.upgradeReq.Host).Str("backend", metadata.BackendRoute.String()).Msg("opened backend websocket connection")
+
+	return backend, nil
+}
+
+// passThroughBackendMessages writes every message from the backend to the client,
+// such as the responses to subscription calls and subscription notifications,
+// closing the session once the backend closes its connection
+func (s *websocketSession) passThroughBackendMessages(backend *websocket.Conn) {
+	for {
+		messageType, message, err := backend.ReadMessage()
+		if err != nil {
+			if s.ctx.Err() == nil {
+				s.Error().Err(err).Str("host", s.upgradeReq.Host).Msg("backend websocket connection closed")
+				// closing the client connection stops serve, which closes the session
+				s.closeConnection(s.client, websocket.CloseGoingAway, "backend connection closed")
+			}
+			return
+		}
+
+		if err := s.writeToClient(messageType, message); err != nil {
+			s.Debug().Err(err).Msg("failed to pass through backend websocket message")
+			return
+		}
+	}
+}
+
+// writeToClient writes the message to the client
+func (s *websocketSession) writeToClient(messageType int, message []byte) error {
+	s.clientMu.Lock()
+	defer s.clientMu.Unlock()
+
+	return s.client.WriteMessage(messageType, message)
+}
+
+// close closes the connections to the client & the backend
+// once the calls being proxied over http complete
+func (s *websocketSession) close() {
+	s.cancel()
+	s.calls.Wait()
+
+	for _, sub := range s.sharedSubscriptions {
+		s.hub.unsubscribe(sub)
+	}
+
+	s.backendMu.Lock()
+	if s.backend != nil {
+		s.closeConnection(s.backend, websocket.CloseNormalClosure, "")
+	}
+	s.backendMu.Unlock()
+
+	s.closeConnection(s.client, websocket.CloseNormalClosure, "")
+}
+
+// closeConnection sends a close message before closing the connection
+func (s *websocketSession) closeConnection(conn *websocket.Conn, code int, text string) {
+	conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, text), time.Now().Add(websocketCloseTimeout))
+	conn.Close()
+}
+
+// websocketURL returns the websocket url of the backend with the http url
+func websocketURL(backendURL url.URL) url.URL {
+	switch strings.ToLower(backendURL.Scheme) {
+	case "https":
+		backendURL.Scheme = "wss"
+	case "http":
+		backendURL.Scheme = "ws"
+	}
+	return backendURL
+}
@@ -0,0 +1,195 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+// newWebsocketBackend creates a backend that responds to eth_subscribe
+// over websockets with a subscription id followed by a notification
+func newWebsocketBackend(t *testing.T) *httptest.Server {
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		for {
+			_, message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if !strings.Contains(string(message), "eth_subscribe") {
+				continue
+			}
+			conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":1,"result":"0xcafe"}`))
+			conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","method":"eth_subscription","params":{"subscription":"0xcafe","result":{"number":"0x1"}}}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestUnitTestWebsocketMiddleware_ProxiesCallsAndSubscriptions(t *testing.T) {
+	backend := newWebsocketBackend(t)
+	proxies, logger := newTestHostProxies(t, backend)
+
+	proxiedReqs := make(chan *http.Request, 2)
+	next := func(w http.ResponseWriter, r *http.Request) {
+		proxiedReqs <- r
+		w.Write([]byte(`{"jsonrpc":"2.0","id":2,"result":"0x10"}`))
+	}
+	server := httptest.NewServer(createWebsocketMiddleware(next, proxies, nil, logger))
+	t.Cleanup(server.Close)
+
+	// requests that are not websocket upgrades are passed to next
+	res, err := http.Post(server.URL, "application/json", strings.NewReader(`{}`))
+	require.NoError(t, err)
+	res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	<-proxiedReqs
+
+	// connections for hosts without backends are rejected
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+	_, res, err = websocket.DefaultDialer.Dial(wsURL, http.Header{"Host": []string{"unknown.kava.io"}})
+	require.ErrorIs(t, err, websocket.ErrBadHandshake)
+	require.Equal(t, http.StatusBadGateway, res.StatusCode)
+
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, http.Header{"Host": []string{"evm.kava.io"}})
+	require.NoError(t, err)
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	// calls are proxied over http by next
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":2,"method":"eth_blockNumber","params":[]}`)))
+	_, message, err := conn.ReadMessage()
+	require.NoError(t, err)
+	require.JSONEq(t, `{"jsonrpc":"2.0","id":2,"result":"0x10"}`, string(message))
+	proxiedReq := <-proxiedReqs
+	require.Equal(t, "evm.kava.io", proxiedReq.Host)
+	require.Equal(t, http.MethodPost, proxiedReq.Method)
+	require.Empty(t, proxiedReq.Header.Get("Upgrade"))
+
+	// subscriptions are forwarded to the backend, whose notifications are passed through
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":1,"method":"eth_subscribe","params":["newHeads"]}`)))
+	_, message, err = conn.ReadMessage()
+	require.NoError(t, err)
+	require.JSONEq(t, `{"jsonrpc":"2.0","id":1,"result":"0xcafe"}`, string(message))
+	_, message, err = conn.ReadMessage()
+	require.NoError(t, err)
+	require.Contains(t, string(message), `"method":"eth_subscription"`)
+}
+
+// subscriptionBackend is a backend recording the subscription calls made to it over websockets
+type subscriptionBackend struct {
+	*httptest.Server
+	// methods of the calls made to the backend
+	calls chan string
+	// connection to the backend, for sending notifications
+	conn   *websocket.Conn
+	connMu sync.Mutex
+}
+
+func newSubscriptionBackend(t *testing.T) *subscriptionBackend {
+	backend := &subscriptionBackend{calls: make(chan string, 10)}
+	upgrader := websocket.Upgrader{}
+	backend.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		backend.connMu.Lock()
+		backend.conn = conn
+		backend.connMu.Unlock()
+
+		for {
+			_, message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			var call struct{ Method string }
+			require.NoError(t, json.Unmarshal(message, &call))
+			backend.calls <- call.Method
+			if call.Method == "eth_subscribe" {
+				backend.write(`{"jsonrpc":"2.0","id":1,"result":"0xupstream"}`)
+			}
+		}
+	}))
+	t.Cleanup(backend.Close)
+	return backend
+}
+
+func (b *subscriptionBackend) write(message string) {
+	b.connMu.Lock()
+	defer b.connMu.Unlock()
+	b.conn.WriteMessage(websocket.TextMessage, []byte(message))
+}
+
+// dialTestWebsocket opens a websocket connection to the server for evm.kava.io
+func dialTestWebsocket(t *testing.T, server *httptest.Server) *websocket.Conn {
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, http.Header{"Host": []string{"evm.kava.io"}})
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	return conn
+}
+
+// websocketTestCall makes the call over the connection, returning the result of the response
+func websocketTestCall(t *testing.T, conn *websocket.Conn, call string) json.RawMessage {
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(call)))
+	_, message, err := conn.ReadMessage()
+	require.NoError(t, err)
+	var response struct{ Result json.RawMessage }
+	require.NoError(t, json.Unmarshal(message, &response))
+	return response.Result
+}
+
+func TestUnitTestWebsocketMiddleware_SharesSubscriptionsBetweenClients(t *testing.T) {
+	backend := newSubscriptionBackend(t)
+	proxies, logger := newTestHostProxies(t, backend.Server)
+	next := func(w http.ResponseWriter, r *http.Request) {}
+	server := httptest.NewServer(createWebsocketMiddleware(next, proxies, newSubscriptionHub(proxies, logger), logger))
+	t.Cleanup(server.Close)
+
+	subscribe := `{"jsonrpc":"2.0","id":1,"method":"eth_subscribe","params":["newHeads"]}`
+	first := dialTestWebsocket(t, server)
+	second := dialTestWebsocket(t, server)
+	firstID := websocketTestCall(t, first, subscribe)
+	secondID := websocketTestCall(t, second, subscribe)
+	require.NotEqual(t, firstID, secondID)
+
+	// a single upstream subscription is created
+	require.Equal(t, "eth_subscribe", <-backend.calls)
+	require.Empty(t, backend.calls)
+
+	// notifications are fanned out to every client with the id of its subscription
+	backend.write(`{"jsonrpc":"2.0","method":"eth_subscription","params":{"subscription":"0xupstream","result":{"number":"0x1"}}}`)
+	for conn, id := range map[*websocket.Conn]json.RawMessage{first: firstID, second: secondID} {
+		_, message, err := conn.ReadMessage()
+		require.NoError(t, err)
+		require.JSONEq(t, `{"jsonrpc":"2.0","method":"eth_subscription","params":{"subscription":`+string(id)+`,"result":{"number":"0x1"}}}`, string(message))
+	}
+
+	// the upstream subscription is torn down once the last client unsubscribes
+	require.JSONEq(t, "true", string(websocketTestCall(t, first, `{"jsonrpc":"2.0","id":2,"method":"eth_unsubscribe","params":[`+string(firstID)+`]}`)))
+	require.Empty(t, backend.calls)
+	require.JSONEq(t, "true", string(websocketTestCall(t, second, `{"jsonrpc":"2.0","id":2,"method":"eth_unsubscribe","params":[`+string(secondID)+`]}`)))
+	select {
+	case method := <-backend.calls:
+		require.Equal(t, "eth_unsubscribe", method)
+	case <-time.After(5 * time.Second):
+		t.Fatal("upstream subscription was not torn down")
+	}
+}